@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// abiParam is a function or event parameter as described in a JSON ABI
+type abiParam struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	InternalType string     `json:"internalType"`
+	Components   []abiParam `json:"components"`
+	Indexed      bool       `json:"indexed"`
+}
+
+// abiEntry is a function, event or error of a JSON ABI
+type abiEntry struct {
+	Type            string     `json:"type"`
+	Name            string     `json:"name"`
+	Inputs          []abiParam `json:"inputs"`
+	Outputs         []abiParam `json:"outputs"`
+	StateMutability string     `json:"stateMutability"`
+}
+
+// contractABI holds a JSON ABI together with the enum definitions found next to it
+type contractABI struct {
+	Entries []abiEntry
+	// Enum variant names keyed by both canonical (Contract.Enum) and short name
+	Enums map[string][]string
+}
+
+// Function to load an ABI from a plain ABI file or a Foundry/Hardhat artifact
+func loadABI(path string) (*contractABI, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ABI: %v", err)
+	}
+
+	contract := &contractABI{Enums: make(map[string][]string)}
+	if err := json.Unmarshal(data, &contract.Entries); err != nil {
+		// Not a bare ABI array, so look for the abi member of an artifact
+		var artifact struct {
+			ABI []abiEntry `json:"abi"`
+		}
+		if err := json.Unmarshal(data, &artifact); err != nil || artifact.ABI == nil {
+			return nil, fmt.Errorf("'%s' is neither an ABI nor a compiler artifact", path)
+		}
+		contract.Entries = artifact.ABI
+
+		// Artifacts that embed the AST also tell us the enum variant names
+		var tree interface{}
+		if err := json.Unmarshal(data, &tree); err == nil {
+			collectEnums(tree, contract.Enums)
+		}
+	}
+	return contract, nil
+}
+
+// Function to load enum definitions given as {"Name": ["VARIANT", ...]}
+func loadEnums(path string, enums map[string][]string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read enum definitions: %v", err)
+	}
+	var defs map[string][]string
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return fmt.Errorf("failed to parse enum definitions: %v", err)
+	}
+	for name, variants := range defs {
+		enums[name] = variants
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			enums[name[i+1:]] = variants
+		}
+	}
+	return nil
+}
+
+// Function to walk a solc AST and record every EnumDefinition node
+func collectEnums(node interface{}, enums map[string][]string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if n["nodeType"] == "EnumDefinition" {
+			var variants []string
+			members, _ := n["members"].([]interface{})
+			for _, member := range members {
+				if m, ok := member.(map[string]interface{}); ok {
+					name, _ := m["name"].(string)
+					variants = append(variants, name)
+				}
+			}
+			if name, ok := n["name"].(string); ok {
+				enums[name] = variants
+			}
+			if canonical, ok := n["canonicalName"].(string); ok {
+				enums[canonical] = variants
+			}
+		}
+		for _, child := range n {
+			collectEnums(child, enums)
+		}
+	case []interface{}:
+		for _, child := range n {
+			collectEnums(child, enums)
+		}
+	}
+}
+
+// Function to build the canonical type string of a parameter, expanding tuples
+func (p abiParam) canonicalType() string {
+	if strings.HasPrefix(p.Type, "tuple") {
+		types := make([]string, len(p.Components))
+		for i, component := range p.Components {
+			types[i] = component.canonicalType()
+		}
+		return "(" + strings.Join(types, ",") + ")" + strings.TrimPrefix(p.Type, "tuple")
+	}
+	return p.Type
+}
+
+// Function to build the declaration of a parameter including its name and tuple member names
+func (p abiParam) declaration() string {
+	typStr := p.Type
+	if strings.HasPrefix(p.Type, "tuple") {
+		members := make([]string, len(p.Components))
+		for i, component := range p.Components {
+			members[i] = component.declaration()
+		}
+		typStr = "(" + strings.Join(members, ",") + ")" + strings.TrimPrefix(p.Type, "tuple")
+	}
+	if p.Name != "" {
+		return typStr + " " + p.Name
+	}
+	return typStr
+}
+
+// Function to build the return type list of a function from its outputs
+func (e abiEntry) returnTypes() string {
+	declarations := make([]string, len(e.Outputs))
+	for i, output := range e.Outputs {
+		declarations[i] = output.declaration()
+	}
+	return "(" + strings.Join(declarations, ",") + ")"
+}
+
+// Function to get the enum name of a parameter, or "" if it is not an enum
+func (p abiParam) enumName() string {
+	if !strings.HasPrefix(p.InternalType, "enum ") {
+		return ""
+	}
+	return strings.TrimPrefix(p.InternalType, "enum ")
+}
+
+// Function to build the canonical signature of a function, event or error
+func (e abiEntry) signature() string {
+	types := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		types[i] = input.canonicalType()
+	}
+	return e.Name + "(" + strings.Join(types, ",") + ")"
+}
+
+// Function to find a function by its signature
+func (c *contractABI) findFunction(signature string) *abiEntry {
+	if canonical, err := canonicalSignature(signature); err == nil {
+		signature = canonical
+	}
+	for i, entry := range c.Entries {
+		if entry.Type == "function" && entry.signature() == signature {
+			return &c.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Function to find an event by its name or signature
+func (c *contractABI) findEvent(nameOrSignature string) *abiEntry {
+	if canonical, err := canonicalSignature(nameOrSignature); err == nil {
+		nameOrSignature = canonical
+	}
+	for i, entry := range c.Entries {
+		if entry.Type == "event" && (entry.Name == nameOrSignature || entry.signature() == nameOrSignature) {
+			return &c.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Function to look up the variant names of an enum parameter
+func (c *contractABI) enumVariants(p abiParam) []string {
+	name := p.enumName()
+	if name == "" || c == nil {
+		return nil
+	}
+	if variants, ok := c.Enums[name]; ok {
+		return variants
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return c.Enums[name[i+1:]]
+	}
+	return nil
+}
+
+// Function to translate an enum variant name (e.g. LIMIT or OrderType.LIMIT) into its index
+func resolveEnumArg(arg string, enumName string, variants []string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if _, ok := new(big.Int).SetString(arg, 10); ok {
+		return arg, nil
+	}
+
+	name := arg
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	for i, variant := range variants {
+		if strings.EqualFold(variant, name) {
+			return strconv.Itoa(i), nil
+		}
+	}
+	return "", fmt.Errorf("unknown %s variant '%s' (expected one of: %s)", enumName, arg, strings.Join(variants, ", "))
+}
+
+// Function to strip one pair of parentheses enclosing a whole type list like (uint256,address)
+func trimTypeList(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && matchingParen(s, 0) == len(s)-1 {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Function to split a comma-separated type list, keeping nested tuples together
+func splitTypeList(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// Function to find the index of the parenthesis closing the one at open, or -1
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Function to build an ABI type from a declaration, including tuples like (address,uint256)[]
+func newABIType(decl string) (abi.Type, error) {
+	typStr, _ := splitTypeName(decl)
+	if !strings.HasPrefix(typStr, "(") {
+		return abi.NewType(typStr, "", nil)
+	}
+
+	end := matchingParen(typStr, 0)
+	if end < 0 {
+		return abi.Type{}, fmt.Errorf("unbalanced parentheses in '%s'", typStr)
+	}
+	components, err := tupleComponents(typStr[1:end])
+	if err != nil {
+		return abi.Type{}, err
+	}
+	return abi.NewType("tuple"+typStr[end+1:], "", components)
+}
+
+// Function to describe the members of a tuple type list for go-ethereum
+func tupleComponents(list string) ([]abi.ArgumentMarshaling, error) {
+	var components []abi.ArgumentMarshaling
+	for i, part := range splitTypeList(list) {
+		typStr, name := splitTypeName(part)
+		if name == "" {
+			// go-ethereum needs a name for every tuple member
+			name = fmt.Sprintf("field%d", i)
+		}
+
+		component := abi.ArgumentMarshaling{Name: name, Type: typStr}
+		if strings.HasPrefix(typStr, "(") {
+			end := matchingParen(typStr, 0)
+			if end < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in '%s'", typStr)
+			}
+			nested, err := tupleComponents(typStr[1:end])
+			if err != nil {
+				return nil, err
+			}
+			component.Type = "tuple" + typStr[end+1:]
+			component.Components = nested
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// Pattern of the name of a function, event or error
+var signatureNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Aliases Solidity accepts for elementary types, which selectors are computed without
+var typeAliases = map[string]string{"uint": "uint256", "int": "int256", "byte": "bytes1"}
+
+// Function to bring a signature as typed by a user, like "transfer(address to, uint amount)",
+// into the canonical form its selector is the hash of: parameter names, data locations and
+// spaces removed and type aliases expanded. Invalid types are rejected rather than hashed.
+func canonicalSignature(signature string) (string, error) {
+	signature = strings.TrimSpace(signature)
+	open := strings.Index(signature, "(")
+	if open < 0 || matchingParen(signature, open) != len(signature)-1 {
+		return "", fmt.Errorf("invalid signature '%s' (expected name(type,...))", signature)
+	}
+	name := strings.TrimSpace(signature[:open])
+	if !signatureNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid function name '%s' in signature '%s'", name, signature)
+	}
+	types, err := canonicalTypeList(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return "", fmt.Errorf("invalid signature '%s': %v", signature, err)
+	}
+	return name + "(" + types + ")", nil
+}
+
+// Function to canonicalize a comma-separated list of parameter declarations
+func canonicalTypeList(list string) (string, error) {
+	if strings.TrimSpace(list) == "" {
+		return "", nil
+	}
+	var types []string
+	for _, decl := range splitTypeList(list) {
+		typ, err := canonicalParamType(decl)
+		if err != nil {
+			return "", err
+		}
+		types = append(types, typ)
+	}
+	return strings.Join(types, ","), nil
+}
+
+// Function to canonicalize a parameter declaration into its type, expanding tuples
+func canonicalParamType(decl string) (string, error) {
+	typStr, _ := splitTypeName(decl)
+	if typStr == "" {
+		return "", fmt.Errorf("empty parameter type")
+	}
+	typStr = strings.TrimPrefix(typStr, "tuple")
+	if strings.HasPrefix(typStr, "(") {
+		end := matchingParen(typStr, 0)
+		if end < 0 {
+			return "", fmt.Errorf("unbalanced parentheses in '%s'", typStr)
+		}
+		components, err := canonicalTypeList(typStr[1:end])
+		if err != nil {
+			return "", err
+		}
+		typStr = "(" + components + ")" + typStr[end+1:]
+	} else {
+		base := typStr
+		if i := strings.Index(base, "["); i >= 0 {
+			base = base[:i]
+		}
+		if alias, ok := typeAliases[base]; ok {
+			typStr, base = alias+typStr[len(base):], alias
+		}
+		// go-ethereum accepts sizes the ABI does not define, like uint7 or bytes33
+		if matches := fuzzTypePattern.FindStringSubmatch(base); matches != nil && matches[2] != "" {
+			size, _ := strconv.Atoi(matches[2])
+			if matches[1] == "bytes" && (size < 1 || size > 32) || matches[1] != "bytes" && (size < 8 || size > 256 || size%8 != 0) {
+				return "", fmt.Errorf("invalid type '%s'", strings.TrimSpace(decl))
+			}
+		}
+	}
+	if _, err := newABIType(typStr); err != nil {
+		return "", fmt.Errorf("invalid type '%s'", strings.TrimSpace(decl))
+	}
+	return typStr, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/blake2b"
+)
+
+// addressCodec converts between an alternative address encoding used by an EVM-compatible
+// chain and the 20-byte addresses the ABI works with
+type addressCodec interface {
+	// Function to check whether a string looks like an address of this encoding
+	matches(s string) bool
+	decode(s string) (common.Address, error)
+	encode(address common.Address) string
+}
+
+// Address encodings usable for arguments and for displaying results, keyed by name
+var addressCodecs = map[string]addressCodec{
+	"hex":      hexCodec{},
+	"tron":     tronCodec{},
+	"filecoin": filecoinCodec{},
+}
+
+// Function to parse an address given in any known encoding
+func parseAddress(s string) (common.Address, error) {
+	s = strings.TrimSpace(s)
+	if codec := findAddressCodec(s); codec != nil {
+		return codec.decode(s)
+	}
+	return common.Address{}, fmt.Errorf("invalid address '%s'", s)
+}
+
+// Function to find the encoding a string looks like an address of, or nil
+func findAddressCodec(s string) addressCodec {
+	for _, codec := range addressCodecs {
+		if codec.matches(s) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// Function to validate the name of an address encoding
+func validateAddressFormat(name string) error {
+	if _, ok := addressCodecs[name]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(addressCodecs))
+	for name := range addressCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("invalid address format '%s' (expected %s)", name, strings.Join(names, ", "))
+}
+
+// hexCodec is the usual 0x-prefixed, checksummed hex encoding
+type hexCodec struct{}
+
+func (hexCodec) matches(s string) bool {
+	return common.IsHexAddress(s)
+}
+
+func (hexCodec) decode(s string) (common.Address, error) {
+	return common.HexToAddress(s), nil
+}
+
+func (hexCodec) encode(address common.Address) string {
+	return address.Hex()
+}
+
+// tronCodec is Tron's base58check encoding of the address prefixed by 0x41, e.g. T...
+type tronCodec struct{}
+
+// Byte prefixed to addresses on the Tron main network
+const tronAddressPrefix = 0x41
+
+// Alphabet of the Bitcoin-style base58 encoding
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func (tronCodec) matches(s string) bool {
+	return len(s) == 34 && s[0] == 'T'
+}
+
+func (tronCodec) decode(s string) (common.Address, error) {
+	decoded, err := base58Decode(s)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid Tron address '%s': %v", s, err)
+	}
+	if len(decoded) != 25 || decoded[0] != tronAddressPrefix {
+		return common.Address{}, fmt.Errorf("invalid Tron address '%s'", s)
+	}
+	if !bytes.Equal(doubleSHA256(decoded[:21])[:4], decoded[21:]) {
+		return common.Address{}, fmt.Errorf("invalid Tron address '%s': checksum mismatch", s)
+	}
+	return common.BytesToAddress(decoded[1:21]), nil
+}
+
+func (tronCodec) encode(address common.Address) string {
+	payload := append([]byte{tronAddressPrefix}, address.Bytes()...)
+	return base58Encode(append(payload, doubleSHA256(payload)[:4]...))
+}
+
+// Function to hash data twice with SHA-256, as base58check checksums do
+func doubleSHA256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// Function to encode bytes in base58, keeping leading zero bytes as '1's
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	base, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// Function to decode a base58 string
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range s {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character '%c'", c)
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// filecoinCodec is Filecoin's f4 encoding of Ethereum addresses, managed by the
+// Ethereum Address Manager actor 10, e.g. f410f...
+type filecoinCodec struct{}
+
+// Protocol and actor id of delegated addresses managed by the Ethereum Address Manager
+const (
+	filecoinDelegatedProtocol = 4
+	filecoinEAMActor          = 10
+)
+
+// Lowercase, unpadded base32 used by Filecoin addresses and CIDv1 strings
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+func (filecoinCodec) matches(s string) bool {
+	return strings.HasPrefix(s, "f410f") || strings.HasPrefix(s, "t410f")
+}
+
+func (filecoinCodec) decode(s string) (common.Address, error) {
+	decoded, err := base32Lower.DecodeString(s[5:])
+	if err != nil || len(decoded) != 24 {
+		return common.Address{}, fmt.Errorf("invalid Filecoin f4 address '%s'", s)
+	}
+	if !bytes.Equal(filecoinChecksum(decoded[:20]), decoded[20:]) {
+		return common.Address{}, fmt.Errorf("invalid Filecoin f4 address '%s': checksum mismatch", s)
+	}
+	return common.BytesToAddress(decoded[:20]), nil
+}
+
+func (filecoinCodec) encode(address common.Address) string {
+	payload := address.Bytes()
+	return "f410f" + base32Lower.EncodeToString(append(payload, filecoinChecksum(payload)...))
+}
+
+// Function to compute the 4-byte blake2b checksum of a delegated address
+func filecoinChecksum(payload []byte) []byte {
+	hash, _ := blake2b.New(4, nil)
+	hash.Write([]byte{filecoinDelegatedProtocol, filecoinEAMActor})
+	hash.Write(payload)
+	return hash.Sum(nil)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Storage slot of the proxy admin defined by EIP-1967: keccak256("eip1967.proxy.admin") - 1
+const eip1967AdminSlot = "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103"
+
+// Prefix of the code of an account delegating to a contract under EIP-7702
+const delegationPrefix = "0xef0100"
+
+// Getters through which contracts commonly expose who controls them
+var ownershipGetters = []string{"owner()", "getOwner()", "pendingOwner()"}
+
+// controller is an account found controlling a contract, with how it was found
+type controller struct {
+	source  string
+	address common.Address
+}
+
+// Function to report who controls a contract by probing common ownership patterns, and
+// whether each controller is an EOA, a multisig or another contract
+func runAdmins(args []string) error {
+	fs := flag.NewFlagSet("admins", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	block := fs.String("block", "latest", "block to query")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler admins [flags] <address>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	contract, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+
+	controllers, err := findControllers(endpoint, contract, *block)
+	if err != nil {
+		return err
+	}
+	if len(controllers) == 0 {
+		fmt.Printf("No owner or admin found for %s\n", formatAddress(contract, opts))
+		return nil
+	}
+	for _, c := range controllers {
+		fmt.Printf("%-16s %s\n", c.source+":", formatAddress(c.address, opts))
+		for _, line := range describeAccount(endpoint, c.address, *block, opts, 1) {
+			fmt.Println(strings.Repeat(" ", 17) + line)
+		}
+	}
+	return nil
+}
+
+// Function to probe the ownership getters and the EIP-1967 admin slot of a contract,
+// skipping unset (zero) values
+func findControllers(rpcURL string, contract common.Address, block string) ([]controller, error) {
+	var code string
+	if err := callRPC(rpcURL, &code, "eth_getCode", contract.Hex(), block); err != nil {
+		return nil, fmt.Errorf("failed to get code of %s: %v", contract.Hex(), err)
+	}
+	if len(code) <= 2 {
+		return nil, fmt.Errorf("%s has no code, it is not a contract", contract.Hex())
+	}
+
+	var controllers []controller
+	for _, getter := range ownershipGetters {
+		if owner, ok := callAddressGetter(rpcURL, contract, getter, block); ok {
+			controllers = append(controllers, controller{source: getter, address: owner})
+		}
+	}
+	var slot string
+	if err := callRPC(rpcURL, &slot, "eth_getStorageAt", contract.Hex(), eip1967AdminSlot, block); err == nil {
+		if admin := common.HexToAddress(slot); admin != (common.Address{}) {
+			controllers = append(controllers, controller{source: "EIP-1967 admin", address: admin})
+		}
+	}
+	return controllers, nil
+}
+
+// Function to call a getter returning an address, reporting false when it is missing,
+// reverts or returns the zero address
+func callAddressGetter(rpcURL string, contract common.Address, getter, block string) (common.Address, bool) {
+	values, err := callForValues(rpcURL, contract.Hex(), "0x"+functionSelector(getter), block, "(address)")
+	if err != nil {
+		return common.Address{}, false
+	}
+	address, ok := values[0].(common.Address)
+	return address, ok && address != (common.Address{})
+}
+
+// Function to describe what kind of account controls a contract. Contracts that are owned
+// themselves, like a ProxyAdmin, are followed up to depth levels.
+func describeAccount(rpcURL string, account common.Address, block string, opts formatOptions, depth int) []string {
+	var code string
+	if err := callRPC(rpcURL, &code, "eth_getCode", account.Hex(), block); err != nil {
+		return []string{fmt.Sprintf("unknown account type: %v", err)}
+	}
+	switch {
+	case len(code) <= 2:
+		return []string{"EOA"}
+	case strings.HasPrefix(code, delegationPrefix) && len(code) == 48:
+		return []string{"EOA delegating to " + formatAddress(common.HexToAddress(code[8:]), opts) + " (EIP-7702)"}
+	}
+
+	if values, err := callForValues(rpcURL, account.Hex(), "0x"+functionSelector("getThreshold()"), block, "(uint256)"); err == nil {
+		threshold, _ := asBigInt(values[0])
+		ownerValues, err := callForValues(rpcURL, account.Hex(), "0x"+functionSelector("getOwners()"), block, "(address[])")
+		if err == nil {
+			owners := ownerValues[0].([]common.Address)
+			lines := []string{fmt.Sprintf("Safe multisig, %s of %d owners:", threshold, len(owners))}
+			for _, owner := range owners {
+				lines = append(lines, "  "+formatAddress(owner, opts))
+			}
+			return lines
+		}
+	}
+
+	lines := []string{"contract"}
+	if depth > 0 {
+		if owner, ok := callAddressGetter(rpcURL, account, "owner()", block); ok {
+			lines[0] = "contract owned by " + formatAddress(owner, opts)
+			for _, line := range describeAccount(rpcURL, owner, block, opts, depth-1) {
+				lines = append(lines, "  "+line)
+			}
+		}
+	}
+	return lines
+}
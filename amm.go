@@ -0,0 +1,267 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Balancer V2 Vault, deployed at the same address on every chain
+const balancerVault = "0xBA12222222228d8Ba445958a75a0704d566BF2C8"
+
+// Address Curve pools list for the native token
+const curveNativeToken = "0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE"
+
+// Most coins a Curve pool is probed for
+const maxCurveCoins = 8
+
+// poolToken is a token of a pool with the metadata its amounts are rendered with
+type poolToken struct {
+	address  common.Address
+	symbol   string
+	decimals int
+}
+
+// Function to run the curve subcommands: pool, listing the coins, balances and parameters
+// of a pool, and quote, pricing a swap with get_dy
+func runCurve(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler curve pool|quote [flags] <pool> [from to amount]")
+	}
+	fs := flag.NewFlagSet("curve "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	block := fs.String("block", "latest", "block to query")
+	fs.Parse(args[1:])
+	switch {
+	case args[0] == "pool" && fs.NArg() != 1:
+		return fmt.Errorf("usage: contract-curler curve pool [flags] <pool>")
+	case args[0] == "quote" && fs.NArg() != 4:
+		return fmt.Errorf("usage: contract-curler curve quote [flags] <pool> <from coin> <to coin> <amount>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	pool, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	blockParam, err := pinBlock(endpoint, *block)
+	if err != nil {
+		return err
+	}
+	coins, indexType := curveCoins(endpoint, pool, blockParam)
+	if len(coins) == 0 {
+		return fmt.Errorf("%s has no coins(), it is not a Curve pool", pool.Hex())
+	}
+
+	switch args[0] {
+	case "pool":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tcoin\tsymbol\tbalance\t")
+		for i, coin := range coins {
+			balance := "-"
+			data, err := encodeMethodCall("balances("+indexType+")", []string{strconv.Itoa(i)})
+			if err != nil {
+				return err
+			}
+			if values, err := callForValues(endpoint, pool.Hex(), data, blockParam, "(uint256)"); err == nil {
+				balance = groupThousands(formatFixed(values[0].(*big.Int), coin.decimals))
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i, formatAddress(coin.address, opts), coin.symbol, balance)
+		}
+		w.Flush()
+		if a, ok := callUintGetter(endpoint, pool.Hex(), "A()", nil, blockParam); ok {
+			fmt.Printf("\nAmplification: %s\n", a)
+		}
+		// Curve fees have 10 decimals, so 4000000 is 0.04%
+		if fee, ok := callUintGetter(endpoint, pool.Hex(), "fee()", nil, blockParam); ok {
+			fmt.Printf("Fee:           %s\n", formatPercent(fee, 10))
+		}
+		if price, ok := callUintGetter(endpoint, pool.Hex(), "get_virtual_price()", nil, blockParam); ok {
+			fmt.Printf("Virtual price: %s\n", formatFixed(price, wadDecimals))
+		}
+		return nil
+
+	case "quote":
+		from, err := curveCoinIndex(coins, fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		to, err := curveCoinIndex(coins, fs.Arg(2))
+		if err != nil {
+			return err
+		}
+		amount, err := parseFixed(fs.Arg(3), coins[from].decimals)
+		if err != nil {
+			return fmt.Errorf("invalid amount '%s': %v", fs.Arg(3), err)
+		}
+		// Stableswap pools index coins with int128, cryptoswap pools with uint256
+		var dy *big.Int
+		for _, typ := range []string{"int128", "uint256"} {
+			data, err := encodeMethodCall("get_dy("+typ+","+typ+",uint256)", []string{strconv.Itoa(from), strconv.Itoa(to), amount.String()})
+			if err != nil {
+				return err
+			}
+			if values, err := callForValues(endpoint, pool.Hex(), data, blockParam, "(uint256)"); err == nil {
+				dy = values[0].(*big.Int)
+				break
+			}
+		}
+		if dy == nil {
+			return fmt.Errorf("get_dy failed on %s", pool.Hex())
+		}
+		fmt.Printf("%s %s -> %s %s\n", fs.Arg(3), coins[from].symbol, groupThousands(formatFixed(dy, coins[to].decimals)), coins[to].symbol)
+		return nil
+	}
+	return fmt.Errorf("unknown curve subcommand '%s' (expected pool or quote)", args[0])
+}
+
+// Function to list the coins of a Curve pool, probing coins(i) until it reverts, and the
+// index type the pool takes: uint256 in newer pools, int128 in the oldest ones
+func curveCoins(rpcURL string, pool common.Address, block string) ([]poolToken, string) {
+	for _, indexType := range []string{"uint256", "int128"} {
+		var coins []poolToken
+		for i := 0; i < maxCurveCoins; i++ {
+			data, err := encodeMethodCall("coins("+indexType+")", []string{strconv.Itoa(i)})
+			if err != nil {
+				break
+			}
+			values, err := callForValues(rpcURL, pool.Hex(), data, block, "(address)")
+			if err != nil {
+				break
+			}
+			coins = append(coins, newPoolToken(rpcURL, values[0].(common.Address), block))
+		}
+		if len(coins) > 0 {
+			return coins, indexType
+		}
+	}
+	return nil, ""
+}
+
+// Function to find a coin of a pool given as its index, address or symbol
+func curveCoinIndex(coins []poolToken, coin string) (int, error) {
+	if i, err := strconv.Atoi(coin); err == nil && i >= 0 && i < len(coins) {
+		return i, nil
+	}
+	for i, candidate := range coins {
+		if strings.EqualFold(candidate.address.Hex(), coin) || strings.EqualFold(candidate.symbol, coin) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("'%s' is not a coin of the pool (give its index, address or symbol)", coin)
+}
+
+// Function to run the balancer subcommands: pool, listing the tokens, balances, weights and
+// fee of a Balancer V2 pool given by its id or address
+func runBalancer(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler balancer pool [flags] <pool id or address>")
+	}
+	fs := flag.NewFlagSet("balancer "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	vault := fs.String("vault", balancerVault, "address of the Balancer vault")
+	block := fs.String("block", "latest", "block to query")
+	fs.Parse(args[1:])
+	if args[0] != "pool" {
+		return fmt.Errorf("unknown balancer subcommand '%s' (expected pool)", args[0])
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler balancer pool [flags] <pool id or address>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	blockParam, err := pinBlock(endpoint, *block)
+	if err != nil {
+		return err
+	}
+	// Pool ids are 32 bytes starting with the pool address; pools know their own id
+	poolID := fs.Arg(0)
+	if id, err := hexutil.Decode(poolID); err != nil || len(id) != 32 {
+		pool, err := cfg.resolveAddress(poolID, *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		id, ok := callBytes32Getter(endpoint, pool, "getPoolId()")
+		if !ok {
+			return fmt.Errorf("%s has no getPoolId(), it is not a Balancer pool", pool.Hex())
+		}
+		poolID = id.Hex()
+	}
+	pool := common.HexToAddress(poolID[:42])
+
+	data, err := encodeMethodCall("getPoolTokens(bytes32)", []string{poolID})
+	if err != nil {
+		return err
+	}
+	values, err := callForValues(endpoint, *vault, data, blockParam, "(address[],uint256[],uint256)")
+	if err != nil {
+		return fmt.Errorf("getPoolTokens failed: %v", err)
+	}
+	tokens, balances := values[0].([]common.Address), values[1].([]*big.Int)
+	var weights []*big.Int
+	if data, err := encodeMethodCall("getNormalizedWeights()", nil); err == nil {
+		if values, err := callForValues(endpoint, pool.Hex(), data, blockParam, "(uint256[])"); err == nil {
+			weights = values[0].([]*big.Int)
+		}
+	}
+
+	fmt.Printf("Pool: %s\nId:   %s\n\n", formatAddress(pool, opts), poolID)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(weights) == len(tokens) {
+		fmt.Fprintln(w, "token\tsymbol\tbalance\tweight\t")
+	} else {
+		fmt.Fprintln(w, "token\tsymbol\tbalance\t")
+	}
+	for i, address := range tokens {
+		token := newPoolToken(endpoint, address, blockParam)
+		// Composable stable pools hold their own BPT, which is not a pool asset
+		symbol := token.symbol
+		if address == pool {
+			symbol += " (pool token)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t", formatAddress(address, opts), symbol, groupThousands(formatFixed(balances[i], token.decimals)))
+		if len(weights) == len(tokens) {
+			fmt.Fprintf(w, "%s\t", formatPercent(weights[i], wadDecimals))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	if fee, ok := callUintGetter(endpoint, pool.Hex(), "getSwapFeePercentage()", nil, blockParam); ok {
+		fmt.Printf("\nSwap fee: %s\n", formatPercent(fee, wadDecimals))
+	}
+	if lastChange, ok := values[2].(*big.Int); ok {
+		fmt.Printf("Last balance change: block %s\n", lastChange)
+	}
+	return nil
+}
+
+// Function to get the symbol and decimals of a pool token, the native token included
+func newPoolToken(rpcURL string, address common.Address, block string) poolToken {
+	if address == common.HexToAddress(curveNativeToken) {
+		return poolToken{address, "ETH", 18}
+	}
+	token := poolToken{address: address, symbol: "?"}
+	if metadata, ok := lookupToken(rpcURL, address, block); ok {
+		token.symbol, token.decimals = firstNonEmpty(metadata.Symbol, "?"), *metadata.Decimals
+	}
+	return token
+}
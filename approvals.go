@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Event emitted by ERC-20 tokens when an allowance is set. ERC-721 tokens emit an event
+// with the same signature but an indexed token id, so it has four topics.
+const approvalEvent = "Approval(address,address,uint256)"
+
+// tokenApproval is an outstanding allowance of an owner
+type tokenApproval struct {
+	token     common.Address
+	spender   common.Address
+	allowance *big.Int
+}
+
+// Function to list the spenders an owner has approved across tokens, with their live
+// allowances, optionally printing the calldata revoking them
+func runApprovals(args []string) error {
+	fs := flag.NewFlagSet("approvals", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployments")
+	tokens := fs.String("token", "", "comma-separated tokens to scan")
+	from := fs.String("from", "0", "first block of the event scan")
+	block := fs.String("block", "latest", "block to query, and last block of the event scan")
+	chunk := fs.Uint64("chunk", 50000, "number of blocks requested per eth_getLogs call")
+	revoke := fs.Bool("revoke", false, "print the approve(spender, 0) calldata revoking each allowance")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *tokens == "" {
+		return fmt.Errorf("usage: contract-curler approvals --token <tokens> [flags] <owner>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	owner, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	var tokenAddresses []string
+	for _, name := range strings.Split(*tokens, ",") {
+		token, err := cfg.resolveAddress(strings.TrimSpace(name), *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		tokenAddresses = append(tokenAddresses, token.Hex())
+	}
+
+	spenders, err := scanApprovals(endpoint, tokenAddresses, owner, *from, *block, *chunk)
+	if err != nil {
+		return err
+	}
+
+	// Events only say an allowance was set at some point, so the live value decides
+	var outstanding []tokenApproval
+	for _, token := range tokenAddresses {
+		for _, spender := range spenders[common.HexToAddress(token)] {
+			data, err := encodeMethodCall("allowance(address,address)", []string{owner.Hex(), spender.Hex()})
+			if err != nil {
+				return err
+			}
+			values, err := callForValues(endpoint, token, data, *block, "(uint256)")
+			if err != nil {
+				return fmt.Errorf("failed to read allowance of %s on %s: %v", spender.Hex(), token, err)
+			}
+			if allowance, _ := asBigInt(values[0]); allowance != nil && allowance.Sign() > 0 {
+				outstanding = append(outstanding, tokenApproval{token: common.HexToAddress(token), spender: spender, allowance: allowance})
+			}
+		}
+	}
+	if len(outstanding) == 0 {
+		fmt.Printf("No outstanding approvals for %s\n", formatAddress(owner, opts))
+		return nil
+	}
+
+	for _, approval := range outstanding {
+		token, isToken := lookupToken(endpoint, approval.token, *block)
+		symbol := token.Symbol
+		amount := "unlimited"
+		if approval.allowance.Cmp(math.MaxBig256) != 0 {
+			amount = approval.allowance.String()
+			if isToken {
+				amount = formatFixed(approval.allowance, *token.Decimals)
+			}
+		}
+		fmt.Printf("%s %s: %s %s\n", formatAddress(approval.token, opts), symbol, formatAddress(approval.spender, opts), amount)
+		if *revoke {
+			data, err := encodeMethodCall("approve(address,uint256)", []string{approval.spender.Hex(), "0"})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  revoke: to %s data %s\n", approval.token.Hex(), data)
+		}
+	}
+	return nil
+}
+
+// Function to collect the spenders an owner approved on each token, from the Approval
+// events of the block range
+func scanApprovals(rpcURL string, tokens []string, owner common.Address, from, to string, chunk uint64) (map[common.Address][]common.Address, error) {
+	if chunk == 0 {
+		return nil, fmt.Errorf("chunk size must be greater than zero")
+	}
+	fromBlock, err := parseBlockNumber(from)
+	if err != nil {
+		return nil, err
+	}
+	var toBlock uint64
+	if to == "latest" {
+		if toBlock, err = latestBlockNumber(rpcURL); err != nil {
+			return nil, fmt.Errorf("failed to fetch latest block: %v", err)
+		}
+	} else if toBlock, err = parseBlockNumber(to); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[common.Address]map[common.Address]bool)
+	topics := []string{eventTopic(approvalEvent), owner.Hash().Hex()}
+	for start := fromBlock; start <= toBlock; start += chunk {
+		end := start + chunk - 1
+		if end > toBlock {
+			end = toBlock
+		}
+		logs, err := getLogs(rpcURL, tokens, topics, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch approvals for blocks %d-%d: %v", start, end, err)
+		}
+		fmt.Fprintf(os.Stderr, "Scanned blocks %d-%d: %d approvals\n", start, end, len(logs))
+		for _, entry := range logs {
+			if entry.Removed || len(entry.Topics) != 3 {
+				continue
+			}
+			token := common.HexToAddress(entry.Address)
+			if seen[token] == nil {
+				seen[token] = make(map[common.Address]bool)
+			}
+			seen[token][common.HexToAddress(entry.Topics[2])] = true
+		}
+	}
+
+	spenders := make(map[common.Address][]common.Address)
+	for token, set := range seen {
+		for spender := range set {
+			spenders[token] = append(spenders[token], spender)
+		}
+		sort.Slice(spenders[token], func(i, j int) bool {
+			return bytes.Compare(spenders[token][i][:], spenders[token][j][:]) < 0
+		})
+	}
+	return spenders, nil
+}
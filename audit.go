@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contract-curler/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// AuditConfig selects where the audit log of the transactions sent is kept: an append-only
+// file, audit.log in the config directory by default, and optionally a syslog server the
+// entries are shipped to as well, as udp://host[:port] or tcp://host[:port]
+type AuditConfig struct {
+	Log    string `json:"log"`
+	Syslog string `json:"syslog"`
+}
+
+// auditEntry records a transaction signed or broadcast, with who sent it from where
+type auditEntry struct {
+	Time    string `json:"time"`
+	Tool    string `json:"tool"`
+	User    string `json:"user"`
+	Host    string `json:"host"`
+	Command string `json:"command"`
+	// broadcast before a raw transaction is sent, request before another write method is
+	// sent, failed when the endpoint refused either, and wallet for transactions a
+	// WalletConnect wallet signed and sent itself
+	Event    string  `json:"event"`
+	Method   string  `json:"method,omitempty"`
+	Endpoint string  `json:"endpoint"`
+	ChainID  uint64  `json:"chainId"`
+	Signer   string  `json:"signer"`
+	Nonce    *uint64 `json:"nonce,omitempty"`
+	To       string  `json:"to,omitempty"`
+	Hash     string  `json:"hash,omitempty"`
+	RawTx    string  `json:"rawTx,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Syslog priority of the entries: facility local0, severity notice
+const auditSyslogPriority = 16*8 + 5
+
+// Serializes the appends of the concurrent requests of the bridge
+var auditMu sync.Mutex
+
+// auditedRequest is a request of a write method recorded in the audit log before it is sent
+type auditedRequest struct {
+	id    string
+	entry auditEntry
+}
+
+// Function to record the requests of write methods in the audit log before they reach an
+// endpoint, whichever command or bridge request sends them, and those the endpoint refuses.
+// A request that cannot be recorded is not sent.
+func auditMiddleware(next rpc.Transport) rpc.Transport {
+	return rpc.TransportFunc(func(call *rpc.Call) (io.ReadCloser, error) {
+		audited := auditedRequests(call)
+		if len(audited) == 0 {
+			return next.RoundTrip(call)
+		}
+		for _, request := range audited {
+			if err := writeAudit(request.entry); err != nil {
+				return nil, fmt.Errorf("%v, the request was not sent", err)
+			}
+		}
+		body, err := next.RoundTrip(call)
+		if err != nil {
+			auditFailures(audited, nil, err)
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			auditFailures(audited, nil, err)
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+		auditFailures(audited, responseErrors(data), nil)
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// Function to describe the requests of write methods of a call, single or batched. Raw
+// transactions are decoded to record their signer, nonce and hash.
+func auditedRequests(call *rpc.Call) []auditedRequest {
+	type request struct {
+		Id     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	var batch []request
+	if json.Unmarshal(call.Payload, &batch) != nil {
+		var single request
+		if json.Unmarshal(call.Payload, &single) != nil {
+			return nil
+		}
+		batch = []request{single}
+	}
+	var audited []auditedRequest
+	for _, r := range batch {
+		if !writeMethods[r.Method] {
+			continue
+		}
+		entry := auditEntry{Event: "request", Method: r.Method, Endpoint: call.Endpoint}
+		var raw string
+		if strings.HasPrefix(r.Method, "eth_sendRawTransaction") && len(r.Params) > 0 && json.Unmarshal(r.Params[0], &raw) == nil {
+			var tx types.Transaction
+			if data, err := hexutil.Decode(raw); err == nil && tx.UnmarshalBinary(data) == nil {
+				entry = transactionAuditEntry("broadcast", call.Endpoint, &tx)
+			}
+			entry.Event, entry.Method, entry.RawTx = "broadcast", r.Method, raw
+		}
+		audited = append(audited, auditedRequest{id: string(r.Id), entry: entry})
+	}
+	return audited
+}
+
+// Function to get the error messages of a response by request id
+func responseErrors(data []byte) map[string]string {
+	type response struct {
+		Id    json.RawMessage `json:"id"`
+		Error *JsonRpcError   `json:"error"`
+	}
+	var batch []response
+	if json.Unmarshal(data, &batch) != nil {
+		var single response
+		if json.Unmarshal(data, &single) != nil {
+			return nil
+		}
+		batch = []response{single}
+	}
+	refused := make(map[string]string)
+	for _, r := range batch {
+		if r.Error != nil {
+			refused[string(r.Id)] = r.Error.Error()
+		}
+	}
+	return refused
+}
+
+// Function to record the audited requests an endpoint refused, all of them when the call
+// failed with sendErr
+func auditFailures(audited []auditedRequest, refused map[string]string, sendErr error) {
+	for _, request := range audited {
+		message, ok := refused[request.id]
+		if sendErr != nil {
+			message, ok = sendErr.Error(), true
+		}
+		if !ok {
+			continue
+		}
+		entry := request.entry
+		entry.Event, entry.Error, entry.RawTx = "failed", message, ""
+		if err := writeAudit(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// Function to record a transaction a wallet signed and broadcast, known only by its hash
+func auditWalletTransaction(rpcURL string, chainID uint64, from common.Address, to *common.Address, nonce *uint64, hash string) {
+	entry := auditEntry{Event: "wallet", Endpoint: rpcURL, ChainID: chainID, Signer: from.Hex(), Nonce: nonce, Hash: hash}
+	if to != nil {
+		entry.To = to.Hex()
+	}
+	if err := writeAudit(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// Function to describe a signed transaction in an audit entry
+func transactionAuditEntry(event, rpcURL string, tx *types.Transaction) auditEntry {
+	nonce := tx.Nonce()
+	entry := auditEntry{Event: event, Endpoint: rpcURL, ChainID: tx.ChainId().Uint64(), Nonce: &nonce, Hash: tx.Hash().Hex()}
+	if signer, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		entry.Signer = signer.Hex()
+	}
+	if tx.To() != nil {
+		entry.To = tx.To().Hex()
+	}
+	return entry
+}
+
+// Function to append an entry to the audit log, one JSON object per line, and ship it to
+// the syslog server, if any. The file is authoritative: only its failures are errors.
+func writeAudit(entry auditEntry) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	entry.Tool = "contract-curler " + toolVersion()
+	entry.Host, _ = os.Hostname()
+	entry.User = os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		entry.User = current.Username
+	}
+	entry.Command = strings.Join(os.Args, " ")
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+
+	path := firstNonEmpty(cfg.Audit.Log, filepath.Join(configDir(), "audit.log"))
+	if err := appendAuditLog(path, data); err != nil {
+		return err
+	}
+	if cfg.Audit.Syslog != "" {
+		if err := shipSyslog(cfg.Audit.Syslog, entry.Host, data); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to ship audit entry to syslog: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// Function to append a line to the audit log file, never rewriting what it holds
+func appendAuditLog(path string, data []byte) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
+
+// Function to send a message to a syslog server in the RFC 5424 format, as a datagram over
+// UDP or with octet-counting framing over TCP (RFC 6587)
+func shipSyslog(rawURL, host string, message []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+		return fmt.Errorf("invalid syslog server '%s' (expected udp://host[:port] or tcp://host[:port])", rawURL)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "514")
+	}
+	line := fmt.Sprintf("<%d>1 %s %s contract-curler %d audit - %s", auditSyslogPriority,
+		time.Now().UTC().Format(time.RFC3339Nano), firstNonEmpty(host, "-"), os.Getpid(), message)
+
+	conn, err := net.DialTimeout(u.Scheme, address, sinkDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(sinkDialTimeout))
+	if u.Scheme == "tcp" {
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	_, err = conn.Write([]byte(line))
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CONTRACT_CURLER_HOME", home)
+	// The endpoint refuses the second transaction and answers everything else
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var request struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.Unmarshal(body, &request)
+		if request.Method == "eth_sendRawTransaction" {
+			sent++
+		}
+		if request.Method == "eth_sendRawTransaction" && sent == 2 {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(request.Id) + `,"error":{"code":-32000,"message":"nonce too low"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(request.Id) + `,"result":"0x1"}`))
+	}))
+	defer server.Close()
+
+	key, err := crypto.HexToECDSA("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+	var hashes []string
+	for _, nonce := range []uint64{5, 7} {
+		tx, err := types.SignTx(newTransaction(1, nonce, &to, big.NewInt(1), 21000, big.NewInt(1), big.NewInt(2), nil, nil), types.LatestSignerForChainID(big.NewInt(1)), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, tx.Hash().Hex())
+		raw, _ := tx.MarshalBinary()
+		// Raw transactions are recorded whichever path sends them, here the rpc command's
+		var result string
+		err = callRPC(server.URL, &result, "eth_sendRawTransaction", hexutil.Encode(raw))
+		if nonce == 7 && (err == nil || !strings.Contains(err.Error(), "nonce too low")) {
+			t.Errorf("refused transaction returned %v", err)
+		}
+	}
+	var result string
+	if err := callRPC(server.URL, &result, "eth_sendTransaction", map[string]string{"to": to.Hex()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := callRPC(server.URL, &result, "eth_chainId"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(home, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Endpoint != server.URL {
+			t.Errorf("entry for endpoint %s", entry.Endpoint)
+		}
+		nonce := "-"
+		if entry.Nonce != nil {
+			nonce = hexutil.EncodeUint64(*entry.Nonce)
+		}
+		got = append(got, strings.Join([]string{entry.Event, entry.Method, entry.Signer, nonce, entry.Hash, entry.Error}, " "))
+	}
+	signer := "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	want := []string{
+		"broadcast eth_sendRawTransaction " + signer + " 0x5 " + hashes[0] + " ",
+		"broadcast eth_sendRawTransaction " + signer + " 0x7 " + hashes[1] + " ",
+		"failed eth_sendRawTransaction " + signer + " 0x7 " + hashes[1] + " rpc error -32000: nonce too low",
+		"request eth_sendTransaction  -  ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("audit log holds\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
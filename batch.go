@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// batchCall is a call packed inside a batching wrapper. Target is nil for calls a contract
+// makes to itself, like the entries of a router's multicall.
+type batchCall struct {
+	Target       *common.Address
+	Value        *big.Int
+	Delegatecall bool
+	Data         []byte
+}
+
+// Wrappers whose arguments are other calls, keyed by signature
+var batchWrappers = map[string]func(values []interface{}) ([]batchCall, error){
+	// Gnosis Safe MultiSend packs its calls as operation, to, value, data length and data
+	"multiSend(bytes)": func(values []interface{}) ([]batchCall, error) {
+		return unpackMultiSend(values[0].([]byte))
+	},
+	"multicall(bytes[])":         selfCalls(0),
+	"multicall(uint256,bytes[])": selfCalls(1),
+	"multicall(bytes32,bytes[])": selfCalls(1),
+	// Multicall3 and its predecessors take (target, ..., callData) tuples
+	"aggregate((address,bytes)[])":                    tupleCalls(0, 0, -1, 1),
+	"tryAggregate(bool,(address,bytes)[])":            tupleCalls(1, 0, -1, 1),
+	"blockAndAggregate((address,bytes)[])":            tupleCalls(0, 0, -1, 1),
+	"tryBlockAndAggregate(bool,(address,bytes)[])":    tupleCalls(1, 0, -1, 1),
+	"aggregate3((address,bool,bytes)[])":              tupleCalls(0, 0, -1, 2),
+	"aggregate3Value((address,bool,uint256,bytes)[])": tupleCalls(0, 0, 2, 3),
+	// A Safe transaction is a single call, often to MultiSend
+	"execTransaction(address,uint256,bytes,uint8,uint256,uint256,uint256,address,address,bytes)": func(values []interface{}) ([]batchCall, error) {
+		target := values[0].(common.Address)
+		return []batchCall{{Target: &target, Value: values[1].(*big.Int), Data: values[2].([]byte), Delegatecall: values[3].(uint8) == 1}}, nil
+	},
+}
+
+// Function to build the unpacker of multicalls whose calls are made on the contract itself
+func selfCalls(argument int) func(values []interface{}) ([]batchCall, error) {
+	return func(values []interface{}) ([]batchCall, error) {
+		var calls []batchCall
+		for _, data := range values[argument].([][]byte) {
+			calls = append(calls, batchCall{Data: data})
+		}
+		return calls, nil
+	}
+}
+
+// Function to build the unpacker of an array of call tuples, given the positions of the
+// target, value (-1 when absent) and calldata in each tuple
+func tupleCalls(argument, target, value, data int) func(values []interface{}) ([]batchCall, error) {
+	return func(values []interface{}) ([]batchCall, error) {
+		tuples := reflect.ValueOf(values[argument])
+		var calls []batchCall
+		for i := 0; i < tuples.Len(); i++ {
+			tuple := tuples.Index(i)
+			address := tuple.Field(target).Interface().(common.Address)
+			call := batchCall{Target: &address, Data: tuple.Field(data).Interface().([]byte)}
+			if value >= 0 {
+				call.Value = tuple.Field(value).Interface().(*big.Int)
+			}
+			calls = append(calls, call)
+		}
+		return calls, nil
+	}
+}
+
+// Function to split the packed transactions of a MultiSend call
+func unpackMultiSend(packed []byte) ([]batchCall, error) {
+	var calls []batchCall
+	for offset := 0; offset < len(packed); {
+		// operation (1) + to (20) + value (32) + data length (32)
+		if len(packed)-offset < 85 {
+			return nil, fmt.Errorf("truncated MultiSend transaction at byte %d", offset)
+		}
+		target := common.BytesToAddress(packed[offset+1 : offset+21])
+		call := batchCall{
+			Target:       &target,
+			Value:        new(big.Int).SetBytes(packed[offset+21 : offset+53]),
+			Delegatecall: packed[offset] == 1,
+		}
+		length := new(big.Int).SetBytes(packed[offset+53 : offset+85])
+		offset += 85
+		if !length.IsUint64() || length.Uint64() > uint64(len(packed)-offset) {
+			return nil, fmt.Errorf("MultiSend transaction %d claims %s bytes of data, only %d left", len(calls)+1, length, len(packed)-offset)
+		}
+		call.Data = packed[offset : offset+int(length.Uint64())]
+		offset += int(length.Uint64())
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// Function to unpack the calls of a batching wrapper, reporting false when the calldata
+// is not a known wrapper
+func unwrapBatch(input []byte) (string, []batchCall, bool) {
+	if len(input) < 4 {
+		return "", nil, false
+	}
+	selector := hex.EncodeToString(input[:4])
+	for signature, unpack := range batchWrappers {
+		if functionSelector(signature) != selector {
+			continue
+		}
+		entry, err := entryFromSignature(signature)
+		if err != nil {
+			return "", nil, false
+		}
+		values, err := entry.decodeInputs(input[4:])
+		if err != nil {
+			return "", nil, false
+		}
+		calls, err := unpack(values)
+		if err != nil {
+			return "", nil, false
+		}
+		return signature, calls, true
+	}
+	return "", nil, false
+}
+
+// Function to describe calldata on as many lines as needed, expanding the calls packed in
+// MultiSend, multicall and similar wrappers recursively
+func (d *callDecoder) describeNested(input []byte, opts formatOptions, indent string) []string {
+	signature, calls, ok := unwrapBatch(input)
+	if !ok {
+		return []string{indent + d.describe(input, opts)}
+	}
+	lines := []string{fmt.Sprintf("%s%s with %s:", indent, signature[:strings.Index(signature, "(")], pluralize(len(calls), "call"))}
+	for i, call := range calls {
+		line := fmt.Sprintf("%s  %d.", indent, i+1)
+		if call.Delegatecall {
+			line += " DELEGATECALL"
+		}
+		if call.Target != nil {
+			line += " " + formatAddress(*call.Target, opts)
+		} else {
+			line += " (self)"
+		}
+		if call.Value != nil && call.Value.Sign() > 0 {
+			line += fmt.Sprintf(" sending %s ETH", formatFixed(call.Value, 18))
+		}
+		lines = append(lines, line)
+		if len(call.Data) == 0 {
+			if call.Value != nil && call.Value.Sign() > 0 {
+				lines = append(lines, indent+"     (plain transfer)")
+			} else {
+				lines = append(lines, indent+"     (empty call)")
+			}
+			continue
+		}
+		lines = append(lines, d.describeNested(call.Data, opts, indent+"     ")...)
+	}
+	return lines
+}
+
+// Function to decode calldata, given directly or as the input of a transaction, expanding
+// batched calls
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL used to fetch transactions (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	abiPaths := fs.String("abi", "", "comma-separated ABI files used to decode calls, in addition to the configured contracts")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler decode [flags] <calldata | tx hash>")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	decoder, err := configDecoder(cfg, *abiPaths)
+	if err != nil {
+		return err
+	}
+
+	arg := strings.TrimSpace(fs.Arg(0))
+	input, err := hexutil.Decode(arg)
+	if err != nil {
+		return fmt.Errorf("invalid calldata: %v", err)
+	}
+	if len(input) == 32 {
+		var tx struct {
+			To    string `json:"to"`
+			Input string `json:"input"`
+		}
+		if err := callRPC(firstNonEmpty(*rpcURL, chainRPC(*chain)), &tx, "eth_getTransactionByHash", arg); err != nil {
+			return fmt.Errorf("failed to fetch transaction: %v", err)
+		}
+		if tx.Input == "" {
+			return fmt.Errorf("transaction %s not found", arg)
+		}
+		fmt.Println("To: " + formatAddress(common.HexToAddress(tx.To), displayOptions(cfg)))
+		input = hexutil.MustDecode(tx.Input)
+	}
+	for _, line := range decoder.describeNested(input, displayOptions(cfg), "") {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Function to render a count with its noun, e.g. 1 call or 3 calls
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
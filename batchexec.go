@@ -0,0 +1,576 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Multicall3, deployed at the same address on most chains
+const multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// Calldata of Multicall3's getBlockNumber()
+var getBlockNumberSelector = crypto.Keccak256([]byte("getBlockNumber()"))[:4]
+
+// Calldata packed into one aggregate3 call at most. Larger batches are split, and a call
+// larger than this on its own is sent directly.
+const maxMulticallCalldata = 96 * 1024
+
+// Requests sent in one JSON-RPC batch at most, the limit of common providers
+const maxRPCBatch = 100
+
+// callRequest is an eth_call of a batch
+type callRequest struct {
+	To   common.Address
+	Data []byte
+	// Sender of the call, if any. Calls aggregated through Multicall3 are sent by it instead.
+	From *common.Address
+}
+
+// Function to build the eth_call call object of a request
+func (r callRequest) callObject() map[string]interface{} {
+	call := map[string]interface{}{"to": r.To.Hex(), "data": hexutil.Encode(r.Data)}
+	if r.From != nil {
+		call["from"] = r.From.Hex()
+	}
+	return call
+}
+
+// callResult is the outcome of a call of a batch: its return data, or why it failed.
+// Reverted calls keep their revert data in Output.
+type callResult struct {
+	Output []byte
+	Err    error
+}
+
+// Function to run the calls of a pipeline file whose steps do not depend on each other all
+// at once, choosing how to batch them
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL, overriding the batch file")
+	chain := fs.String("chain", "", "chain name or id, overriding the batch file")
+	block := fs.String("block", "", "block to run all calls at, overriding the batch file")
+	strategy := fs.String("strategy", "auto", "how calls are sent: auto, multicall (Multicall3 aggregate3), rpc-batch (JSON-RPC batches) or parallel")
+	concurrency := fs.Int("concurrency", 8, "calls in flight at once with the parallel strategy and gas estimation")
+	estimateGas := fs.Bool("gas", false, "also estimate the gas of each call sent as a transaction, to spot expensive calls")
+	scale := fs.String("scale", "", "render integers as fixed-point: a scale for all (ray, wad, e8, decimals or 1eN) or name=scale pairs, e.g. liquidityRate=ray")
+	outFile := addOutputFlags(fs)
+	noColor := fs.Bool("no-color", false, "never color the output, even on a terminal")
+	fs.Parse(args)
+	if *noColor {
+		colorEnabled = false
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler batch [flags] <file>")
+	}
+	switch *strategy {
+	case "auto", "multicall", "rpc-batch", "parallel":
+	default:
+		return fmt.Errorf("invalid strategy '%s' (expected auto, multicall, rpc-batch or parallel)", *strategy)
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	if *outFile.path != "" {
+		if _, err := outFile.outputFormat(); err != nil {
+			return err
+		}
+	}
+
+	batch, err := loadPipeline(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	batch.Chain = firstNonEmpty(*chain, batch.Chain)
+	if *chain != "" && *rpcURL == "" {
+		batch.RpcURL = ""
+	}
+	batch.RpcURL = firstNonEmpty(*rpcURL, batch.RpcURL, chainRPC(batch.Chain))
+	batch.Block = firstNonEmpty(*block, batch.Block, "latest")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, batch.RpcURL)
+	if opts.Scales, err = parseScales(*scale, cfg); err != nil {
+		return err
+	}
+	blockParam, err := pinBlock(batch.RpcURL, batch.Block)
+	if err != nil {
+		return err
+	}
+
+	calls, err := prepareBatch(batch, blockParam)
+	if err != nil {
+		return err
+	}
+	names, returns, requests := calls.names, calls.returns, calls.requests
+
+	results, used, err := executeCalls(batch.RpcURL, blockParam, requests, *strategy, *concurrency)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Ran %s at block %s %s\n", pluralize(len(requests), "call"), blockParam, used)
+	var gas []uint64
+	var gasErrs []error
+	if *estimateGas {
+		gas, gasErrs = estimateCallGas(batch.RpcURL, blockParam, requests, *concurrency)
+	}
+
+	var records []resultRecord
+	failed := 0
+	for i, result := range results {
+		step := batch.Steps[i]
+		fmt.Printf("\n[%s] %s on %s\n", names[i], step.Sig, formatAddress(requests[i].To, opts))
+		if result.Err != nil {
+			failed++
+			fmt.Println("  " + colorize(colorRed, "failed") + ": " + result.Err.Error())
+			continue
+		}
+		output := hexutil.Encode(result.Output)
+		values, err := decodeReturnValues(output, returns[i])
+		if err != nil {
+			failed++
+			fmt.Println("  " + colorize(colorRed, "failed") + ": " + err.Error())
+			continue
+		}
+		returnTypes := splitTypeList(trimTypeList(returns[i]))
+		stepOpts := opts
+		stepOpts.Names = nil
+		for _, returnType := range returnTypes {
+			_, outputName := splitTypeName(returnType)
+			stepOpts.Names = append(stepOpts.Names, outputName)
+		}
+		formatted := formatReturnValues(values, returnTypes, stepOpts)
+		printReturnValues(formatted, returnTypes, "  ")
+		result := newPluginResult(requests[i].To.Hex(), step.Sig, blockParam, values, returnTypes, formatted, stepOpts.Names)
+		record := outFile.record(names[i], result, output)
+		if gas != nil {
+			if gasErrs[i] != nil {
+				fmt.Printf("  gas: unavailable (%v)\n", gasErrs[i])
+			} else {
+				fmt.Printf("  gas: %s\n", groupThousands(strconv.FormatUint(gas[i], 10)))
+				record.Gas = gas[i]
+			}
+		}
+		records = append(records, record)
+	}
+	if gas != nil {
+		printGasSummary(names, gas, gasErrs)
+	}
+	if err := outFile.write(records); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %s failed", failed, pluralize(len(requests), "call"))
+	}
+	return nil
+}
+
+// batchCalls are the encoded calls of a batch file, with the names and return types of its steps
+type batchCalls struct {
+	names    []string
+	returns  []string
+	requests []callRequest
+}
+
+// Function to encode the calls of a batch file, whose steps must not use each other's
+// results, resolving their arguments at a block
+func prepareBatch(batch *pipelineFile, blockParam string) (*batchCalls, error) {
+	decoder, err := newCallDecoder(batch.ABIs)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := newArgContext(batch.RpcURL, batch.Chain, blockParam)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(batch.Steps))
+	returns := make([]string, len(batch.Steps))
+	requests := make([]callRequest, len(batch.Steps))
+	for i, step := range batch.Steps {
+		names[i] = firstNonEmpty(step.Name, strconv.Itoa(i+1))
+		if templatePattern.MatchString(step.To + strings.Join(step.Args, ",")) {
+			return nil, fmt.Errorf("call %s: calls of a batch cannot use earlier results, run the file as a pipeline", names[i])
+		}
+		target, err := ctx.target(step.To)
+		if err != nil {
+			return nil, fmt.Errorf("call %s: %v", names[i], err)
+		}
+		callArgs, err := ctx.resolveArgs(step.Sig, step.Args)
+		if err != nil {
+			return nil, fmt.Errorf("call %s: %v", names[i], err)
+		}
+		encoded, err := encodeMethodCall(step.Sig, callArgs)
+		if err != nil {
+			return nil, fmt.Errorf("call %s: failed to encode call: %v", names[i], err)
+		}
+		returns[i] = step.Returns
+		if returns[i] == "" {
+			if entry, ok := decoder.functions[encoded[:10]]; ok {
+				returns[i] = entry.returnTypes()
+			}
+		}
+		if returns[i] == "" {
+			return nil, fmt.Errorf("call %s: no return types given and %s is not in the batch ABIs", names[i], step.Sig)
+		}
+		requests[i] = callRequest{To: target, Data: hexutil.MustDecode(encoded)}
+	}
+	return &batchCalls{names: names, returns: returns, requests: requests}, nil
+}
+
+// Function to print the total estimated gas of a batch and its most expensive call
+func printGasSummary(names []string, gas []uint64, errs []error) {
+	var total uint64
+	estimated, highest := 0, -1
+	for i := range gas {
+		if errs[i] != nil {
+			continue
+		}
+		estimated++
+		total += gas[i]
+		if highest < 0 || gas[i] > gas[highest] {
+			highest = i
+		}
+	}
+	if highest < 0 {
+		return
+	}
+	fmt.Printf("\nEstimated gas: %s for %s, most by [%s] with %s\n", groupThousands(strconv.FormatUint(total, 10)),
+		pluralize(estimated, "call"), names[highest], groupThousands(strconv.FormatUint(gas[highest], 10)))
+}
+
+// Function to run independent calls at a block with a strategy, returning their results in
+// order and a description of how they were sent. The auto strategy aggregates them through
+// Multicall3 where it is deployed, and otherwise sends JSON-RPC batches, falling back to
+// parallel calls on endpoints refusing batches. The multicall strategy also falls back to
+// JSON-RPC batches on chains without Multicall3.
+func executeCalls(rpcURL, block string, requests []callRequest, strategy string, concurrency int) ([]callResult, string, error) {
+	if strategy == "auto" {
+		strategy = "parallel"
+		if len(requests) > 1 {
+			strategy = "rpc-batch"
+			if deployed, err := multicall3Deployed(rpcURL, block); err == nil && deployed {
+				strategy = "multicall"
+			}
+		}
+	}
+	if strategy == "multicall" {
+		if deployed, err := multicall3Deployed(rpcURL, block); err == nil && !deployed {
+			fmt.Fprintf(os.Stderr, "Multicall3 is not deployed on this chain (see contract-curler multicall status), sending JSON-RPC batches\n")
+			strategy = "rpc-batch"
+		}
+	}
+
+	switch strategy {
+	case "multicall":
+		results, aggregates, err := multicallCalls(rpcURL, block, requests, concurrency)
+		if err != nil {
+			return nil, "", err
+		}
+		return results, fmt.Sprintf("through Multicall3 (%s, msg.sender is Multicall3)", pluralize(aggregates, "aggregate3 call")), nil
+	case "rpc-batch":
+		results, batches, err := batchedCalls(rpcURL, block, requests)
+		if err == nil {
+			if batches == 1 {
+				return results, "in 1 JSON-RPC batch", nil
+			}
+			return results, fmt.Sprintf("in %d JSON-RPC batches", batches), nil
+		}
+		if _, unsupported := err.(batchUnsupportedError); !unsupported {
+			return nil, "", err
+		}
+		fmt.Fprintf(os.Stderr, "The endpoint does not support JSON-RPC batches (%v), sending the calls one by one\n", err)
+	}
+	return parallelCalls(rpcURL, block, requests, concurrency), fmt.Sprintf("as parallel calls (%d at once)", concurrency), nil
+}
+
+// Function to send each call on its own, a limited number at a time
+func parallelCalls(rpcURL, block string, requests []callRequest, concurrency int) []callResult {
+	results := make([]callResult, len(requests))
+	inParallel(len(requests), concurrency, func(i int) {
+		results[i] = singleCall(rpcURL, block, requests[i])
+	})
+	return results
+}
+
+// Function to run a task for indices 0 to n-1, at most concurrency of them at once
+func inParallel(n, concurrency int, task func(i int)) {
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			task(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Function to estimate the gas each call would use if sent as a transaction, a limited
+// number at a time. Estimates include the 21000 gas every transaction pays.
+func estimateCallGas(rpcURL, block string, requests []callRequest, concurrency int) ([]uint64, []error) {
+	gas := make([]uint64, len(requests))
+	errs := make([]error, len(requests))
+	inParallel(len(requests), concurrency, func(i int) {
+		var estimate hexutil.Uint64
+		errs[i] = callRPC(rpcURL, &estimate, "eth_estimateGas", requests[i].callObject(), block)
+		gas[i] = uint64(estimate)
+	})
+	return gas, errs
+}
+
+// Function to run a call, telling reverts apart from transport and node errors
+func singleCall(rpcURL, block string, request callRequest) callResult {
+	var output hexutil.Bytes
+	err := callRPC(rpcURL, &output, "eth_call", request.callObject(), block)
+	if rpcErr, ok := err.(*JsonRpcError); ok && strings.Contains(strings.ToLower(rpcErr.Message), "revert") {
+		var data hexutil.Bytes
+		json.Unmarshal(rpcErr.Data, &data)
+		return callResult{Output: data, Err: fmt.Errorf("reverted: %s", revertReason(data, rpcErr.Message))}
+	}
+	if err != nil {
+		return callResult{Err: err}
+	}
+	return callResult{Output: output}
+}
+
+// batchUnsupportedError is returned when an endpoint does not answer JSON-RPC batches
+type batchUnsupportedError struct {
+	reason string
+}
+
+func (e batchUnsupportedError) Error() string {
+	return e.reason
+}
+
+// Function to send the calls as JSON-RPC batches, returning the number of batches
+func batchedCalls(rpcURL, block string, requests []callRequest) ([]callResult, int, error) {
+	results := make([]callResult, len(requests))
+	batches := 0
+	for start := 0; start < len(requests); start += maxRPCBatch {
+		end := start + maxRPCBatch
+		if end > len(requests) {
+			end = len(requests)
+		}
+		// Request i of the batch has id first+i-start
+		first := reserveRequestIDs(end - start)
+		batch := make([]JsonRpcRequest, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, JsonRpcRequest{JsonRpc: "2.0", Method: "eth_call", Params: []interface{}{requests[i].callObject(), block}, Id: first + i - start})
+		}
+		jsonData, err := json.Marshal(batch)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create JSON request: %v", err)
+		}
+		body, err := postJSON(rpcURL, jsonData, 2)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to execute batch: %v", err)
+		}
+		var responses []JsonRpcResponse
+		if err := json.Unmarshal(body, &responses); err != nil {
+			return nil, 0, batchUnsupportedError{"the response is not an array"}
+		}
+		answered := make(map[int]bool)
+		var retried []int
+		for _, response := range responses {
+			if err := response.checkFields(); err != nil {
+				return nil, 0, fmt.Errorf("invalid batch response: %v", err)
+			}
+			if response.Id == nil {
+				if response.Error != nil {
+					return nil, 0, fmt.Errorf("batch rejected: %v", response.Error)
+				}
+				return nil, 0, fmt.Errorf("batch response has no id")
+			}
+			if *response.Id < first || *response.Id >= first+end-start {
+				return nil, 0, fmt.Errorf("batch response id %d matches no request (ids %d to %d)", *response.Id, first, first+end-start-1)
+			}
+			i := *response.Id - first + start
+			if answered[i] {
+				return nil, 0, fmt.Errorf("batch has several responses with id %d", *response.Id)
+			}
+			answered[i] = true
+			if response.Error != nil {
+				var data hexutil.Bytes
+				json.Unmarshal(response.Error.Data, &data)
+				if offchainLookupOf(response.Error) != nil {
+					retried = append(retried, i)
+				} else if strings.Contains(strings.ToLower(response.Error.Message), "revert") {
+					results[i] = callResult{Output: data, Err: fmt.Errorf("reverted: %s", revertReason(data, response.Error.Message))}
+				} else if isBlockLagError(response.Error) {
+					retried = append(retried, i)
+				} else {
+					results[i] = callResult{Err: response.Error}
+				}
+				continue
+			}
+			var output hexutil.Bytes
+			if err := json.Unmarshal(response.Result, &output); err != nil {
+				results[i] = callResult{Err: fmt.Errorf("failed to parse eth_call result: %v", err)}
+				continue
+			}
+			results[i] = callResult{Output: output}
+		}
+		for i := start; i < end; i++ {
+			if !answered[i] {
+				results[i] = callResult{Err: fmt.Errorf("no response in the JSON-RPC batch")}
+			}
+		}
+		// Calls the node was not caught up for are retried on their own until it is, and
+		// offchain lookups are followed on their own
+		for _, i := range retried {
+			results[i] = singleCall(rpcURL, block, requests[i])
+		}
+		batches++
+	}
+	return results, batches, nil
+}
+
+// Function to aggregate the calls through Multicall3, in as many aggregate3 calls as their
+// calldata needs. Calls too large to share an aggregate are sent on their own. At a block
+// that is not pinned to a number, each aggregate also gets Multicall3's block number, so
+// aggregates run at different blocks fail instead of mixing state.
+func multicallCalls(rpcURL, block string, requests []callRequest, concurrency int) ([]callResult, int, error) {
+	results := make([]callResult, len(requests))
+	var groups [][]int
+	var current []int
+	size := 0
+	var direct []int
+	for i, request := range requests {
+		if len(request.Data) > maxMulticallCalldata {
+			direct = append(direct, i)
+			continue
+		}
+		if size+len(request.Data) > maxMulticallCalldata && len(current) > 0 {
+			groups = append(groups, current)
+			current, size = nil, 0
+		}
+		current = append(current, i)
+		size += len(request.Data)
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+
+	var checkBlock bool
+	if len(groups) > 1 && !strings.HasPrefix(block, "0x") {
+		checkBlock = true
+	}
+	var ranAt *big.Int
+	for _, group := range groups {
+		var calls []multicall3Call
+		if checkBlock {
+			calls = append(calls, multicall3Call{Target: common.HexToAddress(multicall3Address), AllowFailure: true, CallData: getBlockNumberSelector})
+		}
+		for _, i := range group {
+			calls = append(calls, multicall3Call{Target: requests[i].To, AllowFailure: true, CallData: requests[i].Data})
+		}
+		data, err := aggregate3Method.Inputs.Pack(calls)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode aggregate3: %v", err)
+		}
+		var output hexutil.Bytes
+		call := map[string]interface{}{"to": multicall3Address, "data": hexutil.Encode(append(aggregate3Method.ID, data...))}
+		if err := callRPC(rpcURL, &output, "eth_call", call, block); err != nil {
+			return nil, 0, fmt.Errorf("aggregate3 failed: %v", err)
+		}
+		unpacked, err := aggregate3Method.Outputs.Unpack(output)
+		if err != nil || len(unpacked) != 1 {
+			return nil, 0, fmt.Errorf("failed to decode aggregate3 result: %v", err)
+		}
+		tuples := reflect.ValueOf(unpacked[0])
+		if tuples.Len() != len(calls) {
+			return nil, 0, fmt.Errorf("aggregate3 returned %d results for %d calls", tuples.Len(), len(calls))
+		}
+		offset := 0
+		if checkBlock {
+			offset = 1
+			number := new(big.Int).SetBytes(tuples.Index(0).Field(1).Bytes())
+			if ranAt == nil {
+				ranAt = number
+			} else if ranAt.Cmp(number) != 0 {
+				return nil, 0, fmt.Errorf("aggregate3 calls ran at blocks %s and %s, pin a block number to get consistent results", ranAt, number)
+			}
+		}
+		for j, i := range group {
+			success := tuples.Index(j + offset).Field(0).Bool()
+			returnData := tuples.Index(j + offset).Field(1).Bytes()
+			if success {
+				results[i] = callResult{Output: returnData}
+			} else if decodeOffchainLookup(returnData) != nil {
+				// The lookup is followed with a direct call, as it names the target as sender
+				results[i] = singleCall(rpcURL, block, requests[i])
+			} else {
+				results[i] = callResult{Output: returnData, Err: fmt.Errorf("reverted: %s", revertReason(returnData, "execution reverted"))}
+			}
+		}
+	}
+
+	if len(direct) > 0 {
+		directRequests := make([]callRequest, len(direct))
+		for j, i := range direct {
+			directRequests[j] = requests[i]
+		}
+		for j, result := range parallelCalls(rpcURL, block, directRequests, concurrency) {
+			results[direct[j]] = result
+		}
+	}
+	return results, len(groups), nil
+}
+
+// multicall3Call is the Call3 struct of Multicall3's aggregate3
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// The aggregate3 method of Multicall3, built once for packing and unpacking
+var aggregate3Method = newAggregate3Method()
+
+// Function to build the ABI of Multicall3's aggregate3((address,bool,bytes)[])
+func newAggregate3Method() abi.Method {
+	calls, _ := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "target", Type: "address"}, {Name: "allowFailure", Type: "bool"}, {Name: "callData", Type: "bytes"},
+	})
+	results, _ := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "success", Type: "bool"}, {Name: "returnData", Type: "bytes"},
+	})
+	return abi.NewMethod("aggregate3", "aggregate3", abi.Function, "payable", false, true,
+		abi.Arguments{{Name: "calls", Type: calls}}, abi.Arguments{{Name: "returnData", Type: results}})
+}
+
+// Function to describe revert data: the message of Error(string), the code of
+// Panic(uint256), or the raw data of custom errors
+func revertReason(data []byte, fallback string) string {
+	if len(data) >= 4 {
+		switch hexutil.Encode(data[:4]) {
+		case "0x08c379a0":
+			if values, err := decodeReturnValues(hexutil.Encode(data[4:]), "(string)"); err == nil {
+				return fmt.Sprintf("%q", values[0])
+			}
+		case "0x4e487b71":
+			if values, err := decodeReturnValues(hexutil.Encode(data[4:]), "(uint256)"); err == nil {
+				return fmt.Sprintf("panic 0x%x", values[0].(*big.Int))
+			}
+		}
+		return "custom error " + hexutil.Encode(data)
+	}
+	return fallback
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Beacon API endpoint used when none is given or configured, the default of Lighthouse
+const defaultBeaconURL = "http://localhost:5052"
+
+// Slots in an epoch of the beacon chain
+const slotsPerEpoch = 32
+
+// beaconCheckpoint is a checkpoint of the finality gadget
+type beaconCheckpoint struct {
+	Epoch string `json:"epoch"`
+	Root  string `json:"root"`
+}
+
+// beaconValidator is the state of a validator as returned by the Beacon API
+type beaconValidator struct {
+	Index     string `json:"index"`
+	Balance   string `json:"balance"`
+	Status    string `json:"status"`
+	Validator struct {
+		Pubkey                string `json:"pubkey"`
+		WithdrawalCredentials string `json:"withdrawal_credentials"`
+		EffectiveBalance      string `json:"effective_balance"`
+		Slashed               bool   `json:"slashed"`
+		ActivationEpoch       string `json:"activation_epoch"`
+		ExitEpoch             string `json:"exit_epoch"`
+		WithdrawableEpoch     string `json:"withdrawable_epoch"`
+	} `json:"validator"`
+}
+
+// Epoch the Beacon API reports for events that have not happened, 2^64 - 1
+const farFutureEpoch = "18446744073709551615"
+
+// Function to run the beacon subcommands, querying the consensus layer through the Beacon
+// API: finality, validator and balances
+func runBeacon(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler beacon finality|validator|balances [flags] [validators...]")
+	}
+	fs := flag.NewFlagSet("beacon "+args[0], flag.ExitOnError)
+	beaconURL := fs.String("beacon", "", "Beacon API URL (default: the configured one, or "+defaultBeaconURL+")")
+	state := fs.String("state", "head", "state to query: head, finalized, justified, genesis, a slot or a state root")
+	fs.Parse(args[1:])
+
+	endpoint := *beaconURL
+	if endpoint == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		endpoint = firstNonEmpty(cfg.Beacon, defaultBeaconURL)
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+	stateID := url.PathEscape(*state)
+
+	switch args[0] {
+	case "finality":
+		var checkpoints struct {
+			PreviousJustified beaconCheckpoint `json:"previous_justified"`
+			CurrentJustified  beaconCheckpoint `json:"current_justified"`
+			Finalized         beaconCheckpoint `json:"finalized"`
+		}
+		if err := beaconGet(endpoint, "/eth/v1/beacon/states/"+stateID+"/finality_checkpoints", &checkpoints); err != nil {
+			return err
+		}
+		var header struct {
+			Header struct {
+				Message struct {
+					Slot string `json:"slot"`
+				} `json:"message"`
+			} `json:"header"`
+		}
+		if err := beaconGet(endpoint, "/eth/v1/beacon/headers/head", &header); err != nil {
+			return err
+		}
+		slot, _ := strconv.ParseUint(header.Header.Message.Slot, 10, 64)
+		fmt.Printf("Head:               slot %d, epoch %d\n", slot, slot/slotsPerEpoch)
+		fmt.Printf("Finalized:          epoch %s (%s)\n", checkpoints.Finalized.Epoch, checkpoints.Finalized.Root)
+		fmt.Printf("Current justified:  epoch %s (%s)\n", checkpoints.CurrentJustified.Epoch, checkpoints.CurrentJustified.Root)
+		fmt.Printf("Previous justified: epoch %s (%s)\n", checkpoints.PreviousJustified.Epoch, checkpoints.PreviousJustified.Root)
+		// The chain finalizes two epochs behind the head when all is well
+		if finalized, err := strconv.ParseUint(checkpoints.Finalized.Epoch, 10, 64); err == nil && slot/slotsPerEpoch > finalized+2 {
+			fmt.Printf("%s: finality is %s behind the head\n", colorize(colorYellow, "Warning"), pluralize(int(slot/slotsPerEpoch-finalized), "epoch"))
+		}
+		return nil
+
+	case "validator":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: contract-curler beacon validator [flags] <index or pubkey>...")
+		}
+		for i, id := range fs.Args() {
+			var validator beaconValidator
+			if err := beaconGet(endpoint, "/eth/v1/beacon/states/"+stateID+"/validators/"+url.PathEscape(id), &validator); err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			printBeaconValidator(&validator)
+		}
+		return nil
+
+	case "balances":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: contract-curler beacon balances [flags] <index or pubkey>...")
+		}
+		var balances []struct {
+			Index   string `json:"index"`
+			Balance string `json:"balance"`
+		}
+		if err := beaconGet(endpoint, "/eth/v1/beacon/states/"+stateID+"/validator_balances?id="+url.QueryEscape(strings.Join(fs.Args(), ",")), &balances); err != nil {
+			return err
+		}
+		total := new(big.Int)
+		for _, balance := range balances {
+			fmt.Printf("%-10s %s ETH\n", balance.Index, gweiToEther(balance.Balance))
+			if n, ok := new(big.Int).SetString(balance.Balance, 10); ok {
+				total.Add(total, n)
+			}
+		}
+		if len(balances) > 1 {
+			fmt.Printf("%-10s %s ETH\n", "total", formatFixed(total, 9))
+		}
+		if len(balances) < fs.NArg() {
+			return fmt.Errorf("%d of %s not found", fs.NArg()-len(balances), pluralize(fs.NArg(), "validator"))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown beacon subcommand '%s' (expected finality, validator or balances)", args[0])
+}
+
+// Function to GET a Beacon API path and decode the data field of the response into out
+func beaconGet(endpoint, path string, out interface{}) error {
+	request, err := http.NewRequest("GET", endpoint+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to query the Beacon API: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read Beacon API response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Message != "" {
+			return fmt.Errorf("Beacon API error %d: %s", resp.StatusCode, failure.Message)
+		}
+		return fmt.Errorf("Beacon API error: %s", resp.Status)
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Data == nil {
+		return fmt.Errorf("invalid Beacon API response from %s", path)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// Function to print the state of a validator
+func printBeaconValidator(v *beaconValidator) {
+	fmt.Printf("Validator:         %s\n", v.Index)
+	fmt.Printf("Public key:        %s\n", v.Validator.Pubkey)
+	status := v.Status
+	if v.Validator.Slashed {
+		status = colorize(colorRed, status+", slashed")
+	}
+	fmt.Printf("Status:            %s\n", status)
+	fmt.Printf("Balance:           %s ETH\n", gweiToEther(v.Balance))
+	fmt.Printf("Effective balance: %s ETH\n", gweiToEther(v.Validator.EffectiveBalance))
+	fmt.Printf("Activation epoch:  %s\n", beaconEpoch(v.Validator.ActivationEpoch))
+	fmt.Printf("Exit epoch:        %s\n", beaconEpoch(v.Validator.ExitEpoch))
+	fmt.Printf("Withdrawable:      %s\n", beaconEpoch(v.Validator.WithdrawableEpoch))
+	credentials := v.Validator.WithdrawalCredentials
+	fmt.Printf("Withdrawals:       %s\n", credentials)
+	// 0x01 and 0x02 credentials end with the execution address withdrawals are paid to
+	if strings.HasPrefix(credentials, "0x01") || strings.HasPrefix(credentials, "0x02") {
+		fmt.Printf("                   to %s\n", common.HexToAddress(credentials[len(credentials)-40:]).Hex())
+	}
+}
+
+// Function to render an amount of gwei, as the Beacon API gives balances, in ether
+func gweiToEther(gwei string) string {
+	n, ok := new(big.Int).SetString(gwei, 10)
+	if !ok {
+		return gwei
+	}
+	return formatFixed(n, 9)
+}
+
+// Function to render an epoch, naming the one standing for never
+func beaconEpoch(epoch string) string {
+	if epoch == farFutureEpoch {
+		return "never"
+	}
+	return epoch
+}
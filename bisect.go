@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.starlark.net/starlark"
+)
+
+// bisectProbe is the result of the call at one block, and whether the predicate holds there
+type bisectProbe struct {
+	block  uint64
+	values []interface{}
+	value  starlark.Value
+	holds  bool
+}
+
+// Function to binary search a block range for the first block at which the result of a
+// call satisfies a predicate, like an owner changing or a balance dropping below a bound.
+// The predicate is a Starlark expression over value, the result at the block, and start,
+// the result at the first block of the range; by default it is value != start. A call that
+// reverts or returns nothing, e.g. before the contract is deployed, has the value None.
+func runBisect(args []string) error {
+	fs := flag.NewFlagSet("bisect", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting its endpoint profile and contract deployments")
+	to := fs.String("to", "", "address, contract alias or label of the contract to call")
+	sig := fs.String("sig", "", "function signature, e.g. \"balanceOf(address)\", with its arguments given after the flags")
+	returns := fs.String("returns", "", "return types, e.g. \"(uint256)\"")
+	where := fs.String("where", "value != start", "Starlark predicate over value and start, e.g. \"value < 1000 * 10**18\"")
+	startBlock := fs.String("start", "0", "first block of the range")
+	endBlock := fs.String("end", "latest", "last block of the range")
+	fs.Parse(args)
+	if *to == "" || *sig == "" || *returns == "" {
+		return fmt.Errorf("usage: contract-curler bisect --to <contract> --sig <signature> --returns <types> [--where <predicate>] [flags] [args...]")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	target, err := cfg.resolveAddress(*to, *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	ctx, err := newArgContext(endpoint, *chain, "latest")
+	if err != nil {
+		return err
+	}
+	callArgs, err := ctx.resolveArgs(*sig, fs.Args())
+	if err != nil {
+		return err
+	}
+	data, err := encodeMethodCall(*sig, callArgs)
+	if err != nil {
+		return fmt.Errorf("failed to encode call: %v", err)
+	}
+
+	var lo, hi uint64
+	for i, block := range []string{*startBlock, *endBlock} {
+		pinned, err := pinBlock(endpoint, block)
+		if err != nil {
+			return err
+		}
+		n, err := hexutil.DecodeUint64(pinned)
+		if err != nil {
+			return fmt.Errorf("cannot search from block '%s'", block)
+		}
+		if i == 0 {
+			lo = n
+		} else {
+			hi = n
+		}
+	}
+	if lo >= hi {
+		return fmt.Errorf("the range must end after block %d", lo)
+	}
+
+	returnTypes := splitTypeList(trimTypeList(*returns))
+	opts.Names = nil
+	for _, returnType := range returnTypes {
+		_, name := splitTypeName(returnType)
+		opts.Names = append(opts.Names, name)
+	}
+	// Result at the start of the range, set by the first probe
+	var startValue starlark.Value
+	probe := func(block uint64) (*bisectProbe, error) {
+		p := &bisectProbe{block: block, value: starlark.None}
+		values, err := callForValues(endpoint, target.Hex(), data, hexutil.EncodeUint64(block), *returns)
+		rpcErr, isRPCErr := err.(*JsonRpcError)
+		switch {
+		case err == nil:
+			p.values = values
+			if len(values) == 1 {
+				p.value = toStarlark(values[0])
+			} else {
+				p.value = scriptOutputs(values, opts.Names)
+			}
+		case isRPCErr && strings.Contains(strings.ToLower(rpcErr.Message), "revert"), strings.Contains(err.Error(), "empty result"):
+		default:
+			return nil, fmt.Errorf("call at block %d failed: %v", block, err)
+		}
+		if startValue == nil {
+			startValue = p.value
+		}
+		env := starlark.StringDict{
+			"value": p.value,
+			"start": startValue,
+			"block": starlark.MakeUint64(block),
+			"fixed": starlark.NewBuiltin("fixed", scriptFixed),
+			"sum":   starlark.NewBuiltin("sum", scriptSum),
+		}
+		result, err := starlark.Eval(&starlark.Thread{Name: "bisect"}, "predicate", *where, env)
+		if err != nil {
+			return nil, fmt.Errorf("predicate '%s' at block %d: %v", *where, block, err)
+		}
+		holds, ok := result.(starlark.Bool)
+		if !ok {
+			return nil, fmt.Errorf("predicate '%s' is a %s, not a bool", *where, result.Type())
+		}
+		p.holds = bool(holds)
+		if p.holds {
+			fmt.Fprintf(os.Stderr, "Block %d: holds\n", block)
+		} else {
+			fmt.Fprintf(os.Stderr, "Block %d: does not hold\n", block)
+		}
+		return p, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Searching blocks %d to %d, about %s\n", lo, hi, pluralize(bits.Len64(hi-lo)+1, "call"))
+	before, err := probe(lo)
+	if err != nil {
+		return err
+	}
+	if before.holds {
+		fmt.Printf("'%s' already holds at block %d\n", *where, lo)
+		printBisectProbe(before, returnTypes, opts)
+		return nil
+	}
+	after, err := probe(hi)
+	if err != nil {
+		return err
+	}
+	if !after.holds {
+		return fmt.Errorf("'%s' does not hold at block %d", *where, hi)
+	}
+	// The predicate is assumed to keep holding once it does, like a one-way state change
+	for after.block-before.block > 1 {
+		mid, err := probe(before.block + (after.block-before.block)/2)
+		if err != nil {
+			return err
+		}
+		if mid.holds {
+			after = mid
+		} else {
+			before = mid
+		}
+	}
+
+	fmt.Printf("First block where '%s': %s\n", *where, colorize(colorGreen, fmt.Sprint(after.block)))
+	printBisectProbe(before, returnTypes, opts)
+	printBisectProbe(after, returnTypes, opts)
+	return nil
+}
+
+// Function to print the result of the call at a probed block, with the output names in opts
+func printBisectProbe(p *bisectProbe, returnTypes []string, opts formatOptions) {
+	fmt.Printf("  [block %d]\n", p.block)
+	if p.values == nil {
+		fmt.Println("    reverted or returned nothing")
+		return
+	}
+	printReturnValues(formatReturnValues(p.values, returnTypes, opts), returnTypes, "    ")
+}
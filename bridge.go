@@ -0,0 +1,589 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/contract-curler/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Error codes of EIP-1193 providers
+const (
+	providerUnauthorized      = 4100
+	providerUnsupportedMethod = 4200
+	providerUnrecognizedChain = 4902
+)
+
+// bridge is a local JSON-RPC provider for browser tools, forwarding their requests to the
+// configured endpoint and signing their transactions and messages with the bridge's account
+type bridge struct {
+	rpcURL  string
+	chainID uint64
+	key     *ecdsa.PrivateKey
+	wallet  *wcSession
+	// Origins browsers may call the bridge from, "*" for any
+	origins map[string]bool
+	// Random token every request must carry, printed at startup
+	token    string
+	simulate bool
+	// Signing requests are handled one at a time, so nonces and wallet prompts do not mix
+	signing sync.Mutex
+}
+
+// bridgeRequest is a JSON-RPC request received by the bridge
+type bridgeRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// bridgeResponse is the response to a bridgeRequest, with either a result or an error
+type bridgeResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
+}
+
+// Function to serve an EIP-1193 style provider over HTTP and WebSocket, so browser tools
+// reach the configured endpoints and sign through contract-curler
+func runBridge(args []string) error {
+	fs := flag.NewFlagSet("bridge", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8546", "address to serve JSON-RPC on, over HTTP and WebSocket")
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL requests are forwarded to (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	keyFile := fs.String("key-file", "", "file holding the hex private key to sign with (default: $"+privateKeyEnv+")")
+	origins := fs.String("origin", "", "comma-separated origins the bridge may be called from, * for any; requests without an allowed Origin header are refused")
+	simulate := fs.Bool("simulate", true, "simulate transactions with eth_call before signing and reject those that revert")
+	manifestPath := fs.String("manifest", "", "file every forwarded request is appended to, with its endpoint, block and response hash")
+	yes := fs.Bool("yes", false, "sign transactions and messages without asking for confirmation")
+	walletConnect := addWalletConnectFlags(fs)
+	fs.Parse(args)
+	confirmOptions.yes = *yes
+
+	b := &bridge{rpcURL: firstNonEmpty(*rpcURL, chainRPC(*chain)), origins: make(map[string]bool), simulate: *simulate}
+	for _, origin := range strings.Split(*origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			b.origins[strings.TrimSuffix(origin, "/")] = true
+		}
+	}
+	if len(b.origins) == 0 {
+		return fmt.Errorf("no origin may call the bridge, give them with --origin")
+	}
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate access token: %v", err)
+	}
+	b.token = hex.EncodeToString(secret)
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if b.chainID, err = cfg.chainID(*chain, b.rpcURL); err != nil {
+		return err
+	}
+	if *manifestPath != "" {
+		enableManifest(*manifestPath)
+	}
+	if b.wallet, err = walletConnect.connect(b.chainID); err != nil {
+		return err
+	}
+	if b.wallet != nil {
+		defer b.wallet.Close()
+	} else if b.key, err = loadSigningKey(*keyFile, false); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s and ws://%s, forwarding to %s\n", chainName(b.chainID), *listen, *listen, displayEndpoint(resolveEndpoint(b.rpcURL)))
+	if account, ok := b.account(); ok {
+		fmt.Fprintf(os.Stderr, "Signing as %s\n", account.Hex())
+	} else {
+		fmt.Fprintln(os.Stderr, "No signing key given, transactions and signatures are refused")
+	}
+	fmt.Fprintf(os.Stderr, "Access token: %s\n", b.token)
+	fmt.Fprintf(os.Stderr, "Send it as \"Authorization: Bearer <token>\", or connect to ws://%s/?token=%s\n", *listen, b.token)
+	return http.ListenAndServe(*listen, b)
+}
+
+// Function to get the account the bridge signs with, if any
+func (b *bridge) account() (common.Address, bool) {
+	switch {
+	case b.wallet != nil:
+		return b.wallet.account, true
+	case b.key != nil:
+		return crypto.PubkeyToAddress(b.key.PublicKey), true
+	}
+	return common.Address{}, false
+}
+
+// Function to handle an HTTP request: a JSON-RPC POST, a WebSocket upgrade, or a CORS
+// preflight. Requests are refused unless they come from an allowed origin and carry the
+// access token, as any page or local process could otherwise spend from the account.
+func (b *bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || (!b.origins["*"] && !b.origins[origin]) {
+		http.Error(w, "origin not allowed, see --origin", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+	w.Header().Set("Vary", "Origin")
+	// Browsers send preflights without the token
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !b.authorized(r) {
+		http.Error(w, "missing or invalid access token", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.Header.Get("Upgrade") != "":
+		conn, err := rpc.AcceptWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		b.serveWebSocket(conn)
+	case r.Method == http.MethodPost:
+		payload, err := ioutil.ReadAll(io.LimitReader(r.Body, rpc.MaxMessageSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b.handle(payload))
+	default:
+		http.Error(w, "JSON-RPC requests are POSTed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Function to check the access token of a request, given as a bearer token or, as browsers
+// cannot set headers on WebSocket connections, in the token query parameter
+func (b *bridge) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return b.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) == 1
+}
+
+// Function to answer the messages of a WebSocket client until it disconnects, each in its
+// own goroutine so a transaction waiting for a wallet does not hold up reads
+func (b *bridge) serveWebSocket(conn *rpc.Conn) {
+	defer conn.Close()
+	for {
+		payload, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		go func() {
+			conn.WriteMessage(b.handle(payload))
+		}()
+	}
+}
+
+// Function to answer a JSON-RPC payload, a single request or a batch
+func (b *bridge) handle(payload []byte) []byte {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var requests []bridgeRequest
+		if err := json.Unmarshal(trimmed, &requests); err != nil {
+			return bridgeError(nil, &JsonRpcError{Code: -32700, Message: "parse error: " + err.Error()})
+		}
+		responses := make([]json.RawMessage, len(requests))
+		for i, request := range requests {
+			responses[i] = b.answer(request)
+		}
+		out, _ := json.Marshal(responses)
+		return out
+	}
+	var request bridgeRequest
+	if err := json.Unmarshal(trimmed, &request); err != nil {
+		return bridgeError(nil, &JsonRpcError{Code: -32700, Message: "parse error: " + err.Error()})
+	}
+	return b.answer(request)
+}
+
+// Function to answer a request, logging it with its duration
+func (b *bridge) answer(request bridgeRequest) []byte {
+	started := time.Now()
+	var params []json.RawMessage
+	if len(request.Params) > 0 && string(request.Params) != "null" {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return bridgeError(request.Id, &JsonRpcError{Code: -32602, Message: "params must be an array"})
+		}
+	}
+	result, err := b.dispatch(request.Method, params)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		rpcErr, ok := err.(*JsonRpcError)
+		if !ok {
+			rpcErr = &JsonRpcError{Code: -32603, Message: err.Error()}
+		}
+		fmt.Fprintf(os.Stderr, "%s %s (%s): %s\n", colorize(colorRed, "✗"), request.Method, elapsed, rpcErr.Message)
+		return bridgeError(request.Id, rpcErr)
+	}
+	fmt.Fprintf(os.Stderr, "%s %s (%s)\n", colorize(colorCyan, "→"), request.Method, elapsed)
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return bridgeError(request.Id, &JsonRpcError{Code: -32603, Message: err.Error()})
+	}
+	out, _ := json.Marshal(bridgeResponse{JsonRpc: "2.0", Id: request.Id, Result: encoded})
+	return out
+}
+
+// Function to encode an error response
+func bridgeError(id json.RawMessage, rpcErr *JsonRpcError) []byte {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	out, _ := json.Marshal(bridgeResponse{JsonRpc: "2.0", Id: id, Error: rpcErr})
+	return out
+}
+
+// Function to answer the methods of the provider itself and forward the others
+func (b *bridge) dispatch(method string, params []json.RawMessage) (interface{}, error) {
+	account, canSign := b.account()
+	switch method {
+	case "eth_chainId":
+		return hexutil.EncodeUint64(b.chainID), nil
+	case "net_version":
+		return strconv.FormatUint(b.chainID, 10), nil
+	case "eth_accounts", "eth_requestAccounts":
+		if !canSign {
+			return []string{}, nil
+		}
+		return []string{account.Hex()}, nil
+	case "wallet_switchEthereumChain":
+		var target []struct {
+			ChainId hexutil.Uint64 `json:"chainId"`
+		}
+		if err := json.Unmarshal(bridgeParams(params), &target); err != nil || len(target) != 1 {
+			return nil, &JsonRpcError{Code: -32602, Message: "expected [{chainId}]"}
+		}
+		if uint64(target[0].ChainId) != b.chainID {
+			return nil, &JsonRpcError{Code: providerUnrecognizedChain, Message: fmt.Sprintf("the bridge serves %s only", chainName(b.chainID))}
+		}
+		return nil, nil
+	case "eth_subscribe", "eth_unsubscribe":
+		return nil, &JsonRpcError{Code: providerUnsupportedMethod, Message: "subscriptions are not supported, poll instead"}
+	case "eth_sendTransaction", "personal_sign", "eth_signTypedData_v4":
+		if !canSign {
+			return nil, &JsonRpcError{Code: providerUnauthorized, Message: "the bridge has no signing key"}
+		}
+		// Messages are signed locally, without a call the read-only transport would refuse
+		if err := requireWritable("signing"); err != nil {
+			return nil, &JsonRpcError{Code: providerUnauthorized, Message: err.Error()}
+		}
+		b.signing.Lock()
+		defer b.signing.Unlock()
+		switch method {
+		case "eth_sendTransaction":
+			return b.sendTransaction(account, params)
+		case "personal_sign":
+			return b.personalSign(account, params)
+		default:
+			return b.signTypedData(account, params)
+		}
+	}
+	if strings.HasPrefix(method, "wallet_") {
+		return nil, &JsonRpcError{Code: providerUnsupportedMethod, Message: "unsupported method " + method}
+	}
+	forwarded := make([]interface{}, len(params))
+	for i, param := range params {
+		forwarded[i] = param
+	}
+	var result json.RawMessage
+	if err := callRPC(b.rpcURL, &result, method, forwarded...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Function to encode params back into a JSON array
+func bridgeParams(params []json.RawMessage) []byte {
+	encoded, _ := json.Marshal(params)
+	return encoded
+}
+
+// Function to check that a request signs for the bridge's account
+func checkSigner(account common.Address, requested string) error {
+	if !common.IsHexAddress(requested) || common.HexToAddress(requested) != account {
+		return &JsonRpcError{Code: providerUnauthorized, Message: fmt.Sprintf("the bridge signs for %s only, not %s", account.Hex(), requested)}
+	}
+	return nil
+}
+
+// Function to simulate a transaction, then fill in its nonce, gas and fees, sign and
+// broadcast it, or hand it to the wallet
+func (b *bridge) sendTransaction(account common.Address, params []json.RawMessage) (interface{}, error) {
+	var request struct {
+		From                 string          `json:"from"`
+		To                   *common.Address `json:"to"`
+		Value                *hexutil.Big    `json:"value"`
+		Data                 *hexutil.Bytes  `json:"data"`
+		Input                *hexutil.Bytes  `json:"input"`
+		Gas                  *hexutil.Uint64 `json:"gas"`
+		Nonce                *hexutil.Uint64 `json:"nonce"`
+		GasPrice             *hexutil.Big    `json:"gasPrice"`
+		MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	}
+	if len(params) != 1 {
+		return nil, &JsonRpcError{Code: -32602, Message: "expected [transaction]"}
+	}
+	if err := json.Unmarshal(params[0], &request); err != nil {
+		return nil, &JsonRpcError{Code: -32602, Message: "invalid transaction: " + err.Error()}
+	}
+	if err := checkSigner(account, request.From); err != nil {
+		return nil, err
+	}
+	value, data := new(big.Int), []byte{}
+	if request.Value != nil {
+		value = request.Value.ToInt()
+	}
+	if request.Input != nil {
+		data = *request.Input
+	} else if request.Data != nil {
+		data = *request.Data
+	}
+	callObject := map[string]interface{}{"from": account.Hex(), "value": hexutil.EncodeBig(value), "data": hexutil.Encode(data)}
+	if request.To != nil {
+		callObject["to"] = request.To.Hex()
+	}
+
+	if b.simulate {
+		var output hexutil.Bytes
+		if err := callRPC(b.rpcURL, &output, "eth_call", callObject, "pending"); err != nil {
+			if rpcErr, ok := err.(*JsonRpcError); ok {
+				var revertData hexutil.Bytes
+				json.Unmarshal(rpcErr.Data, &revertData)
+				fmt.Fprintf(os.Stderr, "Simulation reverted: %s\n", revertReason(revertData, rpcErr.Message))
+			}
+			return nil, err
+		}
+	}
+	if request.Nonce == nil {
+		var pending hexutil.Uint64
+		if err := callRPC(b.rpcURL, &pending, "eth_getTransactionCount", account.Hex(), "pending"); err != nil {
+			return nil, fmt.Errorf("failed to get nonce of %s: %v", account.Hex(), err)
+		}
+		request.Nonce = &pending
+	}
+	if request.Gas == nil {
+		var estimate hexutil.Uint64
+		if err := callRPC(b.rpcURL, &estimate, "eth_estimateGas", callObject); err != nil {
+			return nil, err
+		}
+		request.Gas = &estimate
+	}
+	var tip, feeCap *big.Int
+	if request.GasPrice != nil {
+		tip = request.GasPrice.ToInt()
+	} else {
+		var priorityFee, maxFee string
+		if request.MaxPriorityFeePerGas != nil {
+			priorityFee = request.MaxPriorityFeePerGas.ToInt().String()
+		}
+		if request.MaxFeePerGas != nil {
+			maxFee = request.MaxFeePerGas.ToInt().String()
+		}
+		var err error
+		if tip, feeCap, err = suggestFees(b.rpcURL, priorityFee, maxFee); err != nil {
+			return nil, err
+		}
+	}
+	tx := newTransaction(b.chainID, uint64(*request.Nonce), request.To, value, uint64(*request.Gas), tip, feeCap, data, nil)
+	if b.wallet != nil {
+		// The wallet signs and broadcasts the transaction as filled in here
+		if err := enforcePolicies(b.chainID, request.To, value, data); err != nil {
+			return nil, err
+		}
+		if err := confirmTransaction(b.rpcURL, b.chainID, account, tx); err != nil {
+			return nil, &JsonRpcError{Code: providerUnauthorized, Message: err.Error()}
+		}
+		var hash string
+		if err := b.wallet.request("eth_sendTransaction", []interface{}{walletTransaction(account, tx)}, &hash); err != nil {
+			return nil, &JsonRpcError{Code: providerUnauthorized, Message: err.Error()}
+		}
+		nonce := tx.Nonce()
+		auditWalletTransaction(b.rpcURL, b.chainID, account, request.To, &nonce, hash)
+		fmt.Fprintf(os.Stderr, "Transaction: %s\n", hash)
+		return hash, nil
+	}
+	signed, err := signTransaction(tx, b.chainID, b.key)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := broadcastTransaction(b.rpcURL, signed)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Transaction: %s (nonce %d)\n", hash, signed.Nonce())
+	return hash, nil
+}
+
+// Function to sign a message with personal_sign, whose params are the message and the
+// address
+func (b *bridge) personalSign(account common.Address, params []json.RawMessage) (interface{}, error) {
+	var message, address string
+	if len(params) < 2 || json.Unmarshal(params[0], &message) != nil || json.Unmarshal(params[1], &address) != nil {
+		return nil, &JsonRpcError{Code: -32602, Message: "expected [message, address]"}
+	}
+	if err := checkSigner(account, address); err != nil {
+		return nil, err
+	}
+	if err := confirmSigning(account, "Message:", messageText(message)); err != nil {
+		return nil, err
+	}
+	if b.wallet != nil {
+		var signature string
+		if err := b.wallet.request("personal_sign", []interface{}{message, address}, &signature); err != nil {
+			return nil, &JsonRpcError{Code: providerUnauthorized, Message: err.Error()}
+		}
+		return signature, nil
+	}
+	return b.sign(personalMessageHash(message))
+}
+
+// Function to sign EIP-712 typed data with eth_signTypedData_v4, whose params are the
+// address and the typed data, as JSON or a JSON string
+func (b *bridge) signTypedData(account common.Address, params []json.RawMessage) (interface{}, error) {
+	var address string
+	if len(params) != 2 || json.Unmarshal(params[0], &address) != nil {
+		return nil, &JsonRpcError{Code: -32602, Message: "expected [address, typedData]"}
+	}
+	if err := checkSigner(account, address); err != nil {
+		return nil, err
+	}
+	typedJSON := []byte(params[1])
+	var asString string
+	if json.Unmarshal(params[1], &asString) == nil {
+		typedJSON = []byte(asString)
+	}
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(typedJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, &JsonRpcError{Code: -32602, Message: "invalid typed data: " + err.Error()}
+	}
+	domain, _ := raw["domain"].(map[string]interface{})
+	message, _ := json.MarshalIndent(raw["message"], "", "  ")
+	if err := confirmSigning(account,
+		fmt.Sprintf("Domain: %s", typedDataDomain(domain)),
+		fmt.Sprintf("Type: %v", raw["primaryType"]),
+		"Message:", string(message)); err != nil {
+		return nil, err
+	}
+	if b.wallet != nil {
+		var signature string
+		if err := b.wallet.request("eth_signTypedData_v4", []interface{}{address, string(typedJSON)}, &signature); err != nil {
+			return nil, &JsonRpcError{Code: providerUnauthorized, Message: err.Error()}
+		}
+		return signature, nil
+	}
+	// Tools send the chain id of the domain as a number, which apitypes only takes as a string
+	if chainID, ok := domain["chainId"].(json.Number); ok {
+		domain["chainId"] = chainID.String()
+		typedJSON, _ = json.Marshal(raw)
+	}
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(typedJSON, &typedData); err != nil {
+		return nil, &JsonRpcError{Code: -32602, Message: "invalid typed data: " + err.Error()}
+	}
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, &JsonRpcError{Code: -32602, Message: "invalid typed data: " + err.Error()}
+	}
+	return b.sign(common.BytesToHash(digest))
+}
+
+// Function to show what the bridge was asked to sign and have it confirmed, unless --yes
+// was given. Lines ending with a colon introduce the next one, which is indented.
+func confirmSigning(account common.Address, lines ...string) error {
+	fmt.Println("Signature request")
+	fmt.Printf("  Signer: %s\n", account.Hex())
+	for i, line := range lines {
+		indent := "  "
+		if i > 0 && strings.HasSuffix(lines[i-1], ":") {
+			indent = "    "
+		}
+		fmt.Println(indent + strings.ReplaceAll(line, "\n", "\n"+indent))
+	}
+	if confirmOptions.yes {
+		return nil
+	}
+	if err := confirmTyped("Sign this message?"); err != nil {
+		return &JsonRpcError{Code: providerUnauthorized, Message: err.Error()}
+	}
+	return nil
+}
+
+// Function to render a personal_sign message: its text when it is printable, else its bytes
+// in hex, so control characters cannot hide what is signed
+func messageText(message string) string {
+	data := []byte(message)
+	if decoded, err := hexutil.Decode(message); err == nil {
+		data = decoded
+	}
+	unprintable := func(r rune) bool { return !unicode.IsPrint(r) && r != '\n' }
+	if utf8.Valid(data) && strings.IndexFunc(string(data), unprintable) < 0 {
+		return string(data)
+	}
+	return fmt.Sprintf("%s (%d bytes)", hexutil.Encode(data), len(data))
+}
+
+// Function to describe the EIP-712 domain of typed data: its name and version, chain and
+// verifying contract, when given
+func typedDataDomain(domain map[string]interface{}) string {
+	var parts []string
+	if name, ok := domain["name"]; ok {
+		parts = append(parts, fmt.Sprint(name))
+	}
+	if version, ok := domain["version"]; ok {
+		parts = append(parts, fmt.Sprintf("version %v", version))
+	}
+	if chainID, ok := domain["chainId"]; ok {
+		parts = append(parts, fmt.Sprintf("chain %v", chainID))
+	}
+	if contract, ok := domain["verifyingContract"]; ok {
+		parts = append(parts, fmt.Sprintf("contract %v", contract))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Function to sign a digest with the bridge's key, with v as 27 or 28
+func (b *bridge) sign(digest common.Hash) (interface{}, error) {
+	signature, err := crypto.Sign(digest[:], b.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %v", err)
+	}
+	signature[64] += 27
+	return hexutil.Encode(signature), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBridgeAuthorization(t *testing.T) {
+	b := &bridge{chainID: 1, origins: map[string]bool{"http://app.test": true}, token: "secret"}
+	tests := []struct {
+		name   string
+		origin string
+		auth   string
+		query  string
+		method string
+		want   int
+	}{
+		{"no origin", "", "Bearer secret", "", http.MethodPost, http.StatusForbidden},
+		{"other origin", "http://evil.test", "Bearer secret", "", http.MethodPost, http.StatusForbidden},
+		{"no token", "http://app.test", "", "", http.MethodPost, http.StatusUnauthorized},
+		{"wrong token", "http://app.test", "Bearer wrong", "", http.MethodPost, http.StatusUnauthorized},
+		{"wrong query token", "http://app.test", "", "?token=wrong", http.MethodPost, http.StatusUnauthorized},
+		{"preflight", "http://app.test", "", "", http.MethodOptions, http.StatusNoContent},
+		{"bearer token", "http://app.test", "Bearer secret", "", http.MethodPost, http.StatusOK},
+		{"query token", "http://app.test", "", "?token=secret", http.MethodPost, http.StatusOK},
+	}
+	for _, test := range tests {
+		body := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`)
+		r := httptest.NewRequest(test.method, "/"+test.query, body)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if test.auth != "" {
+			r.Header.Set("Authorization", test.auth)
+		}
+		w := httptest.NewRecorder()
+		b.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.want)
+		}
+		if test.want == http.StatusOK && !strings.Contains(w.Body.String(), `"result":"0x1"`) {
+			t.Errorf("%s: unexpected response %s", test.name, w.Body.String())
+		}
+	}
+}
+
+func TestBridgeReadOnly(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	b := &bridge{chainID: 1, key: key}
+	typedData := `{"types":{"EIP712Domain":[{"name":"chainId","type":"uint256"}],"Mail":[{"name":"contents","type":"string"}]},` +
+		`"primaryType":"Mail","domain":{"chainId":1},"message":{"contents":"hello"}}`
+	requests := map[string][]json.RawMessage{
+		"personal_sign":        {json.RawMessage(`"0x68656c6c6f"`), json.RawMessage(`"` + address + `"`)},
+		"eth_signTypedData_v4": {json.RawMessage(`"` + address + `"`), json.RawMessage(typedData)},
+	}
+
+	defer func(flag, yes bool) { readOnlyFlag, confirmOptions.yes = flag, yes }(readOnlyFlag, confirmOptions.yes)
+	confirmOptions.yes = true
+	for method, params := range requests {
+		readOnlyFlag = true
+		if _, err := b.dispatch(method, params); err == nil || !strings.Contains(err.Error(), "read-only mode") {
+			t.Errorf("%s in read-only mode returned %v", method, err)
+		}
+		readOnlyFlag = false
+		if signature, err := b.dispatch(method, params); err != nil {
+			t.Errorf("%s: %v", method, err)
+		} else if s, _ := signature.(string); len(s) != 132 {
+			t.Errorf("%s returned signature %v", method, signature)
+		}
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"hello", "hello"},
+		{"0x68656c6c6f0a776f726c64", "hello\nworld"},
+		{"0x0001", "0x0001 (2 bytes)"},
+		{"0x68690d", "0x68690d (3 bytes)"},
+	}
+	for _, test := range tests {
+		if got := messageText(test.message); got != test.want {
+			t.Errorf("messageText(%q) = %q, want %q", test.message, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Gas costs of transactions, per EIP-2028, EIP-3860 and EIP-7623. Data is counted in tokens:
+// one per zero byte and four per non-zero byte, costing 4 gas each, so 16 per non-zero byte.
+const (
+	txBaseGas           = 21000
+	txCreateGas         = 32000
+	initCodeWordGas     = 2
+	tokensPerNonZero    = 4
+	standardGasPerToken = 4
+	floorGasPerToken    = 10
+)
+
+// calldataCost is the size and intrinsic gas of transaction data
+type calldataCost struct {
+	Size, Zero, NonZero int
+	// Gas of the data alone
+	DataGas uint64
+	// Intrinsic gas as charged up front, and the EIP-7623 floor charged instead when the
+	// execution costs less than the difference
+	Intrinsic, Floor uint64
+}
+
+// Function to compute the intrinsic gas of a transaction with the given data, creating
+// a contract when create is set
+func newCalldataCost(data []byte, create bool) calldataCost {
+	c := calldataCost{Size: len(data)}
+	for _, b := range data {
+		if b == 0 {
+			c.Zero++
+		} else {
+			c.NonZero++
+		}
+	}
+	tokens := uint64(c.Zero + tokensPerNonZero*c.NonZero)
+	c.DataGas = tokens * standardGasPerToken
+	c.Intrinsic = txBaseGas + c.DataGas
+	if create {
+		c.Intrinsic += txCreateGas + initCodeWordGas*uint64((len(data)+31)/32)
+	}
+	c.Floor = txBaseGas + tokens*floorGasPerToken
+	return c
+}
+
+// Function to report the size and intrinsic gas of calldata, and its L1 data fee on
+// rollups when an endpoint is given
+func runCalldata(args []string) error {
+	fs := flag.NewFlagSet("calldata", flag.ExitOnError)
+	sig := fs.String("sig", "", "function signature, with its arguments given after the flags")
+	data := fs.String("data", "", "raw calldata, instead of --sig and arguments")
+	to := fs.String("to", "", "address, contract alias or label the data is sent to, for the L1 data fee")
+	create := fs.Bool("create", false, "the data is the init code of a contract creation")
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL to get the L1 data fee of a rollup from")
+	chain := fs.String("chain", "", "chain name or id to get the L1 data fee of, through its endpoint profile")
+	fs.Parse(args)
+
+	var encoded string
+	switch {
+	case *data != "" && *sig != "":
+		return fmt.Errorf("--data and --sig are mutually exclusive")
+	case *data != "":
+		encoded = *data
+	case *sig != "":
+		ctx, err := newArgContext(firstNonEmpty(*rpcURL, chainRPC(*chain)), *chain, "latest")
+		if err != nil {
+			return err
+		}
+		callArgs, err := ctx.resolveArgs(*sig, fs.Args())
+		if err != nil {
+			return err
+		}
+		if encoded, err = encodeMethodCall(*sig, callArgs); err != nil {
+			return fmt.Errorf("failed to encode call: %v", err)
+		}
+		fmt.Println("Calldata:", encoded)
+	default:
+		return fmt.Errorf("usage: contract-curler calldata [flags] --sig <signature> [args...] | --data <hex>")
+	}
+	raw, err := hexutil.Decode(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid calldata: %v", err)
+	}
+
+	cost := newCalldataCost(raw, *create)
+	fmt.Printf("Size: %s (%d zero, %d non-zero)\n", pluralize(cost.Size, "byte"), cost.Zero, cost.NonZero)
+	breakdown := fmt.Sprintf("%d base + %d data", txBaseGas, cost.DataGas)
+	if *create {
+		breakdown += fmt.Sprintf(" + %d creation", cost.Intrinsic-txBaseGas-cost.DataGas)
+	}
+	fmt.Printf("Intrinsic gas: %d (%s)\n", cost.Intrinsic, breakdown)
+	if cost.Floor > cost.Intrinsic {
+		fmt.Printf("EIP-7623 floor: %d, charged when execution uses less than %d gas\n", cost.Floor, cost.Floor-cost.Intrinsic)
+	} else {
+		fmt.Printf("EIP-7623 floor: %d, below the intrinsic gas\n", cost.Floor)
+	}
+
+	if *rpcURL == "" && *chain == "" {
+		return nil
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	chainID, err := cfg.chainID(*chain, endpoint)
+	if err != nil {
+		return err
+	}
+	callObject := map[string]interface{}{"data": encoded}
+	if *to != "" {
+		address, err := cfg.resolveAddress(*to, *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		callObject["to"] = address.Hex()
+	}
+	var gasPrice hexutil.Big
+	if err := callRPC(endpoint, &gasPrice, "eth_gasPrice"); err != nil {
+		return fmt.Errorf("eth_gasPrice failed: %v", err)
+	}
+	l1, err := estimateL1DataFee(endpoint, chainID, callObject, max(cost.Intrinsic, cost.Floor), (*big.Int)(&gasPrice), "latest")
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to get the L1 data fee: %v", err)
+	case l1 == nil:
+		fmt.Printf("L1 data fee: none on %s\n", chainName(chainID))
+	case l1.InGasEstimate:
+		fmt.Printf("L1 data fee: %s ETH (%s, charged as L2 gas)\n", formatFixed(l1.Fee, 18), l1.Source)
+	default:
+		fmt.Printf("L1 data fee: %s ETH (%s)\n", formatFixed(l1.Fee, 18), l1.Source)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Selector of OffchainLookup(address,string[],bytes,bytes4,bytes), the error EIP-3668
+// contracts revert with to have the caller fetch their answer from a gateway
+const offchainLookupSelector = "0x556f1830"
+
+// Lookups followed for one call, as the callback may revert with another lookup
+const maxOffchainLookups = 4
+
+// offchainLookup is the OffchainLookup revert of a contract: the gateways to query with
+// callData, and the callback to call with their response and extraData
+type offchainLookup struct {
+	sender    common.Address
+	urls      []string
+	callData  []byte
+	callback  [4]byte
+	extraData []byte
+}
+
+// Function to decode the OffchainLookup a call reverted with, nil when it failed otherwise
+func offchainLookupOf(err error) *offchainLookup {
+	rpcErr, ok := err.(*JsonRpcError)
+	if !ok || rpcErr == nil {
+		return nil
+	}
+	var data hexutil.Bytes
+	if json.Unmarshal(rpcErr.Data, &data) != nil {
+		return nil
+	}
+	return decodeOffchainLookup(data)
+}
+
+// Function to decode OffchainLookup revert data, nil when it is another error
+func decodeOffchainLookup(data []byte) *offchainLookup {
+	if len(data) < 4 || hexutil.Encode(data[:4]) != offchainLookupSelector {
+		return nil
+	}
+	values, err := decodeReturnValues(hexutil.Encode(data[4:]), "(address,string[],bytes,bytes4,bytes)")
+	if err != nil {
+		return nil
+	}
+	lookup := &offchainLookup{}
+	var ok [5]bool
+	lookup.sender, ok[0] = values[0].(common.Address)
+	lookup.urls, ok[1] = values[1].([]string)
+	lookup.callData, ok[2] = values[2].([]byte)
+	lookup.callback, ok[3] = values[3].([4]byte)
+	lookup.extraData, ok[4] = values[4].([]byte)
+	if ok != [5]bool{true, true, true, true, true} {
+		return nil
+	}
+	return lookup
+}
+
+// Function to follow the OffchainLookup reverts of an eth_call: fetch the data from the
+// gateways of the contract, then call its callback with the response in place of the
+// original call, decoding the result into out. Errors other than lookups are returned as
+// they are.
+func followOffchainLookups(rpcURL string, out interface{}, params []interface{}, err error) error {
+	for lookups := 0; ; lookups++ {
+		lookup := offchainLookupOf(err)
+		if lookup == nil {
+			return err
+		}
+		if lookups == maxOffchainLookups {
+			return fmt.Errorf("gave up after %s, the contract keeps asking for more", pluralize(lookups, "offchain lookup"))
+		}
+		if len(params) == 0 {
+			return err
+		}
+		// The call object is rebuilt as a map, whatever type the caller gave it as
+		encoded, marshalErr := json.Marshal(params[0])
+		if marshalErr != nil {
+			return err
+		}
+		var call map[string]interface{}
+		if json.Unmarshal(encoded, &call) != nil {
+			return err
+		}
+		to, _ := call["to"].(string)
+		if !common.IsHexAddress(to) || common.HexToAddress(to) != lookup.sender {
+			return fmt.Errorf("OffchainLookup sender %s is not the called contract %s", lookup.sender.Hex(), to)
+		}
+		response, fetchErr := fetchOffchainData(lookup)
+		if fetchErr != nil {
+			return fetchErr
+		}
+		arguments, encodeErr := encodeArguments([]string{"bytes", "bytes"}, []string{hexutil.Encode(response), hexutil.Encode(lookup.extraData)})
+		if encodeErr != nil {
+			return fmt.Errorf("failed to encode OffchainLookup callback: %v", encodeErr)
+		}
+		delete(call, "input")
+		call["data"] = hexutil.Encode(append(lookup.callback[:], arguments...))
+		params = append([]interface{}{call}, params[1:]...)
+		err = requestRPC(rpcURL, out, "eth_call", params...)
+	}
+}
+
+// Function to query the gateways of a lookup in order, returning the data of the first
+// that answers. URLs with {data} are fetched with GET, others get the request POSTed as
+// JSON. A gateway refusing the request with a 4xx status ends the lookup, as EIP-3668
+// requires, while other failures move on to the next gateway.
+func fetchOffchainData(lookup *offchainLookup) ([]byte, error) {
+	sender := strings.ToLower(lookup.sender.Hex())
+	data := hexutil.Encode(lookup.callData)
+	var failures []string
+	for _, gateway := range lookup.urls {
+		target := strings.NewReplacer("{sender}", sender, "{data}", data).Replace(gateway)
+		fmt.Fprintf(os.Stderr, "Fetching offchain data for %s from %s\n", sender, displayEndpoint(gateway))
+		var resp *http.Response
+		var err error
+		if strings.Contains(gateway, "{data}") {
+			resp, err = httpClient.Get(target)
+		} else {
+			body, _ := json.Marshal(map[string]string{"data": data, "sender": sender})
+			resp, err = httpClient.Post(target, "application/json", bytes.NewReader(body))
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 16<<20))
+		resp.Body.Close()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", gateway, err))
+			continue
+		}
+		var result struct {
+			Data    *hexutil.Bytes `json:"data"`
+			Message string         `json:"message"`
+		}
+		decodeErr := json.Unmarshal(body, &result)
+		switch {
+		case resp.StatusCode >= 400 && resp.StatusCode < 500:
+			if decodeErr == nil && result.Message != "" {
+				return nil, fmt.Errorf("gateway %s refused the lookup: %s", gateway, result.Message)
+			}
+			return nil, fmt.Errorf("gateway %s refused the lookup: %s", gateway, resp.Status)
+		case resp.StatusCode != http.StatusOK:
+			failures = append(failures, fmt.Sprintf("%s: %s", gateway, resp.Status))
+		case decodeErr != nil || result.Data == nil:
+			failures = append(failures, fmt.Sprintf("%s: response has no hex data", gateway))
+		default:
+			return *result.Data, nil
+		}
+	}
+	if len(failures) == 0 {
+		return nil, fmt.Errorf("OffchainLookup of %s names no gateway", sender)
+	}
+	return nil, fmt.Errorf("offchain lookup failed on %s: %s", pluralize(len(lookup.urls), "gateway"), strings.Join(failures, "; "))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Chain ids of the networks that can be referred to by name
+var chainIDs = map[string]uint64{
+	"mainnet":        1,
+	"ethereum":       1,
+	"optimism":       10,
+	"bsc":            56,
+	"gnosis":         100,
+	"polygon":        137,
+	"zksync":         324,
+	"zksync-sepolia": 300,
+	"base":           8453,
+	"arbitrum":       42161,
+	"avalanche":      43114,
+	"linea":          59144,
+	"scroll":         534352,
+	"holesky":        17000,
+	"sepolia":        11155111,
+}
+
+// Function to parse a chain given by name or numeric id
+func parseChain(chain string) (uint64, error) {
+	chain = strings.ToLower(strings.TrimSpace(chain))
+	if id, ok := chainIDs[chain]; ok {
+		return id, nil
+	}
+	if id, err := strconv.ParseUint(chain, 10, 64); err == nil {
+		return id, nil
+	}
+	names := make([]string, 0, len(chainIDs))
+	for name := range chainIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown chain '%s', expected a chain id or one of %s", chain, strings.Join(names, ", "))
+}
+
+// Function to get the name of a chain id, or the id itself for unnamed chains
+func chainName(id uint64) string {
+	best := ""
+	for name, known := range chainIDs {
+		// Map order is random, so pick the same name every run when an id has several
+		if known == id && (best == "" || name > best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return strconv.FormatUint(id, 10)
+	}
+	return best
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences of the colors used in terminal output
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+// Whether output is colored: only on terminals, and never with NO_COLOR set or --no-color
+var colorEnabled = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+
+// Function to tell whether a file is a terminal rather than a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Function to wrap text in a color when output is colored
+func colorize(color, text string) string {
+	if !colorEnabled || text == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// Function to print decoded return values, as formatted by formatReturnValues, with their
+// values aligned in a column and the types and values colored
+func printReturnValues(lines []string, returnTypes []string, indent string) {
+	width := 0
+	for i, line := range lines {
+		if label := strings.TrimSpace(returnTypes[i]); !strings.HasPrefix(line, label+":\n") {
+			if n := utf8.RuneCountInString(label); n > width {
+				width = n
+			}
+		}
+	}
+	for i, line := range lines {
+		label := strings.TrimSpace(returnTypes[i])
+		value := strings.TrimPrefix(line, label+":")
+		if strings.HasPrefix(value, "\n") {
+			// Arrays and tuples are already laid out one element per line
+			fmt.Println(indent + colorize(colorCyan, label) + ":" + strings.ReplaceAll(value, "\n", "\n"+indent))
+			continue
+		}
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(label))
+		fmt.Println(indent + colorize(colorCyan, label) + ":" + padding + " " + colorize(colorGreen, strings.TrimPrefix(value, " ")))
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Config is the user configuration read from config.json in the config directory
+type Config struct {
+	Display DisplayConfig `json:"display"`
+	// Address labels keyed by address
+	Labels map[string]string `json:"labels"`
+	// Label files applied over the bundled labels, and the URL of the label dataset
+	// downloaded by labels update
+	LabelFiles []string `json:"labelFiles"`
+	LabelsURL  string   `json:"labelsUrl"`
+	// Addresses warned about wherever they are used or shown, like known phishing or
+	// drainer contracts, keyed by address with the reason they are listed for, and files
+	// listing more
+	Denylist      map[string]string `json:"denylist"`
+	DenylistFiles []string          `json:"denylistFiles"`
+	// Archive endpoint retried when a node no longer has the state of a historical call
+	ArchiveRpc string `json:"archiveRpc"`
+	// Named endpoint profiles, usable in place of an RPC URL
+	Endpoints map[string]EndpointConfig `json:"endpoints"`
+	// Default RPC URL or endpoint profile, and the chain whose profile is used otherwise
+	Rpc   string `json:"rpc"`
+	Chain string `json:"chain"`
+	// Beacon API endpoint of a consensus layer client
+	Beacon string `json:"beacon"`
+	// Contract aliases usable in place of addresses
+	Contracts map[string]ContractConfig `json:"contracts"`
+	// Gateways fetching the content of ipfs:// and ar:// URIs in results
+	Gateways GatewayConfig `json:"gateways"`
+	// Named sets of flag values selected with --template, e.g. for standing queries
+	Templates map[string]map[string]interface{} `json:"templates"`
+	// Plugins run on every JSON-RPC request before it is sent and on its response
+	Hooks HooksConfig `json:"hooks"`
+	// Policy files every transaction must pass before it is signed
+	Policies []string `json:"policies"`
+	// Audit log of the transactions sent
+	Audit AuditConfig `json:"audit"`
+	// Disable signing and broadcasting, like --read-only, e.g. on shared machines
+	ReadOnly bool `json:"readOnly"`
+
+	// Denylisted addresses of every source, loaded on first use
+	denied       map[string]string
+	denylistOnce sync.Once
+}
+
+// HooksConfig names the plugins intercepting JSON-RPC traffic, run in order. Pre-send hooks
+// get the request on stdin and may print a replacement, optionally preceded by HTTP header
+// lines and an empty line, e.g. to sign requests for an authenticated endpoint. Post-receive
+// hooks get the response and may print a replacement. A hook exiting with an error fails
+// the request.
+type HooksConfig struct {
+	PreSend     []string `json:"preSend"`
+	PostReceive []string `json:"postReceive"`
+}
+
+// GatewayConfig selects the HTTP gateways of content addressed storage
+type GatewayConfig struct {
+	IPFS    string `json:"ipfs"`
+	Arweave string `json:"arweave"`
+	// Largest document fetched, in bytes
+	MaxSize int64 `json:"maxSize"`
+}
+
+// ContractConfig is a named contract with the ABI used to decode its calls
+type ContractConfig struct {
+	Address string `json:"address"`
+	// ABI or artifact file, or a contract name resolved to its Foundry artifact
+	ABI string `json:"abi"`
+	// Addresses of the contract keyed by chain id, for contracts deployed on several chains
+	Deployments map[uint64]string `json:"deployments"`
+}
+
+// Function to parse a contract alias, which is either an object with an address, an ABI
+// and deployments, or just a map of chain names or ids to addresses, like
+// {"1": "0xa0b8...", "polygon": "0x2791..."}
+func (c *ContractConfig) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key, raw := range fields {
+		var err error
+		switch key {
+		case "address":
+			err = json.Unmarshal(raw, &c.Address)
+		case "abi":
+			err = json.Unmarshal(raw, &c.ABI)
+		case "deployments":
+			var deployments map[string]string
+			if err = json.Unmarshal(raw, &deployments); err == nil {
+				for chain, address := range deployments {
+					if err = c.addDeployment(chain, address); err != nil {
+						break
+					}
+				}
+			}
+		default:
+			var address string
+			if err = json.Unmarshal(raw, &address); err == nil {
+				err = c.addDeployment(key, address)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("contract field '%s': %v", key, err)
+		}
+	}
+	return nil
+}
+
+// Function to record the address of the contract on a chain given by name or id
+func (c *ContractConfig) addDeployment(chain, address string) error {
+	id, err := parseChain(chain)
+	if err != nil {
+		return err
+	}
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("invalid address '%s'", address)
+	}
+	if c.Deployments == nil {
+		c.Deployments = make(map[uint64]string)
+	}
+	c.Deployments[id] = address
+	return nil
+}
+
+// EndpointConfig describes an RPC endpoint and how hard it may be queried
+type EndpointConfig struct {
+	URL string `json:"url"`
+	// Requests per second allowed to the endpoint, 0 for no limit
+	RequestsPerSecond float64 `json:"rps"`
+	// Number of requests that may be sent at once before throttling starts
+	Burst int `json:"burst"`
+	// Endpoints of the same chain failed over to, in order, when the ones before fail or
+	// are unhealthy
+	Fallbacks []string `json:"fallbacks"`
+	// How requests are spread over the endpoints: failover (the default), round-robin,
+	// lowest-latency or sticky
+	Strategy string `json:"strategy"`
+	// Seconds between health checks of the endpoints while running, 0 for the default
+	HealthInterval int `json:"healthInterval"`
+	// Blocks an endpoint may trail the others by, and its slowest acceptable response in
+	// milliseconds, before it is unhealthy. 0 for the defaults.
+	MaxBlockLag  uint64 `json:"maxBlockLag"`
+	MaxLatencyMs int    `json:"maxLatencyMs"`
+}
+
+// Function to get the URLs of the endpoint, the primary one first
+func (e EndpointConfig) urls() []string {
+	var urls []string
+	if e.URL != "" {
+		urls = append(urls, e.URL)
+	}
+	return append(urls, e.Fallbacks...)
+}
+
+// Function to tell whether a URL is one of the endpoint's
+func (e EndpointConfig) serves(url string) bool {
+	for _, u := range e.urls() {
+		if u == url {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayConfig holds the default formatting of decoded values
+type DisplayConfig struct {
+	Bytes      string `json:"bytes"`
+	Uints      string `json:"uints"`
+	Decimals   int    `json:"decimals"`
+	Labels     *bool  `json:"labels"`
+	Timestamps string `json:"timestamps"`
+	Addresses  string `json:"addresses"`
+	Humanize   string `json:"humanize"`
+	Precision  *int   `json:"precision"`
+	// Fixed-point scales usable with --scale by name, in decimals
+	Scales map[string]int `json:"scales"`
+}
+
+// Function to get the directory holding the configuration and local state
+func configDir() string {
+	if dir := os.Getenv("CONTRACT_CURLER_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".contract-curler"
+	}
+	return filepath.Join(home, ".contract-curler")
+}
+
+// Project configuration looked up from the working directory upwards
+const projectConfigName = ".contractcurler"
+
+// Function to load the user configuration with the project configuration applied on top,
+// returning an empty one if neither exists
+func loadConfig() (*Config, error) {
+	cfg, err := readConfigFile(filepath.Join(configDir(), "config.json"))
+	if err != nil {
+		return nil, err
+	}
+	if path := findProjectConfig(); path != "" {
+		project, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		resolveProjectPaths(project, filepath.Dir(path))
+		cfg.merge(project)
+	}
+
+	labels := make(map[string]string, len(cfg.Labels))
+	for address, label := range cfg.Labels {
+		labels[strings.ToLower(address)] = label
+	}
+	cfg.Labels = labels
+	return cfg, nil
+}
+
+// Function to read a config file, returning an empty config if it does not exist
+func readConfigFile(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config '%s': %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Function to find the project config in the working directory or its parents,
+// stopping at the root of the repository
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Function to make the ABI paths of a project config absolute. A bare contract name
+// like "Token" refers to the Foundry artifact out/Token.sol/Token.json.
+func resolveProjectPaths(cfg *Config, dir string) {
+	for name, contract := range cfg.Contracts {
+		switch {
+		case contract.ABI == "":
+		case !strings.ContainsAny(contract.ABI, `/\`) && !strings.HasSuffix(contract.ABI, ".json"):
+			contract.ABI = filepath.Join(dir, "out", contract.ABI+".sol", contract.ABI+".json")
+		case !filepath.IsAbs(contract.ABI):
+			contract.ABI = filepath.Join(dir, contract.ABI)
+		}
+		cfg.Contracts[name] = contract
+	}
+	for i, path := range cfg.LabelFiles {
+		if !filepath.IsAbs(path) {
+			cfg.LabelFiles[i] = filepath.Join(dir, path)
+		}
+	}
+	for i, path := range cfg.Policies {
+		if !filepath.IsAbs(path) {
+			cfg.Policies[i] = filepath.Join(dir, path)
+		}
+	}
+	if cfg.Audit.Log != "" && !filepath.IsAbs(cfg.Audit.Log) {
+		cfg.Audit.Log = filepath.Join(dir, cfg.Audit.Log)
+	}
+	for i, path := range cfg.DenylistFiles {
+		if !filepath.IsAbs(path) {
+			cfg.DenylistFiles[i] = filepath.Join(dir, path)
+		}
+	}
+}
+
+// Function to apply the settings of another config over this one
+func (c *Config) merge(other *Config) {
+	c.Display.Bytes = firstNonEmpty(other.Display.Bytes, c.Display.Bytes)
+	c.Display.Uints = firstNonEmpty(other.Display.Uints, c.Display.Uints)
+	c.Display.Timestamps = firstNonEmpty(other.Display.Timestamps, c.Display.Timestamps)
+	c.Display.Addresses = firstNonEmpty(other.Display.Addresses, c.Display.Addresses)
+	c.Display.Humanize = firstNonEmpty(other.Display.Humanize, c.Display.Humanize)
+	if other.Display.Precision != nil {
+		c.Display.Precision = other.Display.Precision
+	}
+	if other.Display.Decimals != 0 {
+		c.Display.Decimals = other.Display.Decimals
+	}
+	if c.Display.Scales == nil {
+		c.Display.Scales = make(map[string]int)
+	}
+	for name, decimals := range other.Display.Scales {
+		c.Display.Scales[name] = decimals
+	}
+	if other.Display.Labels != nil {
+		c.Display.Labels = other.Display.Labels
+	}
+	c.ArchiveRpc = firstNonEmpty(other.ArchiveRpc, c.ArchiveRpc)
+	c.Rpc = firstNonEmpty(other.Rpc, c.Rpc)
+	c.Chain = firstNonEmpty(other.Chain, c.Chain)
+	c.Beacon = firstNonEmpty(other.Beacon, c.Beacon)
+	// Policies add up, so a project config cannot loosen those of the user config
+	c.Policies = append(c.Policies, other.Policies...)
+	c.Audit.Log = firstNonEmpty(other.Audit.Log, c.Audit.Log)
+	c.Audit.Syslog = firstNonEmpty(other.Audit.Syslog, c.Audit.Syslog)
+	// A project config can turn read-only mode on, never off
+	c.ReadOnly = c.ReadOnly || other.ReadOnly
+	c.LabelsURL = firstNonEmpty(other.LabelsURL, c.LabelsURL)
+	c.LabelFiles = append(c.LabelFiles, other.LabelFiles...)
+	c.DenylistFiles = append(c.DenylistFiles, other.DenylistFiles...)
+	if c.Denylist == nil {
+		c.Denylist = make(map[string]string)
+	}
+	for address, reason := range other.Denylist {
+		c.Denylist[address] = reason
+	}
+	c.Gateways.IPFS = firstNonEmpty(other.Gateways.IPFS, c.Gateways.IPFS)
+	c.Gateways.Arweave = firstNonEmpty(other.Gateways.Arweave, c.Gateways.Arweave)
+	if other.Gateways.MaxSize != 0 {
+		c.Gateways.MaxSize = other.Gateways.MaxSize
+	}
+
+	if c.Labels == nil {
+		c.Labels = make(map[string]string)
+	}
+	for address, label := range other.Labels {
+		c.Labels[address] = label
+	}
+	if c.Endpoints == nil {
+		c.Endpoints = make(map[string]EndpointConfig)
+	}
+	for name, endpoint := range other.Endpoints {
+		c.Endpoints[name] = endpoint
+	}
+	if c.Contracts == nil {
+		c.Contracts = make(map[string]ContractConfig)
+	}
+	for name, contract := range other.Contracts {
+		c.Contracts[name] = contract
+	}
+	if c.Templates == nil {
+		c.Templates = make(map[string]map[string]interface{})
+	}
+	for name, template := range other.Templates {
+		c.Templates[name] = template
+	}
+}
+
+// Function to look up a contract alias, case-insensitively
+func (c *Config) contract(name string) (ContractConfig, bool) {
+	for alias, contract := range c.Contracts {
+		if strings.EqualFold(alias, name) {
+			return contract, true
+		}
+	}
+	return ContractConfig{}, false
+}
+
+// Function to resolve a contract alias or address label to its address, leaving addresses
+// unchanged and converting those of other encodings like Tron's. Aliases deployed on
+// several chains resolve to their address on the given chain, the configured one or,
+// failing both, the chain of the endpoint.
+func (c *Config) resolveAddress(name, chain, rpcURL string) (common.Address, error) {
+	name = strings.TrimSpace(name)
+	if address, err := parseAddress(name); err == nil {
+		return address, nil
+	}
+	if contract, ok := c.contract(name); ok {
+		address := contract.Address
+		if len(contract.Deployments) > 0 {
+			chainID, err := c.chainID(chain, rpcURL)
+			if err != nil {
+				return common.Address{}, fmt.Errorf("cannot tell which deployment of '%s' to use: %v", name, err)
+			}
+			if deployed, ok := contract.Deployments[chainID]; ok {
+				address = deployed
+			} else if address == "" {
+				return common.Address{}, fmt.Errorf("contract '%s' has no deployment on %s", name, chainName(chainID))
+			}
+		}
+		if common.IsHexAddress(address) {
+			return common.HexToAddress(address), nil
+		}
+	}
+	for address, label := range c.Labels {
+		if strings.EqualFold(label, name) {
+			return common.HexToAddress(address), nil
+		}
+	}
+	return common.Address{}, fmt.Errorf("unknown contract '%s', expected an address, a contract alias or a label", name)
+}
+
+// Function to get the id of the chain given by name or id, the configured chain, or the
+// chain reported by the endpoint
+func (c *Config) chainID(chain, rpcURL string) (uint64, error) {
+	if chain = firstNonEmpty(chain, c.Chain); chain != "" {
+		return parseChain(chain)
+	}
+	var id hexutil.Uint64
+	if err := callRPC(rpcURL, &id, "eth_chainId"); err != nil {
+		return 0, fmt.Errorf("failed to get chain id: %v", err)
+	}
+	return uint64(id), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Amount argument of the token functions, shown in units of the token in summaries
+var tokenAmountArgs = map[string]int{
+	"transfer(address,uint256)":             1,
+	"transferFrom(address,address,uint256)": 2,
+	"approve(address,uint256)":              1,
+	"increaseAllowance(address,uint256)":    1,
+	"decreaseAllowance(address,uint256)":    1,
+}
+
+// How transactions are confirmed before they are sent, set by the commands sending them
+var confirmOptions struct {
+	// Set by --yes, sending without asking
+	yes bool
+	// Signature the calldata was encoded from and extra ABI files, naming its function
+	signature, abiPaths string
+}
+
+// Answers to confirmations, shared so lines typed ahead are not lost between prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// Function to show what a transaction does and have it confirmed before it is sent, unless
+// --yes was given. Every transaction goes through here: those broadcast once signed and
+// those handed to a wallet. A chain id of 0 is for transactions without replay protection.
+func confirmTransaction(rpcURL string, chainID uint64, from common.Address, tx *types.Transaction) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	printTransactionSummary(cfg, rpcURL, chainID, from, tx, confirmOptions.signature, confirmOptions.abiPaths)
+	if confirmOptions.yes {
+		return nil
+	}
+	return confirmTyped("Send this transaction?")
+}
+
+// Function to print what a transaction does before it is sent: chain, sender, target,
+// function with its decoded arguments, value and the most it can cost. The signature the
+// calldata was encoded from, if any, names the function when no ABI knows it.
+func printTransactionSummary(cfg *Config, rpcURL string, chainID uint64, from common.Address, tx *types.Transaction, signature, abiPaths string) {
+	opts := displayOptions(cfg).withChain(cfg, rpcURL)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Transaction summary")
+	if chainID == 0 {
+		fmt.Fprintf(w, "  Chain:\t%s\n", colorize(colorYellow, "any (no replay protection)"))
+	} else {
+		fmt.Fprintf(w, "  Chain:\t%s (%d)\n", chainName(chainID), chainID)
+	}
+	fmt.Fprintf(w, "  From:\t%s\n", formatAddress(from, opts))
+	if tx.To() == nil {
+		fmt.Fprintf(w, "  To:\t%s\n", colorize(colorYellow, "contract creation"))
+	} else {
+		fmt.Fprintf(w, "  To:\t%s\n", formatAddress(*tx.To(), opts))
+	}
+
+	if data := tx.Data(); len(data) >= 4 && tx.To() != nil {
+		decoder, err := configDecoder(cfg, abiPaths)
+		selector := "0x" + hex.EncodeToString(data[:4])
+		entry, known := abiEntry{}, false
+		if err == nil {
+			entry, known = decoder.function(selector)
+		}
+		if canonical, err := canonicalSignature(signature); !known && err == nil && "0x"+functionSelector(canonical) == selector {
+			entry, err = entryFromSignature(canonical)
+			known = err == nil
+		}
+		values, err := entry.decodeInputs(data[4:])
+		switch {
+		case !known:
+			fmt.Fprintf(w, "  Function:\t%s (unknown, %d bytes of arguments)\n", selector, len(data)-4)
+		case err != nil:
+			fmt.Fprintf(w, "  Function:\t%s (undecodable arguments: %v)\n", entry.signature(), err)
+		default:
+			fmt.Fprintf(w, "  Function:\t%s\n", entry.signature())
+			amountArg, isAmount := tokenAmountArgs[entry.signature()]
+			for i, value := range values {
+				// The name is shown in its own column rather than inline
+				param := entry.Inputs[i]
+				name := firstNonEmpty(param.Name, fmt.Sprintf("#%d", i))
+				param.Name = ""
+				rendered := formatInline(value, param, opts)
+				if amount, ok := value.(*big.Int); ok && isAmount && i == amountArg && tx.To() != nil {
+					rendered += tokenUnits(rpcURL, *tx.To(), amount)
+				}
+				fmt.Fprintf(w, "    %s:\t%s\n", name, rendered)
+			}
+		}
+	} else if len(data) > 0 {
+		fmt.Fprintf(w, "  Data:\t%d bytes\n", len(data))
+	}
+
+	fmt.Fprintf(w, "  Value:\t%s ETH\n", formatFixed(tx.Value(), 18))
+	fmt.Fprintf(w, "  Nonce:\t%d\n", tx.Nonce())
+	fmt.Fprintf(w, "  Gas limit:\t%d\n", tx.Gas())
+	if tx.Type() == types.LegacyTxType {
+		fmt.Fprintf(w, "  Gas price:\t%s gwei\n", formatFixed(tx.GasPrice(), 9))
+	} else {
+		fmt.Fprintf(w, "  Max fee:\t%s gwei (priority %s gwei)\n", formatFixed(tx.GasFeeCap(), 9), formatFixed(tx.GasTipCap(), 9))
+	}
+	maxCost := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	fmt.Fprintf(w, "  Max cost:\t%s ETH\n", formatFixed(maxCost.Add(maxCost, tx.Value()), 18))
+	w.Flush()
+}
+
+// Function to render a token amount in units of the token, like " (1.5 USDC)", empty when
+// the target is not a token. The unlimited approval amount is called out as such.
+func tokenUnits(rpcURL string, token common.Address, amount *big.Int) string {
+	if amount.Cmp(math.MaxBig256) == 0 {
+		return " " + colorize(colorYellow, "(unlimited)")
+	}
+	metadata, ok := lookupToken(rpcURL, token, "latest")
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (%s %s)", formatFixed(amount, *metadata.Decimals), firstNonEmpty(metadata.Symbol, "tokens"))
+}
+
+// Function to ask for a typed confirmation before something irreversible. Only yes typed in
+// full confirms, so a stray key press or a closed stdin never does.
+func confirmTyped(prompt string) error {
+	fmt.Printf("\n%s Type yes to confirm: ", prompt)
+	answer, _ := stdinReader.ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		if answer == "" {
+			fmt.Println()
+		}
+		return fmt.Errorf("aborted, nothing was sent (use --yes to skip the confirmation)")
+	}
+	return nil
+}
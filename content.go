@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Gateways used to fetch content addressed URIs unless configured otherwise
+const (
+	defaultIPFSGateway    = "https://ipfs.io/ipfs/"
+	defaultArweaveGateway = "https://arweave.net/"
+	// Largest document fetched, in bytes
+	defaultContentMaxSize = 1 << 20
+)
+
+// Function to get the directory caching fetched content. IPFS and Arweave content never
+// changes for a given URI, so cached entries never expire.
+func contentCacheDir() string {
+	return filepath.Join(configDir(), "content-cache")
+}
+
+// Function to check whether a string is a URI whose content can be fetched
+func isContentURI(s string) bool {
+	return strings.HasPrefix(s, "ipfs://") || strings.HasPrefix(s, "ar://")
+}
+
+// Function to map a content URI to its address on the configured gateway
+func gatewayURL(uri string, cfg *Config) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		path := strings.TrimPrefix(strings.TrimPrefix(uri, "ipfs://"), "ipfs/")
+		return strings.TrimSuffix(firstNonEmpty(cfg.Gateways.IPFS, defaultIPFSGateway), "/") + "/" + path, nil
+	case strings.HasPrefix(uri, "ar://"):
+		return strings.TrimSuffix(firstNonEmpty(cfg.Gateways.Arweave, defaultArweaveGateway), "/") + "/" + strings.TrimPrefix(uri, "ar://"), nil
+	}
+	return "", fmt.Errorf("unsupported URI '%s' (expected ipfs:// or ar://)", uri)
+}
+
+// Function to fetch the content behind an ipfs:// or ar:// URI, using the cache when possible
+func fetchContent(uri string, cfg *Config) ([]byte, error) {
+	sum := sha256.Sum256([]byte(uri))
+	cachePath := filepath.Join(contentCacheDir(), hex.EncodeToString(sum[:]))
+	if data, err := ioutil.ReadFile(cachePath); err == nil {
+		return data, nil
+	}
+
+	url, err := gatewayURL(uri, cfg)
+	if err != nil {
+		return nil, err
+	}
+	maxSize := cfg.Gateways.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultContentMaxSize
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("content of %s is %d bytes, over the limit of %d", uri, resp.ContentLength, maxSize)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", url, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("content of %s is over the limit of %d bytes", uri, maxSize)
+	}
+
+	// A failing cache only costs a refetch next time
+	if err := os.MkdirAll(contentCacheDir(), 0755); err == nil {
+		tmpPath := cachePath + ".tmp"
+		if ioutil.WriteFile(tmpPath, data, 0644) == nil {
+			os.Rename(tmpPath, cachePath)
+		}
+	}
+	return data, nil
+}
+
+// Function to fetch and print the content of every ipfs:// and ar:// URI in decoded values
+func printContentURIs(values []interface{}, cfg *Config) {
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, uri := range findContentURIs(jsonValue(value)) {
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			printContent(uri, cfg)
+		}
+	}
+}
+
+// Function to fetch and print the content of a URI, indenting JSON documents
+func printContent(uri string, cfg *Config) {
+	fmt.Printf("\n%s:\n", uri)
+	data, err := fetchContent(uri, cfg)
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		return
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "  ", "  "); err == nil {
+		fmt.Println("  " + indented.String())
+		return
+	}
+	fmt.Printf("  %d bytes of %s\n", len(data), http.DetectContentType(data))
+}
+
+// Function to collect the content URIs in a value converted by jsonValue
+func findContentURIs(value interface{}) []string {
+	var uris []string
+	switch v := value.(type) {
+	case string:
+		if isContentURI(v) {
+			uris = append(uris, v)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			uris = append(uris, findContentURIs(elem)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			uris = append(uris, findContentURIs(v[key])...)
+		}
+	}
+	return uris
+}
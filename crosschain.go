@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// chainCall is the outcome of one call of a fan-out over several chains
+type chainCall struct {
+	Chain   string
+	Address string
+	Values  []interface{}
+	Err     error
+	// Endpoint profile of the chain, the eth_call sent to it and the data it returned
+	rpcURL string
+	call   map[string]interface{}
+	raw    string
+}
+
+// Function to run the same call on several chains concurrently, each through the endpoint
+// profile of the chain and on the deployment of the contract registered for it
+func callOnChains(cfg *Config, chains []string, target, functionSig string, args []string, returnType string) []chainCall {
+	results := make([]chainCall, len(chains))
+	var wg sync.WaitGroup
+	for i, chain := range chains {
+		wg.Add(1)
+		go func(i int, chain string) {
+			defer wg.Done()
+			results[i] = callOnChain(cfg, chain, target, functionSig, args, returnType)
+		}(i, chain)
+	}
+	wg.Wait()
+	return results
+}
+
+// Function to run a call on a single chain of a fan-out
+func callOnChain(cfg *Config, chain, target, functionSig string, args []string, returnType string) chainCall {
+	result := prepareChainCall(cfg, chain, target, functionSig, args)
+	if result.Err != nil {
+		return result
+	}
+	var output string
+	if err := callRPC(result.rpcURL, &output, "eth_call", result.call, "latest"); err != nil {
+		result.Err = fmt.Errorf("eth_call failed: %v", err)
+		return result
+	}
+	result.raw = output
+	result.Values, result.Err = decodeReturnValues(output, returnType)
+	return result
+}
+
+// Function to resolve the endpoint, the contract and the arguments of a call on a chain,
+// leaving the eth_call to send
+func prepareChainCall(cfg *Config, chain, target, functionSig string, args []string) chainCall {
+	result := chainCall{Chain: chain}
+	// Falling back to the default endpoint would silently query the wrong chain
+	rpcURL := chainProfile(cfg, chain)
+	if rpcURL == "" {
+		result.Err = fmt.Errorf("no endpoint profile configured for chain %s", chain)
+		return result
+	}
+	address, err := cfg.resolveAddress(target, chain, rpcURL)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Address = address.Hex()
+
+	ctx, err := newArgContext(rpcURL, chain, "latest")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	resolved, err := ctx.resolveArgs(functionSig, args)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	data, err := encodeMethodCall(functionSig, resolved)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to encode call: %v", err)
+		return result
+	}
+	result.rpcURL = rpcURL
+	result.call = map[string]interface{}{"to": result.Address, "data": data}
+	return result
+}
+
+// Function to print the curl command of the call on each chain of a fan-out, for
+// --dry-run, returning false when the call could not be prepared on every chain
+func printChainCurls(cfg *Config, chains []string, target, functionSig string, args []string) bool {
+	ok := true
+	for _, chain := range chains {
+		result := prepareChainCall(cfg, chain, target, functionSig, args)
+		if result.Err != nil {
+			fmt.Printf("%s: %v\n", chain, result.Err)
+			ok = false
+			continue
+		}
+		jsonData, _ := json.Marshal(newRPCRequest("eth_call", []interface{}{result.call, "latest"}))
+		fmt.Printf("\nGenerated curl command for %s:\n%s\n", chain, curlCommand(result.rpcURL, jsonData))
+	}
+	return ok
+}
+
+// Function to get the output records of the chains of a fan-out that answered, the chain
+// taking the place of the pipeline step
+func chainRecords(cfg *Config, output *outputFlags, results []chainCall, functionSig string, returnTypeList []string, opts formatOptions) []resultRecord {
+	names := make([]string, len(returnTypeList))
+	for j, typStr := range returnTypeList {
+		if _, names[j] = splitTypeName(typStr); names[j] == "" && j < len(opts.Names) {
+			names[j] = opts.Names[j]
+		}
+	}
+	var records []resultRecord
+	for _, result := range results {
+		if result.Err != nil || len(result.Values) != len(returnTypeList) {
+			continue
+		}
+		formatted := formatReturnValues(result.Values, returnTypeList, opts.withChain(cfg, result.rpcURL))
+		values := newPluginResult(result.Address, functionSig, "latest", result.Values, returnTypeList, formatted, names)
+		records = append(records, output.record(result.Chain, values, result.raw))
+	}
+	return records
+}
+
+// Function to print the results of a fan-out as a table with a column per chain. Rows whose
+// values differ between the chains that answered are marked with a *.
+func printChainTable(results []chainCall, returnTypeList []string, opts formatOptions) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(marker, label string, cells []string) {
+		fmt.Fprintf(w, "%s %s\t%s\t\n", marker, label, strings.Join(cells, "\t"))
+	}
+
+	header := make([]string, len(results))
+	addresses := make([]string, len(results))
+	for i, result := range results {
+		header[i] = result.Chain
+		addresses[i] = result.Address
+	}
+	row(" ", "", header)
+	row(" ", "address", addresses)
+
+	for j, typStr := range returnTypeList {
+		typ, name := splitTypeName(typStr)
+		label := typ
+		if name == "" && j < len(opts.Names) {
+			name = opts.Names[j]
+		}
+		if name != "" {
+			label = name + " (" + typ + ")"
+		}
+		var variants []string
+		if j < len(opts.EnumVariants) {
+			variants = opts.EnumVariants[j]
+		}
+
+		cells := make([]string, len(results))
+		distinct := make(map[string]bool)
+		for i, result := range results {
+			switch {
+			case result.Err != nil:
+				cells[i] = "-"
+			case j >= len(result.Values):
+				cells[i] = "?"
+			default:
+				if abiType, err := newABIType(typ); err == nil && isNestedType(abiType) {
+					cells[i] = formatCompact(result.Values[j], abiType, opts)
+				} else {
+					cells[i] = formatValue(result.Values[j], typ, name, variants, opts)
+				}
+				distinct[cells[i]] = true
+			}
+		}
+		marker := " "
+		if len(distinct) > 1 {
+			marker = "*"
+		}
+		row(marker, label, cells)
+	}
+	w.Flush()
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%s: %v\n", result.Chain, result.Err)
+		}
+	}
+}
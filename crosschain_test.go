@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Function to point the endpoint profiles of chains at a fake endpoint answering every
+// eth_call with 42, counting the calls
+func fakeChainEndpoints(t *testing.T, chains ...string) (*Config, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		if request.Method == "eth_call" {
+			atomic.AddInt32(&calls, 1)
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(request.Id) + `,"result":"0x` + strings.Repeat("0", 62) + `2a"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	home := t.TempDir()
+	t.Setenv("CONTRACT_CURLER_HOME", home)
+	endpoints := make(map[string]EndpointConfig)
+	for _, chain := range chains {
+		endpoints[chain] = EndpointConfig{URL: server.URL}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"endpoints": endpoints})
+	if err := ioutil.WriteFile(filepath.Join(home, "config.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Profiles are loaded once per run, so load them again from this config, and from the
+	// one of the next test
+	endpointsOnce = sync.Once{}
+	t.Cleanup(func() { endpointsOnce = sync.Once{} })
+	return cfg, &calls
+}
+
+// Function to capture what a function prints to standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = saved }()
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(reader)
+		done <- data
+	}()
+	f()
+	writer.Close()
+	return string(<-done)
+}
+
+func TestPrintChainCurls(t *testing.T) {
+	cfg, calls := fakeChainEndpoints(t, "mainnet", "base")
+	const token = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	var ok bool
+	out := captureStdout(t, func() {
+		ok = printChainCurls(cfg, []string{"mainnet", "base"}, token, "balanceOf(address)", []string{token})
+	})
+	// A dry run prints the call of every chain, and sends none of them
+	if !ok || *calls != 0 {
+		t.Errorf("dry run returned %v after %d calls", ok, *calls)
+	}
+	for _, chain := range []string{"mainnet", "base"} {
+		if !strings.Contains(out, "Generated curl command for "+chain+":\ncurl -X POST ") {
+			t.Errorf("no curl command for %s in %q", chain, out)
+		}
+	}
+	if strings.Count(out, "curl -X POST http://127.0.0.1:") != 2 || strings.Count(out, `"method":"eth_call"`) != 2 || !strings.Contains(out, `"to":"`+token+`"`) {
+		t.Errorf("unexpected curl commands %q", out)
+	}
+
+	captureStdout(t, func() {
+		ok = printChainCurls(cfg, []string{"mainnet", "unknown"}, token, "balanceOf(address)", []string{token})
+	})
+	if ok {
+		t.Errorf("dry run on a chain without a profile succeeded")
+	}
+
+	results := callOnChains(cfg, []string{"mainnet", "base"}, token, "balanceOf(address)", []string{token}, "uint256")
+	if *calls != 2 {
+		t.Errorf("%d calls executed, want 2", *calls)
+	}
+	for _, result := range results {
+		if result.Err != nil || len(result.Values) != 1 {
+			t.Errorf("%s: %v %v", result.Chain, result.Values, result.Err)
+		}
+	}
+}
+
+func TestChainRecords(t *testing.T) {
+	cfg, _ := fakeChainEndpoints(t, "mainnet", "base")
+	const token = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	results := callOnChains(cfg, []string{"mainnet", "base", "unknown"}, token, "balanceOf(address)", []string{token}, "uint256 balance")
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	fs := flag.NewFlagSet("call", flag.ContinueOnError)
+	output := addOutputFlags(fs)
+	if err := fs.Parse([]string{"--out", path, "--out-raw"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.write(chainRecords(cfg, output, results, "balanceOf(address)", []string{"uint256 balance"}, formatOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []resultRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatal(err)
+	}
+	// One record per chain that answered, the chain without a profile left out
+	if len(records) != 2 {
+		t.Fatalf("wrote %d records, want 2: %s", len(records), data)
+	}
+	for i, chain := range []string{"mainnet", "base"} {
+		record := records[i]
+		if record.Step != chain || record.Contract != token || len(record.Values) != 1 || record.Values[0].Name != "balance" ||
+			record.Values[0].Value != "42" || record.Raw != "0x"+strings.Repeat("0", 62)+"2a" {
+			t.Errorf("record %d: %+v", i, record)
+		}
+	}
+}
+
+func TestChainCallsManifest(t *testing.T) {
+	cfg, _ := fakeChainEndpoints(t, "mainnet", "base")
+	path := filepath.Join(t.TempDir(), "manifest.jsonl")
+	enableManifest(path)
+	defer enableManifest("")
+	const token = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	callOnChains(cfg, []string{"mainnet", "base"}, token, "balanceOf(address)", []string{token}, "uint256")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry manifestEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(entry.Request), `"method":"eth_call"`) {
+			t.Errorf("recorded request %s", entry.Request)
+		}
+		endpoints[entry.Endpoint] = true
+	}
+	if len(endpoints) != 2 || !endpoints["mainnet"] || !endpoints["base"] {
+		t.Errorf("manifest records calls to %v", endpoints)
+	}
+}
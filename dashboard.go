@@ -0,0 +1,261 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Escape sequences moving the cursor home and clearing the terminal
+const clearScreen = "\x1b[H\x1b[2J"
+
+// dashboardSource is a batch file polled by the dashboard, with its encoded calls
+type dashboardSource struct {
+	batch *pipelineFile
+	calls *batchCalls
+	chain string
+}
+
+// dashboardCell is a value shown by the dashboard and when it last changed, zero if it
+// has not since the dashboard started
+type dashboardCell struct {
+	value   string
+	changed time.Time
+}
+
+// dashboardChange is a value that changed between two refreshes
+type dashboardChange struct {
+	row      []string
+	previous string
+}
+
+// Function to poll the calls of one or more batch files, on any chains, and show their
+// results in a table refreshed in place, highlighting the values that changed
+func runDashboard(args []string) error {
+	fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	interval := fs.Duration("interval", 5*time.Second, "time between refreshes")
+	once := fs.Bool("once", false, "show the table once and exit")
+	diff := fs.Bool("diff", false, "after the first table, print only the values that changed and by how much")
+	noColor := fs.Bool("no-color", false, "never color the output, even on a terminal")
+	fs.Parse(args)
+	if *noColor {
+		colorEnabled = false
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: contract-curler dashboard [flags] <file>... (one batch file per chain or endpoint)")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg)
+	var sources []dashboardSource
+	for _, path := range fs.Args() {
+		batch, err := loadPipeline(path)
+		if err != nil {
+			return err
+		}
+		batch.RpcURL = firstNonEmpty(batch.RpcURL, chainRPC(batch.Chain))
+		chainID, err := cfg.chainID(batch.Chain, batch.RpcURL)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		calls, err := prepareBatch(batch, "latest")
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		sources = append(sources, dashboardSource{batch: batch, calls: calls, chain: chainName(chainID)})
+	}
+
+	// Refreshing in place only makes sense on a terminal, elsewhere each refresh is appended
+	inPlace := isTerminal(os.Stdout) && !*once && !*diff
+	cells := make(map[string]*dashboardCell)
+	for first := true; ; first = false {
+		polled := time.Now()
+		rows := [][]string{{"CHAIN", "CALL", "OUTPUT", "VALUE", "CHANGED"}}
+		var changed []bool
+		var changes []dashboardChange
+		for _, source := range sources {
+			for _, row := range pollDashboardSource(source, opts) {
+				key := strings.Join(row[:3], "\x00")
+				cell, seen := cells[key]
+				if !seen {
+					cell = &dashboardCell{value: row[3]}
+					cells[key] = cell
+					if !first {
+						changes = append(changes, dashboardChange{row: row})
+					}
+				}
+				isChanged := seen && cell.value != row[3]
+				if isChanged {
+					changes = append(changes, dashboardChange{row: row, previous: cell.value})
+					cell.value, cell.changed = row[3], polled
+				}
+				age := ""
+				if !cell.changed.IsZero() && !deterministic {
+					age = polled.Sub(cell.changed).Round(time.Second).String() + " ago"
+				}
+				rows = append(rows, append(row, age))
+				changed = append(changed, isChanged)
+			}
+		}
+
+		if *diff && !first {
+			printDashboardChanges(polled, changes)
+			time.Sleep(time.Until(polled.Add(*interval)))
+			continue
+		}
+		if inPlace {
+			fmt.Print(clearScreen)
+		}
+		if !deterministic {
+			fmt.Printf("%s, every %s\n\n", polled.Format("15:04:05"), *interval)
+		}
+		printDashboard(rows, changed)
+		if *once {
+			return nil
+		}
+		if !inPlace {
+			fmt.Println()
+		}
+		time.Sleep(time.Until(polled.Add(*interval)))
+	}
+}
+
+// Function to run the calls of a batch file pinned to the latest block, returning a row per
+// output: chain, call, output name and value. Failed calls get a single row with the error.
+func pollDashboardSource(source dashboardSource, opts formatOptions) [][]string {
+	var rows [][]string
+	// All calls of a refresh see the same block
+	block, err := pinBlock(source.batch.RpcURL, "latest")
+	var results []callResult
+	if err == nil {
+		results, _, err = executeCalls(source.batch.RpcURL, block, source.calls.requests, "auto", 8)
+	}
+	if err != nil {
+		for _, name := range source.calls.names {
+			rows = append(rows, []string{source.chain, name, "", "error: " + err.Error()})
+		}
+		return rows
+	}
+	for i, result := range results {
+		name := source.calls.names[i]
+		values, err := decodeReturnValues(hexutil.Encode(result.Output), source.calls.returns[i])
+		if result.Err != nil {
+			err = result.Err
+		}
+		if err != nil {
+			rows = append(rows, []string{source.chain, name, "", "error: " + err.Error()})
+			continue
+		}
+		returnTypes := splitTypeList(trimTypeList(source.calls.returns[i]))
+		stepOpts := opts
+		stepOpts.Names = nil
+		for _, returnType := range returnTypes {
+			_, outputName := splitTypeName(returnType)
+			stepOpts.Names = append(stepOpts.Names, outputName)
+		}
+		for j, line := range formatReturnValues(values, returnTypes, stepOpts) {
+			label := strings.TrimSpace(returnTypes[j])
+			// Arrays and tuples are laid out over several lines, which a table cell cannot hold
+			value := strings.Join(strings.Fields(strings.TrimPrefix(line, label+":")), " ")
+			rows = append(rows, []string{source.chain, name, label, value})
+		}
+	}
+	return rows
+}
+
+// Function to print the dashboard table with aligned columns, highlighting changed values.
+// Columns are padded by hand since color codes would throw off a tabwriter.
+func printDashboard(rows [][]string, changed []bool) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	for r, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			padded := cell
+			if i < len(row)-1 {
+				padded += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			switch {
+			case r == 0:
+				padded = colorize(colorCyan, padded)
+			case i == 3 && changed[r-1]:
+				padded = colorize(colorYellow, padded)
+			case i == 3 && strings.HasPrefix(cell, "error: "):
+				padded = colorize(colorRed, padded)
+			}
+			line.WriteString(padded)
+		}
+		fmt.Println(strings.TrimRight(line.String(), " "))
+	}
+}
+
+// Function to print the values that changed in a refresh, one per line with the change of
+// numbers. Nothing is printed when no value changed.
+func printDashboardChanges(polled time.Time, changes []dashboardChange) {
+	if len(changes) == 0 {
+		return
+	}
+	if !deterministic {
+		fmt.Println(colorize(colorCyan, polled.Format("15:04:05")))
+	}
+	for _, change := range changes {
+		chain, call, output, value := change.row[0], change.row[1], change.row[2], change.row[3]
+		label := chain + " " + call
+		if output != "" {
+			label += " " + output
+		}
+		if change.previous == "" {
+			fmt.Printf("  %s: %s\n", label, colorize(colorYellow, value))
+			continue
+		}
+		line := fmt.Sprintf("  %s: %s → %s", label, change.previous, colorize(colorYellow, value))
+		if delta := numericDelta(change.previous, value); delta != "" {
+			line += " (" + delta + ")"
+		}
+		fmt.Println(line)
+	}
+}
+
+// Decimal numbers as formatted in a table cell, optionally grouped and followed by a unit
+var decimalCell = regexp.MustCompile(`^(-?[0-9][0-9,_]*(?:\.[0-9]+)?)((?: .*)?)$`)
+
+// Function to get the signed difference between two decimal values with the same unit,
+// or an empty string if either is not a number
+func numericDelta(previous, value string) string {
+	before, after := decimalCell.FindStringSubmatch(previous), decimalCell.FindStringSubmatch(value)
+	if before == nil || after == nil || before[2] != after[2] {
+		return ""
+	}
+	digits := 0
+	var numbers [2]*big.Rat
+	for i, text := range []string{before[1], after[1]} {
+		text = strings.NewReplacer(",", "", "_", "").Replace(text)
+		if _, frac, ok := strings.Cut(text, "."); ok {
+			digits = max(digits, len(frac))
+		}
+		numbers[i], _ = new(big.Rat).SetString(text)
+	}
+	delta := new(big.Rat).Sub(numbers[1], numbers[0])
+	text := delta.FloatString(digits)
+	if strings.Contains(before[1]+after[1], ",") {
+		text = groupThousands(text)
+	}
+	if delta.Sign() > 0 {
+		text = "+" + text
+	}
+	return text + after[2]
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// callDecoder names and decodes calldata using ABIs and the local signature database
+type callDecoder struct {
+	// Functions from the loaded ABIs keyed by 0x-prefixed selector
+	functions map[string]abiEntry
+	// Events from the loaded ABIs and the common token events, keyed by topic0
+	events map[string]abiEntry
+	sigdb  *signatureDB
+}
+
+// Events decoded even without an ABI
+var commonEvents = []string{
+	"Transfer(address indexed from, address indexed to, uint256 value)",
+	"Approval(address indexed owner, address indexed spender, uint256 value)",
+	"OwnershipTransferred(address indexed previousOwner, address indexed newOwner)",
+	"Deposit(address indexed dst, uint256 wad)",
+	"Withdrawal(address indexed src, uint256 wad)",
+}
+
+// Function to create a call decoder from ABI files and the local signature database
+func newCallDecoder(abiPaths []string) (*callDecoder, error) {
+	decoder := &callDecoder{functions: make(map[string]abiEntry), events: make(map[string]abiEntry)}
+	for _, decl := range commonEvents {
+		entry, err := eventFromDeclaration(decl)
+		if err != nil {
+			return nil, err
+		}
+		decoder.events[eventTopic(entry.signature())] = entry
+	}
+	for _, path := range abiPaths {
+		contract, err := loadABI(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder.addABI(contract)
+	}
+
+	db, err := loadSignatureDB()
+	if err != nil {
+		return nil, err
+	}
+	decoder.sigdb = db
+	return decoder, nil
+}
+
+// Function to build a call decoder from the ABIs of the configured contracts and extra files
+func configDecoder(cfg *Config, abiPaths string) (*callDecoder, error) {
+	var paths []string
+	if abiPaths != "" {
+		paths = strings.Split(abiPaths, ",")
+	}
+	names := make([]string, 0, len(cfg.Contracts))
+	for name := range cfg.Contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if path := cfg.Contracts[name].ABI; path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return newCallDecoder(paths)
+}
+
+// Function to make the functions and events of an ABI known to the decoder
+func (d *callDecoder) addABI(contract *contractABI) {
+	for _, entry := range contract.Entries {
+		switch entry.Type {
+		case "function":
+			d.functions["0x"+functionSelector(entry.signature())] = entry
+		case "event":
+			d.events[eventTopic(entry.signature())] = entry
+		}
+	}
+}
+
+// Function to find the function a selector belongs to, preferring ABIs over the signature database
+func (d *callDecoder) function(selector string) (abiEntry, bool) {
+	selector = strings.ToLower(selector)
+	if entry, ok := d.functions[selector]; ok {
+		return entry, true
+	}
+	if d.sigdb != nil {
+		// Only the first known signature is used when selectors collide
+		if signatures := d.sigdb.lookup(selector); len(signatures) > 0 {
+			if entry, err := entryFromSignature(signatures[0]); err == nil {
+				return entry, true
+			}
+		}
+	}
+	return abiEntry{}, false
+}
+
+// Function to build an unnamed-parameter ABI entry from a text signature
+func entryFromSignature(signature string) (abiEntry, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return abiEntry{}, fmt.Errorf("invalid signature '%s'", signature)
+	}
+	entry := abiEntry{Type: "function", Name: signature[:open]}
+	for _, typStr := range splitTypeList(signature[open+1 : len(signature)-1]) {
+		entry.Inputs = append(entry.Inputs, abiParam{Type: typStr})
+	}
+	return entry, nil
+}
+
+// Function to decode the arguments of calldata (without its selector) for a function
+func (e abiEntry) decodeInputs(data []byte) ([]interface{}, error) {
+	var arguments abi.Arguments
+	for _, input := range e.Inputs {
+		typ, err := newABIType(input.declaration())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse type '%s': %v", input.declaration(), err)
+		}
+		arguments = append(arguments, abi.Argument{Type: typ})
+	}
+	return arguments.Unpack(data)
+}
+
+// Function to describe calldata on one line, e.g. transfer(to: 0x..., amount: 5)
+func (d *callDecoder) describe(input []byte, opts formatOptions) string {
+	if len(input) == 0 {
+		return ""
+	}
+	if len(input) < 4 {
+		return "0x" + hex.EncodeToString(input)
+	}
+
+	selector := "0x" + hex.EncodeToString(input[:4])
+	entry, ok := d.function(selector)
+	if !ok {
+		return fmt.Sprintf("%s (unknown, %d bytes of arguments)", selector, len(input)-4)
+	}
+	values, err := entry.decodeInputs(input[4:])
+	if err != nil {
+		return fmt.Sprintf("%s (undecodable arguments: %v)", entry.signature(), err)
+	}
+
+	args := make([]string, len(values))
+	for i, value := range values {
+		args[i] = formatInline(value, entry.Inputs[i], opts)
+	}
+	return entry.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Function to describe a log on one line, e.g. Transfer(from: 0x..., to: 0x..., value: 5)
+func (d *callDecoder) describeLog(entry LogEntry, opts formatOptions) string {
+	if len(entry.Topics) == 0 {
+		return "anonymous log, data " + entry.Data
+	}
+	event, ok := d.events[strings.ToLower(entry.Topics[0])]
+	if !ok {
+		return fmt.Sprintf("%s (unknown event, %d topics)", entry.Topics[0], len(entry.Topics))
+	}
+	values, err := event.decodeLog(entry.Topics, entry.Data)
+	if err != nil {
+		return fmt.Sprintf("%s (undecodable: %v)", event.signature(), err)
+	}
+	args := make([]string, len(values))
+	for i, value := range values {
+		args[i] = formatInline(value, event.Inputs[i], opts)
+	}
+	return event.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Function to render a decoded argument on a single line, prefixed by its name when known
+func formatInline(value interface{}, param abiParam, opts formatOptions) string {
+	typStr := param.canonicalType()
+	var rendered string
+	if typ, err := newABIType(typStr); err == nil && isNestedType(typ) {
+		rendered = formatCompact(value, typ, opts)
+	} else {
+		rendered = formatValue(value, typStr, param.Name, nil, opts)
+	}
+	if param.Name != "" {
+		return param.Name + ": " + rendered
+	}
+	return rendered
+}
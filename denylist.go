@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Function to get the denylisted addresses of the configuration keyed by lowercase address,
+// with the reason each is listed for: the "denylist" entries and the addresses of the
+// "denylistFiles". Files are read once per run, and a file that cannot be read is reported
+// without stopping the command, as it only adds warnings.
+func (c *Config) denylist() map[string]string {
+	c.denylistOnce.Do(func() {
+		c.denied = make(map[string]string)
+		for _, path := range c.DenylistFiles {
+			entries, err := readDenylistFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			for address, reason := range entries {
+				c.denied[address] = reason
+			}
+		}
+		for address, reason := range c.Denylist {
+			c.denied[strings.ToLower(address)] = reason
+		}
+	})
+	return c.denied
+}
+
+// Function to read a denylist file: one address per line, optionally followed by the
+// reason it is listed for after a space, tab or comma. Empty lines and lines starting with
+// # are skipped, so published phishing lists can be used as they are.
+func readDenylistFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read denylist: %v", err)
+	}
+	defer file.Close()
+
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		address, reason := text, ""
+		if i := strings.IndexAny(text, " \t,"); i >= 0 {
+			address, reason = text[:i], strings.Trim(strings.TrimSpace(text[i+1:]), `"`)
+		}
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid address '%s' on line %d of denylist %s", address, line, path)
+		}
+		entries[strings.ToLower(common.HexToAddress(address).Hex())] = firstNonEmpty(reason, "listed in "+path)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read denylist %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// Function to warn on stderr when an address about to be used is denylisted, the role
+// telling how it is used, like "target" or "argument 2"
+func warnDenylisted(cfg *Config, address common.Address, role string) {
+	reason, ok := cfg.denylist()[strings.ToLower(address.Hex())]
+	if !ok {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: the %s %s is on the denylist: %s\n",
+		colorize(colorRed, "WARNING"), role, colorize(colorRed, address.Hex()), reason)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Addresses of the beacon chain deposit contract keyed by chain id
+var depositContracts = map[uint64]string{
+	1:        "0x00000000219ab540356cBB839Cbe05303d7705Fa",
+	17000:    "0x4242424242424242424242424242424242424242",
+	11155111: "0x7f02C3E3c98b133055B8B348B2Ac625669Ed295D",
+}
+
+// Genesis fork versions deposits are signed for, keyed by chain id
+var depositForkVersions = map[uint64]string{
+	1:        "00000000",
+	17000:    "01017000",
+	11155111: "90000069",
+}
+
+// Smallest deposit, and the largest one of validators with 0x00 or 0x01 withdrawal
+// credentials and of compounding 0x02 validators, in gwei
+const (
+	minDepositGwei            = 1000000000
+	maxDepositGwei            = 32000000000
+	maxCompoundingDepositGwei = 2048000000000
+)
+
+// depositData is an entry of the deposit_data.json file written by the staking deposit CLI
+type depositData struct {
+	Pubkey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                uint64 `json:"amount"`
+	Signature             string `json:"signature"`
+	DepositMessageRoot    string `json:"deposit_message_root"`
+	DepositDataRoot       string `json:"deposit_data_root"`
+	ForkVersion           string `json:"fork_version"`
+	NetworkName           string `json:"network_name"`
+}
+
+// Function to run the deposit subcommands for the beacon chain deposit contract: status,
+// reading its deposit count and root, and validate, checking a deposit_data.json file
+// before its deposits are sent
+func runDeposit(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler deposit status|validate [flags]")
+	}
+	fs := flag.NewFlagSet("deposit "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and the deposit contract")
+	address := fs.String("address", "", "address of the deposit contract (default: the one of the chain)")
+	block := fs.String("block", "latest", "block to query")
+	calldata := fs.Bool("calldata", false, "print the deposit call of each valid deposit")
+	fs.Parse(args[1:])
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	// Function to get the chain and its deposit contract
+	depositContract := func(chain string) (uint64, string, error) {
+		chainID, err := cfg.chainID(chain, endpoint)
+		if err != nil {
+			return 0, "", err
+		}
+		contract := firstNonEmpty(*address, depositContracts[chainID])
+		if contract == "" {
+			return 0, "", fmt.Errorf("no deposit contract known on %s, give its address with --address", chainName(chainID))
+		}
+		return chainID, contract, nil
+	}
+
+	switch args[0] {
+	case "status":
+		chainID, contract, err := depositContract(*chain)
+		if err != nil {
+			return err
+		}
+		blockParam, err := pinBlock(endpoint, *block)
+		if err != nil {
+			return err
+		}
+		count, err := callForValues(endpoint, contract, "0x"+functionSelector("get_deposit_count()"), blockParam, "(bytes)")
+		if err != nil {
+			return fmt.Errorf("failed to get deposit count: %v", err)
+		}
+		root, err := callForValues(endpoint, contract, "0x"+functionSelector("get_deposit_root()"), blockParam, "(bytes32)")
+		if err != nil {
+			return fmt.Errorf("failed to get deposit root: %v", err)
+		}
+		var balance hexutil.Big
+		if err := callRPC(endpoint, &balance, "eth_getBalance", contract, blockParam); err != nil {
+			return fmt.Errorf("failed to get balance: %v", err)
+		}
+		// The count is returned as 8 little-endian bytes, as the beacon chain encodes it
+		countBytes, _ := count[0].([]byte)
+		if len(countBytes) != 8 {
+			return fmt.Errorf("unexpected deposit count encoding %s", hexutil.Encode(countBytes))
+		}
+		rootBytes, _ := root[0].([32]byte)
+		fmt.Printf("Deposit contract: %s on %s, block %s\n", contract, chainName(chainID), blockParam)
+		fmt.Printf("Deposit count:    %d\n", binary.LittleEndian.Uint64(countBytes))
+		fmt.Printf("Deposit root:     %s\n", common.Hash(rootBytes).Hex())
+		fmt.Printf("Balance:          %s ETH\n", formatFixed(balance.ToInt(), 18))
+		return nil
+
+	case "validate":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: contract-curler deposit validate [flags] <deposit_data.json>")
+		}
+		data, err := ioutil.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		var deposits []depositData
+		if err := json.Unmarshal(data, &deposits); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", fs.Arg(0), err)
+		}
+		if len(deposits) == 0 {
+			return fmt.Errorf("%s holds no deposits", fs.Arg(0))
+		}
+		// Files name the network they were made for, so they can be checked offline
+		network := *chain
+		if _, err := parseChain(deposits[0].NetworkName); network == "" && err == nil {
+			network = deposits[0].NetworkName
+		}
+		chainID, contract, err := depositContract(network)
+		if err != nil {
+			return err
+		}
+		invalid := 0
+		total := new(big.Int)
+		seen := make(map[string]bool)
+		for i, deposit := range deposits {
+			problems := deposit.validate(chainID)
+			key := strings.ToLower(strings.TrimPrefix(deposit.Pubkey, "0x"))
+			if seen[key] {
+				problems = append(problems, "the public key is deposited for twice in the file")
+			}
+			seen[key] = true
+			if len(problems) > 0 {
+				invalid++
+				fmt.Printf("%s deposit %d (%s…)\n", colorize(colorRed, "✗"), i, shortHex(deposit.Pubkey))
+				for _, problem := range problems {
+					fmt.Println("    " + problem)
+				}
+				continue
+			}
+			total.Add(total, new(big.Int).SetUint64(deposit.Amount))
+			fmt.Printf("%s deposit %d (%s…) of %s ETH\n", colorize(colorGreen, "✓"), i, shortHex(deposit.Pubkey), formatFixed(new(big.Int).SetUint64(deposit.Amount), 9))
+			if *calldata {
+				encoded, err := encodeMethodCall("deposit(bytes,bytes,bytes,bytes32)", []string{
+					"0x" + strings.TrimPrefix(deposit.Pubkey, "0x"),
+					"0x" + strings.TrimPrefix(deposit.WithdrawalCredentials, "0x"),
+					"0x" + strings.TrimPrefix(deposit.Signature, "0x"),
+					"0x" + strings.TrimPrefix(deposit.DepositDataRoot, "0x"),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to encode deposit %d: %v", i, err)
+				}
+				fmt.Printf("    contract-curler send --to %s --value %sgwei --data %s\n", contract, new(big.Int).SetUint64(deposit.Amount), encoded)
+			}
+		}
+		fmt.Printf("\n%d of %s valid, %s ETH in total. Signatures are checked by the beacon chain, not here.\n",
+			len(deposits)-invalid, pluralize(len(deposits), "deposit"), formatFixed(total, 9))
+		if invalid > 0 {
+			return fmt.Errorf("%s invalid", pluralize(invalid, "deposit"))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown deposit subcommand '%s' (expected status or validate)", args[0])
+}
+
+// Function to check a deposit: the sizes of its fields, its withdrawal credentials and
+// amount, its fork version against the chain, and its roots against the ones computed
+// from its fields. Returns what is wrong with it.
+func (d *depositData) validate(chainID uint64) []string {
+	var problems []string
+	field := func(name, value string, size int) []byte {
+		b, err := hexutil.Decode("0x" + strings.TrimPrefix(value, "0x"))
+		if err != nil || len(b) != size {
+			problems = append(problems, fmt.Sprintf("%s is not %d bytes of hex", name, size))
+			return nil
+		}
+		return b
+	}
+	pubkey := field("pubkey", d.Pubkey, 48)
+	credentials := field("withdrawal_credentials", d.WithdrawalCredentials, 32)
+	signature := field("signature", d.Signature, 96)
+	messageRoot := field("deposit_message_root", d.DepositMessageRoot, 32)
+	dataRoot := field("deposit_data_root", d.DepositDataRoot, 32)
+
+	maxAmount := uint64(maxDepositGwei)
+	if credentials != nil {
+		switch credentials[0] {
+		case 0x00:
+		case 0x01, 0x02:
+			if !bytes.Equal(credentials[1:12], make([]byte, 11)) {
+				problems = append(problems, "withdrawal_credentials must have 11 zero bytes before the withdrawal address")
+			}
+			if credentials[0] == 0x02 {
+				maxAmount = maxCompoundingDepositGwei
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("unknown withdrawal credentials type 0x%02x", credentials[0]))
+		}
+	}
+	if d.Amount < minDepositGwei || d.Amount > maxAmount {
+		problems = append(problems, fmt.Sprintf("amount of %s ETH is outside 1 to %s ETH", formatFixed(new(big.Int).SetUint64(d.Amount), 9),
+			formatFixed(new(big.Int).SetUint64(maxAmount), 9)))
+	}
+	if want, ok := depositForkVersions[chainID]; ok && strings.TrimPrefix(d.ForkVersion, "0x") != want {
+		problems = append(problems, fmt.Sprintf("fork_version %s is not the one of %s (%s), the deposit would be lost", d.ForkVersion, chainName(chainID), want))
+	}
+
+	if pubkey != nil && credentials != nil {
+		if messageRoot != nil {
+			if root := depositMessageRoot(pubkey, credentials, d.Amount); !bytes.Equal(root[:], messageRoot) {
+				problems = append(problems, fmt.Sprintf("deposit_message_root does not match the deposit, expected %s", hexutil.Encode(root[:])))
+			}
+		}
+		if signature != nil && dataRoot != nil {
+			if root := depositDataRoot(pubkey, credentials, d.Amount, signature); !bytes.Equal(root[:], dataRoot) {
+				problems = append(problems, fmt.Sprintf("deposit_data_root does not match the deposit, the contract would reject it (expected %s)", hexutil.Encode(root[:])))
+			}
+		}
+	}
+	return problems
+}
+
+// Function to hash two 32 byte nodes of an SSZ Merkle tree
+func sszHash(left, right []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+	return sum[:]
+}
+
+// Function to get the SSZ hash tree roots of a BLS public key and of an amount in gwei
+func depositLeaves(pubkey []byte, amount uint64) (pubkeyRoot, amountLeaf []byte) {
+	pubkeyRoot = sszHash(pubkey[:32], append(append([]byte{}, pubkey[32:]...), make([]byte, 16)...))
+	amountLeaf = make([]byte, 32)
+	binary.LittleEndian.PutUint64(amountLeaf, amount)
+	return pubkeyRoot, amountLeaf
+}
+
+// Function to compute the hash tree root of the DepositMessage signed by the validator
+func depositMessageRoot(pubkey, credentials []byte, amount uint64) [32]byte {
+	pubkeyRoot, amountLeaf := depositLeaves(pubkey, amount)
+	var root [32]byte
+	copy(root[:], sszHash(sszHash(pubkeyRoot, credentials), sszHash(amountLeaf, make([]byte, 32))))
+	return root
+}
+
+// Function to compute the hash tree root of the DepositData, as the deposit contract does
+func depositDataRoot(pubkey, credentials []byte, amount uint64, signature []byte) [32]byte {
+	pubkeyRoot, amountLeaf := depositLeaves(pubkey, amount)
+	signatureRoot := sszHash(sszHash(signature[:32], signature[32:64]), sszHash(signature[64:], make([]byte, 32)))
+	var root [32]byte
+	copy(root[:], sszHash(sszHash(pubkeyRoot, credentials), sszHash(amountLeaf, signatureRoot)))
+	return root
+}
+
+// Function to shorten a hex string to its first bytes, for listing keys
+func shortHex(s string) string {
+	s = "0x" + strings.TrimPrefix(s, "0x")
+	if len(s) > 14 {
+		return s[:14]
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Names of the EVM opcodes, up to Cancun
+var opcodeNames = opcodeTable()
+
+// Function to build the opcode names, generating the numbered PUSH, DUP, SWAP and LOG families
+func opcodeTable() map[byte]string {
+	names := map[byte]string{
+		0x00: "STOP", 0x01: "ADD", 0x02: "MUL", 0x03: "SUB", 0x04: "DIV", 0x05: "SDIV", 0x06: "MOD",
+		0x07: "SMOD", 0x08: "ADDMOD", 0x09: "MULMOD", 0x0a: "EXP", 0x0b: "SIGNEXTEND",
+		0x10: "LT", 0x11: "GT", 0x12: "SLT", 0x13: "SGT", 0x14: "EQ", 0x15: "ISZERO", 0x16: "AND",
+		0x17: "OR", 0x18: "XOR", 0x19: "NOT", 0x1a: "BYTE", 0x1b: "SHL", 0x1c: "SHR", 0x1d: "SAR",
+		0x20: "KECCAK256",
+		0x30: "ADDRESS", 0x31: "BALANCE", 0x32: "ORIGIN", 0x33: "CALLER", 0x34: "CALLVALUE",
+		0x35: "CALLDATALOAD", 0x36: "CALLDATASIZE", 0x37: "CALLDATACOPY", 0x38: "CODESIZE",
+		0x39: "CODECOPY", 0x3a: "GASPRICE", 0x3b: "EXTCODESIZE", 0x3c: "EXTCODECOPY",
+		0x3d: "RETURNDATASIZE", 0x3e: "RETURNDATACOPY", 0x3f: "EXTCODEHASH",
+		0x40: "BLOCKHASH", 0x41: "COINBASE", 0x42: "TIMESTAMP", 0x43: "NUMBER", 0x44: "PREVRANDAO",
+		0x45: "GASLIMIT", 0x46: "CHAINID", 0x47: "SELFBALANCE", 0x48: "BASEFEE", 0x49: "BLOBHASH",
+		0x4a: "BLOBBASEFEE",
+		0x50: "POP", 0x51: "MLOAD", 0x52: "MSTORE", 0x53: "MSTORE8", 0x54: "SLOAD", 0x55: "SSTORE",
+		0x56: "JUMP", 0x57: "JUMPI", 0x58: "PC", 0x59: "MSIZE", 0x5a: "GAS", 0x5b: "JUMPDEST",
+		0x5c: "TLOAD", 0x5d: "TSTORE", 0x5e: "MCOPY", 0x5f: "PUSH0",
+		0xf0: "CREATE", 0xf1: "CALL", 0xf2: "CALLCODE", 0xf3: "RETURN", 0xf4: "DELEGATECALL",
+		0xf5: "CREATE2", 0xfa: "STATICCALL", 0xfd: "REVERT", 0xfe: "INVALID", 0xff: "SELFDESTRUCT",
+	}
+	for i := 0; i < 32; i++ {
+		names[byte(0x60+i)] = fmt.Sprintf("PUSH%d", i+1)
+	}
+	for i := 0; i < 16; i++ {
+		names[byte(0x80+i)] = fmt.Sprintf("DUP%d", i+1)
+		names[byte(0x90+i)] = fmt.Sprintf("SWAP%d", i+1)
+	}
+	for i := 0; i < 5; i++ {
+		names[byte(0xa0+i)] = fmt.Sprintf("LOG%d", i)
+	}
+	return names
+}
+
+// instruction is a disassembled opcode with its immediate argument
+type instruction struct {
+	pc  int
+	op  byte
+	arg []byte
+}
+
+// Function to get the mnemonic of an instruction, with its argument for pushes
+func (in instruction) String() string {
+	name, ok := opcodeNames[in.op]
+	if !ok {
+		name = fmt.Sprintf("UNKNOWN(0x%02x)", in.op)
+	}
+	if len(in.arg) > 0 {
+		return name + " 0x" + hex.EncodeToString(in.arg)
+	}
+	return name
+}
+
+// selectorEntry is a branch of the function dispatcher
+type selectorEntry struct {
+	selector string
+	target   int
+}
+
+// Function to disassemble contract code, list its jump destinations and extract the
+// selectors of its function dispatcher
+func runDisasm(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	block := fs.String("block", "latest", "block to read the code at")
+	selectorsOnly := fs.Bool("selectors", false, "only print the selector dispatch table")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler disasm [flags] <address | hex file>")
+	}
+	code, err := loadCode(fs.Arg(0), firstNonEmpty(*rpcURL, chainRPC(*chain)), *chain, *block)
+	if err != nil {
+		return err
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no code to disassemble")
+	}
+	code, metadata := splitMetadata(code)
+	instructions := disassemble(code)
+
+	if !*selectorsOnly {
+		for _, in := range instructions {
+			fmt.Printf("%06x  %s\n", in.pc, in)
+		}
+		if len(metadata) > 0 {
+			fmt.Printf("\n%d bytes of compiler metadata: 0x%s\n", len(metadata), hex.EncodeToString(metadata))
+		}
+		var dests []string
+		for _, in := range instructions {
+			if in.op == 0x5b {
+				dests = append(dests, fmt.Sprintf("0x%x", in.pc))
+			}
+		}
+		fmt.Printf("\nJump destinations (%d): %s\n\n", len(dests), strings.Join(dests, " "))
+	}
+
+	entries := dispatchTable(instructions)
+	if len(entries) == 0 {
+		fmt.Println("No selector dispatcher found")
+		return nil
+	}
+	db, err := loadSignatureDB()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Selectors (%d):\n", len(entries))
+	for _, entry := range entries {
+		line := fmt.Sprintf("  %s -> 0x%x", entry.selector, entry.target)
+		if signatures := db.lookup(entry.selector); len(signatures) > 0 {
+			line += "  " + strings.Join(signatures, " | ")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Function to read code from a file of hex, or from the chain for an address or alias
+func loadCode(arg, rpcURL, chain, block string) ([]byte, error) {
+	if data, err := ioutil.ReadFile(arg); err == nil {
+		code, err := hexutil.Decode("0x" + strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse code in %s: %v", arg, err)
+		}
+		return code, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", arg, err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	address, err := cfg.resolveAddress(arg, chain, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	var code hexutil.Bytes
+	if err := callRPC(rpcURL, &code, "eth_getCode", address.Hex(), block); err != nil {
+		return nil, fmt.Errorf("failed to get code of %s: %v", address.Hex(), err)
+	}
+	return code, nil
+}
+
+// Function to split the CBOR metadata solc and vyper append to runtime code. Its length is
+// stored in the last two bytes, and it is a map, so it starts with 0xa1 to 0xa5.
+func splitMetadata(code []byte) ([]byte, []byte) {
+	if len(code) < 2 {
+		return code, nil
+	}
+	length := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	start := len(code) - 2 - length
+	if length == 0 || start < 0 || code[start] < 0xa1 || code[start] > 0xa5 {
+		return code, nil
+	}
+	return code[:start], code[start:]
+}
+
+// Function to decode code into instructions. A push running past the end of the code
+// keeps the bytes that are there.
+func disassemble(code []byte) []instruction {
+	var instructions []instruction
+	for pc := 0; pc < len(code); pc++ {
+		in := instruction{pc: pc, op: code[pc]}
+		if in.op >= 0x60 && in.op <= 0x7f {
+			end := pc + 1 + int(in.op-0x5f)
+			if end > len(code) {
+				end = len(code)
+			}
+			in.arg = code[pc+1 : end]
+			pc = end - 1
+		}
+		instructions = append(instructions, in)
+	}
+	return instructions
+}
+
+// Function to find the branches of the function dispatcher: a PUSH4 selector compared with
+// EQ, followed by the push of a jump destination and a JUMPI. Comparisons with GT and LT
+// only split the dispatcher into halves, so they are not entries.
+func dispatchTable(instructions []instruction) []selectorEntry {
+	var entries []selectorEntry
+	seen := make(map[string]bool)
+	for i, in := range instructions {
+		if in.op != 0x63 || len(in.arg) != 4 {
+			continue
+		}
+		// solc emits PUSH4 EQ or PUSH4 DUP2 EQ before the destination
+		j := i + 1
+		if j < len(instructions) && instructions[j].op >= 0x80 && instructions[j].op <= 0x8f {
+			j++
+		}
+		if j+2 >= len(instructions) || instructions[j].op != 0x14 {
+			continue
+		}
+		push, jump := instructions[j+1], instructions[j+2]
+		if push.op < 0x60 || push.op > 0x7f || jump.op != 0x57 {
+			continue
+		}
+		selector := "0x" + hex.EncodeToString(in.arg)
+		if seen[selector] {
+			continue
+		}
+		seen[selector] = true
+		target := 0
+		for _, b := range push.arg {
+			target = target<<8 | int(b)
+		}
+		entries = append(entries, selectorEntry{selector: selector, target: target})
+	}
+	return entries
+}
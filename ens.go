@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/sha3"
+)
+
+// ENS registry, deployed at the same address on mainnet and the test networks
+const ensRegistry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+// NameWrapper of mainnet, which owns the registry entries of wrapped names
+const ensNameWrapper = "0xD4416b13d2b3a9aBae7AcD5D6C2BbDBE25686401"
+
+// ERC-165 interface id of ENSIP-10 extended resolvers, which answer for the names below
+// their own through resolve(bytes,bytes), often with an offchain lookup
+const extendedResolverInterface = "0x9061b923"
+
+// Multicodecs of the content hash formats
+const (
+	contentIPFS    = 0xe3
+	contentSwarm   = 0xe4
+	contentIPNS    = 0xe5
+	contentOnion   = 0x01bc
+	contentOnion3  = 0x01bd
+	contentArweave = 0xb29910
+)
+
+// Function to run the ENS subcommands: resolve, reverse, owner, resolver and content
+func runENS(args []string) error {
+	fs := flag.NewFlagSet("ens", flag.ExitOnError)
+	rpcURL := fs.String("rpc", defaultRPC(), "Ethereum RPC URL")
+	block := fs.String("block", "latest", "block to query")
+	fetch := fs.Bool("fetch", false, "fetch and print the content behind an ipfs:// or ar:// content hash")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: contract-curler ens [flags] resolve|reverse|owner|resolver|content <name or address>")
+	}
+	action, name := fs.Arg(0), strings.ToLower(strings.TrimSpace(fs.Arg(1)))
+	ens := &ensClient{rpcURL: *rpcURL, block: *block}
+
+	switch action {
+	case "resolve":
+		address, err := ens.resolve(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(address.Hex())
+	case "reverse":
+		address, err := parseAddress(name)
+		if err != nil {
+			return err
+		}
+		primary, err := ens.reverse(address)
+		if err != nil {
+			return err
+		}
+		fmt.Println(primary)
+		// Anyone can claim any name in their reverse record, so it only counts if it resolves back
+		if forward, err := ens.resolve(primary); err != nil || forward != address {
+			fmt.Printf("Warning: %s does not resolve back to %s\n", primary, address.Hex())
+		}
+	case "owner":
+		node := namehash(name)
+		values, err := ens.call(ensRegistry, "owner(bytes32)", []string{hexutil.Encode(node[:])}, "(address)")
+		if err != nil {
+			return err
+		}
+		owner := values[0].(common.Address)
+		if owner == common.HexToAddress(ensNameWrapper) {
+			tokenID := new(big.Int).SetBytes(node[:]).String()
+			values, err := ens.call(ensNameWrapper, "ownerOf(uint256)", []string{tokenID}, "(address)")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s (wrapped, registry owner is the NameWrapper)\n", values[0].(common.Address).Hex())
+			return nil
+		}
+		fmt.Println(owner.Hex())
+	case "resolver":
+		resolver, err := ens.resolver(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(resolver.Hex())
+	case "content":
+		resolver, err := ens.resolver(name)
+		if err != nil {
+			return err
+		}
+		node := namehash(name)
+		values, err := ens.query(resolver, name, "contenthash(bytes32)", []string{hexutil.Encode(node[:])}, "(bytes)")
+		if err != nil {
+			return err
+		}
+		hash := values[0].([]byte)
+		if len(hash) == 0 {
+			return fmt.Errorf("%s has no content hash", name)
+		}
+		uri, err := decodeContentHash(hash)
+		if err != nil {
+			return err
+		}
+		fmt.Println(uri)
+		if *fetch && isContentURI(uri) {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			printContent(uri, cfg)
+		}
+	default:
+		return fmt.Errorf("unknown ens action '%s' (expected resolve, reverse, owner, resolver or content)", action)
+	}
+	return nil
+}
+
+// ensClient queries the ENS registry and resolvers of an endpoint
+type ensClient struct {
+	rpcURL string
+	block  string
+}
+
+// Function to call an ENS contract and decode its results
+func (e *ensClient) call(to, signature string, args []string, returnTypes string) ([]interface{}, error) {
+	data, err := encodeMethodCall(signature, args)
+	if err != nil {
+		return nil, err
+	}
+	values, err := callForValues(e.rpcURL, to, data, e.block, returnTypes)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v", signature, err)
+	}
+	return values, nil
+}
+
+// Function to get the resolver of a name from the registry. Names without one of their
+// own are answered by the extended resolver of their closest parent, as ENSIP-10 wildcard
+// resolution has it.
+func (e *ensClient) resolver(name string) (common.Address, error) {
+	for parent := name; parent != ""; {
+		node := namehash(parent)
+		values, err := e.call(ensRegistry, "resolver(bytes32)", []string{hexutil.Encode(node[:])}, "(address)")
+		if err != nil {
+			return common.Address{}, err
+		}
+		resolver := values[0].(common.Address)
+		if resolver != (common.Address{}) {
+			if parent != name && !e.extended(resolver) {
+				break
+			}
+			return resolver, nil
+		}
+		dot := strings.Index(parent, ".")
+		if dot < 0 {
+			break
+		}
+		parent = parent[dot+1:]
+	}
+	return common.Address{}, fmt.Errorf("%s has no resolver", name)
+}
+
+// Function to tell whether a resolver is an ENSIP-10 extended resolver
+func (e *ensClient) extended(resolver common.Address) bool {
+	values, err := e.call(resolver.Hex(), "supportsInterface(bytes4)", []string{extendedResolverInterface}, "(bool)")
+	if err != nil {
+		return false
+	}
+	supported, _ := values[0].(bool)
+	return supported
+}
+
+// Function to query the resolver of a name, wrapping the query in resolve(bytes,bytes)
+// with the DNS-encoded name for extended resolvers
+func (e *ensClient) query(resolver common.Address, name, signature string, args []string, returnTypes string) ([]interface{}, error) {
+	if !e.extended(resolver) {
+		return e.call(resolver.Hex(), signature, args, returnTypes)
+	}
+	encodedName, err := dnsEncode(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeMethodCall(signature, args)
+	if err != nil {
+		return nil, err
+	}
+	values, err := e.call(resolver.Hex(), "resolve(bytes,bytes)", []string{hexutil.Encode(encodedName), data}, "(bytes)")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", signature, err)
+	}
+	values, err = decodeReturnValues(hexutil.Encode(values[0].([]byte)), returnTypes)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v", signature, err)
+	}
+	return values, nil
+}
+
+// Function to resolve a name to the address its resolver holds
+func (e *ensClient) resolve(name string) (common.Address, error) {
+	resolver, err := e.resolver(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	node := namehash(name)
+	values, err := e.query(resolver, name, "addr(bytes32)", []string{hexutil.Encode(node[:])}, "(address)")
+	if err != nil {
+		return common.Address{}, err
+	}
+	address := values[0].(common.Address)
+	if address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%s does not resolve to an address", name)
+	}
+	return address, nil
+}
+
+// Function to look up the primary name of an address in its reverse record
+func (e *ensClient) reverse(address common.Address) (string, error) {
+	reverseName := strings.ToLower(strings.TrimPrefix(address.Hex(), "0x")) + ".addr.reverse"
+	resolver, err := e.resolver(reverseName)
+	if err != nil {
+		return "", fmt.Errorf("%s has no reverse record", address.Hex())
+	}
+	node := namehash(reverseName)
+	values, err := e.query(resolver, reverseName, "name(bytes32)", []string{hexutil.Encode(node[:])}, "(string)")
+	if err != nil {
+		return "", err
+	}
+	name := values[0].(string)
+	if name == "" {
+		return "", fmt.Errorf("%s has no primary name", address.Hex())
+	}
+	return name, nil
+}
+
+// Function to compute the ENS namehash of a name. Names are only lowercased, so names
+// relying on the full ENSIP-15 normalisation must be given in their normalised form.
+func namehash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		labelHasher := sha3.NewLegacyKeccak256()
+		labelHasher.Write([]byte(labels[i]))
+		hasher := sha3.NewLegacyKeccak256()
+		hasher.Write(node[:])
+		hasher.Write(labelHasher.Sum(nil))
+		copy(node[:], hasher.Sum(nil))
+	}
+	return node
+}
+
+// Function to encode a name in the DNS wire format resolve(bytes,bytes) takes: each label
+// prefixed with its length, ending with the empty root label
+func dnsEncode(name string) ([]byte, error) {
+	var encoded []byte
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 255 {
+			return nil, fmt.Errorf("%s cannot be DNS-encoded, its labels must be 1 to 255 bytes", name)
+		}
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+	return append(encoded, 0), nil
+}
+
+// Function to decode an ENSIP-7 content hash into a URI like ipfs://Qm...
+func decodeContentHash(hash []byte) (string, error) {
+	codec, n := binary.Uvarint(hash)
+	if n <= 0 {
+		return "", fmt.Errorf("invalid content hash %s", hexutil.Encode(hash))
+	}
+	payload := hash[n:]
+	switch codec {
+	case contentIPFS:
+		return "ipfs://" + cidString(payload), nil
+	case contentIPNS:
+		return "ipns://" + cidString(payload), nil
+	case contentSwarm:
+		// CIDv1 of a swarm manifest with a 32-byte keccak multihash
+		if len(payload) < 32 {
+			return "", fmt.Errorf("invalid swarm content hash %s", hexutil.Encode(hash))
+		}
+		return "bzz://" + hexutil.Encode(payload[len(payload)-32:])[2:], nil
+	case contentArweave:
+		return "ar://" + base64.RawURLEncoding.EncodeToString(payload), nil
+	case contentOnion, contentOnion3:
+		return "onion://" + string(payload), nil
+	}
+	return "", fmt.Errorf("unsupported content hash codec 0x%x", codec)
+}
+
+// Function to render a binary CID, as CIDv0 for dag-pb sha2-256 content and in base32 otherwise
+func cidString(cid []byte) string {
+	if len(cid) == 36 && cid[0] == 0x01 && cid[1] == 0x70 && cid[2] == 0x12 && cid[3] == 0x20 {
+		return base58Encode(cid[2:])
+	}
+	return "b" + base32Lower.EncodeToString(cid)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Interface id of ERC-721, whose tokens also have name, symbol, totalSupply and balanceOf
+const erc721InterfaceID = "0x80ac58cd"
+
+// erc20Probe is what an address answered to the ERC-20 getters
+type erc20Probe struct {
+	name, symbol string
+	decimals     *big.Int
+	totalSupply  *big.Int
+	balance      *big.Int
+	allowance    bool
+	erc721       bool
+}
+
+// Function to check whether an address behaves like an ERC-20 token and print its metadata.
+// Many tokens predate ERC-165, so the getters themselves are probed.
+func runERC20(args []string) error {
+	fs := flag.NewFlagSet("erc20", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	block := fs.String("block", "latest", "block to query")
+	holder := fs.String("holder", "", "account whose balance is read (default: the zero address)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler erc20 [flags] <address>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	token, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	var account common.Address
+	if *holder != "" {
+		if account, err = cfg.resolveAddress(*holder, *chain, endpoint); err != nil {
+			return err
+		}
+	}
+
+	probe := probeERC20(endpoint, token, account, *block)
+	fmt.Println("Address:      " + formatAddress(token, opts))
+	fmt.Println("Name:         " + firstNonEmpty(probe.name, "-"))
+	fmt.Println("Symbol:       " + firstNonEmpty(probe.symbol, "-"))
+	decimals := 0
+	if probe.decimals != nil {
+		decimals = int(probe.decimals.Int64())
+		fmt.Printf("Decimals:     %d\n", decimals)
+	} else {
+		fmt.Println("Decimals:     -")
+	}
+	if probe.totalSupply != nil {
+		fmt.Printf("Total supply: %s\n", formatFixed(probe.totalSupply, decimals))
+	} else {
+		fmt.Println("Total supply: -")
+	}
+	if probe.balance != nil && *holder != "" {
+		fmt.Printf("Balance:      %s\n", formatFixed(probe.balance, decimals))
+	}
+
+	var missing []string
+	for getter, ok := range map[string]bool{
+		"decimals()": probe.decimals != nil, "totalSupply()": probe.totalSupply != nil,
+		"balanceOf(address)": probe.balance != nil, "allowance(address,address)": probe.allowance,
+	} {
+		if !ok {
+			missing = append(missing, getter)
+		}
+	}
+	switch {
+	case probe.erc721:
+		fmt.Println("\n✗ not an ERC-20: the contract reports ERC-721 support through ERC-165")
+	case len(missing) == 0:
+		fmt.Println("\n✓ behaves like an ERC-20")
+	case probe.decimals == nil && len(missing) == 1:
+		fmt.Println("\n~ behaves like an ERC-20 without decimals() (optional in the standard)")
+	default:
+		sort.Strings(missing)
+		fmt.Printf("\n✗ not an ERC-20: %s missing or malformed\n", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Function to call the ERC-20 getters of an address, tolerating the variants of early
+// tokens and ignoring the getters that fail
+func probeERC20(rpcURL string, token, holder common.Address, block string) erc20Probe {
+	var probe erc20Probe
+	probe.name, _ = callStringGetter(rpcURL, token.Hex(), "name()", block)
+	probe.symbol, _ = callStringGetter(rpcURL, token.Hex(), "symbol()", block)
+	// Read as uint256 so out of range values are caught rather than truncated
+	if decimals, ok := callUintGetter(rpcURL, token.Hex(), "decimals()", nil, block); ok && decimals.Cmp(big.NewInt(255)) <= 0 {
+		probe.decimals = decimals
+	}
+	probe.totalSupply, _ = callUintGetter(rpcURL, token.Hex(), "totalSupply()", nil, block)
+	probe.balance, _ = callUintGetter(rpcURL, token.Hex(), "balanceOf(address)", []string{holder.Hex()}, block)
+	_, probe.allowance = callUintGetter(rpcURL, token.Hex(), "allowance(address,address)", []string{holder.Hex(), holder.Hex()}, block)
+
+	data, err := encodeMethodCall("supportsInterface(bytes4)", []string{erc721InterfaceID})
+	if err == nil {
+		if values, err := callForValues(rpcURL, token.Hex(), data, block, "(bool)"); err == nil {
+			probe.erc721 = values[0].(bool)
+		}
+	}
+	return probe
+}
+
+// Function to call a getter returning a uint256, reporting false when it fails or does not
+// return a single word
+func callUintGetter(rpcURL, address, signature string, args []string, block string) (*big.Int, bool) {
+	data, err := encodeMethodCall(signature, args)
+	if err != nil {
+		return nil, false
+	}
+	var output string
+	call := map[string]interface{}{"to": address, "data": data}
+	if err := callRPC(rpcURL, &output, "eth_call", call, block); err != nil || len(output) != 66 {
+		return nil, false
+	}
+	values, err := decodeReturnValues(output, "(uint256)")
+	if err != nil {
+		return nil, false
+	}
+	return asBigInt(values[0])
+}
+
+// Function to call a getter returning a string, accepting the bytes32 returned by early
+// tokens like MKR
+func callStringGetter(rpcURL, address, signature, block string) (string, bool) {
+	data := "0x" + functionSelector(signature)
+	var output string
+	call := map[string]interface{}{"to": address, "data": data}
+	if err := callRPC(rpcURL, &output, "eth_call", call, block); err != nil {
+		return "", false
+	}
+	if len(output) == 66 {
+		values, err := decodeReturnValues(output, "(bytes32)")
+		if err != nil {
+			return "", false
+		}
+		raw := values[0].([32]byte)
+		text := strings.TrimRight(string(raw[:]), "\x00")
+		return text, utf8.ValidString(text)
+	}
+	values, err := decodeReturnValues(output, "(string)")
+	if err != nil {
+		return "", false
+	}
+	return values[0].(string), true
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Predeploys reporting the L1 data cost of L2 transactions
+const (
+	// GasPriceOracle of OP Stack chains like Optimism and Base
+	opGasPriceOracle = "0x420000000000000000000000000000000000000F"
+	// NodeInterface of Arbitrum chains, only reachable through eth_call
+	arbNodeInterface = "0x00000000000000000000000000000000000000C8"
+)
+
+// Chain ids of the Arbitrum chains
+var arbitrumChains = map[uint64]bool{42161: true, 42170: true, 421614: true}
+
+// l1DataFee is the part of the cost of an L2 transaction paid for posting it to L1
+type l1DataFee struct {
+	Fee    *big.Int
+	Source string
+	// Arbitrum charges the L1 cost as extra L2 gas, so it is already part of the gas estimate
+	InGasEstimate bool
+}
+
+// unsignedDynamicFeeTx is the RLP layout of an unsigned EIP-1559 transaction
+type unsignedDynamicFeeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+}
+
+// accessTuple is an entry of an EIP-2930 access list
+type accessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
+}
+
+// Function to estimate the gas and total cost of a call sent as a transaction,
+// including the L1 data fee on rollups
+func runEstimate(args []string) error {
+	fs := flag.NewFlagSet("estimate", flag.ExitOnError)
+	call := addCallFlags(fs)
+	fs.Parse(args)
+	rpcURL := call.endpoint()
+
+	callObject, err := call.callObject(fs.Args())
+	if err != nil {
+		return err
+	}
+	block, err := call.blockParam()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	chainID, err := cfg.chainID(*call.chain, rpcURL)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Chain: %s\n", chainName(chainID))
+	if zkSyncChains[chainID] {
+		return estimateZkSyncFee(rpcURL, callObject)
+	}
+	if _, ok := callObject["eip712Meta"]; ok {
+		return fmt.Errorf("paymasters are only supported on zkSync chains")
+	}
+
+	var gas hexutil.Uint64
+	if err := callRPC(rpcURL, &gas, "eth_estimateGas", callObject, block); err != nil {
+		return fmt.Errorf("eth_estimateGas failed: %v", err)
+	}
+	var gasPrice hexutil.Big
+	if err := callRPC(rpcURL, &gasPrice, "eth_gasPrice"); err != nil {
+		return fmt.Errorf("eth_gasPrice failed: %v", err)
+	}
+
+	price := (*big.Int)(&gasPrice)
+	total := new(big.Int).Mul(new(big.Int).SetUint64(uint64(gas)), price)
+	fmt.Printf("Gas: %d\n", gas)
+	fmt.Printf("Gas price: %s gwei\n", formatFixed(price, 9))
+
+	l1, err := estimateL1DataFee(rpcURL, chainID, callObject, uint64(gas), price, block)
+	if err != nil {
+		fmt.Printf("Warning: L1 data fee not included: %v\n", err)
+	}
+	if l1 == nil {
+		fmt.Printf("Total cost: %s ETH\n", formatFixed(total, 18))
+		return nil
+	}
+
+	if l1.InGasEstimate {
+		execution := new(big.Int).Sub(total, l1.Fee)
+		fmt.Printf("L2 execution fee: %s ETH\n", formatFixed(execution, 18))
+		fmt.Printf("L1 data fee: %s ETH (%s, included in the gas estimate)\n", formatFixed(l1.Fee, 18), l1.Source)
+	} else {
+		fmt.Printf("L2 execution fee: %s ETH\n", formatFixed(total, 18))
+		fmt.Printf("L1 data fee: %s ETH (%s)\n", formatFixed(l1.Fee, 18), l1.Source)
+		total.Add(total, l1.Fee)
+	}
+	fmt.Printf("Total cost: %s ETH\n", formatFixed(total, 18))
+	return nil
+}
+
+// Function to estimate the L1 data fee of a transaction, returning nil on chains without one
+func estimateL1DataFee(rpcURL string, chainID uint64, callObject map[string]interface{}, gas uint64, gasPrice *big.Int, block string) (*l1DataFee, error) {
+	data, _ := callObject["data"].(string)
+	to, _ := callObject["to"].(string)
+
+	if arbitrumChains[chainID] {
+		input, err := encodeMethodCall("gasEstimateL1Component(address,bool,bytes)", []string{to, "false", firstNonEmpty(data, "0x")})
+		if err != nil {
+			return nil, err
+		}
+		values, err := callForValues(rpcURL, arbNodeInterface, input, block, "(uint64,uint256,uint256)")
+		if err != nil {
+			return nil, fmt.Errorf("NodeInterface.gasEstimateL1Component failed: %v", err)
+		}
+		l1Gas, _ := asBigInt(values[0])
+		baseFee, _ := asBigInt(values[1])
+		return &l1DataFee{Fee: new(big.Int).Mul(l1Gas, baseFee), Source: "NodeInterface.gasEstimateL1Component", InGasEstimate: true}, nil
+	}
+
+	// OP Stack chains are recognised by their GasPriceOracle predeploy
+	var code string
+	if err := callRPC(rpcURL, &code, "eth_getCode", opGasPriceOracle, block); err != nil || len(code) <= 2 {
+		return nil, nil
+	}
+	tx, err := unsignedTransaction(rpcURL, chainID, callObject, gas, gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	input, err := encodeMethodCall("getL1Fee(bytes)", []string{hexutil.Encode(tx)})
+	if err != nil {
+		return nil, err
+	}
+	values, err := callForValues(rpcURL, opGasPriceOracle, input, block, "(uint256)")
+	if err != nil {
+		return nil, fmt.Errorf("GasPriceOracle.getL1Fee failed: %v", err)
+	}
+	fee, _ := asBigInt(values[0])
+	return &l1DataFee{Fee: fee, Source: "GasPriceOracle.getL1Fee"}, nil
+}
+
+// Function to serialise the transaction a call would be sent as, which is what the L1 fee
+// of OP Stack chains is charged on
+func unsignedTransaction(rpcURL string, chainID uint64, callObject map[string]interface{}, gas uint64, gasPrice *big.Int) ([]byte, error) {
+	tx := unsignedDynamicFeeTx{
+		ChainID:   new(big.Int).SetUint64(chainID),
+		GasTipCap: new(big.Int),
+		GasFeeCap: gasPrice,
+		Gas:       gas,
+		Value:     new(big.Int),
+	}
+	if to, ok := callObject["to"].(string); ok {
+		address := common.HexToAddress(to)
+		tx.To = &address
+	}
+	if data, ok := callObject["data"].(string); ok {
+		decoded, err := hexutil.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calldata: %v", err)
+		}
+		tx.Data = decoded
+	}
+	if value, ok := callObject["value"].(string); ok {
+		decoded, err := hexutil.DecodeBig(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s': %v", value, err)
+		}
+		tx.Value = decoded
+	}
+	if from, ok := callObject["from"].(string); ok {
+		var nonce hexutil.Uint64
+		if err := callRPC(rpcURL, &nonce, "eth_getTransactionCount", from, "pending"); err == nil {
+			tx.Nonce = uint64(nonce)
+		}
+	}
+
+	payload, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %v", err)
+	}
+	// Typed transaction envelope of EIP-1559 transactions
+	return append([]byte{0x02}, payload...), nil
+}
+
+// Function to run an eth_call with prepared calldata and decode its results
+func callForValues(rpcURL, to, data, block, returnTypes string) ([]interface{}, error) {
+	var output string
+	call := map[string]interface{}{"to": to, "data": data}
+	if err := callRPC(rpcURL, &output, "eth_call", call, block); err != nil {
+		return nil, err
+	}
+	if strings.TrimPrefix(output, "0x") == "" {
+		return nil, fmt.Errorf("empty result, %s is not deployed on this chain", to)
+	}
+	return decodeReturnValues(output, returnTypes)
+}
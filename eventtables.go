@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Table of the logs of events missing from the ABIs
+const unknownEventsTable = "unknown_events"
+
+// eventColumn is a column of an event table, typed by the ABI type of its values
+type eventColumn struct {
+	name    string
+	abiType string
+}
+
+// Columns every event table starts with, identifying the log
+var eventLogColumns = []eventColumn{
+	{"block_number", "int64"}, {"log_index", "int64"}, {"transaction_hash", "bytes32"}, {"block_hash", "bytes32"}, {"address", "address"},
+}
+
+// eventTables lays out logs as rows of tables: one per event of the ABIs, named after it,
+// and one for the logs of unknown events with their raw topics and data
+type eventTables struct {
+	decoder *callDecoder
+	// Table names in a stable order, and the table of each event keyed by topic0
+	names   []string
+	byTopic map[string]string
+	columns map[string][]eventColumn
+}
+
+// eventRow is a log laid out as a row, its values in the order of the table's columns
+type eventRow struct {
+	table  string
+	values []interface{}
+}
+
+// Function to lay out the events of the ABIs, along with the common token events, as tables
+func newEventTables(abiPaths []string) (*eventTables, error) {
+	decoder, err := newCallDecoder(abiPaths)
+	if err != nil {
+		return nil, err
+	}
+	t := &eventTables{decoder: decoder, byTopic: make(map[string]string), columns: make(map[string][]eventColumn)}
+
+	// Tables are named in an order not depending on the map, so an overloaded event gets
+	// the same table in every run
+	topics := make([]string, 0, len(decoder.events))
+	for topic := range decoder.events {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		return decoder.events[topics[i]].signature() < decoder.events[topics[j]].signature()
+	})
+	used := map[string]bool{unknownEventsTable: true}
+	for _, topic := range topics {
+		event := decoder.events[topic]
+		table := event.Name
+		if used[strings.ToLower(table)] {
+			table += "_" + topic[2:10]
+		}
+		used[strings.ToLower(table)] = true
+		t.byTopic[topic] = table
+		t.names = append(t.names, table)
+
+		columns := append([]eventColumn{}, eventLogColumns...)
+		taken := make(map[string]bool)
+		for _, column := range eventLogColumns {
+			taken[column.name] = true
+		}
+		for i, input := range event.Inputs {
+			name := input.Name
+			if name == "" {
+				name = "arg" + strconv.Itoa(i)
+			}
+			if taken[strings.ToLower(name)] {
+				name = "arg_" + name
+			}
+			taken[strings.ToLower(name)] = true
+			columns = append(columns, eventColumn{name, input.canonicalType()})
+		}
+		t.columns[table] = columns
+	}
+	t.names = append(t.names, unknownEventsTable)
+	t.columns[unknownEventsTable] = append(append([]eventColumn{}, eventLogColumns...), eventColumn{"topics", "string"}, eventColumn{"data", "string"})
+	return t, nil
+}
+
+// Function to lay out a log as a row of the table of its event, or of unknown_events if
+// its event is not known or its data does not decode
+func (t *eventTables) row(entry LogEntry) (eventRow, error) {
+	block, err := hexutil.DecodeUint64(entry.BlockNumber)
+	if err != nil {
+		return eventRow{}, fmt.Errorf("invalid log block number '%s'", entry.BlockNumber)
+	}
+	index, err := hexutil.DecodeUint64(entry.LogIndex)
+	if err != nil {
+		return eventRow{}, fmt.Errorf("invalid log index '%s'", entry.LogIndex)
+	}
+	values := []interface{}{block, index, entry.TransactionHash, entry.BlockHash, entry.Address}
+
+	if len(entry.Topics) > 0 {
+		topic := strings.ToLower(entry.Topics[0])
+		if event, ok := t.decoder.events[topic]; ok {
+			if decoded, err := event.decodeLog(entry.Topics, entry.Data); err == nil {
+				return eventRow{table: t.byTopic[topic], values: append(values, decoded...)}, nil
+			}
+		}
+	}
+	topics, _ := json.Marshal(entry.Topics)
+	return eventRow{table: unknownEventsTable, values: append(values, string(topics), entry.Data)}, nil
+}
+
+// Function to read a line of a log scan, which is either a reorg notice or a log
+func parseScanLine(line []byte) (*reorgNotice, *LogEntry, error) {
+	var notice reorgNotice
+	if err := json.Unmarshal(line, &notice); err == nil && notice.Type == "reorg" {
+		return &notice, nil, nil
+	}
+	var entry LogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode log: %v", err)
+	}
+	return nil, &entry, nil
+}
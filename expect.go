@@ -0,0 +1,304 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// callTracerFrame is a call frame returned by debug_traceCall with the callTracer
+type callTracerFrame struct {
+	Type  string            `json:"type"`
+	From  string            `json:"from"`
+	To    string            `json:"to"`
+	Error string            `json:"error"`
+	Logs  []tracerLog       `json:"logs"`
+	Calls []callTracerFrame `json:"calls"`
+}
+
+// tracerLog is a log emitted within a callTracer frame
+type tracerLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// eventCondition is a comparison on a decoded event argument, e.g. value>=1000
+type eventCondition struct {
+	Arg      string
+	Operator string
+	Expected string
+}
+
+// Function to simulate a call and fail unless it emits an event matching the given conditions
+func runExpectEvent(args []string) error {
+	fs := flag.NewFlagSet("expect-event", flag.ExitOnError)
+	call := addCallFlags(fs)
+	event := fs.String("event", "", "event declaration like 'Transfer(address indexed from,address indexed to,uint256 value)', or an event name with --abi")
+	abiPath := fs.String("abi", "", "ABI file to look the event up in")
+	emitter := fs.String("emitter", "", "only accept events emitted by this address")
+	where := fs.String("where", "", "comma-separated argument conditions like to=0x...,value>=1.5eth")
+	count := fs.Int("count", 1, "minimum number of matching events")
+	fs.Parse(args)
+
+	if *event == "" {
+		return fmt.Errorf("--event is required")
+	}
+	var entry abiEntry
+	if *abiPath != "" {
+		contract, err := loadABI(*abiPath)
+		if err != nil {
+			return err
+		}
+		found := contract.findEvent(*event)
+		if found == nil {
+			return fmt.Errorf("event '%s' not found in %s", *event, *abiPath)
+		}
+		entry = *found
+	} else {
+		parsed, err := eventFromDeclaration(*event)
+		if err != nil {
+			return err
+		}
+		entry = parsed
+	}
+
+	var conditions []eventCondition
+	if *where != "" {
+		for _, expr := range strings.Split(*where, ",") {
+			condition, err := parseEventCondition(expr)
+			if err != nil {
+				return err
+			}
+			if entry.inputIndex(condition.Arg) < 0 {
+				return fmt.Errorf("event %s has no argument '%s'", entry.Name, condition.Arg)
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+
+	callObject, err := call.callObject(fs.Args())
+	if err != nil {
+		return err
+	}
+	block, err := call.blockParam()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg)
+
+	var root callTracerFrame
+	tracer := map[string]interface{}{"tracer": "callTracer", "tracerConfig": map[string]interface{}{"withLog": true}}
+	if err := callRPC(call.endpoint(), &root, "debug_traceCall", callObject, block, tracer); err != nil {
+		return fmt.Errorf("debug_traceCall failed: %v", err)
+	}
+	if root.Error != "" {
+		return fmt.Errorf("simulated call reverted: %s", root.Error)
+	}
+
+	topic0 := eventTopic(entry.signature())
+	matched, seen := 0, 0
+	for _, log := range collectTracerLogs(root) {
+		if len(log.Topics) == 0 || !strings.EqualFold(log.Topics[0], topic0) {
+			continue
+		}
+		if *emitter != "" && !strings.EqualFold(log.Address, *emitter) {
+			continue
+		}
+		seen++
+		values, err := entry.decodeLog(log.Topics, log.Data)
+		if err != nil {
+			fmt.Printf("✗ %s from %s: undecodable (%v)\n", entry.Name, labelAddress(log.Address, opts), err)
+			continue
+		}
+
+		var failures []string
+		for _, condition := range conditions {
+			i := entry.inputIndex(condition.Arg)
+			ok, err := condition.check(values[i], entry.Inputs[i].canonicalType())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				failures = append(failures, fmt.Sprintf("%s%s%s", condition.Arg, condition.Operator, condition.Expected))
+			}
+		}
+
+		rendered := make([]string, len(values))
+		for i, value := range values {
+			rendered[i] = formatInline(value, entry.Inputs[i], opts)
+		}
+		description := fmt.Sprintf("%s(%s) from %s", entry.Name, strings.Join(rendered, ", "), labelAddress(log.Address, opts))
+		if len(failures) > 0 {
+			fmt.Printf("✗ %s: failed %s\n", description, strings.Join(failures, ", "))
+			continue
+		}
+		matched++
+		fmt.Printf("✓ %s\n", description)
+	}
+
+	if matched < *count {
+		return fmt.Errorf("expected %d matching %s event(s), found %d (%d emitted)", *count, entry.Name, matched, seen)
+	}
+	return nil
+}
+
+// Function to build an event entry from a declaration with indexed markers and argument names
+func eventFromDeclaration(decl string) (abiEntry, error) {
+	decl = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(decl), "event "))
+	open := strings.Index(decl, "(")
+	if open <= 0 || !strings.HasSuffix(decl, ")") {
+		return abiEntry{}, fmt.Errorf("invalid event declaration '%s'", decl)
+	}
+	entry := abiEntry{Type: "event", Name: decl[:open]}
+	for _, param := range splitTypeList(decl[open+1 : len(decl)-1]) {
+		typStr, name := splitTypeName(param)
+		indexed := strings.Contains(param[len(typStr):], " indexed")
+		if name == "indexed" {
+			name = ""
+		}
+		entry.Inputs = append(entry.Inputs, abiParam{Type: typStr, Name: name, Indexed: indexed})
+	}
+	return entry, nil
+}
+
+// Function to find an input by name or position, returning -1 if there is none
+func (e abiEntry) inputIndex(arg string) int {
+	for i, input := range e.Inputs {
+		if input.Name != "" && input.Name == arg {
+			return i
+		}
+	}
+	if i, err := strconv.Atoi(arg); err == nil && i >= 0 && i < len(e.Inputs) {
+		return i
+	}
+	return -1
+}
+
+// Function to decode the arguments of a log in declaration order. Indexed arguments of
+// dynamic types are only stored as their hash, which is returned as a bytes32.
+func (e abiEntry) decodeLog(topics []string, data string) ([]interface{}, error) {
+	var nonIndexed abi.Arguments
+	for _, input := range e.Inputs {
+		if input.Indexed {
+			continue
+		}
+		typ, err := newABIType(input.declaration())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse type '%s': %v", input.declaration(), err)
+		}
+		nonIndexed = append(nonIndexed, abi.Argument{Type: typ})
+	}
+	raw, err := hexutil.Decode(firstNonEmpty(data, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid log data: %v", err)
+	}
+	unpacked, err := nonIndexed.Unpack(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(e.Inputs))
+	topic := 1
+	for i, input := range e.Inputs {
+		if !input.Indexed {
+			values[i], unpacked = unpacked[0], unpacked[1:]
+			continue
+		}
+		if topic >= len(topics) {
+			return nil, fmt.Errorf("missing topic for indexed argument %d", i)
+		}
+		word := common.HexToHash(topics[topic])
+		topic++
+		typ, err := newABIType(input.canonicalType())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse type '%s': %v", input.canonicalType(), err)
+		}
+		switch typ.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			values[i] = word
+			continue
+		}
+		decoded, err := abi.Arguments{{Type: typ}}.Unpack(word.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		values[i] = decoded[0]
+	}
+	return values, nil
+}
+
+// Function to parse a condition like "amount>=1.5eth", trying two-character operators first
+func parseEventCondition(expr string) (eventCondition, error) {
+	expr = strings.TrimSpace(expr)
+	for i := 0; i < len(expr); i++ {
+		for _, op := range []string{">=", "<=", "!=", "=", ">", "<"} {
+			if strings.HasPrefix(expr[i:], op) {
+				arg, expected := strings.TrimSpace(expr[:i]), strings.TrimSpace(expr[i+len(op):])
+				if arg == "" || expected == "" {
+					return eventCondition{}, fmt.Errorf("invalid condition '%s'", expr)
+				}
+				return eventCondition{Arg: arg, Operator: op, Expected: expected}, nil
+			}
+		}
+	}
+	return eventCondition{}, fmt.Errorf("invalid condition '%s', expected e.g. value>=1000", expr)
+}
+
+// Function to check a decoded argument against a condition
+func (c eventCondition) check(value interface{}, typStr string) (bool, error) {
+	if n, ok := asBigInt(value); ok {
+		expected, err := parseValue(c.Expected)
+		if err != nil {
+			return false, fmt.Errorf("condition on '%s': %v", c.Arg, err)
+		}
+		cmp := n.Cmp(expected)
+		switch c.Operator {
+		case "=":
+			return cmp == 0, nil
+		case "!=":
+			return cmp != 0, nil
+		case ">":
+			return cmp > 0, nil
+		case ">=":
+			return cmp >= 0, nil
+		case "<":
+			return cmp < 0, nil
+		}
+		return cmp <= 0, nil
+	}
+
+	if c.Operator != "=" && c.Operator != "!=" {
+		return false, fmt.Errorf("condition on '%s': %s arguments only support = and !=", c.Arg, typStr)
+	}
+	var equal bool
+	if b, ok := asBytes(value); ok {
+		equal = strings.EqualFold(hexutil.Encode(b), c.Expected)
+	} else if address, ok := value.(common.Address); ok {
+		equal = strings.EqualFold(address.Hex(), c.Expected)
+	} else {
+		equal = fmt.Sprint(value) == c.Expected
+	}
+	return equal == (c.Operator == "="), nil
+}
+
+// Function to gather the logs of a frame and its callees, skipping frames that reverted
+func collectTracerLogs(frame callTracerFrame) []tracerLog {
+	if frame.Error != "" {
+		return nil
+	}
+	logs := frame.Logs
+	for _, child := range frame.Calls {
+		logs = append(logs, collectTracerLogs(child)...)
+	}
+	return logs
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// exportSink writes decoded logs to a file per event table in a directory, as CSV files
+// or as Parquet files whose columns are typed after the ABI
+type exportSink struct {
+	dir     string
+	format  string
+	tables  *eventTables
+	csv     map[string]*csv.Writer
+	files   map[string]*os.File
+	parquet map[string]*parquetWriter
+	// Highest block of the rows written out per table, which a reorg can no longer retract
+	written map[string]uint64
+}
+
+// Function to open a directory the logs of a scan are exported to
+func openExportSink(dir, format string, abiPaths []string) (logSink, error) {
+	tables, err := newEventTables(abiPaths)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	return &exportSink{dir: dir, format: format, tables: tables, csv: make(map[string]*csv.Writer),
+		files: make(map[string]*os.File), parquet: make(map[string]*parquetWriter), written: make(map[string]uint64)}, nil
+}
+
+// Function to export a log, or drop the rows a reorg notice invalidates where they are still
+// buffered. Rows already written out stay, with a warning to rescan.
+func (s *exportSink) write(line []byte) error {
+	notice, entry, err := parseScanLine(line)
+	if err != nil {
+		return err
+	}
+	if notice != nil {
+		stale := false
+		for table, writer := range s.parquet {
+			kept := writer.rows[:0]
+			for _, row := range writer.rows {
+				if notice.Reason == "removed log" && row[3] != notice.OldHash || notice.Reason != "removed log" && uint64(row[0].(int64)) < notice.Block {
+					kept = append(kept, row)
+				}
+			}
+			writer.rows = kept
+			stale = stale || s.written[table] >= notice.Block
+		}
+		for table := range s.csv {
+			stale = stale || s.written[table] >= notice.Block
+		}
+		if stale {
+			fmt.Fprintf(os.Stderr, "Warning: rows of block %d and later were already exported to %s before a reorg, rescan them\n", notice.Block, s.dir)
+		}
+		return nil
+	}
+	if entry.Removed {
+		return nil
+	}
+
+	row, err := s.tables.row(*entry)
+	if err != nil {
+		return err
+	}
+	columns := s.tables.columns[row.table]
+	block := row.values[0].(uint64)
+	if s.format == "csv" {
+		writer, err := s.csvWriter(row.table, columns)
+		if err != nil {
+			return err
+		}
+		record := make([]string, len(row.values))
+		for i, value := range row.values {
+			record[i] = exportText(value)
+		}
+		writer.Write(record)
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", s.files[row.table].Name(), err)
+		}
+		s.written[row.table] = max(s.written[row.table], block)
+		return nil
+	}
+
+	writer, err := s.parquetWriter(row.table, columns)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(row.values))
+	for i, value := range row.values {
+		values[i] = parquetValue(value, writer.columns[i])
+	}
+	flushed := len(writer.rows)+1 >= parquetRowGroupRows
+	if err := writer.add(values); err != nil {
+		return err
+	}
+	if flushed {
+		s.written[row.table] = max(s.written[row.table], block)
+	}
+	return nil
+}
+
+// Function to get the CSV file of a table, creating it with a header row, or appending
+// to it when a resumed scan finds it
+func (s *exportSink) csvWriter(table string, columns []eventColumn) (*csv.Writer, error) {
+	if writer, ok := s.csv[table]; ok {
+		return writer, nil
+	}
+	path := filepath.Join(s.dir, table+".csv")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	writer := csv.NewWriter(file)
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.name
+		}
+		writer.Write(header)
+	}
+	s.csv[table], s.files[table] = writer, file
+	return writer, nil
+}
+
+// Function to get the Parquet file of a table, creating it. Parquet files cannot be
+// appended to, so a resumed scan writes to a new numbered file next to the earlier ones.
+func (s *exportSink) parquetWriter(table string, columns []eventColumn) (*parquetWriter, error) {
+	if writer, ok := s.parquet[table]; ok {
+		return writer, nil
+	}
+	path := filepath.Join(s.dir, table+".parquet")
+	for n := 1; ; n++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(s.dir, table+"."+strconv.Itoa(n)+".parquet")
+	}
+	fileColumns := make([]parquetColumn, len(columns))
+	for i, column := range columns {
+		fileColumns[i] = parquetColumnOf(column)
+	}
+	writer, err := createParquet(path, fileColumns)
+	if err != nil {
+		return nil, err
+	}
+	s.parquet[table] = writer
+	return writer, nil
+}
+
+func (s *exportSink) Close() error {
+	var firstErr error
+	for _, file := range s.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, writer := range s.parquet {
+		if err := writer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Function to get the Parquet column of an event table column: integers of up to 64 bits
+// and booleans keep their type, wider integers and everything else are strings
+func parquetColumnOf(column eventColumn) parquetColumn {
+	switch {
+	case column.abiType == "bool":
+		return parquetColumn{column.name, parquetBoolean, parquetNoConversion}
+	case column.abiType == "uint64":
+		return parquetColumn{column.name, parquetInt64, parquetUint64}
+	case sqlColumnType(column.abiType) == "INTEGER":
+		return parquetColumn{column.name, parquetInt64, parquetNoConversion}
+	}
+	return parquetColumn{column.name, parquetByteArray, parquetUTF8}
+}
+
+// Function to convert a decoded value to the Go type of its Parquet column
+func parquetValue(value interface{}, column parquetColumn) interface{} {
+	switch column.physical {
+	case parquetBoolean:
+		return value.(bool)
+	case parquetInt64:
+		n, _ := asBigInt(value)
+		if column.converted == parquetUint64 {
+			// Unsigned values are stored as the bits of a signed one
+			return int64(n.Uint64())
+		}
+		return n.Int64()
+	}
+	return exportText(value)
+}
+
+// Function to render a decoded value as text: scalars as call arguments take them, arrays
+// and tuples as JSON
+func exportText(value interface{}) string {
+	if text, ok := templateValue(value); ok {
+		return text
+	}
+	return csvValue(value)
+}
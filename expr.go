@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Nested call in an argument, e.g. {{call: token.decimals() -> uint8}} or
+// {{call: 0x6b17...1d0f.balanceOf(address 0x1111...1111)}}
+var callPattern = regexp.MustCompile(`\{\{\s*call:\s*([^{}]+?)\s*\}\}`)
+
+// Largest exponent accepted by the ^ operator of argument expressions
+const maxExponent = 1024
+
+// argContext resolves the expressions allowed in call arguments: references to earlier
+// results, nested calls and integer arithmetic like 2*10^{{decimals}}
+type argContext struct {
+	rpcURL string
+	// Chain whose deployments contract aliases resolve to, the configured one if empty
+	chain string
+	block string
+	// Config whose contract aliases and labels may be used as call targets
+	cfg *Config
+	// Earlier results keyed by name, then by output name and index
+	results map[string]map[string]string
+}
+
+// Function to create an argument context using the contract aliases and labels of the config
+func newArgContext(rpcURL, chain, block string) (*argContext, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &argContext{rpcURL: rpcURL, chain: chain, block: block, cfg: cfg, results: make(map[string]map[string]string)}, nil
+}
+
+// Function to resolve the arguments of a call to plain values ready for encoding
+func (c *argContext) resolveArgs(methodSig string, args []string) ([]string, error) {
+	var paramTypes []string
+	if open := strings.Index(methodSig, "("); open >= 0 && strings.HasSuffix(methodSig, ")") {
+		paramTypes = splitTypeList(methodSig[open+1 : len(methodSig)-1])
+	}
+	resolved := make([]string, len(args))
+	for i, arg := range args {
+		paramType := ""
+		if i < len(paramTypes) {
+			paramType, _ = splitTypeName(paramTypes[i])
+		}
+		value, err := c.resolve(arg, paramType)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i+1, err)
+		}
+		if paramType == "address" && common.IsHexAddress(value) {
+			warnDenylisted(c.cfg, common.HexToAddress(value), fmt.Sprintf("argument %d", i+1))
+		}
+		resolved[i] = value
+	}
+	return resolved, nil
+}
+
+// Function to resolve a single argument, evaluating arithmetic for integer parameters
+func (c *argContext) resolve(arg, paramType string) (string, error) {
+	expanded, err := c.expand(arg)
+	if err != nil {
+		return "", err
+	}
+	if (strings.HasPrefix(paramType, "uint") || strings.HasPrefix(paramType, "int")) && isArithmetic(expanded) {
+		n, err := evalArithmetic(expanded)
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate '%s': %v", expanded, err)
+		}
+		return n.String(), nil
+	}
+	return expanded, nil
+}
+
+// Function to replace the references and nested calls of a value by their results.
+// References are expanded first so they can be used inside nested calls.
+func (c *argContext) expand(s string) (string, error) {
+	s, err := expandTemplate(s, c.results)
+	if err != nil {
+		return "", err
+	}
+	var callErr error
+	s = callPattern.ReplaceAllStringFunc(s, func(match string) string {
+		value, err := c.evalCall(callPattern.FindStringSubmatch(match)[1])
+		if err != nil {
+			callErr = fmt.Errorf("%s: %v", match, err)
+			return match
+		}
+		return value
+	})
+	return s, callErr
+}
+
+// Function to run a nested call like "token.balanceOf(address 0x...) -> uint256".
+// The return type defaults to uint256 and the target may be a contract alias or label.
+func (c *argContext) evalCall(expr string) (string, error) {
+	returnType := "uint256"
+	if i := strings.LastIndex(expr, "->"); i >= 0 {
+		expr, returnType = strings.TrimSpace(expr[:i]), strings.TrimSpace(expr[i+2:])
+	}
+	open := strings.Index(expr, "(")
+	if open < 0 || !strings.HasSuffix(expr, ")") {
+		return "", fmt.Errorf("expected target.function(type value, ...)")
+	}
+	dot := strings.LastIndex(expr[:open], ".")
+	if dot <= 0 {
+		return "", fmt.Errorf("expected target.function(type value, ...)")
+	}
+	target, err := c.target(strings.TrimSpace(expr[:dot]))
+	if err != nil {
+		return "", err
+	}
+
+	var types, values []string
+	for _, param := range splitTypeList(expr[open+1 : len(expr)-1]) {
+		typStr, value := splitTypeName(param)
+		if value == "" {
+			return "", fmt.Errorf("argument '%s' needs a type and a value", param)
+		}
+		resolved, err := c.resolve(value, typStr)
+		if err != nil {
+			return "", err
+		}
+		types = append(types, typStr)
+		values = append(values, resolved)
+	}
+
+	signature := strings.TrimSpace(expr[dot+1:open]) + "(" + strings.Join(types, ",") + ")"
+	data, err := encodeMethodCall(signature, values)
+	if err != nil {
+		return "", err
+	}
+	var output string
+	call := map[string]interface{}{"to": target.Hex(), "data": data}
+	if err := callRPC(c.rpcURL, &output, "eth_call", call, c.block); err != nil {
+		return "", fmt.Errorf("eth_call failed: %v", err)
+	}
+	decoded, err := decodeReturnValues(output, "("+returnType+")")
+	if err != nil {
+		return "", err
+	}
+	value, ok := templateValue(decoded[0])
+	if !ok {
+		return "", fmt.Errorf("%s results cannot be used as arguments", returnType)
+	}
+	return value, nil
+}
+
+// Function to resolve a call target given as an address, a contract alias or a label
+func (c *argContext) target(name string) (common.Address, error) {
+	address, err := c.cfg.resolveAddress(name, c.chain, c.rpcURL)
+	if err == nil {
+		warnDenylisted(c.cfg, address, "target")
+	}
+	return address, err
+}
+
+// Function to check whether an integer argument is an expression rather than a literal
+func isArithmetic(s string) bool {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") && !strings.ContainsAny(s, "+-*/^() ") {
+		return false
+	}
+	return strings.ContainsAny(strings.TrimPrefix(s, "-"), "+-*/^().eE")
+}
+
+// Function to evaluate an integer expression with + - * / ^ and parentheses.
+// Decimals like 1.5 are allowed as long as the result is a whole number.
+func evalArithmetic(expr string) (*big.Int, error) {
+	p := &exprParser{input: strings.ReplaceAll(expr, " ", "")}
+	value, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected '%s'", p.input[p.pos:])
+	}
+	if !value.IsInt() {
+		return nil, fmt.Errorf("result %s is not a whole number", value.FloatString(18))
+	}
+	return new(big.Int).Set(value.Num()), nil
+}
+
+// exprParser is a recursive descent parser over exact rationals
+type exprParser struct {
+	input string
+	pos   int
+}
+
+// Function to get the next character without consuming it, or 0 at the end
+func (p *exprParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// Function to parse a sum or difference of products
+func (p *exprParser) sum() (*big.Rat, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '+' || p.peek() == '-' {
+		op := p.peek()
+		p.pos++
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		if op == '+' {
+			left.Add(left, right)
+		} else {
+			left.Sub(left, right)
+		}
+	}
+	return left, nil
+}
+
+// Function to parse a product or quotient of powers
+func (p *exprParser) product() (*big.Rat, error) {
+	left, err := p.power()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '*' || p.peek() == '/' {
+		op := p.peek()
+		p.pos++
+		right, err := p.power()
+		if err != nil {
+			return nil, err
+		}
+		if op == '*' {
+			left.Mul(left, right)
+		} else {
+			if right.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			left.Quo(left, right)
+		}
+	}
+	return left, nil
+}
+
+// Function to parse a right-associative power with a whole, non-negative exponent
+func (p *exprParser) power() (*big.Rat, error) {
+	base, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.power()
+	if err != nil {
+		return nil, err
+	}
+	if !exponent.IsInt() || exponent.Sign() < 0 || exponent.Num().Cmp(big.NewInt(maxExponent)) > 0 {
+		return nil, fmt.Errorf("exponent must be a whole number between 0 and %d", maxExponent)
+	}
+	e := exponent.Num()
+	num := new(big.Int).Exp(base.Num(), e, nil)
+	denom := new(big.Int).Exp(base.Denom(), e, nil)
+	return new(big.Rat).SetFrac(num, denom), nil
+}
+
+// Function to parse a signed number or parenthesised expression
+func (p *exprParser) unary() (*big.Rat, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return value.Neg(value), nil
+	case '(':
+		p.pos++
+		value, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return value, nil
+	}
+
+	start := p.pos
+	if strings.HasPrefix(p.input[p.pos:], "0x") {
+		p.pos += 2
+		for p.pos < len(p.input) && strings.IndexByte("0123456789abcdefABCDEF", p.input[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, ok := new(big.Int).SetString(p.input[start+2:p.pos], 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid hex number '%s'", p.input[start:p.pos])
+		}
+		return new(big.Rat).SetInt(n), nil
+	}
+	for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	// Scientific notation like 1.5e18
+	if p.pos > start && (p.peek() == 'e' || p.peek() == 'E') {
+		p.pos++
+		for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	if p.pos == start {
+		if p.pos < len(p.input) {
+			return nil, fmt.Errorf("unexpected '%c'", p.input[p.pos])
+		}
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	value, ok := new(big.Rat).SetString(p.input[start:p.pos])
+	if !ok {
+		return nil, fmt.Errorf("invalid number '%s'", p.input[start:p.pos])
+	}
+	return value, nil
+}
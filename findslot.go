@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// slotCandidate is a storage slot that may hold the value looked for, and how it is derived
+type slotCandidate struct {
+	slot   common.Hash
+	layout string
+}
+
+// Function to find the storage slots of a contract holding a known value, trying the first
+// slots directly and as the base of mappings indexed by the given keys, so the value can
+// be overridden in calls or set with a cheat code
+func runFindSlot(args []string) error {
+	fs := flag.NewFlagSet("find-slot", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting its endpoint profile and contract deployments")
+	block := fs.String("block", "latest", "block to read the storage at")
+	slots := fs.Int("slots", 64, "number of base slots tried, from slot 0")
+	concurrency := fs.Int("concurrency", 8, "storage reads in flight at once")
+	keyList := fs.String("key", "", "comma-separated mapping keys, with the keys of nested mappings joined by / like owner/spender")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: contract-curler find-slot [flags] <contract> <value>")
+	}
+	if *slots < 1 || *concurrency < 1 {
+		return fmt.Errorf("slots and concurrency must be at least 1")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	contract, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	value, err := storageWord(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid value '%s': %v", fs.Arg(1), err)
+	}
+	var keys []string
+	if *keyList != "" {
+		keys = strings.Split(*keyList, ",")
+	}
+	var paths [][]common.Hash
+	for _, key := range keys {
+		var path []common.Hash
+		for _, part := range strings.Split(key, "/") {
+			word, err := storageWord(part)
+			if err != nil {
+				return fmt.Errorf("invalid key '%s': %v", part, err)
+			}
+			path = append(path, word)
+		}
+		paths = append(paths, path)
+	}
+	blockParam, err := pinBlock(endpoint, *block)
+	if err != nil {
+		return err
+	}
+
+	var candidates []slotCandidate
+	for i := 0; i < *slots; i++ {
+		base := common.BigToHash(big.NewInt(int64(i)))
+		candidates = append(candidates, slotCandidate{base, fmt.Sprintf("slot %d", i)})
+		for k, path := range paths {
+			solidity, vyper := base, base
+			for _, key := range path {
+				// Solidity hashes the key before the slot, Vyper the slot before the key
+				solidity = crypto.Keccak256Hash(key.Bytes(), solidity.Bytes())
+				vyper = crypto.Keccak256Hash(vyper.Bytes(), key.Bytes())
+			}
+			candidates = append(candidates,
+				slotCandidate{solidity, fmt.Sprintf("mapping at slot %d[%s]", i, strings.ReplaceAll(keys[k], "/", "]["))},
+				slotCandidate{vyper, fmt.Sprintf("mapping at slot %d[%s] (Vyper layout)", i, strings.ReplaceAll(keys[k], "/", "]["))})
+		}
+	}
+
+	words := make([]common.Hash, len(candidates))
+	errs := make([]error, len(candidates))
+	inParallel(len(candidates), *concurrency, func(i int) {
+		var word common.Hash
+		errs[i] = callRPC(endpoint, &word, "eth_getStorageAt", contract.Hex(), candidates[i].slot.Hex(), blockParam)
+		words[i] = word
+	})
+
+	found := 0
+	for i, candidate := range candidates {
+		if errs[i] != nil {
+			return fmt.Errorf("failed to read %s: %v", candidate.layout, errs[i])
+		}
+		if words[i] != value {
+			offset, ok := packedOffset(words[i], value)
+			if ok {
+				found++
+				fmt.Printf("%s: %s, packed at byte offset %d of %s\n", colorize(colorGreen, candidate.slot.Hex()), candidate.layout, offset, words[i].Hex())
+			}
+			continue
+		}
+		found++
+		fmt.Printf("%s: %s\n", colorize(colorGreen, candidate.slot.Hex()), candidate.layout)
+		fmt.Printf("  state override: {\"%s\": {\"stateDiff\": {\"%s\": \"%s\"}}}\n", contract.Hex(), candidate.slot.Hex(), value.Hex())
+		fmt.Printf("  foundry:        vm.store(%s, %s, %s);\n", contract.Hex(), candidate.slot.Hex(), value.Hex())
+	}
+	if found == 0 {
+		return fmt.Errorf("%s not found in %s of %s at block %s (try more --slots or other --key values)",
+			value.Hex(), pluralize(len(candidates), "candidate slot"), contract.Hex(), blockParam)
+	}
+	return nil
+}
+
+// Function to encode a value or mapping key as the 32 byte word it is stored as: addresses,
+// hex and decimal integers are right aligned, bool true and false are 1 and 0
+func storageWord(s string) (common.Hash, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "true":
+		return common.BigToHash(big.NewInt(1)), nil
+	case s == "false":
+		return common.Hash{}, nil
+	case common.IsHexAddress(s):
+		return common.BytesToHash(common.HexToAddress(s).Bytes()), nil
+	case strings.HasPrefix(s, "0x"):
+		data, err := hexutil.Decode(s)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if len(data) > 32 {
+			return common.Hash{}, fmt.Errorf("longer than 32 bytes")
+		}
+		return common.BytesToHash(data), nil
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return common.Hash{}, fmt.Errorf("expected an address, a hex word, a non-negative integer or a bool")
+	}
+	return common.BigToHash(n), nil
+}
+
+// Function to find a value packed in a storage word with other variables, returning its
+// offset in bytes from the right as Solidity counts them. Values must take at least 4
+// bytes to be looked for, fewer would match by chance too often.
+func packedOffset(word, value common.Hash) (int, bool) {
+	width := len(strings.TrimLeft(string(value.Bytes()), "\x00"))
+	if width < 4 || width == 32 {
+		return 0, false
+	}
+	for offset := 0; offset+width <= 32; offset++ {
+		start := 32 - offset - width
+		if string(word[start:start+width]) == string(value[32-width:]) {
+			return offset, true
+		}
+	}
+	return 0, false
+}
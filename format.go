@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// formatOptions controls how decoded values are displayed
+type formatOptions struct {
+	// Names of the values, used by the name-based heuristics
+	Names []string
+	// Variant names of enum values, nil for values that are not enums
+	EnumVariants [][]string
+	// Timestamp rendering: "auto" (by name), "all" (any plausible value) or "off"
+	Timestamps string
+	// Bytes rendering: "hex", "utf8" or "base64"
+	Bytes string
+	// Unsigned integer rendering: "decimal", "hex" or "fixed"
+	Uints string
+	// Number of decimals used by the fixed uint rendering
+	Decimals int
+	// Readability of unsigned integers: "off", "commas" (thousands separators) or "sci"
+	// (scientific notation for values of a million and more)
+	Humanize string
+	// Digits kept after the decimal point by the fixed and scientific renderings, -1 for all
+	Precision int
+	// Address labels keyed by lowercase address, nil to show bare addresses
+	Labels map[string]string
+	// Cached metadata of the tokens of the chain keyed by lowercase address, annotating
+	// token addresses with their symbol
+	Tokens map[string]tokenMetadata
+	// Reasons of the denylisted addresses keyed by lowercase address, flagged wherever
+	// they are shown, labels or not
+	Denylist map[string]string
+	// Address encoding: "hex", or that of a chain with its own like "tron" or "filecoin"
+	Addresses string
+	// Decimals of fixed-point integers by value name, "*" for any integer, rendering them
+	// scaled down whatever the uint format, e.g. 27 for ray rates
+	Scales map[string]int
+}
+
+// Built-in fixed-point scales: ray (27 decimals) for Aave rates and indexes, wad (18) for
+// most amounts and e8 (8) for oracle prices
+var builtinScales = map[string]int{
+	"ray": 27,
+	"wad": 18,
+	"e8":  8,
+}
+
+// Unix times outside this range are not treated as timestamps
+var (
+	minPlausibleTimestamp = big.NewInt(946684800)  // 2000-01-01
+	maxPlausibleTimestamp = big.NewInt(4102444800) // 2100-01-01
+)
+
+// Function to validate the timestamp rendering mode
+func validateTimestampMode(mode string) error {
+	switch mode {
+	case "auto", "all", "off":
+		return nil
+	}
+	return fmt.Errorf("invalid timestamp mode '%s' (expected auto, all or off)", mode)
+}
+
+// Function to build display options from the config file, falling back to the defaults
+func displayOptions(cfg *Config) formatOptions {
+	opts := formatOptions{
+		Timestamps: firstNonEmpty(cfg.Display.Timestamps, "auto"),
+		Bytes:      firstNonEmpty(cfg.Display.Bytes, "hex"),
+		Uints:      firstNonEmpty(cfg.Display.Uints, "decimal"),
+		Decimals:   cfg.Display.Decimals,
+		Addresses:  firstNonEmpty(cfg.Display.Addresses, "hex"),
+		Humanize:   firstNonEmpty(cfg.Display.Humanize, "off"),
+		Precision:  -1,
+		Denylist:   cfg.denylist(),
+	}
+	if cfg.Display.Precision != nil {
+		opts.Precision = *cfg.Display.Precision
+	}
+	if cfg.Display.Labels == nil || *cfg.Display.Labels {
+		opts.Labels = cfg.Labels
+	}
+	return opts
+}
+
+// Function to label addresses with what is known about the chain of an endpoint: the
+// labels of the chain and the symbols of its cached tokens. Only local data is read, so
+// rendering costs no lookups; addresses stay bare when labels are turned off.
+func (opts formatOptions) withChain(cfg *Config, rpcURL string) formatOptions {
+	if opts.Labels == nil {
+		return opts
+	}
+	chainID, ok := endpointChainID(rpcURL)
+	if !ok {
+		return opts
+	}
+	if labels, _, err := chainLabels(cfg, chainID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		opts.Labels = labels
+	}
+	if cache := tokenCacheFor(rpcURL); cache != nil {
+		opts.Tokens = cache.snapshot()
+	}
+	return opts
+}
+
+// Function to parse the fixed-point scales of a --scale flag: a scale applied to every
+// integer, like ray, or comma-separated name=scale pairs, like rate=ray,price=e8. Scales are
+// built-in or configured names, decimals like 6, or powers of ten like 1e6.
+func parseScales(spec string, cfg *Config) (map[string]int, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	scales := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		name, scale, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			name, scale = "*", name
+		}
+		name, scale = strings.TrimSpace(name), strings.TrimSpace(scale)
+		if name == "" {
+			return nil, fmt.Errorf("invalid scale '%s' (expected a scale or name=scale)", part)
+		}
+		decimals, err := scaleDecimals(scale, cfg)
+		if err != nil {
+			return nil, err
+		}
+		scales[name] = decimals
+	}
+	return scales, nil
+}
+
+// Function to get the decimals of a named scale, configured names taking precedence over
+// the built-in ones
+func scaleDecimals(scale string, cfg *Config) (int, error) {
+	if decimals, ok := cfg.Display.Scales[scale]; ok {
+		return decimals, nil
+	}
+	if decimals, ok := builtinScales[strings.ToLower(scale)]; ok {
+		return decimals, nil
+	}
+	digits := strings.TrimPrefix(strings.ToLower(scale), "1e")
+	decimals, err := strconv.Atoi(digits)
+	if err != nil || decimals < 0 || decimals > 77 {
+		return 0, fmt.Errorf("unknown scale '%s' (expected ray, wad, e8, a configured scale, decimals or 1eN)", scale)
+	}
+	return decimals, nil
+}
+
+// Function to validate the per-type display options
+func validateDisplayOptions(opts formatOptions) error {
+	if err := validateTimestampMode(opts.Timestamps); err != nil {
+		return err
+	}
+	switch opts.Bytes {
+	case "hex", "utf8", "base64":
+	default:
+		return fmt.Errorf("invalid bytes format '%s' (expected hex, utf8 or base64)", opts.Bytes)
+	}
+	switch opts.Uints {
+	case "decimal", "hex", "fixed":
+	default:
+		return fmt.Errorf("invalid uint format '%s' (expected decimal, hex or fixed)", opts.Uints)
+	}
+	if opts.Decimals < 0 {
+		return fmt.Errorf("decimals must not be negative")
+	}
+	switch opts.Humanize {
+	case "off", "commas", "sci":
+	default:
+		return fmt.Errorf("invalid humanize mode '%s' (expected off, commas or sci)", opts.Humanize)
+	}
+	return validateAddressFormat(opts.Addresses)
+}
+
+// Function to render bytes in the configured format
+func formatBytes(b []byte, opts formatOptions) string {
+	switch opts.Bytes {
+	case "utf8":
+		// Fixed-size strings are right-padded with zero bytes
+		trimmed := bytes.TrimRight(b, "\x00")
+		if utf8.Valid(trimmed) {
+			return string(trimmed)
+		}
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Function to decode a bytes32 holding right-padded printable ASCII, as used for names and symbols
+func bytes32String(b []byte) (string, bool) {
+	trimmed := bytes.TrimRight(b, "\x00")
+	if len(b) != 32 || len(trimmed) == 0 {
+		return "", false
+	}
+	for _, c := range trimmed {
+		if c < 0x20 || c > 0x7e {
+			return "", false
+		}
+	}
+	return string(trimmed), true
+}
+
+// Function to render an unsigned integer in the configured format
+func formatUint(n *big.Int, opts formatOptions) string {
+	decimals := 0
+	switch opts.Uints {
+	case "hex":
+		return "0x" + n.Text(16)
+	case "fixed":
+		decimals = opts.Decimals
+	}
+
+	// Small values read fine as they are
+	if opts.Humanize == "sci" && n.CmpAbs(big.NewInt(1000000)) >= 0 {
+		return formatScientific(n, decimals, opts.Precision)
+	}
+	text := formatFixed(n, decimals)
+	if opts.Precision >= 0 && opts.Precision < decimals {
+		text = formatFixed(roundFixed(n, decimals, opts.Precision), opts.Precision)
+	}
+	if opts.Humanize == "commas" {
+		text = groupThousands(text)
+	}
+	return text
+}
+
+// Function to round an integer holding a fixed-point value with decimals to one with fewer
+// decimals, half away from zero
+func roundFixed(n *big.Int, decimals, precision int) *big.Int {
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals-precision)), nil)
+	quotient, remainder := new(big.Int).QuoRem(new(big.Int).Abs(n), divisor, new(big.Int))
+	if remainder.Mul(remainder, big.NewInt(2)).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if n.Sign() < 0 {
+		quotient.Neg(quotient)
+	}
+	return quotient
+}
+
+// Function to render a fixed-point value in scientific notation, e.g. 1.2346e18, with 4
+// digits after the point unless a precision is given
+func formatScientific(n *big.Int, decimals, precision int) string {
+	if precision < 0 {
+		precision = 4
+	}
+	value := new(big.Float).SetPrec(512).SetInt(n)
+	if decimals > 0 {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+		value.Quo(value, new(big.Float).SetPrec(512).SetInt(scale))
+	}
+	text := value.Text('e', precision)
+	mantissa, exponent, _ := strings.Cut(text, "e")
+	if strings.Contains(mantissa, ".") {
+		mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+	}
+	digits := strings.TrimLeft(exponent[1:], "0")
+	if digits == "" {
+		return mantissa
+	}
+	if exponent[0] == '-' {
+		digits = "-" + digits
+	}
+	return mantissa + "e" + digits
+}
+
+// Function to separate the thousands of the whole part of a decimal number with commas
+func groupThousands(text string) string {
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(text, ".")
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if hasFrac {
+		return sign + b.String() + "." + frac
+	}
+	return sign + b.String()
+}
+
+// Function to render an integer scaled down by 10^decimals, e.g. 1500000 with 6 decimals as 1.5
+func formatFixed(n *big.Int, decimals int) string {
+	if decimals == 0 {
+		return n.String()
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	abs := new(big.Int).Abs(n)
+	whole, frac := new(big.Int).QuoRem(abs, scale, new(big.Int))
+
+	result := whole.String()
+	if frac.Sign() != 0 {
+		fracStr := fmt.Sprintf("%0*s", decimals, frac.String())
+		result += "." + strings.TrimRight(fracStr, "0")
+	}
+	if n.Sign() < 0 {
+		result = "-" + result
+	}
+	return result
+}
+
+// Function to parse a decimal like 1.5 scaled up by 10^decimals, the inverse of formatFixed
+func parseFixed(s string, decimals int) (*big.Int, error) {
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("more than %d decimals", decimals)
+	}
+	n, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", decimals-len(frac)), 10)
+	if !ok {
+		return nil, fmt.Errorf("not a number")
+	}
+	return n, nil
+}
+
+// Function to render an address in the configured encoding, with its label when one is known
+func formatAddress(address common.Address, opts formatOptions) string {
+	rendered := address.Hex()
+	if codec, ok := addressCodecs[opts.Addresses]; ok {
+		rendered = codec.encode(address)
+	}
+	if reason, ok := opts.Denylist[strings.ToLower(address.Hex())]; ok {
+		rendered += " " + colorize(colorRed, "[DENYLISTED: "+reason+"]")
+	}
+	if label, ok := opts.Labels[strings.ToLower(address.Hex())]; ok {
+		return rendered + " (" + label + ")"
+	}
+	if token, ok := opts.Tokens[strings.ToLower(address.Hex())]; ok && token.Symbol != "" {
+		return rendered + " (" + token.Symbol + ")"
+	}
+	return rendered
+}
+
+// Function to convert a decoded bytes or bytesN value into a byte slice
+func asBytes(val interface{}) ([]byte, bool) {
+	if b, ok := val.([]byte); ok {
+		return b, true
+	}
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return b, true
+	}
+	return nil, false
+}
+
+// Function to check whether a value name suggests it holds a Unix time
+func isTimestampName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.Contains(lower, "timestamp") ||
+		strings.Contains(lower, "deadline") ||
+		strings.Contains(lower, "expir") ||
+		strings.HasSuffix(lower, "time") ||
+		strings.HasSuffix(name, "At")
+}
+
+// Function to render an integer as an RFC3339 date if the options say it is a timestamp
+func timestampSuffix(value *big.Int, name string, opts formatOptions) string {
+	switch opts.Timestamps {
+	case "off":
+		return ""
+	case "auto":
+		if !isTimestampName(name) {
+			return ""
+		}
+	}
+	if value.Cmp(minPlausibleTimestamp) < 0 || value.Cmp(maxPlausibleTimestamp) >= 0 {
+		return ""
+	}
+	return " (" + time.Unix(value.Int64(), 0).UTC().Format(time.RFC3339) + ")"
+}
+
+// Function to convert any decoded integer into a big.Int
+func asBigInt(val interface{}) (*big.Int, bool) {
+	switch v := val.(type) {
+	case *big.Int:
+		return v, true
+	case uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		n, ok := new(big.Int).SetString(fmt.Sprint(v), 10)
+		return n, ok
+	}
+	return nil, false
+}
+
+// Function to split a declared type like "uint256 deadline" into its type and name
+func splitTypeName(decl string) (string, string) {
+	// Split on whitespace outside of tuple parentheses
+	var fields []string
+	depth, start := 0, -1
+	for i, c := range decl {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case (c == ' ' || c == '\t') && depth == 0:
+			if start >= 0 {
+				fields = append(fields, decl[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, decl[start:])
+	}
+
+	if len(fields) == 0 {
+		return "", ""
+	}
+	name := fields[len(fields)-1]
+	if len(fields) == 1 || name == "memory" || name == "calldata" || name == "storage" {
+		name = ""
+	}
+	return fields[0], name
+}
+
+// Function to render a decoded array or tuple, one element per line indented below its parent
+func formatNested(val interface{}, typ abi.Type, opts formatOptions, indent string) string {
+	v := reflect.Indirect(reflect.ValueOf(val))
+	var b strings.Builder
+
+	switch typ.T {
+	case abi.SliceTy, abi.ArrayTy:
+		if v.Len() == 0 {
+			return " []"
+		}
+		for i := 0; i < v.Len(); i++ {
+			b.WriteString(fmt.Sprintf("\n%s[%d]:", indent, i))
+			b.WriteString(formatElement(v.Index(i).Interface(), *typ.Elem, "", opts, indent))
+		}
+	case abi.TupleTy:
+		for i, elem := range typ.TupleElems {
+			label := elem.String()
+			name := typ.TupleRawNames[i]
+			if name == fmt.Sprintf("field%d", i) {
+				name = ""
+			} else {
+				label += " " + name
+			}
+			b.WriteString(fmt.Sprintf("\n%s%s:", indent, label))
+			b.WriteString(formatElement(v.Field(i).Interface(), *elem, name, opts, indent))
+		}
+	}
+	return b.String()
+}
+
+// Function to render an element of an array or tuple, nesting further if needed
+func formatElement(val interface{}, typ abi.Type, name string, opts formatOptions, indent string) string {
+	if isNestedType(typ) {
+		return formatNested(val, typ, opts, indent+"  ")
+	}
+	return " " + formatValue(val, typ.String(), name, nil, opts)
+}
+
+// Function to render a decoded value of any type on a single line, e.g. [(0x..., 5), (0x..., 7)]
+func formatCompact(val interface{}, typ abi.Type, opts formatOptions) string {
+	v := reflect.Indirect(reflect.ValueOf(val))
+	switch typ.T {
+	case abi.SliceTy, abi.ArrayTy:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = formatCompact(v.Index(i).Interface(), *typ.Elem, opts)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case abi.TupleTy:
+		parts := make([]string, len(typ.TupleElems))
+		for i, elem := range typ.TupleElems {
+			parts[i] = formatCompact(v.Field(i).Interface(), *elem, opts)
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+	return formatValue(val, typ.String(), "", nil, opts)
+}
+
+// Function to check whether values of a type are rendered as nested elements
+func isNestedType(typ abi.Type) bool {
+	return typ.T == abi.SliceTy || typ.T == abi.ArrayTy || typ.T == abi.TupleTy
+}
+
+// Function to get the fixed-point scale of an integer value by its name, or the one of all
+// integers
+func (opts formatOptions) scale(name string) (int, bool) {
+	if decimals, ok := opts.Scales[name]; ok && name != "" {
+		return decimals, true
+	}
+	decimals, ok := opts.Scales["*"]
+	return decimals, ok
+}
+
+// Function to render a single non-nested value
+func formatValue(val interface{}, typStr string, name string, enumVariants []string, opts formatOptions) string {
+	if enumVariants != nil {
+		index, err := strconv.Atoi(fmt.Sprint(val))
+		if err == nil && index >= 0 && index < len(enumVariants) {
+			return fmt.Sprintf("%s (%d)", enumVariants[index], index)
+		}
+	}
+
+	if n, ok := asBigInt(val); ok {
+		if decimals, ok := opts.scale(name); ok {
+			scaled := opts
+			scaled.Uints, scaled.Decimals = "fixed", decimals
+			return formatUint(n, scaled)
+		}
+		if suffix := timestampSuffix(n, name, opts); suffix != "" {
+			return n.String() + suffix
+		}
+		if strings.HasPrefix(typStr, "uint") {
+			return formatUint(n, opts)
+		}
+	}
+	if v, ok := val.(common.Address); ok {
+		return formatAddress(v, opts)
+	}
+	if b, ok := asBytes(val); ok {
+		result := formatBytes(b, opts)
+		if s, ok := bytes32String(b); ok && opts.Bytes == "hex" {
+			result += fmt.Sprintf(" (%q)", s)
+		}
+		return result
+	}
+
+	switch v := val.(type) {
+	case string:
+		return v
+	case *big.Int:
+		return v.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Argument left to the fuzzer when fixing the others on the command line
+const fuzzPlaceholder = "_"
+
+// Sizes of the ABI types the fuzzer generates values for
+var fuzzTypePattern = regexp.MustCompile(`^(u?int|bytes)(\d*)$`)
+
+// fuzzOutcome is a group of runs ending the same way, with the first input that did
+type fuzzOutcome struct {
+	description string
+	runs        int
+	example     []string
+}
+
+// Function to call a function with random and boundary inputs and report the inputs that
+// revert or behave unexpectedly, a quick property probe against live contracts
+func runFuzz(args []string) error {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting its endpoint profile and contract deployments")
+	to := fs.String("to", "", "address, contract alias or label of the contract to call")
+	sig := fs.String("sig", "", "function signature, e.g. \"f(uint256,address)\"; arguments given after the flags are fixed, "+fuzzPlaceholder+" leaves one to the fuzzer")
+	returns := fs.String("returns", "", "return types, e.g. \"(uint256)\"; results not decoding as them are reported")
+	from := fs.String("from", "", "sender of the calls")
+	block := fs.String("block", "latest", "block to run all calls at")
+	runs := fs.Int("runs", 100, "number of calls")
+	seed := fs.Int64("seed", 0, "seed of the random inputs, to repeat a run (default: random)")
+	concurrency := fs.Int("concurrency", 8, "calls in flight at once when the endpoint does not support batches")
+	fs.Parse(args)
+	if *to == "" || *sig == "" {
+		return fmt.Errorf("usage: contract-curler fuzz --to <contract> --sig <signature> [flags] [fixed args...]")
+	}
+	if *runs < 1 || *concurrency < 1 {
+		return fmt.Errorf("runs and concurrency must be at least 1")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	open := strings.Index(*sig, "(")
+	if open < 0 || !strings.HasSuffix(*sig, ")") {
+		return fmt.Errorf("invalid function signature '%s'", *sig)
+	}
+	paramTypes := splitTypeList(trimTypeList((*sig)[open:]))
+	fixed := fs.Args()
+	if len(fixed) > len(paramTypes) {
+		return fmt.Errorf("got %d arguments for %d parameters", len(fixed), len(paramTypes))
+	}
+	for len(fixed) < len(paramTypes) {
+		fixed = append(fixed, fuzzPlaceholder)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	target, err := cfg.resolveAddress(*to, *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	var sender *common.Address
+	if *from != "" {
+		address, err := cfg.resolveAddress(*from, *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		sender = &address
+	}
+
+	// Boundary values come first, then random values mixed with them
+	var boundaries [][]string
+	for i, paramType := range paramTypes {
+		paramType = strings.TrimSpace(paramType)
+		values, err := fuzzBoundaries(paramType, target, sender)
+		if err != nil {
+			return err
+		}
+		if fixed[i] != fuzzPlaceholder {
+			values = []string{fixed[i]}
+		}
+		boundaries = append(boundaries, values)
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+
+	inputs := make([][]string, *runs)
+	requests := make([]callRequest, *runs)
+	for run := range inputs {
+		stride := 1
+		for i, paramType := range paramTypes {
+			values := boundaries[i]
+			switch {
+			case fixed[i] != fuzzPlaceholder:
+				inputs[run] = append(inputs[run], fixed[i])
+			case run < *runs/2:
+				// Walk the combinations of boundary values
+				inputs[run] = append(inputs[run], values[run/stride%len(values)])
+				stride *= len(values)
+			case rng.Intn(4) == 0:
+				inputs[run] = append(inputs[run], values[rng.Intn(len(values))])
+			default:
+				inputs[run] = append(inputs[run], fuzzRandom(rng, strings.TrimSpace(paramType)))
+			}
+		}
+		encoded, err := encodeMethodCall(*sig, inputs[run])
+		if err != nil {
+			return fmt.Errorf("failed to encode (%s): %v", strings.Join(inputs[run], ", "), err)
+		}
+		requests[run] = callRequest{To: target, Data: hexutil.MustDecode(encoded), From: sender}
+	}
+
+	blockParam, err := pinBlock(endpoint, *block)
+	if err != nil {
+		return err
+	}
+	// Multicall3 would replace the sender, so calls with one are only batched over JSON-RPC
+	strategy := "auto"
+	if sender != nil {
+		strategy = "rpc-batch"
+	}
+	results, used, err := executeCalls(endpoint, blockParam, requests, strategy, *concurrency)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Fuzzed %s on %s at block %s with %s %s (seed %d)\n", *sig, target.Hex(), blockParam, pluralize(*runs, "call"), used, *seed)
+
+	returned, distinct := 0, make(map[string]bool)
+	var reverts, anomalies []*fuzzOutcome
+	record := func(outcomes *[]*fuzzOutcome, description string, input []string) {
+		for _, outcome := range *outcomes {
+			if outcome.description == description {
+				outcome.runs++
+				return
+			}
+		}
+		*outcomes = append(*outcomes, &fuzzOutcome{description: description, runs: 1, example: input})
+	}
+	for run, result := range results {
+		switch {
+		case result.Err != nil && len(result.Output) >= 4 && hexutil.Encode(result.Output[:4]) == "0x4e487b71":
+			// Panics are failed assertions, overflows and out of bounds accesses, not checks
+			record(&anomalies, result.Err.Error(), inputs[run])
+		case result.Err != nil && strings.HasPrefix(result.Err.Error(), "reverted: "):
+			record(&reverts, strings.TrimPrefix(result.Err.Error(), "reverted: "), inputs[run])
+		case result.Err != nil:
+			record(&anomalies, result.Err.Error(), inputs[run])
+		case *returns != "" && len(result.Output) == 0:
+			record(&anomalies, "returned no data", inputs[run])
+		default:
+			if *returns != "" {
+				if _, err := decodeReturnValues(hexutil.Encode(result.Output), *returns); err != nil {
+					record(&anomalies, fmt.Sprintf("returned data not decoding as %s", *returns), inputs[run])
+					continue
+				}
+			}
+			returned++
+			distinct[string(result.Output)] = true
+		}
+	}
+
+	fmt.Printf("\nReturned: %d (%s)\n", returned, pluralize(len(distinct), "distinct result"))
+	printFuzzOutcomes("Reverted", reverts, paramTypes)
+	if anomalous := printFuzzOutcomes("Anomalies", anomalies, paramTypes); anomalous > 0 {
+		return fmt.Errorf("%d of %s behaved unexpectedly, rerun with --seed %d to reproduce", anomalous, pluralize(*runs, "call"), *seed)
+	}
+	return nil
+}
+
+// Function to print groups of runs, most frequent first, with an input causing each,
+// returning the number of runs in the groups
+func printFuzzOutcomes(title string, outcomes []*fuzzOutcome, paramTypes []string) int {
+	total := 0
+	for _, outcome := range outcomes {
+		total += outcome.runs
+	}
+	fmt.Printf("%s: %d\n", title, total)
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		return outcomes[i].runs > outcomes[j].runs
+	})
+	for _, outcome := range outcomes {
+		example := make([]string, len(outcome.example))
+		for i, value := range outcome.example {
+			if strings.TrimSpace(paramTypes[i]) == "string" {
+				value = strconv.Quote(value)
+			}
+			example[i] = value
+		}
+		fmt.Printf("  %s (%s), e.g. (%s)\n", colorize(colorRed, outcome.description), pluralize(outcome.runs, "run"), strings.Join(example, ", "))
+	}
+	return total
+}
+
+// Function to list the edge cases of a parameter type, in the string form of arguments
+func fuzzBoundaries(paramType string, target common.Address, sender *common.Address) ([]string, error) {
+	switch paramType {
+	case "bool":
+		return []string{"true", "false"}, nil
+	case "address":
+		values := []string{
+			common.Address{}.Hex(),
+			common.BigToAddress(big.NewInt(1)).Hex(),
+			common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff").Hex(),
+			target.Hex(),
+		}
+		if sender != nil {
+			values = append(values, sender.Hex())
+		}
+		return values, nil
+	case "string":
+		return []string{"", "a", strings.Repeat("A", 256), "ünïcödé 🚀"}, nil
+	case "bytes":
+		return []string{"0x", "0x00", hexutil.Encode(make([]byte, 32)), "0x" + strings.Repeat("ff", 33)}, nil
+	}
+
+	kind, size, err := fuzzType(paramType)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "bytes":
+		return []string{"0x" + strings.Repeat("00", size), "0x" + strings.Repeat("ff", size)}, nil
+	case "uint":
+		max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(size)), big.NewInt(1))
+		half := new(big.Int).Lsh(big.NewInt(1), uint(size-1))
+		values := []string{"0", "1", max.String(), new(big.Int).Sub(max, big.NewInt(1)).String(), half.String()}
+		if oneEther := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil); oneEther.Cmp(max) < 0 {
+			values = append(values, oneEther.String())
+		}
+		return values, nil
+	}
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), uint(size-1)))
+	max := new(big.Int).Sub(new(big.Int).Neg(min), big.NewInt(1))
+	return []string{"0", "1", "-1", min.String(), max.String(), new(big.Int).Add(min, big.NewInt(1)).String()}, nil
+}
+
+// Function to draw a random value of a parameter type, spreading integers over all magnitudes
+func fuzzRandom(rng *rand.Rand, paramType string) string {
+	randomBytes := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+	switch paramType {
+	case "bool":
+		return strconv.FormatBool(rng.Intn(2) == 0)
+	case "address":
+		return common.BytesToAddress(randomBytes(common.AddressLength)).Hex()
+	case "string":
+		s := make([]byte, rng.Intn(65))
+		for i := range s {
+			s[i] = byte(' ' + rng.Intn(95))
+		}
+		return string(s)
+	case "bytes":
+		return hexutil.Encode(randomBytes(rng.Intn(101)))
+	}
+
+	// The types were checked while listing their boundaries
+	kind, size, _ := fuzzType(paramType)
+	if kind == "bytes" {
+		return hexutil.Encode(randomBytes(size))
+	}
+	bits := size
+	if kind == "int" {
+		bits--
+	}
+	n := new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), uint(1+rng.Intn(bits))))
+	if kind == "int" && rng.Intn(2) == 0 {
+		n.Neg(n)
+	}
+	return n.String()
+}
+
+// Function to split a sized ABI type like uint64 or bytes4 into its kind and size,
+// rejecting the types arguments cannot be given for
+func fuzzType(paramType string) (string, int, error) {
+	matches := fuzzTypePattern.FindStringSubmatch(paramType)
+	if matches == nil {
+		return "", 0, fmt.Errorf("cannot fuzz parameters of type %s", paramType)
+	}
+	size := 256
+	if matches[1] == "bytes" {
+		size = 32
+	}
+	if matches[2] != "" {
+		size, _ = strconv.Atoi(matches[2])
+	}
+	if matches[1] == "bytes" && (size < 1 || size > 32) || matches[1] != "bytes" && (size < 8 || size > 256 || size%8 != 0) {
+		return "", 0, fmt.Errorf("invalid type %s", paramType)
+	}
+	return matches[1], size, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// structLog is a single step of a debug_traceCall struct log trace
+type structLog struct {
+	Pc      uint64   `json:"pc"`
+	Op      string   `json:"op"`
+	Gas     uint64   `json:"gas"`
+	GasCost uint64   `json:"gasCost"`
+	Depth   int      `json:"depth"`
+	Stack   []string `json:"stack"`
+}
+
+// structLogTrace is the result of debug_traceCall with the default struct logger
+type structLogTrace struct {
+	Gas         uint64      `json:"gas"`
+	Failed      bool        `json:"failed"`
+	ReturnValue string      `json:"returnValue"`
+	StructLogs  []structLog `json:"structLogs"`
+}
+
+// gasFrame accumulates the gas of one call frame of a trace
+type gasFrame struct {
+	Label     string
+	Depth     int
+	EntryGas  uint64
+	Inclusive uint64
+	Self      uint64
+}
+
+// Opcodes grouped into the categories of the gas profile, besides the stack and log families
+var opcodeCategories = map[string]string{
+	"SLOAD": "storage", "SSTORE": "storage", "TLOAD": "storage", "TSTORE": "storage",
+	"CALL": "calls", "STATICCALL": "calls", "DELEGATECALL": "calls", "CALLCODE": "calls",
+	"CREATE": "calls", "CREATE2": "calls", "SELFDESTRUCT": "calls",
+	"KECCAK256": "hashing", "SHA3": "hashing",
+	"MLOAD": "memory", "MSTORE": "memory", "MSTORE8": "memory", "MCOPY": "memory", "MSIZE": "memory",
+	"CALLDATACOPY": "memory", "CODECOPY": "memory", "RETURNDATACOPY": "memory", "EXTCODECOPY": "memory",
+	"BALANCE": "account access", "SELFBALANCE": "account access", "EXTCODESIZE": "account access", "EXTCODEHASH": "account access",
+	"JUMP": "control flow", "JUMPI": "control flow", "JUMPDEST": "control flow", "PC": "control flow",
+	"STOP": "control flow", "RETURN": "control flow", "REVERT": "control flow", "INVALID": "control flow",
+}
+
+// Function to classify an opcode for the gas profile
+func opcodeCategory(op string) string {
+	switch {
+	case strings.HasPrefix(op, "PUSH"), strings.HasPrefix(op, "DUP"), strings.HasPrefix(op, "SWAP"), op == "POP":
+		return "stack"
+	case strings.HasPrefix(op, "LOG"):
+		return "logs"
+	}
+	if category, ok := opcodeCategories[op]; ok {
+		return category
+	}
+	return "arithmetic and other"
+}
+
+// Function to profile the gas usage of a simulated call with debug_traceCall
+func runGasProfile(args []string) error {
+	fs := flag.NewFlagSet("gas-profile", flag.ExitOnError)
+	call := addCallFlags(fs)
+	fs.Parse(args)
+
+	callObject, err := call.callObject(fs.Args())
+	if err != nil {
+		return err
+	}
+	block, err := call.blockParam()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg)
+
+	var trace structLogTrace
+	tracerConfig := map[string]interface{}{"disableStorage": true, "enableMemory": false, "enableReturnData": false}
+	if err := callRPC(call.endpoint(), &trace, "debug_traceCall", callObject, block, tracerConfig); err != nil {
+		return fmt.Errorf("debug_traceCall failed: %v", err)
+	}
+	if len(trace.StructLogs) == 0 {
+		fmt.Printf("Total gas used: %d (no opcodes executed)\n", trace.Gas)
+		return nil
+	}
+
+	categories, frames := profileStructLogs(trace.StructLogs, labelAddress(*call.to, opts), opts)
+
+	execution := frames[0].Inclusive
+	fmt.Printf("Total gas used: %d", trace.Gas)
+	if trace.Failed {
+		fmt.Print(" (call reverted)")
+	}
+	fmt.Println()
+	if trace.Gas >= execution {
+		fmt.Printf("  Intrinsic and refunds: %d\n", trace.Gas-execution)
+	}
+	fmt.Printf("  Execution: %d\n", execution)
+
+	fmt.Println("\nBy opcode category:")
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return categories[names[i]] > categories[names[j]] })
+	for _, name := range names {
+		share := 0.0
+		if execution > 0 {
+			share = float64(categories[name]) * 100 / float64(execution)
+		}
+		fmt.Printf("  %-22s %10d  %5.1f%%\n", name, categories[name], share)
+	}
+
+	fmt.Println("\nBy call frame (inclusive / self):")
+	for _, frame := range frames {
+		fmt.Printf("%s%s: %d / %d\n", strings.Repeat("  ", frame.Depth), frame.Label, frame.Inclusive, frame.Self)
+	}
+	return nil
+}
+
+// Function to attribute the gas of every step to an opcode category and a call frame.
+// The cost of a step is the drop in remaining gas until the next step of the same frame,
+// so call opcodes are only charged their own overhead and not the gas their callee used.
+func profileStructLogs(logs []structLog, target string, opts formatOptions) (map[string]uint64, []*gasFrame) {
+	categories := make(map[string]uint64)
+	frames := []*gasFrame{{Label: "call to " + target, Depth: 1, EntryGas: logs[0].Gas}}
+	stack := []*gasFrame{frames[0]}
+	// Index of the call step that opened each frame on the stack, -1 for the top level
+	callers := []int{-1}
+
+	for i, step := range logs {
+		current := stack[len(stack)-1]
+
+		var cost uint64
+		switch {
+		case i+1 < len(logs) && logs[i+1].Depth > step.Depth:
+			// Entering a callee: its frame is charged when it returns
+			frame := &gasFrame{Label: step.Op + " " + callTarget(step, opts), Depth: step.Depth + 1, EntryGas: logs[i+1].Gas}
+			frames = append(frames, frame)
+			stack = append(stack, frame)
+			callers = append(callers, i)
+			continue
+		case i+1 < len(logs) && logs[i+1].Depth == step.Depth:
+			cost = step.Gas - logs[i+1].Gas
+		default:
+			cost = step.GasCost
+		}
+		categories[opcodeCategory(step.Op)] += cost
+		current.Self += cost
+
+		// Leaving the frame: settle it and charge its caller's call opcode overhead
+		if i+1 == len(logs) || logs[i+1].Depth < step.Depth {
+			remaining := step.Gas - cost
+			current.Inclusive = current.EntryGas - remaining
+			caller := callers[len(callers)-1]
+			stack = stack[:len(stack)-1]
+			callers = callers[:len(callers)-1]
+			if caller < 0 || i+1 == len(logs) {
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			callStep := logs[caller]
+			overhead := callStep.Gas - logs[i+1].Gas - current.Inclusive
+			if callStep.Gas < logs[i+1].Gas+current.Inclusive {
+				overhead = 0
+			}
+			categories[opcodeCategory(callStep.Op)] += overhead
+			parent.Self += overhead
+		}
+	}
+
+	// Frames still open (e.g. an out-of-gas abort) are settled at the last step
+	for _, frame := range stack {
+		last := logs[len(logs)-1]
+		if frame.Inclusive == 0 && frame.EntryGas >= last.Gas {
+			frame.Inclusive = frame.EntryGas - last.Gas + last.GasCost
+		}
+	}
+	return categories, frames
+}
+
+// Function to find the target of a call opcode from its stack arguments
+func callTarget(step structLog, opts formatOptions) string {
+	if strings.HasPrefix(step.Op, "CREATE") {
+		return "(new contract)"
+	}
+	// The address is the second stack item from the top for all call variants
+	if len(step.Stack) < 2 {
+		return "(unknown)"
+	}
+	word := step.Stack[len(step.Stack)-2]
+	return formatAddress(common.HexToAddress(word), opts)
+}
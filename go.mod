@@ -2,11 +2,14 @@ module github.com/contract-curler
 
 go 1.23
 
-require github.com/ethereum/go-ethereum v1.10.26
+require (
+	github.com/ethereum/go-ethereum v1.10.26
+	go.starlark.net v0.0.0-20240520160348-046347dcd104
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+)
 
 require (
 	github.com/btcsuite/btcd/btcec/v2 v2.2.0 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1 // indirect
-	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
-	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
 )
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Event of OpenZeppelin Governor and GovernorBravo announcing a proposal and its actions
+const proposalCreatedEvent = "ProposalCreated(uint256,address,address[],uint256[],string[],bytes[],uint256,uint256,string)"
+
+// proposalAction is one call made when a proposal executes
+type proposalAction struct {
+	Target common.Address
+	Value  *big.Int
+	Data   []byte
+}
+
+// actionLayout gives the positions of the arguments carrying actions in a governance call.
+// Signatures is -1 when the calldata starts with its selector, as in OpenZeppelin contracts;
+// Compound-style contracts pass the function signature separately.
+type actionLayout struct {
+	signature                              string
+	targets, values, calldatas, signatures int
+}
+
+// Governor and timelock functions whose arguments are calls to make
+var actionLayouts = []actionLayout{
+	{"propose(address[],uint256[],bytes[],string)", 0, 1, 2, -1},
+	{"queue(address[],uint256[],bytes[],bytes32)", 0, 1, 2, -1},
+	{"execute(address[],uint256[],bytes[],bytes32)", 0, 1, 2, -1},
+	{"propose(address[],uint256[],string[],bytes[],string)", 0, 1, 3, 2},
+	{"schedule(address,uint256,bytes,bytes32,bytes32,uint256)", 0, 1, 2, -1},
+	{"scheduleBatch(address[],uint256[],bytes[],bytes32,bytes32,uint256)", 0, 1, 2, -1},
+	{"execute(address,uint256,bytes,bytes32,bytes32)", 0, 1, 2, -1},
+	{"executeBatch(address[],uint256[],bytes[],bytes32,bytes32)", 0, 1, 2, -1},
+	{"queueTransaction(address,uint256,string,bytes,uint256)", 0, 1, 3, 2},
+	{"executeTransaction(address,uint256,string,bytes,uint256)", 0, 1, 3, 2},
+}
+
+// Function to decode the actions of a governance proposal, given as the calldata of a
+// propose, queue, schedule or execute call, the transaction making one, or a proposal id
+func runProposal(args []string) error {
+	fs := flag.NewFlagSet("proposal", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	governor := fs.String("governor", "", "governor to read the actions of a proposal id from")
+	abiPaths := fs.String("abi", "", "comma-separated ABI files used to decode the actions, in addition to the configured contracts")
+	from := fs.String("from", "0", "first block searched for the ProposalCreated event of a proposal id")
+	chunk := fs.Uint64("chunk", 50000, "number of blocks requested per eth_getLogs call")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler proposal [flags] <calldata | tx hash | proposal id>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	decoder, err := configDecoder(cfg, *abiPaths)
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+
+	arg := strings.TrimSpace(fs.Arg(0))
+	var actions []proposalAction
+	switch {
+	case *governor != "":
+		id, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			return fmt.Errorf("invalid proposal id '%s'", arg)
+		}
+		address, err := cfg.resolveAddress(*governor, *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		if actions, err = proposalActions(endpoint, address, id, *from, *chunk); err != nil {
+			return err
+		}
+	case len(arg) == 66 && strings.HasPrefix(arg, "0x"):
+		var tx struct {
+			Input string `json:"input"`
+		}
+		if err := callRPC(endpoint, &tx, "eth_getTransactionByHash", arg); err != nil {
+			return fmt.Errorf("failed to fetch transaction: %v", err)
+		}
+		if tx.Input == "" {
+			return fmt.Errorf("transaction %s not found", arg)
+		}
+		if actions, _, err = decodeActions(hexutil.MustDecode(tx.Input)); err != nil {
+			return err
+		}
+	default:
+		data, err := hexutil.Decode(arg)
+		if err != nil {
+			return fmt.Errorf("invalid calldata: %v", err)
+		}
+		if actions, _, err = decodeActions(data); err != nil {
+			return err
+		}
+	}
+
+	printActions(actions, decoder, opts, "")
+	return nil
+}
+
+// Function to decode the actions carried by a governor or timelock call, returning them
+// with the signature of the call
+func decodeActions(data []byte) ([]proposalAction, string, error) {
+	if len(data) < 4 {
+		return nil, "", fmt.Errorf("calldata is too short to hold a selector")
+	}
+	selector := hex.EncodeToString(data[:4])
+	for _, layout := range actionLayouts {
+		if functionSelector(layout.signature) != selector {
+			continue
+		}
+		entry, err := entryFromSignature(layout.signature)
+		if err != nil {
+			return nil, "", err
+		}
+		values, err := entry.decodeInputs(data[4:])
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode %s: %v", layout.signature, err)
+		}
+		var signatures []string
+		if layout.signatures >= 0 {
+			signatures = asStrings(values[layout.signatures])
+		}
+		actions, err := buildActions(asAddresses(values[layout.targets]), asBigInts(values[layout.values]), asByteSlices(values[layout.calldatas]), signatures)
+		return actions, layout.signature, err
+	}
+	return nil, "", fmt.Errorf("0x%s is not a known governor or timelock function", selector)
+}
+
+// Function to zip the arrays of a proposal into actions, prefixing the calldata of
+// Compound-style actions with the selector of their signature
+func buildActions(targets []common.Address, values []*big.Int, calldatas [][]byte, signatures []string) ([]proposalAction, error) {
+	if len(values) != len(targets) || len(calldatas) != len(targets) || (signatures != nil && len(signatures) != len(targets)) {
+		return nil, fmt.Errorf("proposal arrays have different lengths (%d targets, %d values, %d calldatas)", len(targets), len(values), len(calldatas))
+	}
+	actions := make([]proposalAction, len(targets))
+	for i := range targets {
+		actions[i] = proposalAction{Target: targets[i], Value: values[i], Data: calldatas[i]}
+		if signatures != nil && signatures[i] != "" {
+			selector, _ := hex.DecodeString(functionSelector(signatures[i]))
+			actions[i].Data = append(selector, calldatas[i]...)
+		}
+	}
+	return actions, nil
+}
+
+// Function to read the actions of a proposal from a governor, through GovernorBravo's
+// getActions, OpenZeppelin GovernorStorage's proposalDetails, or its ProposalCreated event
+func proposalActions(rpcURL string, governor common.Address, id *big.Int, from string, chunk uint64) ([]proposalAction, error) {
+	call := func(signature, returnTypes string) ([]interface{}, error) {
+		data, err := encodeMethodCall(signature, []string{id.String()})
+		if err != nil {
+			return nil, err
+		}
+		return callForValues(rpcURL, governor.Hex(), data, "latest", returnTypes)
+	}
+	if values, err := call("getActions(uint256)", "(address[],uint256[],string[],bytes[])"); err == nil && len(asAddresses(values[0])) > 0 {
+		return buildActions(asAddresses(values[0]), asBigInts(values[1]), asByteSlices(values[3]), asStrings(values[2]))
+	}
+	if values, err := call("proposalDetails(uint256)", "(address[],uint256[],bytes[],bytes32)"); err == nil && len(asAddresses(values[0])) > 0 {
+		return buildActions(asAddresses(values[0]), asBigInts(values[1]), asByteSlices(values[2]), nil)
+	}
+
+	fromBlock, err := parseBlockNumber(from)
+	if err != nil {
+		return nil, err
+	}
+	toBlock, err := latestBlockNumber(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest block: %v", err)
+	}
+	if chunk == 0 {
+		return nil, fmt.Errorf("chunk size must be greater than zero")
+	}
+	topic := eventTopic(proposalCreatedEvent)
+	for start := fromBlock; start <= toBlock; start += chunk {
+		end := start + chunk - 1
+		if end > toBlock {
+			end = toBlock
+		}
+		logs, err := getLogs(rpcURL, []string{governor.Hex()}, []string{topic}, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch proposals for blocks %d-%d: %v", start, end, err)
+		}
+		fmt.Fprintf(os.Stderr, "Scanned blocks %d-%d: %d proposals\n", start, end, len(logs))
+		for _, entry := range logs {
+			values, err := decodeReturnValues(entry.Data, "(uint256,address,address[],uint256[],string[],bytes[],uint256,uint256,string)")
+			if err != nil {
+				continue
+			}
+			if proposalID, _ := asBigInt(values[0]); proposalID != nil && proposalID.Cmp(id) == 0 {
+				return buildActions(asAddresses(values[2]), asBigInts(values[3]), asByteSlices(values[5]), asStrings(values[4]))
+			}
+		}
+	}
+	return nil, fmt.Errorf("proposal %s not found on %s", id, governor.Hex())
+}
+
+// Function to print the actions of a proposal, expanding actions that are themselves
+// governance calls, like a governor scheduling a batch on its timelock
+func printActions(actions []proposalAction, decoder *callDecoder, opts formatOptions, prefix string) {
+	indent := strings.Repeat("  ", strings.Count(prefix, "."))
+	for i, action := range actions {
+		number := fmt.Sprintf("%s%d.", prefix, i+1)
+		line := fmt.Sprintf("%s%s %s", indent, number, formatAddress(action.Target, opts))
+		if action.Value != nil && action.Value.Sign() > 0 {
+			line += fmt.Sprintf(" sending %s ETH", formatFixed(action.Value, 18))
+		}
+		fmt.Println(line)
+		if len(action.Data) == 0 {
+			fmt.Println(indent + "   (plain transfer)")
+			continue
+		}
+		if nested, signature, err := decodeActions(action.Data); err == nil {
+			fmt.Printf("%s   %s with %s:\n", indent, signature, pluralize(len(nested), "call"))
+			printActions(nested, decoder, opts, number)
+			continue
+		}
+		for _, line := range decoder.describeNested(action.Data, opts, indent+"   ") {
+			fmt.Println(line)
+		}
+	}
+}
+
+// Function to convert a decoded address[] value
+func asAddresses(value interface{}) []common.Address {
+	switch v := value.(type) {
+	case []common.Address:
+		return v
+	case common.Address:
+		return []common.Address{v}
+	}
+	return nil
+}
+
+// Function to convert a decoded uint256[] value
+func asBigInts(value interface{}) []*big.Int {
+	switch v := value.(type) {
+	case []*big.Int:
+		return v
+	case *big.Int:
+		return []*big.Int{v}
+	}
+	return nil
+}
+
+// Function to convert a decoded bytes[] value
+func asByteSlices(value interface{}) [][]byte {
+	switch v := value.(type) {
+	case [][]byte:
+		return v
+	case []byte:
+		return [][]byte{v}
+	}
+	return nil
+}
+
+// Function to convert a decoded string[] value
+func asStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	}
+	return nil
+}
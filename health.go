@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/contract-curler/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Defaults of the health checks of endpoint profiles
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultMaxBlockLag    = 5
+	healthCheckTimeout    = 10 * time.Second
+	// Consecutive failed requests opening the circuit of an endpoint, and how long it is
+	// then left out of the rotation
+	circuitThreshold = 3
+	circuitCooldown  = 30 * time.Second
+)
+
+// Strategies spreading the requests of a profile over its endpoints
+const (
+	// The first available endpoint in the order configured
+	strategyFailover = "failover"
+	// Each available endpoint in turn
+	strategyRoundRobin = "round-robin"
+	// The available endpoint that has been responding fastest
+	strategyLowestLatency = "lowest-latency"
+	// The endpoint that last answered, until it fails
+	strategySticky = "sticky"
+)
+
+// Function to get the strategy of a profile, failover if it names none
+func (e EndpointConfig) strategy() (string, error) {
+	switch e.Strategy {
+	case "", strategyFailover:
+		return strategyFailover, nil
+	case strategyRoundRobin, strategyLowestLatency, strategySticky:
+		return e.Strategy, nil
+	}
+	return strategyFailover, fmt.Errorf("unknown strategy '%s' (expected %s, %s, %s or %s)", e.Strategy,
+		strategyFailover, strategyRoundRobin, strategyLowestLatency, strategySticky)
+}
+
+// endpointHealth is what is known of the health of an endpoint URL
+type endpointHealth struct {
+	// Result of the last health check
+	checked time.Time
+	latency time.Duration
+	block   uint64
+	lag     uint64
+	// Why the endpoint is unhealthy, nil if it is not
+	err error
+	// Moving average of the latency of requests and checks
+	average time.Duration
+	// Consecutive failed requests, and when the endpoint is back in the rotation
+	failures  int
+	openUntil time.Time
+}
+
+// Function to fold the latency of a response into the moving average
+func (h *endpointHealth) observe(elapsed time.Duration) {
+	if h.average == 0 {
+		h.average = elapsed
+	} else {
+		h.average = (h.average*4 + elapsed) / 5
+	}
+}
+
+// Function to tell whether the endpoint is in the rotation
+func (h *endpointHealth) available() bool {
+	return !time.Now().Before(h.openUntil)
+}
+
+// endpointPool is where a profile with fallbacks is in its strategy
+type endpointPool struct {
+	// Requests spread so far, for round-robin
+	requests int
+	// Endpoint that last answered, for sticky
+	current string
+}
+
+var health struct {
+	sync.Mutex
+	// Health of the endpoints keyed by URL
+	endpoints map[string]*endpointHealth
+	// Pools of the profiles in use keyed by name, whose endpoints are checked in the
+	// background
+	pools map[string]*endpointPool
+}
+
+// Function to get the health of an endpoint URL, with the health lock held
+func healthOf(url string) *endpointHealth {
+	if health.endpoints == nil {
+		health.endpoints = make(map[string]*endpointHealth)
+	}
+	h, ok := health.endpoints[url]
+	if !ok {
+		h = &endpointHealth{}
+		health.endpoints[url] = h
+	}
+	return h
+}
+
+// Function to get the URLs a request to an endpoint or profile is tried on, in the order
+// given by the strategy of the profile. The endpoints of profiles with fallbacks are
+// checked in the background, and those with an open circuit go last, tried only when all
+// others fail.
+func endpointRotation(rpcURL string) []string {
+	profile, ok := loadEndpointProfiles()[rpcURL]
+	if !ok || len(profile.urls()) < 2 {
+		return []string{resolveEndpoint(rpcURL)}
+	}
+	health.Lock()
+	defer health.Unlock()
+	if health.pools == nil {
+		health.pools = make(map[string]*endpointPool)
+	}
+	pool, ok := health.pools[rpcURL]
+	if !ok {
+		pool = &endpointPool{}
+		health.pools[rpcURL] = pool
+		if _, err := profile.strategy(); err != nil {
+			fmt.Fprintf(os.Stderr, colorize(colorYellow, "Warning")+": endpoint profile '%s': %v, failing over instead\n", rpcURL, err)
+		}
+		go watchProfile(profile)
+	}
+
+	urls := profile.urls()
+	strategy, _ := profile.strategy()
+	switch strategy {
+	case strategyRoundRobin:
+		start := pool.requests % len(urls)
+		pool.requests++
+		urls = append(append([]string{}, urls[start:]...), urls[:start]...)
+	case strategyLowestLatency:
+		// Endpoints not measured yet sort first, so each gets measured
+		sort.SliceStable(urls, func(i, j int) bool {
+			return healthOf(urls[i]).average < healthOf(urls[j]).average
+		})
+	case strategySticky:
+		for i, url := range urls {
+			if url == pool.current {
+				urls = append(append([]string{url}, urls[:i]...), urls[i+1:]...)
+				break
+			}
+		}
+	}
+
+	var available, open []string
+	for _, url := range urls {
+		if healthOf(url).available() {
+			available = append(available, url)
+		} else {
+			open = append(open, url)
+		}
+	}
+	return append(available, open...)
+}
+
+// Function to record the outcome of a request to an endpoint of a profile's rotation,
+// opening its circuit after too many consecutive failures
+func recordOutcome(profile, url string, elapsed time.Duration, err error) {
+	health.Lock()
+	defer health.Unlock()
+	h := healthOf(url)
+	pool := health.pools[profile]
+	if err == nil {
+		h.failures = 0
+		h.observe(elapsed)
+		if pool != nil {
+			pool.current = url
+		}
+		return
+	}
+	if pool != nil && pool.current == url {
+		pool.current = ""
+	}
+	h.failures++
+	if h.failures >= circuitThreshold && h.available() {
+		h.openUntil = time.Now().Add(circuitCooldown)
+		fmt.Fprintf(os.Stderr, colorize(colorYellow, "Warning")+": %s failed %s in a row, leaving it out for %s\n",
+			displayEndpoint(url), pluralize(h.failures, "time"), circuitCooldown)
+	}
+}
+
+// Function to check the endpoints of a profile every health interval, for as long as the
+// program runs
+func watchProfile(profile EndpointConfig) {
+	interval := defaultHealthInterval
+	if profile.HealthInterval > 0 {
+		interval = time.Duration(profile.HealthInterval) * time.Second
+	}
+	for range time.Tick(interval) {
+		checkProfile(profile)
+	}
+}
+
+// Function to check the endpoints of a profile at once, recording their latency and how
+// far each trails the highest block seen. Unhealthy endpoints are left out of the rotation
+// until the next check.
+func checkProfile(profile EndpointConfig) {
+	urls := profile.urls()
+	results := make([]endpointHealth, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			started := time.Now()
+			results[i].block, results[i].err = checkEndpoint(url)
+			results[i].latency = time.Since(started)
+		}(i, url)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for _, result := range results {
+		if result.err == nil {
+			highest = max(highest, result.block)
+		}
+	}
+	maxLag := uint64(defaultMaxBlockLag)
+	if profile.MaxBlockLag > 0 {
+		maxLag = profile.MaxBlockLag
+	}
+	maxLatency := time.Duration(profile.MaxLatencyMs) * time.Millisecond
+
+	health.Lock()
+	defer health.Unlock()
+	now := time.Now()
+	for i, url := range urls {
+		result := results[i]
+		if result.err == nil {
+			result.lag = highest - result.block
+			switch {
+			case result.lag > maxLag:
+				result.err = fmt.Errorf("%s behind", pluralize(int(result.lag), "block"))
+			case maxLatency > 0 && result.latency > maxLatency:
+				result.err = fmt.Errorf("responded in %s", result.latency.Round(time.Millisecond))
+			}
+		}
+		h := healthOf(url)
+		h.checked, h.latency, h.block, h.lag, h.err = now, result.latency, result.block, result.lag, result.err
+		if result.err != nil {
+			// Left out until the next check, which may bring it back
+			h.openUntil = now.Add(max(circuitCooldown, time.Duration(profile.HealthInterval)*time.Second))
+		} else {
+			h.failures = 0
+			h.openUntil = time.Time{}
+			h.observe(result.latency)
+		}
+	}
+}
+
+// Function to get the latest block of an endpoint, bypassing the rotation and the manifest
+func checkEndpoint(url string) (uint64, error) {
+	t, err := rpc.Open(url)
+	if err != nil {
+		return 0, err
+	}
+	request := newRPCRequest("eth_blockNumber", nil)
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	type outcome struct {
+		block uint64
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		body, err := t.RoundTrip(&rpc.Call{Endpoint: url, Payload: jsonData})
+		if err != nil {
+			done <- outcome{err: err}
+			return
+		}
+		defer body.Close()
+		var response JsonRpcResponse
+		if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&response); err != nil {
+			done <- outcome{err: fmt.Errorf("failed to parse response: %v", err)}
+			return
+		}
+		if err := response.validate(request.Id); err != nil {
+			done <- outcome{err: err}
+			return
+		}
+		if response.Error != nil {
+			done <- outcome{err: response.Error}
+			return
+		}
+		var block hexutil.Uint64
+		if err := json.Unmarshal(response.Result, &block); err != nil {
+			done <- outcome{err: fmt.Errorf("invalid block number: %v", err)}
+			return
+		}
+		done <- outcome{block: uint64(block)}
+	}()
+	select {
+	case result := <-done:
+		return result.block, result.err
+	case <-time.After(healthCheckTimeout):
+		return 0, fmt.Errorf("no response in %s", healthCheckTimeout)
+	}
+}
+
+// Function to run the endpoints command
+func runEndpoints(args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		return fmt.Errorf("usage: contract-curler endpoints status [profile...]")
+	}
+	fs := flag.NewFlagSet("endpoints status", flag.ExitOnError)
+	fs.Parse(args[1:])
+
+	profiles := loadEndpointProfiles()
+	names := fs.Args()
+	if len(names) == 0 {
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fmt.Println("No endpoint profiles configured")
+		return nil
+	}
+	for _, name := range names {
+		profile, ok := profiles[name]
+		if !ok {
+			return fmt.Errorf("unknown endpoint profile '%s'", name)
+		}
+		if _, err := profile.strategy(); err != nil {
+			return fmt.Errorf("endpoint profile '%s': %v", name, err)
+		}
+	}
+
+	unhealthy := 0
+	for _, name := range names {
+		profile := profiles[name]
+		checkProfile(profile)
+		strategy, _ := profile.strategy()
+		if len(profile.urls()) > 1 {
+			fmt.Printf("%s (%s)\n", colorize(colorCyan, name), strategy)
+		} else {
+			fmt.Println(colorize(colorCyan, name))
+		}
+		health.Lock()
+		for i, url := range profile.urls() {
+			h := healthOf(url)
+			role := "primary"
+			if i > 0 {
+				role = fmt.Sprintf("fallback %d", i)
+			}
+			if h.err != nil {
+				unhealthy++
+				fmt.Printf("  %s %-11s %s: %v\n", colorize(colorRed, "✗"), role, displayEndpoint(url), h.err)
+				continue
+			}
+			fmt.Printf("  %s %-11s %s: block %d, %s\n", colorize(colorGreen, "✓"), role, displayEndpoint(url),
+				h.block, h.latency.Round(time.Millisecond))
+		}
+		health.Unlock()
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%s unhealthy", pluralize(unhealthy, "endpoint"))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/contract-curler/rpc"
+)
+
+// Function to run the pre-send and post-receive hook plugins of the config around each
+// attempt of a call
+func hookMiddleware(next rpc.Transport) rpc.Transport {
+	cfg, err := loadConfig()
+	if err != nil {
+		return next
+	}
+	var preSend []rpc.PreSendHook
+	for _, name := range cfg.Hooks.PreSend {
+		preSend = append(preSend, hookPlugin(name))
+	}
+	var postReceive []rpc.PostReceiveHook
+	for _, name := range cfg.Hooks.PostReceive {
+		postReceive = append(postReceive, hookPlugin(name))
+	}
+	return rpc.Hooks(preSend, postReceive)(next)
+}
+
+// hookPlugin is a hook plugin of the config, run on each request or response
+type hookPlugin string
+
+func (name hookPlugin) PreSend(call *rpc.Call) error {
+	output, err := runHook(string(name), "pre-send", call.Endpoint, payloadMethod(call.Payload), call.Payload)
+	if err != nil {
+		return err
+	}
+	header, payload, err := parseHookOutput(output)
+	if err != nil {
+		return fmt.Errorf("pre-send hook %s printed invalid headers: %v", name, err)
+	}
+	if len(payload) > 0 {
+		call.Payload = payload
+	}
+	if len(header) > 0 && call.Header == nil {
+		call.Header = make(http.Header)
+	}
+	for key, values := range header {
+		call.Header[key] = values
+	}
+	return nil
+}
+
+func (name hookPlugin) PostReceive(call *rpc.Call, response []byte) ([]byte, error) {
+	output, err := runHook(string(name), "post-receive", call.Endpoint, payloadMethod(call.Payload), response)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(output)) > 0 {
+		return output, nil
+	}
+	return response, nil
+}
+
+// Function to run a hook plugin on a request or response, returning what it prints. The
+// plugin gets the stage as its argument, and the endpoint and method in its environment.
+func runHook(name, stage, endpoint, method string, input []byte) ([]byte, error) {
+	path, ok := findPlugin(name)
+	if !ok {
+		return nil, fmt.Errorf("%s hook '%s' not found (expected %s%s in %s or on the PATH)", stage, name, pluginPrefix, name, pluginDir())
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, stage)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &stdout, &stderr
+	cmd.Env = append(os.Environ(), "CONTRACT_CURLER_HOME="+configDir(), "CONTRACT_CURLER_ENDPOINT="+endpoint, "CONTRACT_CURLER_METHOD="+method)
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s hook %s failed: %s", stage, name, message)
+		}
+		return nil, fmt.Errorf("%s hook %s failed: %v", stage, name, err)
+	}
+	os.Stderr.Write(stderr.Bytes())
+	return stdout.Bytes(), nil
+}
+
+// Function to split the output of a pre-send hook into the header lines it may start
+// with, ended by an empty line, and the payload following them
+func parseHookOutput(output []byte) (http.Header, []byte, error) {
+	trimmed := bytes.TrimSpace(output)
+	if len(trimmed) == 0 || trimmed[0] == '{' || trimmed[0] == '[' {
+		return nil, trimmed, nil
+	}
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(output)))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	payload, _ := ioutil.ReadAll(reader.R)
+	return http.Header(header), bytes.TrimSpace(payload), nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Function to split ABI-encoded data into 32-byte words, the last one possibly short
+func splitWords(data []byte) [][]byte {
+	var words [][]byte
+	for i := 0; i < len(data); i += 32 {
+		end := i + 32
+		if end > len(data) {
+			end = len(data)
+		}
+		words = append(words, data[i:end])
+	}
+	return words
+}
+
+// Function to check whether a word looks like a left-padded address
+func isAddressWord(word []byte) bool {
+	if len(word) != 32 {
+		return false
+	}
+	for _, b := range word[:12] {
+		if b != 0 {
+			return false
+		}
+	}
+	// Small numbers are far more likely than addresses with many leading zero bytes
+	return new(big.Int).SetBytes(word).BitLen() > 128
+}
+
+// Function to check whether a word looks like a negative two's complement integer
+func isNegativeWord(word []byte) bool {
+	return len(word) == 32 && word[0] == 0xff && word[1] == 0xff
+}
+
+// Function to guess the most plausible static type of a word
+func guessWordType(word []byte) string {
+	n := new(big.Int).SetBytes(word)
+	switch {
+	case len(word) != 32:
+		return "bytes"
+	case n.Sign() == 0 || n.Cmp(big.NewInt(1)) == 0:
+		return "bool"
+	case isAddressWord(word):
+		return "address"
+	case isNegativeWord(word):
+		return "int256"
+	}
+	if _, ok := bytes32String(word); ok {
+		return "bytes32"
+	}
+	if n.BitLen() > 128 {
+		return "bytes32"
+	}
+	return "uint256"
+}
+
+// Function to describe the plausible interpretations of a word
+func describeWord(word []byte) string {
+	if len(word) != 32 {
+		return fmt.Sprintf("truncated word (%d bytes)", len(word))
+	}
+
+	n := new(big.Int).SetBytes(word)
+	var interpretations []string
+	switch guessWordType(word) {
+	case "bool":
+		interpretations = append(interpretations, "uint256 "+n.String(), fmt.Sprintf("bool %v", n.Sign() != 0))
+	case "address":
+		interpretations = append(interpretations, "address "+common.BytesToAddress(word).Hex())
+	case "int256":
+		signed := new(big.Int).Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		interpretations = append(interpretations, "int256 "+signed.String())
+	case "bytes32":
+		if s, ok := bytes32String(word); ok {
+			interpretations = append(interpretations, fmt.Sprintf("bytes32 %q", s))
+		} else {
+			interpretations = append(interpretations, "bytes32 or hash")
+		}
+	default:
+		interpretations = append(interpretations, "uint256 "+n.String())
+	}
+	return strings.Join(interpretations, ", ")
+}
+
+// Function to guess a return type list for undecodable data
+func guessReturnTypes(data []byte) string {
+	words := splitWords(data)
+
+	// A single dynamic string or bytes value has an offset of 32 followed by its length
+	if len(words) >= 2 && len(data)%32 == 0 {
+		offset := new(big.Int).SetBytes(words[0])
+		length := new(big.Int).SetBytes(words[1])
+		if offset.Cmp(big.NewInt(32)) == 0 && length.IsInt64() && 64+length.Int64() <= int64(len(data)) &&
+			int64(len(data)) <= 64+length.Int64()+31 {
+			content := data[64 : 64+length.Int64()]
+			if s := string(content); isPrintable(s) {
+				return "(string)"
+			}
+			return "(bytes)"
+		}
+	}
+
+	types := make([]string, len(words))
+	for i, word := range words {
+		types[i] = guessWordType(word)
+	}
+	return "(" + strings.Join(types, ",") + ")"
+}
+
+// Function to check whether a string only holds printable ASCII
+func isPrintable(s string) bool {
+	for _, c := range []byte(s) {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// Function to print raw return data when it cannot be decoded against the declared types
+func printRawFallback(data []byte) {
+	fmt.Println("\nRaw Result:")
+	fmt.Println("0x" + hex.EncodeToString(data))
+
+	if len(data) == 0 {
+		fmt.Println("(empty: the call returned no data)")
+		return
+	}
+	if len(data)%32 != 0 {
+		fmt.Printf("(length %d is not a multiple of 32 bytes, so this is not plain ABI-encoded data)\n", len(data))
+	}
+
+	fmt.Println("\nWords:")
+	printWords(data)
+
+	fmt.Println("\nPossible return type:", guessReturnTypes(data))
+}
+
+// Function to print data word by word with offsets and annotations
+func printWords(data []byte) {
+	notes := annotateWords(data)
+	for i, word := range splitWords(data) {
+		fmt.Printf("[%d] 0x%04x: %s  %s\n", i, i*32, hex.EncodeToString(word), notes[i])
+	}
+}
+
+// Function to annotate each word of ABI-encoded data, recognising heads that point
+// forward to a length word and the string, bytes or array content that follows it
+func annotateWords(data []byte) []string {
+	words := splitWords(data)
+	notes := make([]string, len(words))
+
+	for i, word := range words {
+		if notes[i] != "" || len(word) != 32 {
+			continue
+		}
+		value := new(big.Int).SetBytes(word)
+		if !value.IsInt64() {
+			continue
+		}
+		offset := value.Int64()
+		if offset%32 != 0 || offset <= int64(i*32) || offset >= int64(len(data)) {
+			continue
+		}
+
+		// The target must hold a length whose content fits in the data
+		k := int(offset / 32)
+		lengthValue := new(big.Int).SetBytes(words[k])
+		if !lengthValue.IsInt64() || len(words[k]) != 32 {
+			continue
+		}
+		length := lengthValue.Int64()
+		start := int64(k+1) * 32
+		fitsAsBytes := start+length <= int64(len(data))
+		fitsAsArray := int64(k+1)+length <= int64(len(words))
+		if !fitsAsBytes && !fitsAsArray {
+			continue
+		}
+
+		notes[i] = fmt.Sprintf("head: offset 0x%x -> word %d", offset, k)
+		if notes[k] != "" {
+			continue
+		}
+
+		switch {
+		case length == 0:
+			notes[k] = "tail: length 0 (empty string, bytes or array)"
+		case fitsAsBytes && isPrintable(string(data[start:start+length])):
+			notes[k] = fmt.Sprintf("tail: length %d", length)
+			notes[k+1] = fmt.Sprintf("string %q", data[start:start+length])
+			for j := k + 2; int64(j*32) < start+length; j++ {
+				notes[j] = "(string continued)"
+			}
+		case fitsAsArray:
+			notes[k] = fmt.Sprintf("tail: length %d (array or bytes)", length)
+		default:
+			notes[k] = fmt.Sprintf("tail: length %d (bytes)", length)
+			for j := k + 1; int64(j*32) < start+length; j++ {
+				notes[j] = "bytes content"
+			}
+		}
+	}
+
+	// Everything else is described on its own
+	for i, word := range words {
+		if notes[i] == "" {
+			notes[i] = describeWord(word)
+		}
+	}
+	return notes
+}
+
+// Function to inspect arbitrary ABI-encoded data or calldata given as hex
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: contract-curler inspect <0x-hex data>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var input string
+	if fs.NArg() > 0 {
+		input = fs.Arg(0)
+	} else {
+		raw, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read data: %v", err)
+		}
+		input = string(raw)
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(input), "0x"))
+	if err != nil {
+		return fmt.Errorf("failed to decode hex data: %v", err)
+	}
+
+	// Calldata and revert data start with a 4-byte selector before the words
+	if len(data)%32 == 4 {
+		selector := "0x" + hex.EncodeToString(data[:4])
+		fmt.Printf("Selector: %s\n", selector)
+		if db, err := loadSignatureDB(); err == nil {
+			for _, signature := range db.lookup(selector) {
+				fmt.Printf("  known as %s\n", signature)
+			}
+		}
+		data = data[4:]
+	}
+	fmt.Printf("Length: %d bytes (%d words)\n", len(data), (len(data)+31)/32)
+	if len(data) == 0 {
+		return nil
+	}
+
+	printWords(data)
+	fmt.Println("\nPossible types:", guessReturnTypes(data))
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// solidityWriter renders ABI entries as a Solidity interface, collecting the structs the
+// tuples of the entries need
+type solidityWriter struct {
+	structs     []string
+	structNames map[string]string
+}
+
+// Function to generate a Solidity interface or a human-readable ABI from an ABI file, or
+// from the selectors found in the code of a contract
+func runInterface(args []string) error {
+	fs := flag.NewFlagSet("interface", flag.ExitOnError)
+	abiPath := fs.String("abi", "", "ABI or artifact file to generate the interface from")
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	block := fs.String("block", "latest", "block to read the code at")
+	name := fs.String("name", "IContract", "name of the generated interface")
+	format := fs.String("format", "sol", "output format: sol for a Solidity interface, human for a human-readable ABI")
+	out := fs.String("out", "", "file to write to instead of the standard output")
+	fs.Parse(args)
+
+	if *format != "sol" && *format != "human" {
+		return fmt.Errorf("invalid format '%s' (expected sol or human)", *format)
+	}
+	var entries []abiEntry
+	var unknown []string
+	switch {
+	case *abiPath != "":
+		contract, err := loadABI(*abiPath)
+		if err != nil {
+			return err
+		}
+		entries = contract.Entries
+	case fs.NArg() == 1:
+		// Only the selectors are known, so parameters are unnamed and outputs are missing
+		code, err := loadCode(fs.Arg(0), firstNonEmpty(*rpcURL, chainRPC(*chain)), *chain, *block)
+		if err != nil {
+			return err
+		}
+		code, _ = splitMetadata(code)
+		db, err := loadSignatureDB()
+		if err != nil {
+			return err
+		}
+		for _, selector := range dispatchTable(disassemble(code)) {
+			signatures := db.lookup(selector.selector)
+			if len(signatures) == 0 {
+				unknown = append(unknown, selector.selector)
+				continue
+			}
+			entry, err := entryFromSignature(signatures[0])
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return fmt.Errorf("usage: contract-curler interface [flags] --abi <file> | <address | hex file>")
+	}
+
+	var text string
+	if *format == "human" {
+		text = humanReadableABI(entries)
+	} else {
+		text = solidityInterface(*name, entries, unknown, *abiPath == "")
+	}
+	if *out == "" {
+		fmt.Print(text)
+		return nil
+	}
+	if err := ioutil.WriteFile(*out, []byte(text), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", *out, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *out)
+	return nil
+}
+
+// Function to render entries as a Solidity interface. Selectors without a known signature
+// are listed as comments.
+func solidityInterface(name string, entries []abiEntry, unknown []string, fromSelectors bool) string {
+	w := &solidityWriter{structNames: make(map[string]string)}
+	var body []string
+	for _, kind := range []string{"error", "event", "function"} {
+		for _, entry := range entries {
+			if entry.Type != kind {
+				continue
+			}
+			switch kind {
+			case "error":
+				body = append(body, fmt.Sprintf("error %s(%s);", entry.Name, w.params(entry.Inputs, "", false)))
+			case "event":
+				body = append(body, fmt.Sprintf("event %s(%s);", entry.Name, w.params(entry.Inputs, "", true)))
+			case "function":
+				line := fmt.Sprintf("function %s(%s) external", entry.Name, w.params(entry.Inputs, "calldata", false))
+				if entry.StateMutability != "" && entry.StateMutability != "nonpayable" {
+					line += " " + entry.StateMutability
+				}
+				if len(entry.Outputs) > 0 {
+					line += fmt.Sprintf(" returns (%s)", w.params(entry.Outputs, "memory", false))
+				}
+				body = append(body, line+";")
+			}
+		}
+	}
+	for _, selector := range unknown {
+		body = append(body, "// "+selector+": unknown selector")
+	}
+
+	var b strings.Builder
+	b.WriteString("// SPDX-License-Identifier: UNLICENSED\npragma solidity ^0.8.0;\n\n")
+	if fromSelectors {
+		b.WriteString("// Generated from the selectors of the contract code: return values and mutability are unknown\n")
+	}
+	b.WriteString("interface " + name + " {\n")
+	for _, def := range w.structs {
+		b.WriteString(def + "\n")
+	}
+	if len(w.structs) > 0 && len(body) > 0 {
+		b.WriteString("\n")
+	}
+	for _, line := range body {
+		b.WriteString("    " + line + "\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Function to render a parameter list. Reference types get the data location, which
+// events and errors do not take.
+func (w *solidityWriter) params(params []abiParam, location string, event bool) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		part := w.solidityType(p)
+		if location != "" && isReferenceType(p.Type) {
+			part += " " + location
+		}
+		if event && p.Indexed {
+			part += " indexed"
+		}
+		if p.Name != "" {
+			part += " " + p.Name
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Function to get the Solidity type of a parameter, declaring a struct for tuples
+func (w *solidityWriter) solidityType(p abiParam) string {
+	if !strings.HasPrefix(p.Type, "tuple") {
+		return p.Type
+	}
+	suffix := strings.TrimPrefix(p.Type, "tuple")
+	canonical := p.canonicalType()
+	canonical = canonical[:len(canonical)-len(suffix)]
+	if name, ok := w.structNames[canonical]; ok {
+		return name + suffix
+	}
+
+	// Structs are named like in the source when the ABI has internal types
+	name := fmt.Sprintf("Struct%d", len(w.structs)+1)
+	if strings.HasPrefix(p.InternalType, "struct ") {
+		name = strings.TrimPrefix(p.InternalType, "struct ")
+		name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], suffix)
+	}
+	w.structNames[canonical] = name
+
+	members := make([]string, len(p.Components))
+	for i, component := range p.Components {
+		memberName := component.Name
+		if memberName == "" {
+			memberName = fmt.Sprintf("field%d", i)
+		}
+		members[i] = fmt.Sprintf("        %s %s;", w.solidityType(component), memberName)
+	}
+	w.structs = append(w.structs, "    struct "+name+" {\n"+strings.Join(members, "\n")+"\n    }")
+	return name + suffix
+}
+
+// Function to check whether values of a type live in memory or calldata
+func isReferenceType(typ string) bool {
+	return typ == "string" || typ == "bytes" || strings.HasSuffix(typ, "]") || strings.HasPrefix(typ, "tuple")
+}
+
+// Function to render entries as a human-readable ABI, one signature per line as accepted
+// by ethers and viem
+func humanReadableABI(entries []abiEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		switch entry.Type {
+		case "function":
+			line := fmt.Sprintf("function %s(%s)", entry.Name, humanParams(entry.Inputs))
+			if entry.StateMutability != "" && entry.StateMutability != "nonpayable" {
+				line += " " + entry.StateMutability
+			}
+			if len(entry.Outputs) > 0 {
+				line += fmt.Sprintf(" returns (%s)", humanParams(entry.Outputs))
+			}
+			b.WriteString(line + "\n")
+		case "event", "error":
+			b.WriteString(fmt.Sprintf("%s %s(%s)\n", entry.Type, entry.Name, humanParams(entry.Inputs)))
+		}
+	}
+	return b.String()
+}
+
+// Function to render parameters of a human-readable ABI, with tuples inline
+func humanParams(params []abiParam) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		part := p.Type
+		if strings.HasPrefix(p.Type, "tuple") {
+			part = "(" + humanParams(p.Components) + ")" + strings.TrimPrefix(p.Type, "tuple")
+		}
+		if p.Indexed {
+			part += " indexed"
+		}
+		if p.Name != "" {
+			part += " " + p.Name
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.starlark.net/starlark"
+)
+
+// Invariants are Starlark expressions over the calls of a batch file, listed under
+// "invariants". Each call is bound by its name: to its value if it has a single output,
+// otherwise to a dict of its outputs by name and by index, like in scripts.
+//
+//	"invariants": ["supply == sum([alice, bob, treasury])", "reserves[0] > 0"]
+
+// Function to check invariants over call results every few blocks, alerting when one breaks
+// and when it holds again
+func runInvariant(args []string) error {
+	fs := flag.NewFlagSet("invariant", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL, overriding the batch file")
+	chain := fs.String("chain", "", "chain name or id, overriding the batch file")
+	every := fs.Uint64("every", 1, "check the invariants every this many blocks")
+	interval := fs.Duration("interval", 12*time.Second, "time between polls for new blocks")
+	once := fs.Bool("once", false, "check the invariants at a single block, given by --block, and exit")
+	block := fs.String("block", "latest", "block checked with --once")
+	stopOnBreak := fs.Bool("exit-on-break", false, "exit with an error as soon as an invariant breaks, e.g. to hand alerting to a supervisor")
+	noColor := fs.Bool("no-color", false, "never color the output, even on a terminal")
+	fs.Parse(args)
+	if *noColor {
+		colorEnabled = false
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler invariant [flags] <file>")
+	}
+	if *every < 1 {
+		return fmt.Errorf("--every must be at least 1")
+	}
+
+	batch, err := loadPipeline(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(batch.Invariants) == 0 {
+		return fmt.Errorf("%s lists no invariants", fs.Arg(0))
+	}
+	batch.Chain = firstNonEmpty(*chain, batch.Chain)
+	if *chain != "" && *rpcURL == "" {
+		batch.RpcURL = ""
+	}
+	batch.RpcURL = firstNonEmpty(*rpcURL, batch.RpcURL, chainRPC(batch.Chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg)
+	if !*once {
+		*block = "latest"
+	}
+	blockParam, err := pinBlock(batch.RpcURL, *block)
+	if err != nil {
+		return err
+	}
+	// Arguments are resolved once, at the first block checked
+	calls, err := prepareBatch(batch, blockParam)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Checking %s over %s on %s\n", pluralize(len(batch.Invariants), "invariant"),
+		pluralize(len(calls.requests), "call"), displayEndpoint(batch.RpcURL))
+
+	broken := make(map[string]bool)
+	for {
+		newlyBroken, err := checkInvariants(batch, calls, blockParam, broken, opts)
+		if err != nil {
+			return err
+		}
+		if *once || *stopOnBreak && newlyBroken > 0 {
+			if len(broken) > 0 {
+				return fmt.Errorf("%s of %d broken at block %s", pluralize(len(broken), "invariant"), len(batch.Invariants), blockParam)
+			}
+			return nil
+		}
+
+		checked, _ := hexutil.DecodeUint64(blockParam)
+		next := checked + *every
+		for {
+			time.Sleep(*interval)
+			head, err := latestBlockNumber(batch.RpcURL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to get latest block: %v\n", err)
+				continue
+			}
+			if head >= next {
+				blockParam = hexutil.EncodeUint64(head)
+				break
+			}
+		}
+	}
+}
+
+// Function to run the calls of a batch at a block and evaluate its invariants over their
+// results, reporting the invariants that broke or hold again since the last check.
+// Returns how many invariants newly broke.
+func checkInvariants(batch *pipelineFile, calls *batchCalls, blockParam string, broken map[string]bool, opts formatOptions) (int, error) {
+	stamp := ""
+	if !deterministic {
+		stamp = time.Now().Format("15:04:05") + " "
+	}
+	results, _, err := executeCalls(batch.RpcURL, blockParam, calls.requests, "auto", 8)
+	if err != nil {
+		fmt.Printf("%sBlock %s: %v\n", stamp, blockParam, err)
+		return 0, nil
+	}
+
+	env := starlark.StringDict{
+		"fixed": starlark.NewBuiltin("fixed", scriptFixed),
+		"sum":   starlark.NewBuiltin("sum", scriptSum),
+	}
+	all := starlark.NewDict(len(results))
+	formatted := make([][]string, len(results))
+	returnTypes := make([][]string, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%sBlock %s: call %s failed, invariants not checked: %v\n", stamp, blockParam, calls.names[i], result.Err)
+			return 0, nil
+		}
+		values, err := decodeReturnValues(hexutil.Encode(result.Output), calls.returns[i])
+		if err != nil {
+			fmt.Printf("%sBlock %s: call %s failed, invariants not checked: %v\n", stamp, blockParam, calls.names[i], err)
+			return 0, nil
+		}
+		returnTypes[i] = splitTypeList(trimTypeList(calls.returns[i]))
+		stepOpts := opts
+		stepOpts.Names = nil
+		for _, returnType := range returnTypes[i] {
+			_, outputName := splitTypeName(returnType)
+			stepOpts.Names = append(stepOpts.Names, outputName)
+		}
+		formatted[i] = formatReturnValues(values, returnTypes[i], stepOpts)
+
+		outputs := scriptOutputs(values, stepOpts.Names)
+		all.SetKey(starlark.String(calls.names[i]), outputs)
+		if len(values) == 1 {
+			env[calls.names[i]] = toStarlark(values[0])
+		} else {
+			env[calls.names[i]] = outputs
+		}
+	}
+	env["results"] = all
+
+	var newlyBroken, holding int
+	for _, invariant := range batch.Invariants {
+		thread := &starlark.Thread{Name: "invariant"}
+		value, err := starlark.Eval(thread, "invariant", invariant, env)
+		if err != nil {
+			return 0, fmt.Errorf("invariant '%s': %v", invariant, err)
+		}
+		holds, ok := value.(starlark.Bool)
+		if !ok {
+			return 0, fmt.Errorf("invariant '%s' is a %s, not a bool", invariant, value.Type())
+		}
+		switch {
+		case bool(holds) && broken[invariant]:
+			delete(broken, invariant)
+			fmt.Printf("%sBlock %s: %s %s\n", stamp, blockParam, colorize(colorGreen, "HOLDS AGAIN"), invariant)
+		case !bool(holds) && !broken[invariant]:
+			broken[invariant] = true
+			newlyBroken++
+			fmt.Printf("%sBlock %s: %s %s\n", stamp, blockParam, colorize(colorRed, "BROKEN"), invariant)
+		}
+		if holds {
+			holding++
+		}
+	}
+	if newlyBroken > 0 {
+		for i, name := range calls.names {
+			fmt.Printf("  [%s]\n", name)
+			printReturnValues(formatted[i], returnTypes[i], "    ")
+		}
+	}
+	fmt.Printf("%sBlock %s: %d of %s hold\n", stamp, blockParam, holding, pluralize(len(batch.Invariants), "invariant"))
+	return newlyBroken, nil
+}
+
+// Function implementing sum(values), which adds up a list of integers
+func scriptSum(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var values starlark.Iterable
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &values); err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	iter := values.Iterate()
+	defer iter.Done()
+	var value starlark.Value
+	for iter.Next(&value) {
+		n, ok := value.(starlark.Int)
+		if !ok {
+			return nil, fmt.Errorf("%s: got %s, want int", b.Name(), value.Type())
+		}
+		total.Add(total, n.BigInt())
+	}
+	return starlark.MakeBigInt(total), nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Labels of well-known contracts keyed by chain id, shown when neither the configuration
+// nor a label file labels an address
+var knownLabels = map[uint64]map[string]string{
+	1: {
+		"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2": "WETH",
+		"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48": "USDC",
+		"0xdAC17F958D2ee523a2206206994597C13D831ec7": "USDT",
+		"0x6B175474E89094C44Da98b954EedeAC495271d0F": "DAI",
+		"0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599": "WBTC",
+		"0xae7ab96520DE3A18E5e111B5EaAb095312D7fE84": "Lido stETH",
+		"0x7f39C581F595B53c5cb19bD0b3f8dA6c935E2Ca0": "Lido wstETH",
+		"0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f": "Uniswap V2 Factory",
+		"0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D": "Uniswap V2 Router",
+		"0x1F98431c8aD98523631AE4a59f267346ea31F984": "Uniswap V3 Factory",
+		"0xE592427A0AEce92De3Edee1F18E0157C05861564": "Uniswap V3 Router",
+		"0x68b3465833fb72A70ecDF485E0e4C7bD8665Fc45": "Uniswap V3 Router 2",
+		"0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD": "Uniswap Universal Router",
+		"0x1111111254EEB25477B68fb85Ed929f73A960582": "1inch Router V5",
+		"0x111111125421cA6dc452d289314280a0f8842A65": "1inch Router V6",
+		"0xDef1C0ded9bec7F1a1670819833240f027b25EfF": "0x Exchange Proxy",
+		"0x9008D19f58AAbD9eD0D60971565AA8510560ab41": "CoW Protocol Settlement",
+		"0xbEbc44782C7dB0a1A60Cb6fe97d0b483032FF1C7": "Curve 3pool",
+		"0x7d2768dE32b0b80b7a3454c06BdAc94A69DDc7A9": "Aave V2 Pool",
+		"0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419": "Chainlink ETH/USD",
+		"0xd9Db270c1B5E3Bd161E8c8503c55cEABeE709552": "Safe Singleton 1.3.0",
+		"0xa6B71E26C5e0845f74c812102Ca7114b6a896AB2": "Safe Proxy Factory 1.3.0",
+		"0x4Dbd4fc535Ac27206064B68FfCf827b0A60BAB3f": "Arbitrum Delayed Inbox",
+	},
+	10: {
+		"0x4200000000000000000000000000000000000006": "WETH",
+		"0x0b2C639c533813f4Aa9D7837cAf62653d097Ff85": "USDC",
+		"0x4200000000000000000000000000000000000016": "L2ToL1MessagePasser",
+	},
+	137: {
+		"0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359": "USDC",
+	},
+	8453: {
+		"0x4200000000000000000000000000000000000006": "WETH",
+		"0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913": "USDC",
+		"0x4200000000000000000000000000000000000016": "L2ToL1MessagePasser",
+	},
+	42161: {
+		"0x82aF49447D8a07e3bd95BD0d56f35241523fBab1": "WETH",
+		"0xaf88d065e77c8cC2239327C5EDb3A432268e5831": "USDC",
+		"0x0000000000000000000000000000000000000064": "ArbSys",
+	},
+}
+
+// Labels of contracts deployed at the same address on every chain
+var universalLabels = map[string]string{
+	multicall3Address: "Multicall3",
+	permit2Address:    "Permit2",
+	balancerVault:     "Balancer Vault",
+}
+
+// labelSet is a set of address labels keyed by chain id, 0 holding the labels of every chain
+type labelSet map[uint64]map[string]string
+
+// Function to add a label to a set, keyed by lowercase address
+func (s labelSet) add(chainID uint64, address, label string) {
+	if s[chainID] == nil {
+		s[chainID] = make(map[string]string)
+	}
+	s[chainID][strings.ToLower(address)] = label
+}
+
+// Function to get the bundled labels: the well-known contracts above and the contracts
+// the presets of this tool know the addresses of
+func bundledLabels() labelSet {
+	set := make(labelSet)
+	for address, label := range universalLabels {
+		set.add(0, address, label)
+	}
+	for chainID, labels := range knownLabels {
+		for address, label := range labels {
+			set.add(chainID, address, label)
+		}
+	}
+	for chainID, address := range aavePools {
+		set.add(chainID, address, "Aave V3 Pool")
+	}
+	for chainID, address := range depositContracts {
+		set.add(chainID, address, "Beacon Deposit Contract")
+	}
+	for l2, portal := range optimismPortals {
+		set.add(portal.chain, portal.contract, "OptimismPortal ("+chainName(l2)+")")
+	}
+	for l2, outbox := range arbitrumOutboxes {
+		set.add(outbox.chain, outbox.contract, "Arbitrum Outbox ("+chainName(l2)+")")
+	}
+	for _, chainID := range layerZeroChains {
+		set.add(chainID, layerZeroEndpoint, "LayerZero EndpointV2")
+	}
+	for _, chainID := range []uint64{1, 17000, 11155111} {
+		set.add(chainID, ensRegistry, "ENS Registry")
+	}
+	return set
+}
+
+// Function to read a label file: a JSON object of addresses to labels for every chain, or
+// of chain names or ids ("*" for every chain) to such objects, like
+// {"mainnet": {"0x7a25...": "Uniswap V2 Router"}, "*": {"0xcA11...": "Multicall3"}}
+func readLabelFile(path string) (labelSet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read label file: %v", err)
+	}
+	return parseLabels(data, path)
+}
+
+// Function to parse the content of a label file named source in errors
+func parseLabels(data []byte, source string) (labelSet, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid label file %s: %v", source, err)
+	}
+	set := make(labelSet)
+	for key, raw := range fields {
+		if common.IsHexAddress(key) {
+			var label string
+			if err := json.Unmarshal(raw, &label); err != nil {
+				return nil, fmt.Errorf("invalid label of %s in %s: %v", key, source, err)
+			}
+			set.add(0, key, label)
+			continue
+		}
+		chainID := uint64(0)
+		if key != "*" {
+			var err error
+			if chainID, err = parseChain(key); err != nil {
+				return nil, fmt.Errorf("invalid label file %s: %v", source, err)
+			}
+		}
+		var labels map[string]string
+		if err := json.Unmarshal(raw, &labels); err != nil {
+			return nil, fmt.Errorf("invalid labels of chain '%s' in %s: %v", key, source, err)
+		}
+		for address, label := range labels {
+			if !common.IsHexAddress(address) {
+				return nil, fmt.Errorf("invalid address '%s' in %s", address, source)
+			}
+			set.add(chainID, address, label)
+		}
+	}
+	return set, nil
+}
+
+// Function to get the path of the label dataset downloaded by labels update
+func labelDatasetPath() string {
+	return filepath.Join(configDir(), "labels.json")
+}
+
+// Function to get the labels of a chain from every source, later sources overriding
+// earlier ones: the bundled labels, the downloaded dataset, the configured label files and
+// the labels of the configuration. Each label is returned with the source it came from.
+func chainLabels(cfg *Config, chainID uint64) (map[string]string, map[string]string, error) {
+	labels, sources := make(map[string]string), make(map[string]string)
+	apply := func(set labelSet, source string) {
+		for _, id := range []uint64{0, chainID} {
+			for address, label := range set[id] {
+				labels[address], sources[address] = label, source
+			}
+		}
+	}
+	apply(bundledLabels(), "bundled")
+	if _, err := os.Stat(labelDatasetPath()); err == nil {
+		set, err := readLabelFile(labelDatasetPath())
+		if err != nil {
+			return nil, nil, err
+		}
+		apply(set, "dataset")
+	}
+	for _, path := range cfg.LabelFiles {
+		set, err := readLabelFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		apply(set, path)
+	}
+	for address, label := range cfg.Labels {
+		labels[address], sources[address] = label, "config"
+	}
+	return labels, sources, nil
+}
+
+// Function to manage address labels: list the labels of a chain, look up addresses and
+// update the downloaded label dataset
+func runLabels(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler labels list|lookup|update [flags]")
+	}
+	fs := flag.NewFlagSet("labels "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id whose labels are used")
+	url := fs.String("url", "", "URL of the label dataset to download (default: labelsUrl of the configuration)")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if args[0] == "update" {
+		return updateLabelDataset(firstNonEmpty(*url, cfg.LabelsURL))
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	chainID, err := cfg.chainID(*chain, endpoint)
+	if err != nil {
+		return err
+	}
+	labels, sources, err := chainLabels(cfg, chainID)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		addresses := make([]string, 0, len(labels))
+		for address := range labels {
+			addresses = append(addresses, address)
+		}
+		sort.Slice(addresses, func(i, j int) bool {
+			return strings.ToLower(labels[addresses[i]]) < strings.ToLower(labels[addresses[j]])
+		})
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "address\tlabel\tsource\t")
+		for _, address := range addresses {
+			fmt.Fprintf(w, "%s\t%s\t%s\t\n", common.HexToAddress(address).Hex(), labels[address], sources[address])
+		}
+		w.Flush()
+		fmt.Printf("\n%s for %s\n", pluralize(len(labels), "label"), chainName(chainID))
+		return nil
+
+	case "lookup":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: contract-curler labels lookup [flags] <address>...")
+		}
+		for _, arg := range fs.Args() {
+			address, err := parseAddress(arg)
+			if err != nil {
+				return fmt.Errorf("invalid address '%s': %v", arg, err)
+			}
+			if label, ok := labels[strings.ToLower(address.Hex())]; ok {
+				fmt.Printf("%s: %s (%s)\n", address.Hex(), label, sources[strings.ToLower(address.Hex())])
+			} else {
+				fmt.Printf("%s: no label on %s\n", address.Hex(), chainName(chainID))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown labels subcommand '%s' (expected list, lookup or update)", args[0])
+}
+
+// Function to download a label dataset, checking it parses before replacing the old one
+func updateLabelDataset(url string) error {
+	if url == "" {
+		return fmt.Errorf("no dataset to download, give its URL with --url or set \"labelsUrl\" in %s", filepath.Join(configDir(), "config.json"))
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	set, err := parseLabels(data, url)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	tmpPath := labelDatasetPath() + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write label dataset: %v", err)
+	}
+	if err := os.Rename(tmpPath, labelDatasetPath()); err != nil {
+		return fmt.Errorf("failed to write label dataset: %v", err)
+	}
+	count := 0
+	for _, labels := range set {
+		count += len(labels)
+	}
+	fmt.Printf("Saved %s to %s\n", pluralize(count, "label"), labelDatasetPath())
+	return nil
+}
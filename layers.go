@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Key of argument files and templates holding the positional arguments of a command
+const positionalKey = "args"
+
+// layeredFlags are the flags selecting the layers merged under the command line
+type layeredFlags struct {
+	fs             *flag.FlagSet
+	template       *string
+	argsFiles      *string
+	printEffective *bool
+	// Layer each flag got its value from, for --print-effective-config
+	sources map[string]string
+}
+
+// Function to register the flags of layered configuration on a flag set. Flag values come
+// from, lowest first: the defaults and config file, a template of the config file, argument
+// files in the order given, and the command line.
+func addLayeredFlags(fs *flag.FlagSet) *layeredFlags {
+	return &layeredFlags{
+		fs:             fs,
+		template:       fs.String("template", "", "template of the config file whose flag values are applied"),
+		argsFiles:      fs.String("args-file", "", "comma-separated JSON files of flag values, applied in order over the template"),
+		printEffective: fs.Bool("print-effective-config", false, "print the merged config and the flag values with where they come from, then exit"),
+		sources:        make(map[string]string),
+	}
+}
+
+// Function to apply the template and argument files to the flags not given on the command
+// line, returning the positional arguments, which the layers supply when there are none
+func (l *layeredFlags) apply(cfg *Config) ([]string, error) {
+	explicit := make(map[string]bool)
+	l.fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+		l.sources[f.Name] = "command line"
+	})
+
+	type layer struct {
+		source string
+		values map[string]interface{}
+	}
+	var layers []layer
+	if *l.template != "" {
+		values, ok := cfg.Templates[*l.template]
+		if !ok {
+			return nil, fmt.Errorf("unknown template '%s'", *l.template)
+		}
+		layers = append(layers, layer{"template " + *l.template, values})
+	}
+	if *l.argsFiles != "" {
+		for _, path := range strings.Split(*l.argsFiles, ",") {
+			path = strings.TrimSpace(path)
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read args file: %v", err)
+			}
+			var values map[string]interface{}
+			if err := json.Unmarshal(data, &values); err != nil {
+				return nil, fmt.Errorf("failed to parse args file '%s': %v", path, err)
+			}
+			layers = append(layers, layer{path, values})
+		}
+	}
+
+	positional := l.fs.Args()
+	positionalSource := "command line"
+	for _, layer := range layers {
+		for name, value := range layer.values {
+			if name == positionalKey {
+				if len(l.fs.Args()) > 0 {
+					continue
+				}
+				list, ok := value.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s: '%s' must be a list", layer.source, positionalKey)
+				}
+				positional = make([]string, len(list))
+				for i, item := range list {
+					positional[i] = layerValue(item)
+				}
+				positionalSource = layer.source
+				continue
+			}
+			if l.fs.Lookup(name) == nil {
+				return nil, fmt.Errorf("%s: unknown flag '%s'", layer.source, name)
+			}
+			if explicit[name] || name == "template" || name == "args-file" {
+				continue
+			}
+			if err := l.fs.Set(name, layerValue(value)); err != nil {
+				return nil, fmt.Errorf("%s: invalid value for '%s': %v", layer.source, name, err)
+			}
+			l.sources[name] = layer.source
+		}
+	}
+	if len(positional) > 0 {
+		l.sources[positionalKey] = positionalSource
+	}
+
+	if *l.printEffective {
+		l.print(cfg, positional)
+		os.Exit(0)
+	}
+	return positional, nil
+}
+
+// Function to convert a JSON value of a layer to the string form of a flag value. Lists
+// become comma-separated, like the list flags expect.
+func layerValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = layerValue(item)
+		}
+		return strings.Join(items, ",")
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// Function to print the merged config file and every flag with its value and source
+func (l *layeredFlags) print(cfg *Config, positional []string) {
+	encoded, err := json.MarshalIndent(cfg, "", "  ")
+	if err == nil {
+		fmt.Printf("Config (user config with the project config applied):\n%s\n\n", encoded)
+	}
+	fmt.Println("Flags:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var names []string
+	l.fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "print-effective-config" {
+			continue
+		}
+		value := l.fs.Lookup(name).Value.String()
+		fmt.Fprintf(w, "  --%s\t%q\t%s\n", name, value, firstNonEmpty(l.sources[name], "default"))
+	}
+	if len(positional) > 0 {
+		fmt.Fprintf(w, "  %s\t%q\t%s\n", positionalKey, positional, l.sources[positionalKey])
+	}
+	w.Flush()
+}
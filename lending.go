@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Aave V3 Pool of each chain, used when --pool is not given
+var aavePools = map[uint64]string{
+	1:     "0x87870Bca3F3fD6335C3F4ce8392D69350B4fA4E2",
+	10:    "0x794a61358D6845594F94dc1DB02A252b5b4814aD",
+	137:   "0x794a61358D6845594F94dc1DB02A252b5b4814aD",
+	8453:  "0xA238Dd80C259a72e81d7e4664a9801593F98d1c5",
+	42161: "0x794a61358D6845594F94dc1DB02A252b5b4814aD",
+	43114: "0x794a61358D6845594F94dc1DB02A252b5b4814aD",
+}
+
+// Return types of getReserveData in Aave V3, whose pools keep this layout for it, and in
+// Aave V2, told apart by the number of words returned
+const (
+	aaveV3ReserveData = "(uint256 configuration, uint128 liquidityIndex, uint128 currentLiquidityRate, uint128 variableBorrowIndex, " +
+		"uint128 currentVariableBorrowRate, uint128 currentStableBorrowRate, uint40 lastUpdateTimestamp, uint16 id, address aTokenAddress, " +
+		"address stableDebtTokenAddress, address variableDebtTokenAddress, address interestRateStrategyAddress, uint128 accruedToTreasury, " +
+		"uint128 unbacked, uint128 isolationModeTotalDebt)"
+	aaveV2ReserveData = "(uint256 configuration, uint128 liquidityIndex, uint128 variableBorrowIndex, uint128 currentLiquidityRate, " +
+		"uint128 currentVariableBorrowRate, uint128 currentStableBorrowRate, uint40 lastUpdateTimestamp, address aTokenAddress, " +
+		"address stableDebtTokenAddress, address variableDebtTokenAddress, address interestRateStrategyAddress, uint8 id)"
+)
+
+// Decimals of the fixed-point values of the lending markets: Aave rates and indexes are
+// rays, health factors and Compound mantissas wads, and ratios of the Aave reserve
+// configuration basis points
+const (
+	rayDecimals = 27
+	wadDecimals = 18
+	bpsDecimals = 4
+)
+
+// Periods interest compounds over in a year: seconds for Aave, blocks of 12 seconds for
+// Compound V2 on mainnet
+const (
+	secondsPerYear = 365 * 24 * 60 * 60
+	blocksPerYear  = secondsPerYear / 12
+)
+
+// Function to run the aave subcommands, reading a reserve or the position of an account
+// from an Aave V2 or V3 pool with its rays, basis points and base currency amounts scaled
+func runAave(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler aave reserve|account [flags] <asset or account>")
+	}
+	fs := flag.NewFlagSet("aave "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and the pool")
+	poolFlag := fs.String("pool", "", "address of the Aave pool (default: the Aave V3 pool of the chain)")
+	block := fs.String("block", "latest", "block to query")
+	fs.Parse(args[1:])
+	switch {
+	case args[0] == "reserve" && fs.NArg() != 1:
+		return fmt.Errorf("usage: contract-curler aave reserve [flags] <asset>")
+	case args[0] == "account" && fs.NArg() != 1:
+		return fmt.Errorf("usage: contract-curler aave account [flags] <account>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	pool := *poolFlag
+	if pool == "" {
+		chainID, err := cfg.chainID(*chain, endpoint)
+		if err != nil {
+			return err
+		}
+		if pool = aavePools[chainID]; pool == "" {
+			return fmt.Errorf("no Aave pool known on %s, give its address with --pool", chainName(chainID))
+		}
+	}
+	poolAddress, err := cfg.resolveAddress(pool, *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	target, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	blockParam, err := pinBlock(endpoint, *block)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "reserve":
+		data, err := encodeMethodCall("getReserveData(address)", []string{target.Hex()})
+		if err != nil {
+			return err
+		}
+		var output string
+		call := map[string]interface{}{"to": poolAddress.Hex(), "data": data}
+		if err := callRPC(endpoint, &output, "eth_call", call, blockParam); err != nil {
+			return fmt.Errorf("getReserveData failed: %v", err)
+		}
+		version, returnTypes := "V3", aaveV3ReserveData
+		if len(output) == 2+64*12 {
+			version, returnTypes = "V2", aaveV2ReserveData
+		}
+		values, err := decodeReturnValues(output, returnTypes)
+		if err != nil {
+			return fmt.Errorf("unexpected getReserveData result: %v", err)
+		}
+		reserve := make(map[string]interface{})
+		for i, returnType := range splitTypeList(trimTypeList(returnTypes)) {
+			_, name := splitTypeName(returnType)
+			reserve[name] = values[i]
+		}
+		if reserve["aTokenAddress"].(common.Address) == (common.Address{}) {
+			return fmt.Errorf("%s is not a reserve of the Aave pool %s", target.Hex(), poolAddress.Hex())
+		}
+		token, _ := lookupToken(endpoint, target, blockParam)
+		symbol := token.Symbol
+		configuration := reserve["configuration"].(*big.Int)
+		bits := func(from, width uint) *big.Int {
+			mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), width), big.NewInt(1))
+			return mask.And(mask, new(big.Int).Rsh(configuration, from))
+		}
+		flags := func(bit uint) string {
+			return fmt.Sprint(configuration.Bit(int(bit)) == 1)
+		}
+
+		fmt.Printf("Reserve:               %s %s (Aave %s, id %v)\n", formatAddress(target, opts), symbol, version, reserve["id"])
+		fmt.Printf("Supply rate:           %s APR, %s APY\n", formatPercent(reserve["currentLiquidityRate"].(*big.Int), rayDecimals), compoundedPercent(reserve["currentLiquidityRate"].(*big.Int), rayDecimals, secondsPerYear))
+		fmt.Printf("Variable borrow rate:  %s APR, %s APY\n", formatPercent(reserve["currentVariableBorrowRate"].(*big.Int), rayDecimals), compoundedPercent(reserve["currentVariableBorrowRate"].(*big.Int), rayDecimals, secondsPerYear))
+		fmt.Printf("Stable borrow rate:    %s APR\n", formatPercent(reserve["currentStableBorrowRate"].(*big.Int), rayDecimals))
+		fmt.Printf("Liquidity index:       %s\n", formatFixed(reserve["liquidityIndex"].(*big.Int), rayDecimals))
+		fmt.Printf("Variable borrow index: %s\n", formatFixed(reserve["variableBorrowIndex"].(*big.Int), rayDecimals))
+		fmt.Printf("Last update:           %s\n", formatValue(reserve["lastUpdateTimestamp"], "uint40", "lastUpdateTimestamp", nil, opts))
+		fmt.Printf("LTV:                   %s\n", formatPercent(bits(0, 16), bpsDecimals))
+		fmt.Printf("Liquidation threshold: %s\n", formatPercent(bits(16, 16), bpsDecimals))
+		// The bonus is stored as 100% plus the bonus, e.g. 10500 for 5%
+		if bonus := bits(32, 16); bonus.Sign() > 0 {
+			fmt.Printf("Liquidation bonus:     %s\n", formatPercent(bonus.Sub(bonus, big.NewInt(10000)), bpsDecimals))
+		}
+		fmt.Printf("Reserve factor:        %s\n", formatPercent(bits(64, 16), bpsDecimals))
+		fmt.Printf("Decimals:              %s\n", bits(48, 8))
+		fmt.Printf("Active:                %s, frozen %s, borrowing %s\n", flags(56), flags(57), flags(58))
+		if version == "V3" {
+			fmt.Printf("Paused:                %s\n", flags(60))
+		}
+		fmt.Printf("aToken:                %s\n", formatAddress(reserve["aTokenAddress"].(common.Address), opts))
+		fmt.Printf("Variable debt token:   %s\n", formatAddress(reserve["variableDebtTokenAddress"].(common.Address), opts))
+		fmt.Printf("Stable debt token:     %s\n", formatAddress(reserve["stableDebtTokenAddress"].(common.Address), opts))
+		fmt.Printf("Rate strategy:         %s\n", formatAddress(reserve["interestRateStrategyAddress"].(common.Address), opts))
+		return nil
+
+	case "account":
+		data, err := encodeMethodCall("getUserAccountData(address)", []string{target.Hex()})
+		if err != nil {
+			return err
+		}
+		values, err := callForValues(endpoint, poolAddress.Hex(), data, blockParam, "(uint256,uint256,uint256,uint256,uint256,uint256)")
+		if err != nil {
+			return fmt.Errorf("getUserAccountData failed: %v", err)
+		}
+		// V3 pools report amounts in the base currency of their oracle, USD with 8
+		// decimals, and V2 pools in ETH; only V3 pools have BRIDGE_PROTOCOL_FEE
+		unit, decimals := "ETH", wadDecimals
+		if _, ok := callUintGetter(endpoint, poolAddress.Hex(), "BRIDGE_PROTOCOL_FEE()", nil, blockParam); ok {
+			unit, decimals = "USD", 8
+		}
+		amount := func(i int) string {
+			return groupThousands(formatFixed(roundFixed(values[i].(*big.Int), decimals, 2), 2)) + " " + unit
+		}
+		fmt.Printf("Account:               %s\n", formatAddress(target, opts))
+		fmt.Printf("Total collateral:      %s\n", amount(0))
+		fmt.Printf("Total debt:            %s\n", amount(1))
+		fmt.Printf("Available to borrow:   %s\n", amount(2))
+		fmt.Printf("Liquidation threshold: %s\n", formatPercent(values[3].(*big.Int), bpsDecimals))
+		fmt.Printf("LTV:                   %s\n", formatPercent(values[4].(*big.Int), bpsDecimals))
+		healthFactor := values[5].(*big.Int)
+		switch {
+		case values[1].(*big.Int).Sign() == 0:
+			fmt.Println("Health factor:         no debt")
+		case healthFactor.Cmp(new(big.Int).Exp(big.NewInt(10), big.NewInt(wadDecimals), nil)) < 0:
+			fmt.Printf("Health factor:         %s, the account can be liquidated\n", colorize(colorRed, formatFixed(roundFixed(healthFactor, wadDecimals, 4), 4)))
+		default:
+			fmt.Printf("Health factor:         %s\n", formatFixed(roundFixed(healthFactor, wadDecimals, 4), 4))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown aave subcommand '%s' (expected reserve or account)", args[0])
+}
+
+// Function to run the compound subcommands, reading a Compound V2 market or the position
+// of an account in it, with exchange rates and balances in the underlying token
+func runCompound(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler compound market|account [flags] <cToken> [account]")
+	}
+	fs := flag.NewFlagSet("compound "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	block := fs.String("block", "latest", "block to query")
+	perYear := fs.Uint64("blocks-per-year", blocksPerYear, "blocks in a year, compounding the per-block rates of the market")
+	fs.Parse(args[1:])
+	switch {
+	case args[0] == "market" && fs.NArg() != 1:
+		return fmt.Errorf("usage: contract-curler compound market [flags] <cToken>")
+	case args[0] == "account" && fs.NArg() != 2:
+		return fmt.Errorf("usage: contract-curler compound account [flags] <cToken> <account>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	cToken, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	blockParam, err := pinBlock(endpoint, *block)
+	if err != nil {
+		return err
+	}
+
+	// The exchange rate is scaled by 10^(18 + underlying decimals - cToken decimals)
+	cTokenMetadata, ok := lookupToken(endpoint, cToken, blockParam)
+	if !ok {
+		return fmt.Errorf("%s has no decimals(), it is not a cToken", cToken.Hex())
+	}
+	cSymbol, cDecimals := cTokenMetadata.Symbol, *cTokenMetadata.Decimals
+	// cETH holds ether and has no underlying()
+	symbol, decimals := "ETH", 18
+	underlying, hasUnderlying := callAddressGetter(endpoint, cToken, "underlying()", blockParam)
+	if hasUnderlying {
+		token, ok := lookupToken(endpoint, underlying, blockParam)
+		if !ok {
+			return fmt.Errorf("underlying token %s has no decimals()", underlying.Hex())
+		}
+		symbol, decimals = token.Symbol, *token.Decimals
+	}
+	rateDecimals := wadDecimals + decimals - cDecimals
+	// Function to convert an amount of cTokens to the underlying token
+	toUnderlying := func(amount, rate *big.Int) string {
+		underlyingAmount := new(big.Int).Mul(amount, rate)
+		return formatFixed(underlyingAmount.Div(underlyingAmount, new(big.Int).Exp(big.NewInt(10), big.NewInt(wadDecimals), nil)), decimals) + " " + symbol
+	}
+
+	switch args[0] {
+	case "market":
+		rate, ok := callUintGetter(endpoint, cToken.Hex(), "exchangeRateStored()", nil, blockParam)
+		if !ok {
+			return fmt.Errorf("%s has no exchangeRateStored(), it is not a cToken", cToken.Hex())
+		}
+		fmt.Printf("Market:        %s %s\n", formatAddress(cToken, opts), cSymbol)
+		if hasUnderlying {
+			fmt.Printf("Underlying:    %s %s\n", formatAddress(underlying, opts), symbol)
+		} else {
+			fmt.Println("Underlying:    ETH")
+		}
+		fmt.Printf("Exchange rate: 1 %s = %s %s\n", cSymbol, formatFixed(rate, rateDecimals), symbol)
+		for _, getter := range []struct{ label, signature string }{
+			{"Supply rate:  ", "supplyRatePerBlock()"},
+			{"Borrow rate:  ", "borrowRatePerBlock()"},
+		} {
+			if perBlock, ok := callUintGetter(endpoint, cToken.Hex(), getter.signature, nil, blockParam); ok {
+				yearly := new(big.Int).Mul(perBlock, new(big.Int).SetUint64(*perYear))
+				fmt.Printf("%s %s APR, %s APY\n", getter.label, formatPercent(yearly, wadDecimals), compoundedPercent(yearly, wadDecimals, *perYear))
+			}
+		}
+		if supply, ok := callUintGetter(endpoint, cToken.Hex(), "totalSupply()", nil, blockParam); ok {
+			fmt.Printf("Total supply:  %s\n", toUnderlying(supply, rate))
+		}
+		for _, getter := range []struct{ label, signature string }{
+			{"Total borrows:", "totalBorrows()"},
+			{"Cash:         ", "getCash()"},
+			{"Reserves:     ", "totalReserves()"},
+		} {
+			if amount, ok := callUintGetter(endpoint, cToken.Hex(), getter.signature, nil, blockParam); ok {
+				fmt.Printf("%s %s %s\n", getter.label, formatFixed(amount, decimals), symbol)
+			}
+		}
+		return nil
+
+	case "account":
+		account, err := cfg.resolveAddress(fs.Arg(1), *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		data, err := encodeMethodCall("getAccountSnapshot(address)", []string{account.Hex()})
+		if err != nil {
+			return err
+		}
+		values, err := callForValues(endpoint, cToken.Hex(), data, blockParam, "(uint256,uint256,uint256,uint256)")
+		if err != nil {
+			return fmt.Errorf("getAccountSnapshot failed: %v", err)
+		}
+		if code := values[0].(*big.Int); code.Sign() != 0 {
+			return fmt.Errorf("getAccountSnapshot returned error code %s", code)
+		}
+		balance, borrowed, rate := values[1].(*big.Int), values[2].(*big.Int), values[3].(*big.Int)
+		fmt.Printf("Account:       %s\n", formatAddress(account, opts))
+		fmt.Printf("Market:        %s %s\n", formatAddress(cToken, opts), cSymbol)
+		fmt.Printf("Balance:       %s %s\n", formatFixed(balance, cDecimals), cSymbol)
+		fmt.Printf("Supplied:      %s\n", toUnderlying(balance, rate))
+		fmt.Printf("Borrowed:      %s %s\n", formatFixed(borrowed, decimals), symbol)
+		fmt.Printf("Exchange rate: 1 %s = %s %s\n", cSymbol, formatFixed(rate, rateDecimals), symbol)
+		return nil
+	}
+	return fmt.Errorf("unknown compound subcommand '%s' (expected market or account)", args[0])
+}
+
+// Function to render a fixed-point fraction as a percentage with two decimals
+func formatPercent(n *big.Int, decimals int) string {
+	return formatFixed(roundFixed(n, decimals-2, 2), 2) + "%"
+}
+
+// Function to render the yearly yield of a yearly rate compounded over periods
+func compoundedPercent(rate *big.Int, decimals int, periods uint64) string {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	r, _ := new(big.Float).Quo(new(big.Float).SetInt(rate), new(big.Float).SetInt(scale)).Float64()
+	return fmt.Sprintf("%.2f%%", (math.Pow(1+r/float64(periods), float64(periods))-1)*100)
+}
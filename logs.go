@@ -0,0 +1,441 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/sha3"
+)
+
+// LogEntry represents a log returned by eth_getLogs
+type LogEntry struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	BlockHash       string   `json:"blockHash"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	Removed         bool     `json:"removed"`
+}
+
+// logScanCheckpoint records the progress of a getLogs scan so it can be resumed
+type logScanCheckpoint struct {
+	RpcURL    string   `json:"rpcUrl"`
+	Addresses []string `json:"addresses"`
+	Topics    []string `json:"topics"`
+	FromBlock uint64   `json:"fromBlock"`
+	ToBlock   uint64   `json:"toBlock"`
+	NextBlock uint64   `json:"nextBlock"`
+	ChunkSize uint64   `json:"chunkSize"`
+}
+
+// Function to scan a block range with eth_getLogs in chunks
+func runLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: "+defaultRPC()+")")
+	addresses := fs.String("address", "", "comma-separated contract addresses to filter on")
+	event := fs.String("event", "", "event signature used as topic0, e.g. Transfer(address,address,uint256)")
+	topics := fs.String("topics", "", "comma-separated topics by position, empty entries match anything")
+	from := fs.String("from", "0", "first block of the scan")
+	to := fs.String("to", "latest", "last block of the scan")
+	chunk := fs.Uint64("chunk", 2000, "number of blocks requested per eth_getLogs call")
+	checkpointPath := fs.String("checkpoint", "logs-checkpoint.json", "file recording the last processed block")
+	resume := fs.Bool("resume", false, "continue the scan recorded in the checkpoint file")
+	force := fs.Bool("force", false, "start a new scan even though the checkpoint file records an earlier one, discarding its progress")
+	follow := fs.Bool("follow", false, "keep polling for new blocks once the range is scanned")
+	interval := fs.Duration("interval", 12*time.Second, "polling interval in follow mode")
+	reorgDepth := fs.Uint64("reorg-depth", 64, "number of recent block hashes tracked for reorg detection")
+	sink := fs.String("sink", "", "send the logs to tcp://host:port, nats://host:port/subject, redis://host:port/stream, kafka-rest://host:port/topic, sqlite:<file>, csv:<dir> or parquet:<dir> instead of standard output (sqlite needs the sqlite3 command line shell installed)")
+	abiPaths := fs.String("abi", "", "comma-separated ABI files whose events get a table in sqlite, csv and parquet sinks")
+	fs.Parse(args)
+	if !*resume && !*force {
+		if _, err := os.Stat(*checkpointPath); err == nil {
+			return fmt.Errorf("checkpoint '%s' records an earlier scan, continue it with --resume or discard it with --force", *checkpointPath)
+		}
+	}
+	if *sink != "" {
+		var paths []string
+		if *abiPaths != "" {
+			paths = strings.Split(*abiPaths, ",")
+		}
+		opened, err := openSink(*sink, paths)
+		if err != nil {
+			return err
+		}
+		logOutput = opened
+		defer closeLogOutput()
+	}
+
+	filterAddresses, filterTopics, err := logFilters(*addresses, *topics, *event)
+	if err != nil {
+		return err
+	}
+	var cp *logScanCheckpoint
+	if *resume {
+		loaded, err := loadCheckpoint(*checkpointPath)
+		if err != nil {
+			return err
+		}
+		cp = loaded
+		// Continuing with other filters would leave the logs before the resumed block unscanned
+		if !sameFilters(cp.Addresses, filterAddresses) || !sameFilters(cp.Topics, filterTopics) {
+			return fmt.Errorf("checkpoint '%s' is for addresses %v and topics %v, not addresses %v and topics %v (rerun with the same filters, or without --resume to start a new scan)",
+				*checkpointPath, cp.Addresses, cp.Topics, filterAddresses, filterTopics)
+		}
+		if *rpcURL != "" {
+			cp.RpcURL = *rpcURL
+		}
+		fmt.Fprintf(os.Stderr, "Resuming scan at block %d of %d-%d\n", cp.NextBlock, cp.FromBlock, cp.ToBlock)
+	} else {
+		cp = &logScanCheckpoint{RpcURL: *rpcURL, Addresses: filterAddresses, Topics: filterTopics, ChunkSize: *chunk}
+		if cp.RpcURL == "" {
+			cp.RpcURL = defaultRPC()
+		}
+
+		fromBlock, err := parseBlockNumber(*from)
+		if err != nil {
+			return err
+		}
+		var toBlock uint64
+		if *to == "latest" {
+			toBlock, err = latestBlockNumber(cp.RpcURL)
+			if err != nil {
+				return fmt.Errorf("failed to fetch latest block: %v", err)
+			}
+		} else {
+			toBlock, err = parseBlockNumber(*to)
+			if err != nil {
+				return err
+			}
+		}
+		if fromBlock > toBlock {
+			return fmt.Errorf("from block %d is after to block %d", fromBlock, toBlock)
+		}
+		cp.FromBlock = fromBlock
+		cp.ToBlock = toBlock
+		cp.NextBlock = fromBlock
+	}
+	if cp.ChunkSize == 0 {
+		return fmt.Errorf("chunk size must be greater than zero")
+	}
+
+	for cp.NextBlock <= cp.ToBlock {
+		start := cp.NextBlock
+		end := start + cp.ChunkSize - 1
+		if end > cp.ToBlock {
+			end = cp.ToBlock
+		}
+
+		// Logs are emitted as they arrive, so a chunk failing midway is emitted again on resume
+		count, err := streamLogs(cp.RpcURL, cp.Addresses, cp.Topics, start, end, emitLog)
+		if err != nil {
+			return fmt.Errorf("failed to fetch logs for blocks %d-%d: %v (rerun with --resume to continue from block %d)",
+				start, end, err, start)
+		}
+		fmt.Fprintf(os.Stderr, "Scanned blocks %d-%d: %d logs\n", start, end, count)
+
+		cp.NextBlock = end + 1
+		if err := saveCheckpoint(*checkpointPath, cp); err != nil {
+			return err
+		}
+	}
+
+	if *follow {
+		return followLogs(cp, *checkpointPath, *interval, *reorgDepth)
+	}
+
+	// The scan is complete, so there is nothing left to resume
+	os.Remove(*checkpointPath)
+	return nil
+}
+
+// reorgNotice is written to the output stream when previously emitted logs are invalidated
+type reorgNotice struct {
+	Type    string `json:"type"`
+	Block   uint64 `json:"block"`
+	Depth   uint64 `json:"depth,omitempty"`
+	OldHash string `json:"oldHash"`
+	NewHash string `json:"newHash,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Function to poll for new blocks after a scan, re-fetching logs when the chain reorganizes.
+// Logs emitted for blocks after a reorg notice's block must be discarded by the consumer.
+func followLogs(cp *logScanCheckpoint, checkpointPath string, interval time.Duration, depth uint64) error {
+	if depth == 0 {
+		return fmt.Errorf("reorg depth must be greater than zero")
+	}
+
+	head := cp.ToBlock
+	header, err := getBlockHeader(cp.RpcURL, head)
+	if err != nil {
+		return fmt.Errorf("failed to fetch block %d: %v", head, err)
+	}
+	recent := map[uint64]string{head: header.Hash}
+	fmt.Fprintf(os.Stderr, "Following new blocks after %d\n", head)
+
+	for {
+		time.Sleep(interval)
+
+		latest, err := latestBlockNumber(cp.RpcURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching latest block: %v\n", err)
+			continue
+		}
+		if latest <= head {
+			continue
+		}
+
+		// Make sure the last processed block is still canonical before moving on
+		ancestor, err := findCommonAncestor(cp.RpcURL, recent, head)
+		if err != nil {
+			return err
+		}
+		if ancestor < head {
+			canonical, err := getBlockHeader(cp.RpcURL, ancestor+1)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error fetching block %d: %v\n", ancestor+1, err)
+				continue
+			}
+			notice := reorgNotice{
+				Type:    "reorg",
+				Block:   ancestor + 1,
+				Depth:   head - ancestor,
+				OldHash: recent[ancestor+1],
+				NewHash: canonical.Hash,
+				Reason:  "block hash mismatch",
+			}
+			if err := emitJSON(notice); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Reorg of depth %d detected, re-fetching from block %d\n", notice.Depth, ancestor+1)
+			for n := ancestor + 1; n <= head; n++ {
+				delete(recent, n)
+			}
+			head = ancestor
+		}
+
+		// Record hashes of the new blocks first so the logs can be checked against them
+		end := latest
+		if end-head > cp.ChunkSize {
+			end = head + cp.ChunkSize
+		}
+		start := head + 1
+		if end >= depth && end-depth+1 > start {
+			start = end - depth + 1
+		}
+		for n := start; n <= end; n++ {
+			header, err := getBlockHeader(cp.RpcURL, n)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error fetching block %d: %v\n", n, err)
+				end = n - 1
+				break
+			}
+			if prev, ok := recent[n-1]; ok && header.ParentHash != prev {
+				// The chain changed underneath us; the next poll will locate the fork
+				end = n - 1
+				break
+			}
+			recent[n] = header.Hash
+		}
+		if end <= head {
+			continue
+		}
+
+		logs, err := getLogs(cp.RpcURL, cp.Addresses, cp.Topics, head+1, end)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching logs for blocks %d-%d: %v\n", head+1, end, err)
+			continue
+		}
+		for _, entry := range logs {
+			number, err := hexutil.DecodeUint64(entry.BlockNumber)
+			if err != nil {
+				return fmt.Errorf("invalid log block number '%s'", entry.BlockNumber)
+			}
+			if entry.Removed {
+				notice := reorgNotice{Type: "reorg", Block: number, OldHash: entry.BlockHash, Reason: "removed log"}
+				if err := emitJSON(notice); err != nil {
+					return err
+				}
+			} else if hash, ok := recent[number]; ok && hash != entry.BlockHash {
+				// Stop before the block whose logs no longer match the recorded header
+				end = number - 1
+				break
+			}
+			if err := emitLog(entry); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Scanned blocks %d-%d\n", head+1, end)
+
+		head = end
+		for n := range recent {
+			if n+depth <= head {
+				delete(recent, n)
+			}
+		}
+		cp.ToBlock = head
+		cp.NextBlock = head + 1
+		if err := saveCheckpoint(checkpointPath, cp); err != nil {
+			return err
+		}
+	}
+}
+
+// Function to find the newest tracked block that is still part of the canonical chain
+func findCommonAncestor(rpcURL string, recent map[uint64]string, head uint64) (uint64, error) {
+	for n := head; ; n-- {
+		hash, ok := recent[n]
+		if !ok {
+			return 0, fmt.Errorf("reorg deeper than the tracked %d blocks below block %d", len(recent), head)
+		}
+		header, err := getBlockHeader(rpcURL, n)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch block %d: %v", n, err)
+		}
+		if header.Hash == hash {
+			return n, nil
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("no common ancestor found")
+		}
+	}
+}
+
+// Function to write a log to the output stream
+func emitLog(entry LogEntry) error {
+	return emitJSON(entry)
+}
+
+// Function to write a value to the output stream as a single JSON line
+func emitJSON(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %v", err)
+	}
+	return logOutput.write(line)
+}
+
+// Function to fetch the logs of a block range
+func getLogs(rpcURL string, addresses []string, topics []string, fromBlock, toBlock uint64) ([]LogEntry, error) {
+	var logs []LogEntry
+	_, err := streamLogs(rpcURL, addresses, topics, fromBlock, toBlock, func(entry LogEntry) error {
+		logs = append(logs, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// Function to fetch the logs of a block range, handing each to each as it is decoded
+func streamLogs(rpcURL string, addresses []string, topics []string, fromBlock, toBlock uint64, each func(LogEntry) error) (int, error) {
+	filter := map[string]interface{}{
+		"fromBlock": hexutil.EncodeUint64(fromBlock),
+		"toBlock":   hexutil.EncodeUint64(toBlock),
+	}
+	if len(addresses) > 0 {
+		filter["address"] = addresses
+	}
+	if len(topics) > 0 {
+		// Empty topics are wildcards and must be sent as null
+		topicFilter := make([]interface{}, len(topics))
+		for i, topic := range topics {
+			if topic != "" {
+				topicFilter[i] = topic
+			}
+		}
+		filter["topics"] = topicFilter
+	}
+
+	count := 0
+	err := streamRPC(rpcURL, "eth_getLogs", []interface{}{filter}, func(item json.RawMessage) error {
+		var entry LogEntry
+		if err := json.Unmarshal(item, &entry); err != nil {
+			return fmt.Errorf("failed to parse log: %v", err)
+		}
+		count++
+		return each(entry)
+	})
+	return count, err
+}
+
+// Function to compute the topic0 hash of an event signature
+func eventTopic(signature string) string {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(strings.ReplaceAll(signature, " ", "")))
+	return "0x" + hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Function to get the address and topic filters of a scan from its flags, the event
+// signature giving topic0
+func logFilters(addresses, topics, event string) ([]string, []string, error) {
+	var filterAddresses, filterTopics []string
+	if addresses != "" {
+		for _, address := range strings.Split(addresses, ",") {
+			filterAddresses = append(filterAddresses, strings.TrimSpace(address))
+		}
+	}
+	if topics != "" {
+		for _, topic := range strings.Split(topics, ",") {
+			filterTopics = append(filterTopics, strings.TrimSpace(topic))
+		}
+	}
+	if event != "" {
+		signature, err := canonicalSignature(event)
+		if err != nil {
+			return nil, nil, err
+		}
+		topic0 := eventTopic(signature)
+		if len(filterTopics) == 0 {
+			filterTopics = []string{topic0}
+		} else {
+			filterTopics[0] = topic0
+		}
+	}
+	return filterAddresses, filterTopics, nil
+}
+
+// Function to compare filters, hex strings matching regardless of case
+func sameFilters(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Function to read a scan checkpoint from disk
+func loadCheckpoint(path string) (*logScanCheckpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	var cp logScanCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint '%s': %v", path, err)
+	}
+	return &cp, nil
+}
+
+// Function to write a scan checkpoint, replacing the previous one atomically
+func saveCheckpoint(path string, cp *logScanCheckpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogsResumeChecksFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	transfer := eventTopic("Transfer(address,address,uint256)")
+	cp := &logScanCheckpoint{
+		RpcURL:    "http://127.0.0.1:1",
+		Addresses: []string{"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"},
+		Topics:    []string{transfer},
+		FromBlock: 100,
+		ToBlock:   200,
+		// Nothing is left to scan, so resuming with the same filters completes at once
+		NextBlock: 201,
+		ChunkSize: 10,
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		ok   bool
+	}{
+		{"same filters", []string{"--address", "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "--event", "Transfer(address,address,uint256)"}, true},
+		{"same topics in upper case", []string{"--address", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "--topics", "0x" + strings.ToUpper(transfer[2:])}, true},
+		{"no filters", nil, false},
+		{"other address", []string{"--address", "0xdAC17F958D2ee523a2206206994597C13D831ec7", "--event", "Transfer(address,address,uint256)"}, false},
+		{"other event", []string{"--address", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "--event", "Approval(address,address,uint256)"}, false},
+		{"extra topic", []string{"--address", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "--topics", transfer + ","}, false},
+	}
+	for _, test := range tests {
+		if err := saveCheckpoint(path, cp); err != nil {
+			t.Fatal(err)
+		}
+		err := runLogs(append([]string{"--resume", "--checkpoint", path}, test.args...))
+		if test.ok && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.ok && (err == nil || !strings.Contains(err.Error(), "rerun with the same filters")) {
+			t.Errorf("%s: resumed with %v", test.name, err)
+		}
+	}
+}
+
+func TestLogsKeepsCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp := &logScanCheckpoint{RpcURL: "http://127.0.0.1:1", FromBlock: 100, ToBlock: 200, NextBlock: 150, ChunkSize: 10}
+	if err := saveCheckpoint(path, cp); err != nil {
+		t.Fatal(err)
+	}
+	// A new scan would overwrite the progress of the earlier one
+	err := runLogs([]string{"--checkpoint", path, "--from", "0", "--to", "10"})
+	if err == nil || !strings.Contains(err.Error(), "--resume") {
+		t.Errorf("new scan over a checkpoint returned %v", err)
+	}
+	if loaded, err := loadCheckpoint(path); err != nil || loaded.NextBlock != 150 {
+		t.Errorf("checkpoint changed to %+v, %v", loaded, err)
+	}
+	// Forced, it starts over, failing here on the endpoint that does not exist
+	err = runLogs([]string{"--checkpoint", path, "--rpc", "http://127.0.0.1:1", "--from", "0", "--to", "10", "--force"})
+	if err == nil || strings.Contains(err.Error(), "--force") {
+		t.Errorf("forced scan returned %v", err)
+	}
+}
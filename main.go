@@ -2,21 +2,19 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"math/big"
-	"net/http"
 	"os"
-	"regexp"
-	"strconv"
+	"reflect"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.starlark.net/starlark"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -28,46 +26,45 @@ type JsonRpcRequest struct {
 	Id      int           `json:"id"`
 }
 
-// JsonRpcResponse represents an Ethereum JSON-RPC response
-type JsonRpcResponse struct {
-	JsonRpc string `json:"jsonrpc"`
-	Id      int    `json:"id"`
-	Result  string `json:"result"`
-}
-
 // Function to encode method signature and parameters
 func encodeMethodCall(methodSig string, args []string) (string, error) {
-	// Extract function name and parameters
-	re := regexp.MustCompile(`(\w+)\((.*)\)`)
-	matches := re.FindStringSubmatch(methodSig)
-	if len(matches) < 3 {
-		return "", fmt.Errorf("invalid method signature format")
-	}
-
-	functionName := matches[1]
-	paramTypesStr := matches[2]
-	var paramTypes []string
-	if paramTypesStr != "" {
-		paramTypes = strings.Split(paramTypesStr, ",")
+	// Parameter names and type aliases would silently change the selector
+	methodSignature, err := canonicalSignature(methodSig)
+	if err != nil {
+		return "", err
 	}
+	open := strings.Index(methodSignature, "(")
+	paramTypes := splitTypeList(methodSignature[open+1 : len(methodSignature)-1])
 
 	// Create function signature hash (first 4 bytes of keccak256 hash)
-	methodSignature := functionName + "(" + strings.Join(paramTypes, ",") + ")"
 	methodID := functionSelector(methodSignature)
 
-	fmt.Println("Method ID:", methodID)
-
 	// If no args, just return the method ID
 	if len(paramTypes) == 0 || len(args) == 0 {
 		return "0x" + methodID, nil
 	}
 
+	encodedArgs, err := encodeArguments(paramTypes, args)
+	if err != nil {
+		return "", err
+	}
+
+	// Combine method ID and encoded arguments
+	return "0x" + methodID + hex.EncodeToString(encodedArgs), nil
+}
+
+// Function to ABI-encode argument values given as strings, like abi.encode would
+func encodeArguments(paramTypes []string, args []string) ([]byte, error) {
+	if len(args) > len(paramTypes) {
+		return nil, fmt.Errorf("got %d arguments for %d parameters", len(args), len(paramTypes))
+	}
+
 	// Build ABI argument types
 	var arguments abi.Arguments
 	for _, paramType := range paramTypes {
 		abiType, err := abi.NewType(strings.TrimSpace(paramType), "", nil)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse ABI type '%s': %v", paramType, err)
+			return nil, fmt.Errorf("failed to parse ABI type '%s': %v", paramType, err)
 		}
 		arguments = append(arguments, abi.Argument{Type: abiType})
 	}
@@ -85,18 +82,46 @@ func encodeMethodCall(methodSig string, args []string) (string, error) {
 			bigInt := new(big.Int)
 			_, success := bigInt.SetString(arg, 10)
 			if !success {
-				return "", fmt.Errorf("failed to parse integer argument '%s'", arg)
+				return nil, fmt.Errorf("failed to parse integer argument '%s'", arg)
 			}
 			value = bigInt
+
+			// Integers of 64 bits or less must be packed with their exact Go type. Arrays and
+			// slices of integers are left to the packer, which rejects them.
+			isInteger := arguments[i].Type.T == abi.IntTy || arguments[i].Type.T == abi.UintTy
+			if goType := arguments[i].Type.GetType(); isInteger && goType.Kind() != reflect.Ptr {
+				sized := reflect.New(goType).Elem()
+				if goType.Kind() >= reflect.Uint && goType.Kind() <= reflect.Uint64 {
+					if bigInt.Sign() < 0 || !bigInt.IsUint64() || sized.OverflowUint(bigInt.Uint64()) {
+						return nil, fmt.Errorf("integer argument '%s' out of range for %s", arg, paramType)
+					}
+					sized.SetUint(bigInt.Uint64())
+				} else {
+					if !bigInt.IsInt64() || sized.OverflowInt(bigInt.Int64()) {
+						return nil, fmt.Errorf("integer argument '%s' out of range for %s", arg, paramType)
+					}
+					sized.SetInt(bigInt.Int64())
+				}
+				value = sized.Interface()
+			}
 		case paramType == "address":
+			// Addresses of chains with their own encoding are converted to their 20 bytes
+			if codec := findAddressCodec(arg); codec != nil {
+				address, err := codec.decode(arg)
+				if err != nil {
+					return nil, err
+				}
+				value = address
+				break
+			}
 			if !strings.HasPrefix(arg, "0x") {
 				arg = "0x" + arg
 			}
 			value = common.HexToAddress(arg)
 		case paramType == "bool":
-			value, err = strconv.ParseBool(arg)
+			value, err = parseBoolArg(arg)
 			if err != nil {
-				return "", fmt.Errorf("failed to parse boolean argument: %v", err)
+				return nil, fmt.Errorf("failed to parse boolean argument: %v", err)
 			}
 		case strings.HasPrefix(paramType, "bytes"):
 			if !strings.HasPrefix(arg, "0x") {
@@ -104,13 +129,23 @@ func encodeMethodCall(methodSig string, args []string) (string, error) {
 			}
 			bytes, err := hexutil.Decode(arg)
 			if err != nil {
-				return "", fmt.Errorf("failed to decode bytes argument: %v", err)
+				return nil, fmt.Errorf("failed to decode bytes argument: %v", err)
 			}
 			value = bytes
+
+			// Fixed-size bytes must be packed as arrays of their exact length
+			if goType := arguments[i].Type.GetType(); arguments[i].Type.T == abi.FixedBytesTy {
+				if len(bytes) != goType.Len() {
+					return nil, fmt.Errorf("%s argument must be %d bytes, got %d", paramType, goType.Len(), len(bytes))
+				}
+				array := reflect.New(goType).Elem()
+				reflect.Copy(array, reflect.ValueOf(bytes))
+				value = array.Interface()
+			}
 		case paramType == "string":
 			value = arg
 		default:
-			return "", fmt.Errorf("unsupported parameter type: %s", paramType)
+			return nil, fmt.Errorf("unsupported parameter type: %s", paramType)
 		}
 
 		values = append(values, value)
@@ -119,21 +154,15 @@ func encodeMethodCall(methodSig string, args []string) (string, error) {
 	// Pack the arguments
 	encodedArgs, err := arguments.Pack(values...)
 	if err != nil {
-		return "", fmt.Errorf("failed to encode arguments: %v", err)
+		return nil, fmt.Errorf("failed to encode arguments: %v", err)
 	}
-
-	// Combine method ID and encoded arguments
-	return "0x" + methodID + hex.EncodeToString(encodedArgs), nil
+	return encodedArgs, nil
 }
 
 // Function to decode return values
 func decodeReturnValues(returnData string, returnTypes string) ([]interface{}, error) {
 	// Parse return types
-	returnTypesStr := strings.Trim(returnTypes, "()")
-	var returnTypeList []string
-	if returnTypesStr != "" {
-		returnTypeList = strings.Split(returnTypesStr, ",")
-	}
+	returnTypeList := splitTypeList(trimTypeList(returnTypes))
 
 	// Remove 0x prefix if present
 	if strings.HasPrefix(returnData, "0x") {
@@ -149,8 +178,7 @@ func decodeReturnValues(returnData string, returnTypes string) ([]interface{}, e
 	// Build ABI return types
 	var arguments abi.Arguments
 	for _, typStr := range returnTypeList {
-		typStr = strings.TrimSpace(typStr)
-		abiType, err := abi.NewType(typStr, "", nil)
+		abiType, err := newABIType(typStr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse return type '%s': %v", typStr, err)
 		}
@@ -167,29 +195,87 @@ func decodeReturnValues(returnData string, returnTypes string) ([]interface{}, e
 }
 
 // Function to format return values for display
-func formatReturnValues(values []interface{}, returnTypes []string) []string {
+func formatReturnValues(values []interface{}, returnTypes []string, opts formatOptions) []string {
 	results := make([]string, len(values))
 
 	for i, val := range values {
 		returnType := strings.TrimSpace(returnTypes[i])
+		typStr, _ := splitTypeName(returnType)
+		var name string
+		if i < len(opts.Names) {
+			name = opts.Names[i]
+		}
+		var enumVariants []string
+		if i < len(opts.EnumVariants) {
+			enumVariants = opts.EnumVariants[i]
+		}
 
-		switch v := val.(type) {
-		case common.Address:
-			results[i] = fmt.Sprintf("%s: %s", returnType, v.Hex())
-		case []byte:
-			results[i] = fmt.Sprintf("%s: %s", returnType, hex.EncodeToString(v))
-		case string:
-			results[i] = fmt.Sprintf("%s: %s", returnType, v)
-		case *big.Int:
-			results[i] = fmt.Sprintf("%s: %s", returnType, v.String())
-		default:
-			results[i] = fmt.Sprintf("%s: %v", returnType, v)
+		// Arrays and tuples are expanded one element per line
+		if typ, err := newABIType(typStr); err == nil && isNestedType(typ) {
+			results[i] = returnType + ":" + formatNested(val, typ, opts, "  ")
+			continue
 		}
+
+		results[i] = fmt.Sprintf("%s: %s", returnType, formatValue(val, typStr, name, enumVariants, opts))
 	}
 
 	return results
 }
 
+// Function to parse a boolean argument, accepting the common spellings case-insensitively
+func parseBoolArg(arg string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "true", "t", "yes", "y", "1":
+		return true, nil
+	case "false", "f", "no", "n", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean '%s' (accepted forms: true/false, yes/no, y/n, 1/0)", arg)
+}
+
+// Function to check an argument can be encoded for its parameter as soon as it is typed,
+// explaining what the type expects when it cannot. References and nested calls are only
+// known once resolved, so they are left to the encoding.
+func validateArg(arg, paramType string) error {
+	if strings.Contains(arg, "{{") {
+		return nil
+	}
+	isInteger := strings.HasPrefix(paramType, "uint") || strings.HasPrefix(paramType, "int")
+	if isInteger && isArithmetic(arg) {
+		n, err := evalArithmetic(arg)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate '%s': %v", arg, err)
+		}
+		arg = n.String()
+	}
+	if paramType == "address" && findAddressCodec(arg) == nil {
+		digits := strings.TrimPrefix(arg, "0x")
+		if _, err := hex.DecodeString(strings.Repeat("0", len(digits)%2) + digits); err != nil || len(digits) > 40 {
+			return fmt.Errorf("'%s' is not an address, expected 20 bytes of hex like 0x%s", arg, strings.Repeat("0", 40))
+		}
+	}
+	if _, err := encodeArguments([]string{paramType}, []string{arg}); err != nil {
+		switch {
+		case isInteger:
+			return fmt.Errorf("%v, expected a decimal integer or an expression like 1.5*10^18", err)
+		case strings.HasPrefix(paramType, "bytes"):
+			return fmt.Errorf("%v, expected hex like 0x1234", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Function to return the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
 func functionSelector(signature string) string {
 	hasher := sha3.NewLegacyKeccak256()
 	hasher.Write([]byte(signature))
@@ -198,55 +284,428 @@ func functionSelector(signature string) string {
 	return fmt.Sprintf("%x", selector)
 }
 
+// Subcommands available in addition to the interactive mode
+var commands = map[string]func(args []string) error{
+	"logs":           runLogs,
+	"inspect":        runInspect,
+	"sigdb":          runSigdb,
+	"rpc":            runRPC,
+	"trace":          runTrace,
+	"gas-profile":    runGasProfile,
+	"estimate":       runEstimate,
+	"calldata":       runCalldata,
+	"state-diff":     runStateDiff,
+	"expect-event":   runExpectEvent,
+	"pipeline":       runPipeline,
+	"batch":          runBatch,
+	"fuzz":           runFuzz,
+	"invariant":      runInvariant,
+	"dashboard":      runDashboard,
+	"status":         runStatus,
+	"ens":            runENS,
+	"recover":        runRecover,
+	"sign-message":   runSignMessage,
+	"verify-sig":     runVerifySig,
+	"merkle":         runMerkle,
+	"rlp":            runRLP,
+	"permit2":        runPermit2,
+	"roles":          runRoles,
+	"admins":         runAdmins,
+	"proposal":       runProposal,
+	"decode":         runDecode,
+	"approvals":      runApprovals,
+	"disasm":         runDisasm,
+	"interface":      runInterface,
+	"selectors":      runSelectors,
+	"erc20":          runERC20,
+	"send":           runSend,
+	"wait":           runWait,
+	"bridge":         runBridge,
+	"endpoints":      runEndpoints,
+	"bisect":         runBisect,
+	"find-slot":      runFindSlot,
+	"upgrade":        runUpgrade,
+	"beacon":         runBeacon,
+	"deposit":        runDeposit,
+	"multicall":      runMulticall,
+	"aave":           runAave,
+	"compound":       runCompound,
+	"curve":          runCurve,
+	"balancer":       runBalancer,
+	"message-status": runMessageStatus,
+	"withdrawal":     runWithdrawal,
+	"tokens":         runTokens,
+	"labels":         runLabels,
+	"bump":           runBump,
+	"cancel":         runCancel,
+	"plugins":        runPlugins,
+}
+
 func main() {
+	// Global flags come before the command
+	args := os.Args[1:]
+	for len(args) > 0 {
+		if args[0] == "--read-only" || args[0] == "-read-only" {
+			readOnlyFlag = true
+		} else if args[0] == "--override-policy" || args[0] == "-override-policy" {
+			policyOverride = true
+		} else {
+			break
+		}
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			err := command(args[1:])
+			printRPCMetrics()
+			if err != nil {
+				fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if path, ok := findPlugin(args[0]); ok {
+			if err := runPlugin(path, args[1:]); err != nil {
+				fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	runInteractive(args)
+}
+
+// Function to read the answer to a prompt, unless it was already given by a flag
+func ask(scanner *bufio.Scanner, prompt, given string) string {
+	if given != "" {
+		return given
+	}
+	fmt.Print(prompt)
+	scanner.Scan()
+	return scanner.Text()
+}
+
+// Function to prompt for a single call and optionally execute it
+func runInteractive(args []string) {
+	fs := flag.NewFlagSet("contract-curler", flag.ExitOnError)
+	abiPath := fs.String("abi", "", "ABI or Foundry/Hardhat artifact describing the contract")
+	enumsPath := fs.String("enums", "", "JSON file mapping enum names to their variant names")
+	timestamps := fs.String("timestamps", "", "render Unix times as dates: auto (by value name), all or off")
+	bytesFormat := fs.String("bytes", "", "display bytes as hex, utf8 or base64")
+	uintFormat := fs.String("uints", "", "display unsigned integers as decimal, hex or fixed")
+	decimals := fs.Int("decimals", -1, "number of decimals used by the fixed uint format")
+	humanize := fs.String("humanize", "", "make large uints readable: off, commas (1,234,567) or sci (1.2346e18)")
+	precision := fs.Int("precision", -1, "digits kept after the decimal point by the fixed and sci uint formats (default: all, 4 for sci)")
+	scale := fs.String("scale", "", "render integers as fixed-point: a scale for all (ray, wad, e8, decimals or 1eN) or name=scale pairs, e.g. liquidityRate=ray")
+	noLabels := fs.Bool("no-labels", false, "show addresses without their configured labels")
+	addressFormat := fs.String("addresses", "", "display addresses as hex, tron or filecoin")
+	retries := fs.Int("retries", 2, "number of retries on transport errors")
+	chain := fs.String("chain", "", "chain name or id, selecting its endpoint profile and contract deployments")
+	chains := fs.String("chains", "", "comma-separated chains to run the call on concurrently, comparing the decoded results")
+	preflight := fs.Bool("preflight", false, "report the status of the endpoint before calling it")
+	formatter := fs.String("formatter", "", "plugin formatting the decoded results instead of the built-in output")
+	fetchURIs := fs.Bool("fetch-uris", false, "fetch and print the JSON behind ipfs:// and ar:// URIs in the results")
+	script := fs.String("script", "", "Starlark script post-processing the decoded results, available as results[\"call\"]")
+	dryRun := fs.Bool("dry-run", false, "only print the curl command, never execute it")
+	executeFlag := fs.Bool("execute", false, "execute the command without asking")
+	yes := fs.Bool("yes", false, "answer yes to the confirmation prompt, like --execute")
+	output := addOutputFlags(fs)
+	manifestPath := fs.String("manifest", "", "file every executed request is appended to, with its endpoint, block and response hash")
+	fs.BoolVar(&deterministic, "deterministic", false, "leave timestamps, latencies and endpoint names out of the output")
+	noColor := fs.Bool("no-color", false, "never color the output, even on a terminal")
+	contractFlag := fs.String("contract", "", "contract address or alias, instead of asking for it")
+	functionFlag := fs.String("function", "", "function signature, instead of asking for it")
+	returnsFlag := fs.String("returns", "", "return type, instead of asking for it")
+	rpcFlag := fs.String("rpc", "", "Ethereum RPC URL, instead of asking for it")
+	layers := addLayeredFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+		os.Exit(1)
+	}
+	// Positional arguments are the arguments of the call, asked for when there are none
+	positional, err := layers.apply(cfg)
+	if err != nil {
+		fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+		os.Exit(1)
+	}
+	if *noColor {
+		colorEnabled = false
+	}
+
+	if *dryRun && (*executeFlag || *yes) {
+		fmt.Println(colorize(colorRed, "Error") + ": --dry-run cannot be combined with --execute or --yes")
+		os.Exit(1)
+	}
+	if *output.path != "" {
+		if _, err := output.outputFormat(); err != nil {
+			fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Flags take precedence over the config file, which takes precedence over the defaults
+	display := displayOptions(cfg)
+	display.Timestamps = firstNonEmpty(*timestamps, display.Timestamps)
+	display.Bytes = firstNonEmpty(*bytesFormat, display.Bytes)
+	display.Uints = firstNonEmpty(*uintFormat, display.Uints)
+	display.Addresses = firstNonEmpty(*addressFormat, display.Addresses)
+	if *decimals >= 0 {
+		display.Decimals = *decimals
+	}
+	display.Humanize = firstNonEmpty(*humanize, display.Humanize)
+	if *precision >= 0 {
+		display.Precision = *precision
+	}
+	if *noLabels {
+		display.Labels = nil
+	}
+	if display.Scales, err = parseScales(*scale, cfg); err != nil {
+		fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateDisplayOptions(display); err != nil {
+		fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+		os.Exit(1)
+	}
+
+	var contract *contractABI
+	if *abiPath != "" {
+		loaded, err := loadABI(*abiPath)
+		if err != nil {
+			fmt.Printf(colorize(colorRed, "Error loading ABI")+": %v\n", err)
+			os.Exit(1)
+		}
+		contract = loaded
+	}
+	if *enumsPath != "" {
+		if contract == nil {
+			contract = &contractABI{Enums: make(map[string][]string)}
+		}
+		if err := loadEnums(*enumsPath, contract.Enums); err != nil {
+			fmt.Printf(colorize(colorRed, "Error loading enums")+": %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	scanner := bufio.NewScanner(os.Stdin)
 
 	// Get contract address
-	fmt.Print("Enter contract address: ")
-	scanner.Scan()
-	contractAddress := scanner.Text()
+	contractAddress := strings.TrimSpace(ask(scanner, "Enter contract address or alias: ", *contractFlag))
+	if alias, ok := cfg.contract(contractAddress); ok {
+		// The alias ABI is used unless one was given explicitly
+		if *abiPath == "" && alias.ABI != "" {
+			loaded, err := loadABI(alias.ABI)
+			if err != nil {
+				fmt.Printf(colorize(colorRed, "Error loading ABI")+": %v\n", err)
+				os.Exit(1)
+			}
+			if contract != nil {
+				for name, variants := range contract.Enums {
+					loaded.Enums[name] = variants
+				}
+			}
+			contract = loaded
+		}
+	}
 
 	// Get function signature
-	fmt.Print("Enter function signature (e.g., getBalance(address)): ")
-	scanner.Scan()
-	functionSig := scanner.Text()
+	functionSig := ask(scanner, "Enter function signature (e.g., getBalance(address)): ", *functionFlag)
 
-	// Extract function parameters from signature
-	re := regexp.MustCompile(`\((.*)\)`)
-	matches := re.FindStringSubmatch(functionSig)
-	var paramTypes []string
-	if len(matches) > 1 && matches[1] != "" {
-		paramTypes = strings.Split(matches[1], ",")
+	// Bring the signature into the form its selector is computed from
+	canonical, err := canonicalSignature(functionSig)
+	if err != nil {
+		fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+		os.Exit(1)
+	}
+	if canonical != functionSig {
+		fmt.Println("Using signature:", canonical)
+		functionSig = canonical
+	}
+	paramTypes := splitTypeList(functionSig[strings.Index(functionSig, "(")+1 : len(functionSig)-1])
+
+	// Look the function up in the ABI to learn about enum parameters and results
+	var function *abiEntry
+	if contract != nil {
+		function = contract.findFunction(functionSig)
 	}
 
 	// Get return type
-	fmt.Print("Enter return type (e.g., (uint256,address)): ")
-	scanner.Scan()
-	returnType := scanner.Text()
+	returnPrompt := "Enter return type (e.g., (uint256,address)): "
+	if function != nil {
+		returnPrompt = "Enter return type (leave empty to use the ABI outputs): "
+	}
+	returnType := ask(scanner, returnPrompt, *returnsFlag)
+	if strings.TrimSpace(returnType) == "" && function != nil {
+		returnType = function.returnTypes()
+		fmt.Println("Using return type from ABI:", returnType)
+	}
 
 	// Get arguments
-	var args []string
+	if len(positional) > 0 && len(positional) != len(paramTypes) {
+		fmt.Printf(colorize(colorRed, "Error")+": %s takes %d arguments, %d given\n", functionSig, len(paramTypes), len(positional))
+		os.Exit(1)
+	}
+	var callArgs []string
 	for i, paramType := range paramTypes {
-		fmt.Printf("Enter value for parameter %d (%s): ", i+1, paramType)
-		scanner.Scan()
-		args = append(args, scanner.Text())
+		var variants []string
+		var enumName string
+		if function != nil && i < len(function.Inputs) {
+			variants = contract.enumVariants(function.Inputs[i])
+			enumName = function.Inputs[i].enumName()
+		}
+
+		var given string
+		if len(positional) > 0 {
+			given = positional[i]
+		}
+		argPrompt := fmt.Sprintf("Enter value for parameter %d (%s): ", i+1, paramType)
+		if variants != nil {
+			argPrompt = fmt.Sprintf("Enter value for parameter %d (%s %s: %s): ", i+1, paramType, enumName, strings.Join(variants, ", "))
+		}
+		arg := ask(scanner, argPrompt, given)
+
+		// Typed values are checked right away and asked again until valid, given ones fail
+		for {
+			var err error
+			if variants != nil {
+				var resolved string
+				if resolved, err = resolveEnumArg(arg, enumName, variants); err == nil {
+					arg = resolved
+				}
+			}
+			if err == nil {
+				err = validateArg(arg, paramType)
+			}
+			if err == nil {
+				break
+			}
+			fmt.Printf(colorize(colorRed, "Invalid value for parameter %d (%s)")+": %v\n", i+1, paramType, err)
+			if given != "" {
+				os.Exit(1)
+			}
+			fmt.Print(argPrompt)
+			if !scanner.Scan() {
+				fmt.Println()
+				os.Exit(1)
+			}
+			arg = scanner.Text()
+		}
+		callArgs = append(callArgs, arg)
+	}
+
+	// Run the call on every chain through its endpoint profile instead of a single endpoint
+	if *chains != "" {
+		if strings.TrimSpace(returnType) == "" {
+			fmt.Println(colorize(colorRed, "Error") + ": a return type is required to compare results")
+			os.Exit(1)
+		}
+		returnTypeList := splitTypeList(trimTypeList(returnType))
+		opts := display
+		if function != nil && len(function.Outputs) == len(returnTypeList) {
+			for _, output := range function.Outputs {
+				opts.Names = append(opts.Names, output.Name)
+				opts.EnumVariants = append(opts.EnumVariants, contract.enumVariants(output))
+			}
+		}
+		var chainList []string
+		for _, name := range strings.Split(*chains, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				chainList = append(chainList, name)
+			}
+		}
+		if *dryRun {
+			if !printChainCurls(cfg, chainList, contractAddress, functionSig, callArgs) {
+				os.Exit(1)
+			}
+			return
+		}
+		if *manifestPath != "" {
+			enableManifest(*manifestPath)
+		}
+		fmt.Println()
+		results := callOnChains(cfg, chainList, contractAddress, functionSig, callArgs, returnType)
+		printChainTable(results, returnTypeList, opts)
+		if err := output.write(chainRecords(cfg, output, results, functionSig, returnTypeList, opts)); err != nil {
+			fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+			os.Exit(1)
+		}
+		for _, result := range results {
+			if result.Err != nil {
+				os.Exit(1)
+			}
+		}
+		return
 	}
 
 	// Get RPC URL
-	fmt.Print("Enter Ethereum RPC URL (default: http://localhost:8545): ")
-	scanner.Scan()
-	rpcURL := scanner.Text()
+	fallbackRPC := chainRPC(*chain)
+	rpcURL := ask(scanner, fmt.Sprintf("Enter Ethereum RPC URL (default: %s): ", displayEndpoint(fallbackRPC)), *rpcFlag)
 	if rpcURL == "" {
-		rpcURL = "http://localhost:8545"
+		rpcURL = fallbackRPC
 	}
+	if *preflight {
+		status, err := probeNode(rpcURL)
+		if err != nil {
+			fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+			os.Exit(1)
+		}
+		printNodeStatus(rpcURL, status)
+		if status.Syncing != nil {
+			fmt.Println("Warning: the node is still syncing, results may be stale")
+		}
+	}
+
+	// Aliases and labels are resolved once the endpoint, and so the chain, is known
+	if !common.IsHexAddress(contractAddress) {
+		resolved, err := cfg.resolveAddress(contractAddress, *chain, rpcURL)
+		if err != nil {
+			fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+			os.Exit(1)
+		}
+		contractAddress = resolved.Hex()
+		fmt.Println("Using address:", contractAddress)
+	}
+	warnDenylisted(cfg, common.HexToAddress(contractAddress), "contract")
+
+	// Resolve nested calls and arithmetic in the arguments
+	ctx, err := newArgContext(rpcURL, *chain, "latest")
+	if err != nil {
+		fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+		os.Exit(1)
+	}
+	resolvedArgs, err := ctx.resolveArgs(functionSig, callArgs)
+	if err != nil {
+		fmt.Printf(colorize(colorRed, "Error resolving arguments")+": %v\n", err)
+		os.Exit(1)
+	}
+	for i, arg := range resolvedArgs {
+		if arg != callArgs[i] {
+			fmt.Printf("Argument %d: %s = %s\n", i+1, callArgs[i], arg)
+		}
+	}
+	callArgs = resolvedArgs
 
 	// Encode function call
-	encodedData, err := encodeMethodCall(functionSig, args)
+	encodedData, err := encodeMethodCall(functionSig, callArgs)
 	if err != nil {
-		fmt.Printf("Error encoding function call: %v\n", err)
+		fmt.Printf(colorize(colorRed, "Error encoding function call")+": %v\n", err)
 		os.Exit(1)
 	}
+	fmt.Println("Method ID:", encodedData[2:10])
 	fmt.Println("Encoded data:", encodedData)
+	var knownSignatures []string
+	if contract != nil {
+		for _, entry := range contract.Entries {
+			if entry.Type == "function" {
+				knownSignatures = append(knownSignatures, entry.signature())
+			}
+		}
+	}
+	warnUnknownSelector(rpcURL, contractAddress, functionSig, knownSignatures)
 
 	// Create JSON-RPC request
 	request := JsonRpcRequest{
@@ -259,72 +718,140 @@ func main() {
 			},
 			"latest",
 		},
-		Id: 1,
+		Id: reserveRequestIDs(1),
 	}
 
 	// Convert to JSON
 	jsonData, err := json.Marshal(request)
 	if err != nil {
-		fmt.Printf("Error creating JSON request: %v\n", err)
+		fmt.Printf(colorize(colorRed, "Error creating JSON request")+": %v\n", err)
 		os.Exit(1)
 	}
 
 	// Display the curl command
-	curlCmd := fmt.Sprintf("curl -X POST %s -H \"Content-Type: application/json\" --data '%s'",
-		rpcURL, string(jsonData))
 	fmt.Println("\nGenerated curl command:")
-	fmt.Println(curlCmd)
+	fmt.Println(curlCommand(rpcURL, jsonData))
 
-	// Ask if user wants to execute the command
-	fmt.Print("\nDo you want to execute this command? (y/n): ")
-	scanner.Scan()
-	execute := scanner.Text()
+	if *dryRun {
+		return
+	}
 
-	if strings.ToLower(execute) == "y" || strings.ToLower(execute) == "yes" {
-		// Execute the request
-		resp, err := http.Post(rpcURL, "application/json", bytes.NewBuffer(jsonData))
-		if err != nil {
-			fmt.Printf("Error executing request: %v\n", err)
-			os.Exit(1)
-		}
-		defer resp.Body.Close()
+	// Ask if user wants to execute the command, unless the flags already decided
+	execute := *executeFlag || *yes
+	if !execute {
+		fmt.Print("\nDo you want to execute this command? (y/n): ")
+		scanner.Scan()
+		answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		execute = answer == "y" || answer == "yes"
+	}
 
-		body, err := ioutil.ReadAll(resp.Body)
+	if execute {
+		if *manifestPath != "" {
+			enableManifest(*manifestPath)
+		}
+		// Execute the request
+		body, err := postJSON(rpcURL, jsonData, *retries)
 		if err != nil {
-			fmt.Printf("Error reading response: %v\n", err)
+			fmt.Printf(colorize(colorRed, "Error executing request")+": %v\n", err)
 			os.Exit(1)
 		}
 
 		// Parse the response
 		var response JsonRpcResponse
 		err = json.Unmarshal(body, &response)
+		if err == nil {
+			err = response.validate(request.Id)
+		}
 		if err != nil {
-			fmt.Printf("Error parsing response: %v\n", err)
+			fmt.Printf(colorize(colorRed, "Error parsing response")+": %v\n", err)
 			os.Exit(1)
 		}
 
 		fmt.Println("\nRaw Response:")
 		fmt.Println(string(body))
+		if offchainLookupOf(response.Error) != nil {
+			// EIP-3668 contracts answer through a gateway and a callback
+			var output hexutil.Bytes
+			if err := followOffchainLookups(rpcURL, &output, request.Params, response.Error); err != nil {
+				fmt.Printf(colorize(colorRed, "Offchain lookup failed")+": %v\n", err)
+				os.Exit(1)
+			}
+			response.Error = nil
+			response.Result, _ = json.Marshal(output)
+		}
+		if response.Error != nil {
+			var data hexutil.Bytes
+			json.Unmarshal(response.Error.Data, &data)
+			fmt.Printf(colorize(colorRed, "Call failed")+": %s\n", revertReason(data, response.Error.Message))
+			os.Exit(1)
+		}
+		// eth_call returns hex data, or null from some nodes when there is no code
+		var result string
+		if err := json.Unmarshal(response.Result, &result); err != nil {
+			fmt.Printf(colorize(colorRed, "Error parsing response")+": expected hex data, got %s\n", response.Result)
+			os.Exit(1)
+		}
 
 		// Parse the return types
-		returnTypeStr := strings.Trim(returnType, "()")
-		var returnTypeList []string
-		if returnTypeStr != "" {
-			returnTypeList = strings.Split(returnTypeStr, ",")
-		}
+		returnTypeList := splitTypeList(trimTypeList(returnType))
 
 		// Decode and display the result
-		if response.Result != "" {
+		if result != "" {
 			fmt.Println("\nDecoded Result:")
-			values, err := decodeReturnValues(response.Result, returnType)
+			values, err := decodeReturnValues(result, returnType)
 			if err != nil {
-				fmt.Printf("Error decoding results: %v\n", err)
-				os.Exit(1)
+				// Still show what came back so the declared types can be corrected
+				fmt.Printf(colorize(colorRed, "Error decoding results")+": %v\n", err)
+				data, decodeErr := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+				if decodeErr != nil {
+					fmt.Println("Raw result:", result)
+					return
+				}
+				printRawFallback(data)
+				return
+			}
+
+			opts := display.withChain(cfg, rpcURL)
+			for _, typStr := range returnTypeList {
+				_, name := splitTypeName(typStr)
+				opts.Names = append(opts.Names, name)
+			}
+			if function != nil && len(function.Outputs) == len(values) {
+				for i, output := range function.Outputs {
+					if opts.Names[i] == "" {
+						opts.Names[i] = output.Name
+					}
+					opts.EnumVariants = append(opts.EnumVariants, contract.enumVariants(output))
+				}
 			}
 
-			formattedValues := formatReturnValues(values, returnTypeList)
-			for _, value := range formattedValues {
-				fmt.Println(value)
+			formattedValues := formatReturnValues(values, returnTypeList, opts)
+			result := newPluginResult(contractAddress, functionSig, "latest", values, returnTypeList, formattedValues, opts.Names)
+			if err := output.write([]resultRecord{output.record("", result, string(body))}); err != nil {
+				fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+				os.Exit(1)
+			}
+			if *formatter != "" {
+				formatted, err := formatWithPlugin(*formatter, result)
+				if err != nil {
+					fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Print(formatted)
+				return
+			}
+			printReturnValues(formattedValues, returnTypeList, "")
+			if *fetchURIs {
+				printContentURIs(values, cfg)
+			}
+			if *script != "" {
+				results := starlark.NewDict(1)
+				results.SetKey(starlark.String("call"), scriptOutputs(values, opts.Names))
+				fmt.Println()
+				if err := runScript(*script, results, opts); err != nil {
+					fmt.Printf(colorize(colorRed, "Error")+": %v\n", err)
+					os.Exit(1)
+				}
 			}
 		}
 	}
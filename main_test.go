@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMethodCall(t *testing.T) {
+	tests := []struct {
+		signature string
+		args      []string
+		want      string
+	}{
+		{"f(uint8)", []string{"255"}, "0x" + functionSelector("f(uint8)") + strings.Repeat("0", 62) + "ff"},
+		{"f(int64)", []string{"-1"}, "0x" + functionSelector("f(int64)") + strings.Repeat("f", 64)},
+		{"f(uint256)", []string{"1"}, "0x" + functionSelector("f(uint256)") + strings.Repeat("0", 63) + "1"},
+		{"f(bytes2)", []string{"0x1234"}, "0x" + functionSelector("f(bytes2)") + "1234" + strings.Repeat("0", 60)},
+	}
+	for _, test := range tests {
+		got, err := encodeMethodCall(test.signature, test.args)
+		if err != nil {
+			t.Errorf("%s %v: %v", test.signature, test.args, err)
+		} else if got != test.want {
+			t.Errorf("%s %v = %s, want %s", test.signature, test.args, got, test.want)
+		}
+	}
+}
+
+func TestEncodeMethodCallErrors(t *testing.T) {
+	tests := []struct {
+		signature string
+		args      []string
+	}{
+		{"f(uint8)", []string{"256"}},
+		{"f(uint8)", []string{"-1"}},
+		{"f(int8)", []string{"128"}},
+		{"f(bytes2)", []string{"0x123456"}},
+		// Arrays and slices are not parsed from a single string, and must fail cleanly
+		{"f(uint256[])", []string{"1"}},
+		{"f(uint8[2])", []string{"1"}},
+		{"f(int64[])", []string{"1"}},
+		{"f(bytes32[])", []string{"0x12"}},
+		{"f(bytes2[2])", []string{"0x1234"}},
+	}
+	for _, test := range tests {
+		if _, err := encodeMethodCall(test.signature, test.args); err == nil {
+			t.Errorf("%s %v: expected an error", test.signature, test.args)
+		}
+	}
+}
+
+func TestValidateArg(t *testing.T) {
+	tests := []struct {
+		arg, paramType string
+		valid          bool
+	}{
+		{"255", "uint8", true},
+		{"2^8-1", "uint8", true},
+		{"256", "uint8", false},
+		{"1.5", "uint256", false},
+		{"0x1234", "address", true},
+		{"0xzz", "address", false},
+		{"0x" + strings.Repeat("1", 42), "address", false},
+		{"yes", "bool", true},
+		{"maybe", "bool", false},
+		{"0x1234", "bytes2", true},
+		{"0x12", "bytes2", false},
+		{"{{call: token.decimals() -> uint8}}", "uint8", true},
+		// Array and slice types must be rejected, not crash the prompt
+		{"1", "uint8[2]", false},
+		{"1", "uint256[]", false},
+		{"0x12", "bytes32[]", false},
+	}
+	for _, test := range tests {
+		err := validateArg(test.arg, test.paramType)
+		if test.valid && err != nil {
+			t.Errorf("validateArg(%q, %s): %v", test.arg, test.paramType, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("validateArg(%q, %s): expected an error", test.arg, test.paramType)
+		}
+	}
+}
+
+func TestParseRPCParams(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// Numbers beyond float64 precision are sent as written
+		{[]string{`[{"value":123456789012345678901234567890,"gas":21000},1.5e3]`}, `[{"gas":21000,"value":123456789012345678901234567890},1.5e3]`},
+		{[]string{"0x2c7536E3605D9C16a7a3D7b1898e529396a65c23", "9007199254740993", "true"}, `["0x2c7536E3605D9C16a7a3D7b1898e529396a65c23",9007199254740993,true]`},
+		{[]string{"latest", "1 2", `{"a":1}x`}, `["latest","1 2","{\"a\":1}x"]`},
+		{nil, `[]`},
+	}
+	for _, test := range tests {
+		params, err := parseRPCParams(test.args)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		got, _ := json.Marshal(params)
+		if string(got) != test.want {
+			t.Errorf("%q = %s, want %s", test.args, got, test.want)
+		}
+	}
+	if _, err := parseRPCParams([]string{`[1] [2]`}); err == nil {
+		t.Errorf("params with trailing data parsed")
+	}
+}
+
+func TestStreamRPC(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		items    int
+		err      string
+	}{
+		{"result", `{"jsonrpc":"2.0","id":ID,"result":[1,2,3]}`, 3, ""},
+		{"id last", `{"jsonrpc":"2.0","result":[1,2],"id":ID}`, 2, ""},
+		{"null result", `{"jsonrpc":"2.0","id":ID,"result":null}`, 0, ""},
+		{"no id", `{"jsonrpc":"2.0","result":[1]}`, 1, "response has no id"},
+		{"null id", `{"jsonrpc":"2.0","id":null,"result":[1]}`, 1, "response has no id"},
+		{"other id", `{"jsonrpc":"2.0","id":0,"result":[1]}`, 0, "does not match request id"},
+		{"other id last", `{"jsonrpc":"2.0","result":[1],"id":0}`, 1, "does not match request id"},
+		{"no version", `{"id":ID,"result":[1]}`, 1, "not a JSON-RPC 2.0 response"},
+		{"neither", `{"jsonrpc":"2.0","id":ID}`, 0, "neither a result nor an error"},
+		{"both", `{"jsonrpc":"2.0","id":ID,"result":[],"error":{"code":-32000,"message":"failed"}}`, 0, "both a result and an error"},
+		{"error", `{"jsonrpc":"2.0","id":ID,"error":{"code":-32005,"message":"query returned more than 10000 results"}}`, 0, "more than 10000 results"},
+		// Endpoints that could not tell which request failed leave the id out
+		{"error without id", `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`, 0, "parse error"},
+		{"error of other id", `{"jsonrpc":"2.0","id":0,"error":{"code":-32000,"message":"failed"}}`, 0, "does not match request id"},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request JsonRpcRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			w.Write([]byte(strings.Replace(test.response, "ID", strconv.Itoa(request.Id), 1)))
+		}))
+		var items int
+		err := streamRPC(server.URL, "eth_getLogs", nil, func(item json.RawMessage) error {
+			items++
+			return nil
+		})
+		server.Close()
+		if test.err == "" && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: returned %v, want %q", test.name, err, test.err)
+		}
+		if items != test.items {
+			t.Errorf("%s: streamed %d items, want %d", test.name, items, test.items)
+		}
+	}
+}
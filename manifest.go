@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Version of the tool, set at build time with -ldflags "-X main.version=v1.2.3"
+var version = ""
+
+// manifestEntry records an executed request, as audit evidence of what was read where
+type manifestEntry struct {
+	Time     string          `json:"time"`
+	Tool     string          `json:"tool"`
+	Endpoint string          `json:"endpoint"`
+	Request  json.RawMessage `json:"request"`
+	// Block the request read: its block parameter when a number, otherwise the head of the
+	// endpoint once the response arrived
+	Block          *uint64 `json:"block,omitempty"`
+	ResponseSHA256 string  `json:"responseSha256"`
+	ResponseBytes  int64   `json:"responseBytes"`
+}
+
+// Manifest every executed request is appended to, empty when none was asked for
+var manifest struct {
+	sync.Mutex
+	path string
+}
+
+// Function to record every request executed from now on in a manifest file
+func enableManifest(path string) {
+	manifest.Lock()
+	defer manifest.Unlock()
+	manifest.path = path
+}
+
+// Function to get the version of the tool, from the build flags or the module information
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// manifestRecorder hashes a response body as it is read and appends the manifest entry of
+// the request once the body is closed
+type manifestRecorder struct {
+	io.ReadCloser
+	rpcURL  string
+	request []byte
+	hasher  hash.Hash
+	size    int64
+}
+
+func (r *manifestRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hasher.Write(p[:n])
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *manifestRecorder) Close() error {
+	// Whatever the caller did not read is still part of the response
+	io.Copy(ioutil.Discard, r)
+	err := r.ReadCloser.Close()
+	entry := manifestEntry{
+		Time:           time.Now().UTC().Format(time.RFC3339Nano),
+		Tool:           "contract-curler " + toolVersion(),
+		Endpoint:       r.rpcURL,
+		Request:        r.request,
+		Block:          requestBlock(r.rpcURL, r.request),
+		ResponseSHA256: hex.EncodeToString(r.hasher.Sum(nil)),
+		ResponseBytes:  r.size,
+	}
+	if writeErr := appendManifest(entry); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", writeErr)
+	}
+	return err
+}
+
+// Function to wrap a response body so its request is recorded in the manifest, if any
+func recordResponse(rpcURL string, request []byte, body io.ReadCloser) io.ReadCloser {
+	manifest.Lock()
+	enabled := manifest.path != ""
+	manifest.Unlock()
+	if !enabled {
+		return body
+	}
+	return &manifestRecorder{ReadCloser: body, rpcURL: rpcURL, request: request, hasher: sha256.New()}
+}
+
+// Function to find the block a request read: a numeric block parameter, or the head of
+// the endpoint for block tags and requests without one
+func requestBlock(rpcURL string, request []byte) *uint64 {
+	var parsed JsonRpcRequest
+	if err := json.Unmarshal(request, &parsed); err == nil && len(parsed.Params) > 0 {
+		if tag, ok := parsed.Params[len(parsed.Params)-1].(string); ok && len(tag) <= 18 {
+			if n, err := hexutil.DecodeUint64(tag); err == nil {
+				return &n
+			}
+		}
+	}
+	// Asked directly, so the lookup itself is not recorded
+	lookup := newRPCRequest("eth_blockNumber", nil)
+	data, _ := json.Marshal(lookup)
+	body, err := openRequest(rpcURL, data, 0)
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	var response struct {
+		Id     *int           `json:"id"`
+		Result hexutil.Uint64 `json:"result"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil || checkResponseID(response.Id, lookup.Id, false) != nil {
+		return nil
+	}
+	n := uint64(response.Result)
+	return &n
+}
+
+// Function to append an entry to the manifest, one JSON object per line
+func appendManifest(entry manifestEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest entry: %v", err)
+	}
+	manifest.Lock()
+	defer manifest.Unlock()
+	file, err := os.OpenFile(manifest.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
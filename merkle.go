@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Trees are laid out like OpenZeppelin's StandardMerkleTree so roots and proofs work with
+// MerkleProof.verify: leaves are keccak256(keccak256(abi.encode(values))), sorted, stored
+// at the end of a flat array, and every node hashes its children in sorted order.
+type merkleTree struct {
+	nodes []common.Hash
+	// Position in nodes of each leaf hash
+	positions map[common.Hash]int
+}
+
+// Function to run the merkle subcommands: root, proof and verify
+func runMerkle(args []string) error {
+	fs := flag.NewFlagSet("merkle", flag.ExitOnError)
+	types := fs.String("types", "", "comma-separated ABI types of the values of a leaf, e.g. address,uint256")
+	file := fs.String("file", "", "file with one leaf per line, its values separated by commas")
+	leaf := fs.String("leaf", "", "comma-separated values of the leaf to prove or verify")
+	root := fs.String("root", "", "root to verify against, instead of building the tree from --file")
+	proof := fs.String("proof", "", "comma-separated proof to verify")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler merkle [flags] root|proof|verify")
+	}
+	if *types == "" {
+		return fmt.Errorf("--types is required")
+	}
+	leafTypes := strings.Split(*types, ",")
+
+	switch fs.Arg(0) {
+	case "root":
+		tree, err := loadMerkleTree(*file, leafTypes)
+		if err != nil {
+			return err
+		}
+		fmt.Println(tree.root().Hex())
+	case "proof":
+		tree, err := loadMerkleTree(*file, leafTypes)
+		if err != nil {
+			return err
+		}
+		hash, err := merkleLeaf(leafTypes, splitLeaf(*leaf))
+		if err != nil {
+			return err
+		}
+		path, err := tree.proof(hash)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Leaf:  " + hash.Hex())
+		fmt.Println("Root:  " + tree.root().Hex())
+		fmt.Println("Proof: " + formatHashList(path))
+	case "verify":
+		hash, err := merkleLeaf(leafTypes, splitLeaf(*leaf))
+		if err != nil {
+			return err
+		}
+		expected := common.HexToHash(*root)
+		if *root == "" {
+			tree, err := loadMerkleTree(*file, leafTypes)
+			if err != nil {
+				return err
+			}
+			expected = tree.root()
+		}
+		var path []common.Hash
+		for _, node := range strings.Split(strings.Trim(*proof, "[] "), ",") {
+			if node = strings.TrimSpace(node); node != "" {
+				path = append(path, common.HexToHash(node))
+			}
+		}
+		if computed := processProof(hash, path); computed != expected {
+			return fmt.Errorf("proof is not valid: it leads to %s, not %s", computed.Hex(), expected.Hex())
+		}
+		fmt.Println("✓ leaf is in the tree of root " + expected.Hex())
+	default:
+		return fmt.Errorf("unknown merkle action '%s' (expected root, proof or verify)", fs.Arg(0))
+	}
+	return nil
+}
+
+// Function to split the values of a leaf, trimming the spaces around them
+func splitLeaf(line string) []string {
+	values := strings.Split(line, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// Function to compute the hash of a leaf the way StandardMerkleTree does. Hashing twice
+// keeps leaves from being mistaken for inner nodes.
+func merkleLeaf(types []string, values []string) (common.Hash, error) {
+	if len(values) != len(types) {
+		return common.Hash{}, fmt.Errorf("leaf %s has %d values, expected %d (%s)", strings.Join(values, ","), len(values), len(types), strings.Join(types, ","))
+	}
+	encoded, err := encodeArguments(types, values)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(crypto.Keccak256(encoded)), nil
+}
+
+// Function to read the leaves of a tree from a file and build it, skipping blank lines
+// and # comments
+func loadMerkleTree(path string, types []string) (*merkleTree, error) {
+	if path == "" {
+		return nil, fmt.Errorf("--file is required")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open leaves: %v", err)
+	}
+	defer file.Close()
+
+	var leaves []common.Hash
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, err := merkleLeaf(types, splitLeaf(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		leaves = append(leaves, hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read leaves: %v", err)
+	}
+	return buildMerkleTree(leaves)
+}
+
+// Function to build a tree over leaf hashes
+func buildMerkleTree(leaves []common.Hash) (*merkleTree, error) {
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("cannot build a tree without leaves")
+	}
+	sorted := append([]common.Hash(nil), leaves...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+
+	tree := &merkleTree{nodes: make([]common.Hash, 2*len(sorted)-1), positions: make(map[common.Hash]int)}
+	for i, leaf := range sorted {
+		position := len(tree.nodes) - 1 - i
+		tree.nodes[position] = leaf
+		tree.positions[leaf] = position
+	}
+	for i := len(tree.nodes) - 1 - len(sorted); i >= 0; i-- {
+		tree.nodes[i] = hashPair(tree.nodes[2*i+1], tree.nodes[2*i+2])
+	}
+	return tree, nil
+}
+
+// Function to get the root of a tree
+func (t *merkleTree) root() common.Hash {
+	return t.nodes[0]
+}
+
+// Function to get the sibling hashes from a leaf up to the root
+func (t *merkleTree) proof(leaf common.Hash) ([]common.Hash, error) {
+	i, ok := t.positions[leaf]
+	if !ok {
+		return nil, fmt.Errorf("leaf %s is not in the tree", leaf.Hex())
+	}
+	var path []common.Hash
+	for i > 0 {
+		sibling := i - 1
+		if i%2 == 1 {
+			sibling = i + 1
+		}
+		path = append(path, t.nodes[sibling])
+		i = (i - 1) / 2
+	}
+	return path, nil
+}
+
+// Function to compute the root a proof leads to, like MerkleProof.processProof
+func processProof(leaf common.Hash, proof []common.Hash) common.Hash {
+	computed := leaf
+	for _, node := range proof {
+		computed = hashPair(computed, node)
+	}
+	return computed
+}
+
+// Function to hash two nodes in sorted order
+func hashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a[:], b[:])
+}
+
+// Function to render hashes as an array argument, e.g. [0x..,0x..]
+func formatHashList(hashes []common.Hash) string {
+	parts := make([]string, len(hashes))
+	for i, hash := range hashes {
+		parts[i] = hexutil.Encode(hash[:])
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Events marking a message leaving a chain: a LayerZero V2 packet, an OP Stack withdrawal
+// and an Arbitrum L2 to L1 message
+var (
+	packetSentTopic     = crypto.Keccak256Hash([]byte("PacketSent(bytes,bytes,address)"))
+	messagePassedTopic  = crypto.Keccak256Hash([]byte("MessagePassed(uint256,address,address,uint256,uint256,bytes,bytes32)"))
+	l2ToL1TxTopic       = crypto.Keccak256Hash([]byte("L2ToL1Tx(address,address,uint256,uint256,uint256,uint256,uint256,uint256,bytes)"))
+	l2ToL1MessagePasser = common.HexToAddress("0x4200000000000000000000000000000000000016")
+	arbSys              = common.HexToAddress("0x0000000000000000000000000000000000000064")
+)
+
+// LayerZero V2 EndpointV2, deployed at the same address on the chains below
+const layerZeroEndpoint = "0x1a44076050125825900e736c501f859c50fE728c"
+
+// Chains of the LayerZero V2 endpoint ids
+var layerZeroChains = map[uint32]uint64{
+	30101: 1,
+	30102: 56,
+	30106: 43114,
+	30109: 137,
+	30110: 42161,
+	30111: 10,
+	30184: 8453,
+}
+
+// settlement is the L1 chain of a rollup and the contract its withdrawals are settled by
+type settlement struct {
+	chain    uint64
+	contract string
+}
+
+// OptimismPortal of OP Stack chains and Outbox of Arbitrum chains, by L2 chain id
+var (
+	optimismPortals = map[uint64]settlement{
+		10:   {1, "0xbEb5Fc579115071764c7423A4f12eDde41f106Ed"},
+		8453: {1, "0x49048044D57e1C92A77f79988d21Fa8fAF74E97e"},
+	}
+	arbitrumOutboxes = map[uint64]settlement{
+		42161: {1, "0x0B9857ae2D4A3DBe74ffE1d7DF045bb7F96E4840"},
+	}
+)
+
+// Delay between proving an OP Stack withdrawal and finalizing it, and between an Arbitrum
+// message and the confirmation of the assertion holding it, on mainnet
+const withdrawalDelay = 7 * 24 * time.Hour
+
+// Function to report the status of the cross-chain messages sent by a transaction: the
+// LayerZero V2 packets, OP Stack withdrawals and Arbitrum L2 to L1 messages it emitted,
+// each looked up on its destination chain
+func runMessageStatus(args []string) error {
+	fs := flag.NewFlagSet("message-status", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL of the source chain (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "source chain name or id, selecting its endpoint")
+	destRPC := fs.String("dest-rpc", "", "RPC URL of the destination chain (default: the endpoint profile of the chain)")
+	destContract := fs.String("dest-contract", "", "LayerZero endpoint, OptimismPortal or Outbox on the destination (default: the known one of the chain)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler message-status [flags] <tx hash>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	sourceChain, err := cfg.chainID(*chain, endpoint)
+	if err != nil {
+		return err
+	}
+	receipt, err := sentMessageReceipt(endpoint, fs.Arg(0), sourceChain)
+	if err != nil {
+		return err
+	}
+	destination := func(chainID uint64) (string, error) {
+		return destinationRPC(cfg, *destRPC, chainID)
+	}
+
+	found := 0
+	for _, entry := range receipt.Logs {
+		if len(entry.Topics) == 0 {
+			continue
+		}
+		topic, emitter := common.HexToHash(entry.Topics[0]), common.HexToAddress(entry.Address)
+		var report func() error
+		switch {
+		case topic == packetSentTopic:
+			report = func() error { return layerZeroStatus(entry, destination, *destContract) }
+		case topic == messagePassedTopic && emitter == l2ToL1MessagePasser:
+			report = func() error { return optimismWithdrawalStatus(entry, sourceChain, destination, *destContract) }
+		case topic == l2ToL1TxTopic && emitter == arbSys:
+			report = func() error { return arbitrumMessageStatus(entry, sourceChain, destination, *destContract) }
+		default:
+			continue
+		}
+		if found++; found > 1 {
+			fmt.Println()
+		}
+		if err := report(); err != nil {
+			fmt.Printf("  Status:   %s: %v\n", colorize(colorRed, "unknown"), err)
+		}
+	}
+	if found == 0 {
+		return fmt.Errorf("transaction %s sent no LayerZero, OP Stack or Arbitrum message", fs.Arg(0))
+	}
+	return nil
+}
+
+// Function to report a LayerZero V2 packet: verified packets have their payload hash
+// stored on the destination endpoint until they are executed, which clears it and moves
+// the lazy inbound nonce past them
+func layerZeroStatus(entry LogEntry, destination func(uint64) (string, error), destContract string) error {
+	values, err := decodeReturnValues(entry.Data, "(bytes,bytes,address)")
+	if err != nil {
+		return fmt.Errorf("invalid PacketSent event: %v", err)
+	}
+	// Packet header: version (1 byte), nonce (8), source eid (4), sender (32), destination
+	// eid (4), receiver (32), then the guid (32) and the message
+	packet := values[0].([]byte)
+	if len(packet) < 113 {
+		return fmt.Errorf("packet of %d bytes is too short", len(packet))
+	}
+	nonce := binary.BigEndian.Uint64(packet[1:9])
+	srcEid := binary.BigEndian.Uint32(packet[9:13])
+	sender := common.BytesToHash(packet[13:45])
+	dstEid := binary.BigEndian.Uint32(packet[45:49])
+	receiver := common.BytesToHash(packet[49:81])
+	guid := common.BytesToHash(packet[81:113])
+
+	fmt.Printf("LayerZero V2 packet %d from eid %d to eid %d\n", nonce, srcEid, dstEid)
+	fmt.Printf("  GUID:     %s\n", guid.Hex())
+	fmt.Printf("  Sender:   %s\n", common.BytesToAddress(sender.Bytes()).Hex())
+	fmt.Printf("  Receiver: %s\n", common.BytesToAddress(receiver.Bytes()).Hex())
+	chainID, known := layerZeroChains[dstEid]
+	if !known && destContract == "" {
+		return fmt.Errorf("destination eid %d is not known, give the destination with --dest-rpc and --dest-contract", dstEid)
+	}
+	rpcURL, err := destination(chainID)
+	if err != nil {
+		return err
+	}
+	endpoint := firstNonEmpty(destContract, layerZeroEndpoint)
+	receiverAddress := common.BytesToAddress(receiver.Bytes()).Hex()
+	args := []string{receiverAddress, strconv.FormatUint(uint64(srcEid), 10), sender.Hex()}
+
+	data, err := encodeMethodCall("inboundPayloadHash(address,uint32,bytes32,uint64)", append(args, strconv.FormatUint(nonce, 10)))
+	if err != nil {
+		return err
+	}
+	hash, err := callForValues(rpcURL, endpoint, data, "latest", "(bytes32)")
+	if err != nil {
+		return fmt.Errorf("inboundPayloadHash failed: %v", err)
+	}
+	if hash[0].([32]byte) != [32]byte{} {
+		fmt.Printf("  Status:   %s, verified on the destination and waiting to be executed\n", colorize(colorYellow, "verified"))
+		return nil
+	}
+	data, err = encodeMethodCall("lazyInboundNonce(address,uint32,bytes32)", args)
+	if err != nil {
+		return err
+	}
+	lazyNonce, err := callForValues(rpcURL, endpoint, data, "latest", "(uint64)")
+	if err != nil {
+		return fmt.Errorf("lazyInboundNonce failed: %v", err)
+	}
+	if lazyNonce[0].(uint64) >= nonce {
+		fmt.Printf("  Status:   %s\n", colorize(colorGreen, "delivered"))
+		return nil
+	}
+	fmt.Printf("  Status:   %s, not verified on the destination yet\n", colorize(colorYellow, "in flight"))
+	return nil
+}
+
+// Function to report an OP Stack withdrawal, which is proven on the OptimismPortal of L1
+// once an output root covering it is published, then finalized after the proof matures
+func optimismWithdrawalStatus(entry LogEntry, sourceChain uint64, destination func(uint64) (string, error), destContract string) error {
+	values, err := decodeReturnValues(entry.Data, "(uint256,uint256,bytes,bytes32)")
+	if err != nil || len(entry.Topics) != 4 {
+		return fmt.Errorf("invalid MessagePassed event")
+	}
+	withdrawalHash := common.Hash(values[3].([32]byte))
+	fmt.Printf("OP Stack withdrawal %s\n", withdrawalHash.Hex())
+	fmt.Printf("  Nonce:    %s\n", new(big.Int).SetBytes(common.HexToHash(entry.Topics[1]).Bytes()))
+	fmt.Printf("  Sender:   %s\n", common.HexToAddress(entry.Topics[2]).Hex())
+	fmt.Printf("  Target:   %s\n", common.HexToAddress(entry.Topics[3]).Hex())
+	fmt.Printf("  Value:    %s ETH\n", formatFixed(values[0].(*big.Int), 18))
+	portal, known := optimismPortals[sourceChain]
+	if !known && destContract == "" {
+		return fmt.Errorf("no OptimismPortal known for %s, give it with --dest-rpc and --dest-contract", chainName(sourceChain))
+	}
+	rpcURL, err := destination(portal.chain)
+	if err != nil {
+		return err
+	}
+	address := common.HexToAddress(firstNonEmpty(destContract, portal.contract))
+
+	data, err := encodeMethodCall("finalizedWithdrawals(bytes32)", []string{withdrawalHash.Hex()})
+	if err != nil {
+		return err
+	}
+	finalized, err := callForValues(rpcURL, address.Hex(), data, "latest", "(bool)")
+	if err != nil {
+		return fmt.Errorf("finalizedWithdrawals failed: %v", err)
+	}
+	if finalized[0].(bool) {
+		fmt.Printf("  Status:   %s\n", colorize(colorGreen, "finalized"))
+		return nil
+	}
+
+	provenAt, _, err := optimismProof(rpcURL, address, withdrawalHash)
+	if err != nil {
+		return err
+	}
+	if provenAt == nil {
+		fmt.Printf("  Status:   %s, waiting to be proven on %s\n", colorize(colorYellow, "initiated"), chainName(portal.chain))
+		return nil
+	}
+	finalizable := optimismFinalizable(rpcURL, address, provenAt)
+	if time.Now().Before(finalizable) {
+		fmt.Printf("  Status:   %s, can be finalized after %s\n", colorize(colorYellow, "proven"), finalizable.UTC().Format(time.RFC3339))
+	} else {
+		fmt.Printf("  Status:   %s, ready to be finalized\n", colorize(colorYellow, "proven"))
+	}
+	return nil
+}
+
+// Function to report an Arbitrum L2 to L1 message, executable on the L1 Outbox once the
+// assertion holding it is confirmed and marked spent there once executed
+func arbitrumMessageStatus(entry LogEntry, sourceChain uint64, destination func(uint64) (string, error), destContract string) error {
+	values, err := decodeReturnValues(entry.Data, "(address,uint256,uint256,uint256,uint256,bytes)")
+	if err != nil || len(entry.Topics) != 4 {
+		return fmt.Errorf("invalid L2ToL1Tx event")
+	}
+	position := new(big.Int).SetBytes(common.HexToHash(entry.Topics[3]).Bytes())
+	timestamp := values[3].(*big.Int)
+	fmt.Printf("Arbitrum L2 to L1 message %s\n", position)
+	fmt.Printf("  Sender:   %s\n", values[0].(common.Address).Hex())
+	fmt.Printf("  Target:   %s\n", common.HexToAddress(entry.Topics[1]).Hex())
+	fmt.Printf("  Value:    %s ETH\n", formatFixed(values[4].(*big.Int), 18))
+	outbox, known := arbitrumOutboxes[sourceChain]
+	if !known && destContract == "" {
+		return fmt.Errorf("no Outbox known for %s, give it with --dest-rpc and --dest-contract", chainName(sourceChain))
+	}
+	rpcURL, err := destination(outbox.chain)
+	if err != nil {
+		return err
+	}
+	data, err := encodeMethodCall("isSpent(uint256)", []string{position.String()})
+	if err != nil {
+		return err
+	}
+	spent, err := callForValues(rpcURL, firstNonEmpty(destContract, outbox.contract), data, "latest", "(bool)")
+	if err != nil {
+		return fmt.Errorf("isSpent failed: %v", err)
+	}
+	if spent[0].(bool) {
+		fmt.Printf("  Status:   %s\n", colorize(colorGreen, "executed"))
+		return nil
+	}
+	confirmable := time.Unix(timestamp.Int64(), 0).Add(withdrawalDelay)
+	if time.Now().Before(confirmable) {
+		fmt.Printf("  Status:   %s, executable once confirmed, around %s\n", colorize(colorYellow, "waiting"), confirmable.UTC().Format(time.RFC3339))
+	} else {
+		fmt.Printf("  Status:   %s, executable on the Outbox once its assertion is confirmed\n", colorize(colorYellow, "not executed"))
+	}
+	return nil
+}
+
+// Function to fetch the receipt of a transaction sending cross-chain messages
+func sentMessageReceipt(rpcURL, hash string, chainID uint64) (*txReceipt, error) {
+	var receipt *txReceipt
+	if err := callRPC(rpcURL, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, fmt.Errorf("failed to fetch receipt: %v", err)
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("transaction %s not found or not mined on %s", hash, chainName(chainID))
+	}
+	if receipt.Status != 1 {
+		return nil, fmt.Errorf("transaction %s reverted, it sent no message", hash)
+	}
+	return receipt, nil
+}
+
+// Function to get the endpoint of the destination chain of a message: the one given, or
+// the endpoint profile of the chain, as falling back to the default endpoint would
+// silently query the wrong chain
+func destinationRPC(cfg *Config, override string, chainID uint64) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if profile := chainProfile(cfg, chainName(chainID)); profile != "" {
+		return profile, nil
+	}
+	return "", fmt.Errorf("no endpoint profile configured for %s, give its endpoint with --dest-rpc", chainName(chainID))
+}
+
+// Function to find when an OP Stack withdrawal was proven on an OptimismPortal, nil when
+// it is not, and who proved it. Portals with fault proofs keep a proof per submitter,
+// earlier ones a single proof with no submitter.
+func optimismProof(rpcURL string, portal common.Address, withdrawalHash common.Hash) (*big.Int, common.Address, error) {
+	submitters, ok := callUintGetter(rpcURL, portal.Hex(), "numProofSubmitters(bytes32)", []string{withdrawalHash.Hex()}, "latest")
+	if !ok {
+		data, err := encodeMethodCall("provenWithdrawals(bytes32)", []string{withdrawalHash.Hex()})
+		if err != nil {
+			return nil, common.Address{}, err
+		}
+		proof, err := callForValues(rpcURL, portal.Hex(), data, "latest", "(bytes32,uint128,uint128)")
+		if err != nil {
+			return nil, common.Address{}, fmt.Errorf("provenWithdrawals failed: %v", err)
+		}
+		if proof[1].(*big.Int).Sign() == 0 {
+			return nil, common.Address{}, nil
+		}
+		return proof[1].(*big.Int), common.Address{}, nil
+	}
+	if submitters.Sign() == 0 {
+		return nil, common.Address{}, nil
+	}
+	data, err := encodeMethodCall("proofSubmitters(bytes32,uint256)", []string{withdrawalHash.Hex(), "0"})
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	values, err := callForValues(rpcURL, portal.Hex(), data, "latest", "(address)")
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("proofSubmitters failed: %v", err)
+	}
+	submitter := values[0].(common.Address)
+	if data, err = encodeMethodCall("provenWithdrawals(bytes32,address)", []string{withdrawalHash.Hex(), submitter.Hex()}); err != nil {
+		return nil, common.Address{}, err
+	}
+	proof, err := callForValues(rpcURL, portal.Hex(), data, "latest", "(address,uint64)")
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("provenWithdrawals failed: %v", err)
+	}
+	return new(big.Int).SetUint64(proof[1].(uint64)), submitter, nil
+}
+
+// Function to get when a withdrawal proven at a time can be finalized on a portal
+func optimismFinalizable(rpcURL string, portal common.Address, provenAt *big.Int) time.Time {
+	delay := withdrawalDelay
+	if seconds, ok := callUintGetter(rpcURL, portal.Hex(), "proofMaturityDelaySeconds()", nil, "latest"); ok {
+		delay = time.Duration(seconds.Int64()) * time.Second
+	}
+	return time.Unix(provenAt.Int64(), 0).Add(delay)
+}
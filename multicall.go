@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Account of the presigned transaction deploying Multicall3 at its canonical address, the
+// address of its first contract creation, on any chain where the account has not been used
+const multicall3Deployer = "0x05f32B3cC3888453ff71B01135B34FF8e41263F2"
+
+// Function to run the multicall subcommands: status, telling whether Multicall3 is deployed
+// on a chain, and deploy, broadcasting its presigned deployment where it is not
+func runMulticall(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler multicall status|deploy [flags]")
+	}
+	fs := flag.NewFlagSet("multicall "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	txSource := fs.String("tx", "", "presigned Multicall3 deployment transaction published with Multicall3, as hex or a file holding it")
+	fund := fs.Bool("fund", false, "send the deployer what it lacks to pay for the deployment from the signing key")
+	keyFile := fs.String("key-file", "", "file holding the hex private key funding the deployer (default: $"+privateKeyEnv+")")
+	yes := fs.Bool("yes", false, "send the funding and deployment transactions without asking for confirmation")
+	wait := addWaitFlags(fs)
+	fs.Parse(args[1:])
+	confirmOptions.yes = *yes
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	deployed, err := multicall3Deployed(endpoint, "latest")
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "status":
+		if deployed {
+			fmt.Printf("Multicall3 is deployed at %s, batches are aggregated through it\n", multicall3Address)
+			return nil
+		}
+		fmt.Printf("Multicall3 is %s at %s, batches fall back to JSON-RPC batching\n", colorize(colorYellow, "not deployed"), multicall3Address)
+		var nonce hexutil.Uint64
+		var balance hexutil.Big
+		if err := callRPC(endpoint, &nonce, "eth_getTransactionCount", multicall3Deployer, "latest"); err != nil {
+			return fmt.Errorf("failed to get nonce of %s: %v", multicall3Deployer, err)
+		}
+		if err := callRPC(endpoint, &balance, "eth_getBalance", multicall3Deployer, "latest"); err != nil {
+			return fmt.Errorf("failed to get balance of %s: %v", multicall3Deployer, err)
+		}
+		fmt.Printf("Deployer %s: nonce %d, balance %s ETH\n", multicall3Deployer, nonce, formatFixed((*big.Int)(&balance), 18))
+		if nonce > 0 {
+			fmt.Println(colorize(colorRed, "The deployer has already been used on this chain") + ", Multicall3 can no longer be deployed at its canonical address")
+			return nil
+		}
+		fmt.Println("Deploy it with: contract-curler multicall deploy --tx <presigned transaction>")
+		return nil
+
+	case "deploy":
+		if err := requireWritable("multicall deploy"); err != nil {
+			return err
+		}
+		if deployed {
+			fmt.Printf("Multicall3 is already deployed at %s\n", multicall3Address)
+			return nil
+		}
+		if *txSource == "" {
+			return fmt.Errorf("usage: contract-curler multicall deploy --tx <hex or file> [flags], with the presigned transaction published in the Multicall3 repository")
+		}
+		tx, sender, err := loadMulticall3Deployment(*txSource)
+		if err != nil {
+			return err
+		}
+		var nonce hexutil.Uint64
+		if err := callRPC(endpoint, &nonce, "eth_getTransactionCount", sender.Hex(), "pending"); err != nil {
+			return fmt.Errorf("failed to get nonce of %s: %v", sender.Hex(), err)
+		}
+		if uint64(nonce) != tx.Nonce() {
+			return fmt.Errorf("deployer %s is at nonce %d on this chain, not %d, Multicall3 can no longer be deployed at its canonical address", sender.Hex(), nonce, tx.Nonce())
+		}
+
+		// The deployer must hold the full cost, which it pays at the fixed presigned gas price
+		cost := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+		cost.Add(cost, tx.Value())
+		var balance hexutil.Big
+		if err := callRPC(endpoint, &balance, "eth_getBalance", sender.Hex(), "latest"); err != nil {
+			return fmt.Errorf("failed to get balance of %s: %v", sender.Hex(), err)
+		}
+		if missing := new(big.Int).Sub(cost, (*big.Int)(&balance)); missing.Sign() > 0 {
+			if !*fund {
+				return fmt.Errorf("deployer %s needs %s ETH more to pay for the deployment, send it or use --fund", sender.Hex(), formatFixed(missing, 18))
+			}
+			if err := fundMulticall3Deployer(endpoint, *chain, *keyFile, sender, missing, wait); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Deploying Multicall3 to %s from %s\n", multicall3Address, sender.Hex())
+		hash, err := broadcastTransaction(endpoint, tx)
+		if err != nil {
+			if !tx.Protected() {
+				return fmt.Errorf("%v (the presigned transaction has no chain id, the node must accept unprotected transactions)", err)
+			}
+			return err
+		}
+		fmt.Println("Transaction:", hash)
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := waitAndReport(endpoint, hash, wait, cfg); err != nil {
+			return err
+		}
+		if deployed, err := multicall3Deployed(endpoint, "latest"); err != nil || !deployed {
+			return fmt.Errorf("no code at %s after the deployment", multicall3Address)
+		}
+		fmt.Printf("Multicall3 is deployed at %s\n", multicall3Address)
+		return nil
+	}
+	return fmt.Errorf("unknown multicall subcommand '%s' (expected status or deploy)", args[0])
+}
+
+// Function to tell whether Multicall3 has code at its canonical address at a block
+func multicall3Deployed(rpcURL, block string) (bool, error) {
+	var code hexutil.Bytes
+	if err := callRPC(rpcURL, &code, "eth_getCode", multicall3Address, block); err != nil {
+		return false, fmt.Errorf("failed to get code of Multicall3: %v", err)
+	}
+	return len(code) > 0, nil
+}
+
+// Function to load a presigned deployment transaction, given as hex or in a file, checking
+// that it creates a contract at the canonical Multicall3 address
+func loadMulticall3Deployment(source string) (*types.Transaction, common.Address, error) {
+	text := source
+	if !strings.HasPrefix(strings.TrimSpace(source), "0x") {
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			return nil, common.Address{}, fmt.Errorf("failed to read transaction: %v", err)
+		}
+		text = string(data)
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(text))
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("invalid transaction hex: %v", err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, common.Address{}, fmt.Errorf("invalid transaction: %v", err)
+	}
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.LatestSignerForChainID(tx.ChainId())
+	}
+	sender, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("invalid transaction signature: %v", err)
+	}
+	if tx.To() != nil || crypto.CreateAddress(sender, tx.Nonce()) != common.HexToAddress(multicall3Address) {
+		return nil, common.Address{}, fmt.Errorf("transaction does not deploy a contract at %s", multicall3Address)
+	}
+	return tx, sender, nil
+}
+
+// Function to send the deployer the amount it lacks from the signing key, waiting for the
+// transfer so the deployment can pay for itself
+func fundMulticall3Deployer(rpcURL, chain, keyFile string, deployer common.Address, amount *big.Int, wait *waitOptions) error {
+	key, err := loadSigningKey(keyFile, false)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("--fund needs a signing key (--key-file or $%s)", privateKeyEnv)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	chainID, err := cfg.chainID(chain, rpcURL)
+	if err != nil {
+		return err
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	var nonce hexutil.Uint64
+	if err := callRPC(rpcURL, &nonce, "eth_getTransactionCount", from.Hex(), "pending"); err != nil {
+		return fmt.Errorf("failed to get nonce of %s: %v", from.Hex(), err)
+	}
+	tip, feeCap, err := suggestFees(rpcURL, "", "")
+	if err != nil {
+		return err
+	}
+	tx := newTransaction(chainID, uint64(nonce), &deployer, amount, transferGas, tip, feeCap, nil, nil)
+	signed, err := signTransaction(tx, chainID, key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Funding deployer %s with %s ETH from %s\n", deployer.Hex(), formatFixed(amount, 18), from.Hex())
+	hash, err := broadcastTransaction(rpcURL, signed)
+	if err != nil {
+		return err
+	}
+	receipt, err := waitForReceipt(rpcURL, hash, *wait.confirmations, *wait.timeout, *wait.interval)
+	if err != nil {
+		return fmt.Errorf("funding transaction %s: %v", hash, err)
+	}
+	if receipt.Status != 1 {
+		return fmt.Errorf("funding transaction %s reverted", hash)
+	}
+	return nil
+}
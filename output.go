@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set by --deterministic: output leaves out what changes between runs of the same query,
+// i.e. timestamps, latencies and endpoint names, so it can be diffed against golden files
+var deterministic bool
+
+// resultRecord is a decoded call result written to an output file
+type resultRecord struct {
+	Time string `json:"time,omitempty"`
+	// Name of the pipeline step, or the chain of a --chains call, empty for single calls
+	Step string `json:"step,omitempty"`
+	pluginResult
+	// Estimated gas of the call sent as a transaction, with batch --gas
+	Gas uint64 `json:"gas,omitempty"`
+	// Raw JSON-RPC response, only kept with --out-raw, or the returned data for --chains
+	// calls
+	Raw string `json:"raw,omitempty"`
+}
+
+// outputFlags select the file decoded results are written to
+type outputFlags struct {
+	path   *string
+	format *string
+	append *bool
+	raw    *bool
+}
+
+// Columns of the CSV output, one row per decoded value
+var csvColumns = []string{"time", "step", "contract", "function", "block", "name", "type", "value", "display", "raw", "gas"}
+
+// Function to register the output file flags on a flag set
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		path:   fs.String("out", "", "file the decoded results are written to, e.g. results.json, results.csv or results.db"),
+		format: fs.String("out-format", "", "format of the output file: json, jsonl, csv or sqlite, which needs the sqlite3 command line shell installed (default: from the extension)"),
+		append: fs.Bool("append", false, "add the results to the output file instead of replacing it"),
+		raw:    fs.Bool("out-raw", false, "also write the raw JSON-RPC responses to the output file"),
+	}
+}
+
+// Function to get the format of the output file, checking it is supported
+func (o *outputFlags) outputFormat() (string, error) {
+	format := *o.format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*o.path)), ".")
+	}
+	switch format {
+	case "json", "jsonl", "csv", "sqlite":
+		return format, nil
+	case "db", "sqlite3":
+		return "sqlite", nil
+	case "":
+		return "", fmt.Errorf("cannot tell the format of %s, use --out-format json, jsonl, csv or sqlite", *o.path)
+	}
+	return "", fmt.Errorf("invalid output format '%s' (expected json, jsonl, csv or sqlite)", format)
+}
+
+// Function to create a record of a decoded result, keeping the raw response only when asked
+func (o *outputFlags) record(step string, result pluginResult, raw string) resultRecord {
+	record := resultRecord{Step: step, pluginResult: result}
+	if !deterministic {
+		record.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	if *o.raw {
+		record.Raw = raw
+	}
+	return record
+}
+
+// Function to write records to the output file, if one was given. The file is replaced
+// atomically, so readers and interrupted runs never see it half written; in append mode
+// the previous records are kept.
+func (o *outputFlags) write(records []resultRecord) error {
+	if *o.path == "" {
+		return nil
+	}
+	format, err := o.outputFormat()
+	if err != nil {
+		return err
+	}
+	if format == "sqlite" {
+		// The database is written in place, SQLite keeping it consistent
+		if err := writeSQLiteResults(*o.path, records, *o.append); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s to %s\n", pluralize(len(records), "result"), *o.path)
+		return nil
+	}
+	var existing []byte
+	if *o.append {
+		if existing, err = ioutil.ReadFile(*o.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %v", *o.path, err)
+		}
+	}
+
+	var data []byte
+	switch format {
+	case "json":
+		var all []json.RawMessage
+		if len(bytes.TrimSpace(existing)) > 0 {
+			if err := json.Unmarshal(existing, &all); err != nil {
+				return fmt.Errorf("cannot append to %s, it is not a JSON array: %v", *o.path, err)
+			}
+		}
+		for _, record := range records {
+			encoded, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode results: %v", err)
+			}
+			all = append(all, encoded)
+		}
+		if data, err = json.MarshalIndent(all, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode results: %v", err)
+		}
+		data = append(data, '\n')
+	case "jsonl":
+		data = existing
+		for _, record := range records {
+			encoded, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode results: %v", err)
+			}
+			data = append(append(data, encoded...), '\n')
+		}
+	case "csv":
+		var b bytes.Buffer
+		b.Write(existing)
+		w := csv.NewWriter(&b)
+		if len(existing) == 0 {
+			w.Write(csvColumns)
+		}
+		for _, record := range records {
+			for _, value := range record.Values {
+				gas := ""
+				if record.Gas > 0 {
+					gas = strconv.FormatUint(record.Gas, 10)
+				}
+				w.Write([]string{record.Time, record.Step, record.Contract, record.Function, record.Block,
+					value.Name, value.Type, csvValue(value.Value), value.Display, record.Raw, gas})
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("failed to encode results: %v", err)
+		}
+		data = b.Bytes()
+	}
+	if err := writeFileAtomic(*o.path, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s to %s\n", pluralize(len(records), "result"), *o.path)
+	return nil
+}
+
+// Function to name an endpoint in output, or hide it in deterministic mode
+func displayEndpoint(rpcURL string) string {
+	if deterministic {
+		return "<endpoint>"
+	}
+	return rpcURL
+}
+
+// Function to render a value in a CSV cell: scalars as is, arrays and tuples as JSON
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return fmt.Sprint(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// Function to replace a file atomically, writing a temporary file in the same directory
+// and renaming it over the target
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	// Temporary files are private, the results are not
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Parquet files are written without a library: uncompressed, PLAIN encoded, with required
+// columns only, which pandas, DuckDB and Spark all read. The metadata is Thrift in its
+// compact protocol, per parquet-format's parquet.thrift.
+
+// Physical types of Parquet columns
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+)
+
+// Converted types annotating physical types, and the absence of one
+const (
+	parquetNoConversion = -1
+	parquetUTF8         = 0
+	parquetUint64       = 14
+)
+
+// Rows buffered before they are written as a row group
+const parquetRowGroupRows = 50000
+
+// parquetColumn is a required column of a Parquet file
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32
+}
+
+// parquetWriter writes rows to a Parquet file in row groups, the footer describing them
+// being written on close
+type parquetWriter struct {
+	file    *os.File
+	offset  int64
+	columns []parquetColumn
+	// Buffered rows, their values int64, bool or string by physical type
+	rows [][]interface{}
+	// Encoded RowGroup structs of the row groups written so far
+	rowGroups [][]byte
+	numRows   int64
+}
+
+// Function to create a Parquet file with the given columns
+func createParquet(path string, columns []parquetColumn) (*parquetWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if _, err := file.WriteString("PAR1"); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return &parquetWriter{file: file, offset: 4, columns: columns}, nil
+}
+
+// Function to add a row, writing a row group once enough rows are buffered
+func (w *parquetWriter) add(row []interface{}) error {
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= parquetRowGroupRows {
+		return w.flush()
+	}
+	return nil
+}
+
+// Function to write the buffered rows as a row group with a single data page per column
+func (w *parquetWriter) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	group := &thriftWriter{}
+	group.listHeader(1, len(w.columns), thriftStruct)
+	var groupSize int64
+	for i, column := range w.columns {
+		var values bytes.Buffer
+		switch column.physical {
+		case parquetBoolean:
+			// Booleans are bit-packed, least significant bit first
+			packed := make([]byte, (len(w.rows)+7)/8)
+			for r, row := range w.rows {
+				if row[i].(bool) {
+					packed[r/8] |= 1 << (r % 8)
+				}
+			}
+			values.Write(packed)
+		case parquetInt64:
+			for _, row := range w.rows {
+				binary.Write(&values, binary.LittleEndian, row[i].(int64))
+			}
+		case parquetByteArray:
+			for _, row := range w.rows {
+				s := row[i].(string)
+				binary.Write(&values, binary.LittleEndian, uint32(len(s)))
+				values.WriteString(s)
+			}
+		}
+
+		header := &thriftWriter{}
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(values.Len()))
+		header.i32(3, int32(values.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(w.rows)))
+		header.i32(2, 0) // PLAIN
+		header.i32(3, 3) // RLE, unused by required columns
+		header.i32(4, 3)
+		header.endStruct()
+		header.stop()
+
+		pageOffset := w.offset
+		if _, err := w.file.Write(header.buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write %s: %v", w.file.Name(), err)
+		}
+		if _, err := w.file.Write(values.Bytes()); err != nil {
+			return fmt.Errorf("failed to write %s: %v", w.file.Name(), err)
+		}
+		size := int64(header.buf.Len() + values.Len())
+		w.offset += size
+		groupSize += size
+
+		// ColumnChunk with its ColumnMetaData
+		group.beginListStruct()
+		group.i64(2, pageOffset)
+		group.beginStruct(3)
+		group.i32(1, column.physical)
+		group.listHeader(2, 1, thriftI32)
+		group.varint(zigzag(0)) // PLAIN
+		group.listHeader(3, 1, thriftBinary)
+		group.rawString(column.name)
+		group.i32(4, 0) // UNCOMPRESSED
+		group.i64(5, int64(len(w.rows)))
+		group.i64(6, size)
+		group.i64(7, size)
+		group.i64(9, pageOffset)
+		group.endStruct()
+		group.endStruct()
+	}
+	group.i64(2, groupSize)
+	group.i64(3, int64(len(w.rows)))
+	group.stop()
+
+	w.rowGroups = append(w.rowGroups, group.buf.Bytes())
+	w.numRows += int64(len(w.rows))
+	w.rows = nil
+	return nil
+}
+
+// Function to write the remaining rows and the footer, and close the file
+func (w *parquetWriter) Close() error {
+	defer w.file.Close()
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	meta := &thriftWriter{}
+	meta.i32(1, 1)
+	meta.listHeader(2, len(w.columns)+1, thriftStruct)
+	meta.beginListStruct()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(w.columns)))
+	meta.endStruct()
+	for _, column := range w.columns {
+		meta.beginListStruct()
+		meta.i32(1, column.physical)
+		meta.i32(3, 0) // REQUIRED
+		meta.binary(4, column.name)
+		if column.converted != parquetNoConversion {
+			meta.i32(6, column.converted)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, w.numRows)
+	meta.listHeader(4, len(w.rowGroups), thriftStruct)
+	for _, group := range w.rowGroups {
+		meta.buf.Write(group)
+	}
+	meta.binary(6, "contract-curler "+toolVersion())
+	meta.stop()
+
+	footer := meta.buf.Bytes()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	footer = append(footer, "PAR1"...)
+	if _, err := w.file.Write(footer); err != nil {
+		return fmt.Errorf("failed to write %s: %v", w.file.Name(), err)
+	}
+	return w.file.Close()
+}
+
+// Type ids of the Thrift compact protocol
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, where field headers hold
+// the difference to the previous field id of the same struct
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	// Last field ids of the enclosing structs
+	stack []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// Function to map signed integers to unsigned ones, small magnitudes to small numbers
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawString(s)
+}
+
+// Function to write a string without a field header, as list elements are
+func (t *thriftWriter) rawString(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) listHeader(id int16, size int, elemType byte) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginListStruct()
+}
+
+// Function to start a struct without a field header, as list elements are
+func (t *thriftWriter) beginListStruct() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
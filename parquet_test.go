@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// thriftReader decodes the Thrift compact protocol into maps of field ids to values, so
+// the files written can be checked against parquet.thrift independently of the writer
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		r.pos++
+		return int64(int8(r.data[r.pos-1]))
+	case 4, 5, 6:
+		v := r.varint()
+		return int64(v>>1) ^ -int64(v&1)
+	case 8:
+		n := int(r.varint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case 9, 10:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.structValue()
+	}
+	panic("unsupported Thrift type")
+}
+
+func (r *thriftReader) structValue() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var lastID int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		id := lastID + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.value(5).(int64))
+		}
+		fields[id] = r.value(header & 0x0f)
+		lastID = id
+	}
+}
+
+// Function to read back the rows of a file written by parquetWriter, checking its layout
+// against the Parquet format on the way
+func readParquet(t *testing.T, data []byte, columns []parquetColumn) ([][]interface{}, []int64) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerLength : len(data)-8]}
+	meta := footer.structValue()
+	if footer.pos != footerLength {
+		t.Fatalf("footer is %d bytes, decoded %d", footerLength, footer.pos)
+	}
+	if meta[1] != int64(1) {
+		t.Errorf("version %v", meta[1])
+	}
+
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if root[4] != "schema" || root[5] != int64(len(columns)) {
+		t.Errorf("root schema element %v", root)
+	}
+	for i, column := range columns {
+		element := schema[i+1].(map[int16]interface{})
+		want := map[int16]interface{}{1: int64(column.physical), 3: int64(0), 4: column.name}
+		if column.converted != parquetNoConversion {
+			want[6] = int64(column.converted)
+		}
+		if !reflect.DeepEqual(element, want) {
+			t.Errorf("schema element %d = %v, want %v", i, element, want)
+		}
+	}
+
+	var rows [][]interface{}
+	var groupRows []int64
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		numRows := group[3].(int64)
+		groupRows = append(groupRows, numRows)
+		groupValues := make([][]interface{}, numRows)
+		var groupSize int64
+		for i, c := range group[1].([]interface{}) {
+			chunk := c.(map[int16]interface{})
+			columnMeta := chunk[3].(map[int16]interface{})
+			offset := columnMeta[9].(int64)
+			if chunk[2] != offset || columnMeta[1] != int64(columns[i].physical) || columnMeta[4] != int64(0) || columnMeta[5] != numRows ||
+				!reflect.DeepEqual(columnMeta[2], []interface{}{int64(0)}) || !reflect.DeepEqual(columnMeta[3], []interface{}{columns[i].name}) {
+				t.Fatalf("column chunk %v", chunk)
+			}
+
+			page := &thriftReader{data: data[offset:]}
+			header := page.structValue()
+			dataPage := header[5].(map[int16]interface{})
+			size := header[3].(int64)
+			if header[1] != int64(0) || header[2] != size || dataPage[1] != numRows || dataPage[2] != int64(0) {
+				t.Fatalf("page header %v", header)
+			}
+			if columnMeta[6] != int64(page.pos)+size || columnMeta[7] != columnMeta[6] {
+				t.Errorf("column chunk sizes %v and %v, page is %d bytes", columnMeta[6], columnMeta[7], int64(page.pos)+size)
+			}
+			groupSize += columnMeta[6].(int64)
+
+			values := data[offset+int64(page.pos) : offset+int64(page.pos)+size]
+			for r := range groupValues {
+				switch columns[i].physical {
+				case parquetBoolean:
+					groupValues[r] = append(groupValues[r], values[r/8]&(1<<(r%8)) != 0)
+				case parquetInt64:
+					groupValues[r] = append(groupValues[r], int64(binary.LittleEndian.Uint64(values)))
+					values = values[8:]
+				case parquetByteArray:
+					n := binary.LittleEndian.Uint32(values)
+					groupValues[r] = append(groupValues[r], string(values[4:4+n]))
+					values = values[4+n:]
+				}
+			}
+		}
+		if group[2] != groupSize {
+			t.Errorf("row group size %v, want %d", group[2], groupSize)
+		}
+		rows = append(rows, groupValues...)
+	}
+	if meta[3] != int64(len(rows)) {
+		t.Errorf("file has %v rows, its row groups %d", meta[3], len(rows))
+	}
+	return rows, groupRows
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	defer func(saved string) { version = saved }(version)
+	version = "test"
+
+	columns := []parquetColumn{
+		{"block", parquetInt64, parquetUint64},
+		{"amount", parquetInt64, parquetNoConversion},
+		{"success", parquetBoolean, parquetNoConversion},
+		{"sender", parquetByteArray, parquetUTF8},
+	}
+	var rows [][]interface{}
+	for i := 0; i < 20; i++ {
+		rows = append(rows, []interface{}{int64(18_000_000 + i), int64(i*i - 100), i%3 == 0, string(rune('a'+i)) + "é"})
+	}
+	rows[7][3] = ""
+
+	path := filepath.Join(t.TempDir(), "events.parquet")
+	writer, err := createParquet(path, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		if err := writer.add(row); err != nil {
+			t.Fatal(err)
+		}
+		// Two row groups, the first with a partial byte of booleans
+		if i == 10 {
+			if err := writer.flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, groupRows := readParquet(t, data, columns)
+	if !reflect.DeepEqual(groupRows, []int64{11, 9}) {
+		t.Errorf("row groups of %v rows", groupRows)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("read back %v, want %v", got, rows)
+	}
+
+	golden := filepath.Join("testdata", "events.parquet")
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("file differs from %s, run go test -run TestParquetRoundTrip -update if the change is intended", golden)
+	}
+}
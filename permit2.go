@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Permit2 is deployed at the same address on every chain
+const permit2Address = "0x000000000022D473030F116dDEE9F6B43aC78BA3"
+
+// Environment variable holding the private key used to sign, when no key file is given
+const privateKeyEnv = "CONTRACT_CURLER_PRIVATE_KEY"
+
+// EIP-712 type strings of the Permit2 allowance transfer structures
+const (
+	permitDetailsType = "PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"
+	permitSingleType  = "PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)" + permitDetailsType
+	permitBatchType   = "PermitBatch(PermitDetails[] details,address spender,uint256 sigDeadline)" + permitDetailsType
+	// Permit2's domain has no version field
+	permit2DomainType = "EIP712Domain(string name,uint256 chainId,address verifyingContract)"
+)
+
+// permitDetails is the allowance granted for one token. Field names follow the ABI
+// component names so the struct can be packed as a tuple.
+type permitDetails struct {
+	Token      common.Address
+	Amount     *big.Int
+	Expiration *big.Int
+	Nonce      *big.Int
+}
+
+// permitSingle is a Permit2 allowance for a single token
+type permitSingle struct {
+	Details     permitDetails
+	Spender     common.Address
+	SigDeadline *big.Int
+}
+
+// permitBatch is a Permit2 allowance for several tokens
+type permitBatch struct {
+	Details     []permitDetails
+	Spender     common.Address
+	SigDeadline *big.Int
+}
+
+// ABI components of PermitDetails
+var permitDetailsComponents = []abi.ArgumentMarshaling{
+	{Name: "token", Type: "address"},
+	{Name: "amount", Type: "uint160"},
+	{Name: "expiration", Type: "uint48"},
+	{Name: "nonce", Type: "uint48"},
+}
+
+// Function to build, hash and optionally sign a Permit2 PermitSingle or PermitBatch,
+// printing the typed data, the signature and the calldata of Permit2.permit
+func runPermit2(args []string) error {
+	fs := flag.NewFlagSet("permit2", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id the permit is valid on")
+	tokens := fs.String("token", "", "comma-separated tokens, one per PermitDetails")
+	amounts := fs.String("amount", "", "comma-separated amounts in token units, one per token (expressions like 1000*10^6 allowed)")
+	spender := fs.String("spender", "", "address allowed to spend the tokens")
+	owner := fs.String("owner", "", "owner of the tokens, derived from the key when signing")
+	expiration := fs.String("expiration", "30d", "expiry of the allowance, a duration from now or a Unix time")
+	deadline := fs.String("sig-deadline", "30m", "deadline of the signature, a duration from now or a Unix time")
+	nonces := fs.String("nonce", "", "comma-separated nonces, read from Permit2 for the owner if omitted")
+	keyFile := fs.String("key-file", "", "file holding the hex private key to sign with (default: $"+privateKeyEnv+")")
+	signature := fs.String("signature", "", "signature made elsewhere, to build the calldata without a key")
+	walletConnect := addWalletConnectFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || (fs.Arg(0) != "single" && fs.Arg(0) != "batch") {
+		return fmt.Errorf("usage: contract-curler permit2 [flags] single|batch")
+	}
+	batch := fs.Arg(0) == "batch"
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var key *ecdsa.PrivateKey
+	var wallet *wcSession
+	if *walletConnect.enabled {
+		chainID, err := cfg.chainID(*chain, endpoint)
+		if err != nil {
+			return err
+		}
+		if wallet, err = walletConnect.connect(chainID); err != nil {
+			return err
+		}
+		defer wallet.Close()
+	} else if key, err = loadSigningKey(*keyFile, *signature == ""); err != nil {
+		return err
+	}
+	var ownerAddress common.Address
+	switch {
+	case wallet != nil:
+		ownerAddress = wallet.account
+	case key != nil:
+		ownerAddress = crypto.PubkeyToAddress(key.PublicKey)
+	case *owner != "":
+		if ownerAddress, err = cfg.resolveAddress(*owner, *chain, endpoint); err != nil {
+			return err
+		}
+	}
+
+	tokenList, amountList, nonceList := splitLeaf(*tokens), splitLeaf(*amounts), splitLeaf(*nonces)
+	if *tokens == "" || len(amountList) != len(tokenList) {
+		return fmt.Errorf("--token and --amount must list the same number of values")
+	}
+	if !batch && len(tokenList) != 1 {
+		return fmt.Errorf("a single permit takes one token, use batch for several")
+	}
+	if *nonces != "" && len(nonceList) != len(tokenList) {
+		return fmt.Errorf("--nonce must list one nonce per token")
+	}
+	if *spender == "" {
+		return fmt.Errorf("--spender is required")
+	}
+	spenderAddress, err := cfg.resolveAddress(*spender, *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	expiry, err := parseDeadline(*expiration)
+	if err != nil {
+		return fmt.Errorf("invalid --expiration: %v", err)
+	}
+	sigDeadline, err := parseDeadline(*deadline)
+	if err != nil {
+		return fmt.Errorf("invalid --sig-deadline: %v", err)
+	}
+
+	ctx, err := newArgContext(endpoint, *chain, "latest")
+	if err != nil {
+		return err
+	}
+	var details []permitDetails
+	for i := range tokenList {
+		token, err := cfg.resolveAddress(tokenList[i], *chain, endpoint)
+		if err != nil {
+			return err
+		}
+		amount, err := ctx.resolve(amountList[i], "uint160")
+		if err != nil {
+			return err
+		}
+		entry := permitDetails{Token: token, Amount: new(big.Int), Expiration: expiry}
+		if _, ok := entry.Amount.SetString(amount, 10); !ok {
+			return fmt.Errorf("invalid amount '%s'", amountList[i])
+		}
+		if *nonces != "" {
+			entry.Nonce, _ = new(big.Int).SetString(nonceList[i], 10)
+			if entry.Nonce == nil {
+				return fmt.Errorf("invalid nonce '%s'", nonceList[i])
+			}
+		} else {
+			if ownerAddress == (common.Address{}) {
+				return fmt.Errorf("--owner or a signing key is needed to read the nonce, or give --nonce")
+			}
+			if entry.Nonce, err = permit2Nonce(endpoint, ownerAddress, token, spenderAddress); err != nil {
+				return err
+			}
+		}
+		details = append(details, entry)
+	}
+
+	chainID, err := cfg.chainID(*chain, endpoint)
+	if err != nil {
+		return err
+	}
+	digest := permit2Digest(chainID, details, spenderAddress, sigDeadline, batch)
+	typedData := permit2TypedData(chainID, details, spenderAddress, sigDeadline, batch)
+	fmt.Println("Typed data (eth_signTypedData_v4):")
+	fmt.Println(typedData)
+	fmt.Println("Digest:", digest.Hex())
+
+	sig := *signature
+	if wallet != nil {
+		if err := wallet.request("eth_signTypedData_v4", []interface{}{ownerAddress.Hex(), typedData}, &sig); err != nil {
+			return err
+		}
+		fmt.Println("Signer:", ownerAddress.Hex())
+		fmt.Println("Signature:", sig)
+		// Smart contract wallets sign for EIP-1271, which recovering cannot check
+		raw, err := decodeSignature(sig)
+		if err == nil {
+			var signer common.Address
+			if signer, err = recoverSigner(digest, raw); err == nil && signer != ownerAddress {
+				err = fmt.Errorf("recovered %s", signer.Hex())
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: the signature does not recover to %s, only a smart contract wallet accepts it\n", colorize(colorYellow, "Warning"), ownerAddress.Hex())
+		}
+	} else if key != nil {
+		signed, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			return fmt.Errorf("failed to sign permit: %v", err)
+		}
+		signed[64] += 27
+		sig = hexutil.Encode(signed)
+		fmt.Println("Signer:", ownerAddress.Hex())
+		fmt.Println("Signature:", sig)
+	}
+	if sig == "" {
+		fmt.Println("\nSign the typed data, then run again with --signature and --owner to get the calldata")
+		return nil
+	}
+	if ownerAddress == (common.Address{}) {
+		return fmt.Errorf("--owner is required to build the calldata")
+	}
+
+	calldata, err := permit2Calldata(ownerAddress, details, spenderAddress, sigDeadline, sig, batch)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nCalldata of permit on %s:\n%s\n", permit2Address, calldata)
+	return nil
+}
+
+// Function to load the signing key from a file or the environment. A missing key is only
+// an error when it is required.
+func loadSigningKey(path string, required bool) (*ecdsa.PrivateKey, error) {
+	hexKey := os.Getenv(privateKeyEnv)
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %v", err)
+		}
+		hexKey = string(data)
+	}
+	hexKey = strings.TrimPrefix(strings.TrimSpace(hexKey), "0x")
+	if hexKey != "" {
+		if err := requireWritable("signing"); err != nil {
+			return nil, err
+		}
+	}
+	if hexKey == "" {
+		if required {
+			fmt.Fprintf(os.Stderr, "No signing key given (--key-file or $%s), printing the data to sign\n", privateKeyEnv)
+		}
+		return nil, nil
+	}
+	key, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	return key, nil
+}
+
+// Function to parse a deadline given as a duration from now, like 30m or 7d, or a Unix time
+func parseDeadline(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if n, ok := new(big.Int).SetString(s, 10); ok {
+		return n, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		days, ok := new(big.Int).SetString(strings.TrimSuffix(s, "d"), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid duration '%s'", s)
+		}
+		days.Mul(days, big.NewInt(24))
+		s = days.String() + "h"
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(time.Now().Add(d).Unix()), nil
+}
+
+// Function to read the next nonce of an owner's Permit2 allowance for a token and spender
+func permit2Nonce(rpcURL string, owner, token, spender common.Address) (*big.Int, error) {
+	data, err := encodeMethodCall("allowance(address,address,address)", []string{owner.Hex(), token.Hex(), spender.Hex()})
+	if err != nil {
+		return nil, err
+	}
+	values, err := callForValues(rpcURL, permit2Address, data, "latest", "(uint160,uint48,uint48)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Permit2 nonce: %v", err)
+	}
+	nonce, _ := asBigInt(values[2])
+	return nonce, nil
+}
+
+// Function to compute the EIP-712 digest a Permit2 permit is signed over
+func permit2Digest(chainID uint64, details []permitDetails, spender common.Address, sigDeadline *big.Int, batch bool) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte(permit2DomainType)),
+		crypto.Keccak256([]byte("Permit2")),
+		common.BigToHash(new(big.Int).SetUint64(chainID)).Bytes(),
+		common.HexToAddress(permit2Address).Hash().Bytes(),
+	)
+
+	var detailHashes [][]byte
+	for _, d := range details {
+		detailHashes = append(detailHashes, crypto.Keccak256(
+			crypto.Keccak256([]byte(permitDetailsType)),
+			d.Token.Hash().Bytes(),
+			common.BigToHash(d.Amount).Bytes(),
+			common.BigToHash(d.Expiration).Bytes(),
+			common.BigToHash(d.Nonce).Bytes(),
+		))
+	}
+	typeHash := crypto.Keccak256([]byte(permitSingleType))
+	detailsHash := detailHashes[0]
+	if batch {
+		typeHash = crypto.Keccak256([]byte(permitBatchType))
+		detailsHash = crypto.Keccak256(detailHashes...)
+	}
+	structHash := crypto.Keccak256(typeHash, detailsHash, spender.Hash().Bytes(), common.BigToHash(sigDeadline).Bytes())
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+// Function to render a permit as the typed data wallets sign with eth_signTypedData_v4
+func permit2TypedData(chainID uint64, details []permitDetails, spender common.Address, sigDeadline *big.Int, batch bool) string {
+	field := func(name, typ string) map[string]string { return map[string]string{"name": name, "type": typ} }
+	var messageDetails []map[string]string
+	for _, d := range details {
+		messageDetails = append(messageDetails, map[string]string{
+			"token": d.Token.Hex(), "amount": d.Amount.String(), "expiration": d.Expiration.String(), "nonce": d.Nonce.String(),
+		})
+	}
+	primaryType, detailsType := "PermitSingle", "PermitDetails"
+	var detailsValue interface{} = messageDetails[0]
+	if batch {
+		primaryType, detailsType, detailsValue = "PermitBatch", "PermitDetails[]", messageDetails
+	}
+
+	typedData := map[string]interface{}{
+		"types": map[string]interface{}{
+			"EIP712Domain":  []map[string]string{field("name", "string"), field("chainId", "uint256"), field("verifyingContract", "address")},
+			"PermitDetails": []map[string]string{field("token", "address"), field("amount", "uint160"), field("expiration", "uint48"), field("nonce", "uint48")},
+			primaryType:     []map[string]string{field("details", detailsType), field("spender", "address"), field("sigDeadline", "uint256")},
+		},
+		"primaryType": primaryType,
+		"domain":      map[string]interface{}{"name": "Permit2", "chainId": chainID, "verifyingContract": permit2Address},
+		"message":     map[string]interface{}{"details": detailsValue, "spender": spender.Hex(), "sigDeadline": sigDeadline.String()},
+	}
+	out, _ := json.MarshalIndent(typedData, "", "  ")
+	return string(out)
+}
+
+// Function to encode the call of Permit2.permit for a single or batch permit
+func permit2Calldata(owner common.Address, details []permitDetails, spender common.Address, sigDeadline *big.Int, signature string, batch bool) (string, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature: %v", err)
+	}
+	detailsType := "tuple"
+	if batch {
+		detailsType = "tuple[]"
+	}
+	permitType, err := abi.NewType("tuple", "", []abi.ArgumentMarshaling{
+		{Name: "details", Type: detailsType, Components: permitDetailsComponents},
+		{Name: "spender", Type: "address"},
+		{Name: "sigDeadline", Type: "uint256"},
+	})
+	if err != nil {
+		return "", err
+	}
+	addressType, _ := abi.NewType("address", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	arguments := abi.Arguments{{Type: addressType}, {Type: permitType}, {Type: bytesType}}
+
+	var permit interface{} = permitSingle{Details: details[0], Spender: spender, SigDeadline: sigDeadline}
+	if batch {
+		permit = permitBatch{Details: details, Spender: spender, SigDeadline: sigDeadline}
+	}
+	packed, err := arguments.Pack(owner, permit, sig)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode permit: %v", err)
+	}
+	methodSig := "permit(address,((address,uint160,uint48,uint48),address,uint256),bytes)"
+	if batch {
+		methodSig = "permit(address,((address,uint160,uint48,uint48)[],address,uint256),bytes)"
+	}
+	return "0x" + functionSelector(methodSig) + hexutil.Encode(packed)[2:], nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.starlark.net/starlark"
+)
+
+// pipelineFile describes a sequence of calls where later calls use the results of earlier ones
+type pipelineFile struct {
+	RpcURL string `json:"rpc"`
+	// Chain whose endpoint profile and contract deployments are used
+	Chain string `json:"chain"`
+	Block string `json:"block"`
+	// ABI files used to find return types of steps that do not declare them
+	ABIs  []string       `json:"abi"`
+	Steps []pipelineStep `json:"steps"`
+	// Starlark script post-processing the results of all steps
+	Script string `json:"script"`
+	// Starlark expressions over the results checked by the invariant command
+	Invariants []string `json:"invariants"`
+}
+
+// pipelineStep is a single eth_call of a pipeline. To and Args may reference earlier
+// results as {{step.output}}, where output is a return value name or index.
+type pipelineStep struct {
+	Name    string   `json:"name"`
+	To      string   `json:"to"`
+	Sig     string   `json:"sig"`
+	Args    []string `json:"args"`
+	Returns string   `json:"returns"`
+}
+
+// Placeholder referencing an earlier result, e.g. {{pool.0}} or {{pool.token0}}
+var templatePattern = regexp.MustCompile(`\{\{\s*([\w-]+)(?:\.(\w+))?\s*\}\}`)
+
+// Function to run the calls of a pipeline file in order, passing results between them
+func runPipeline(args []string) error {
+	fs := flag.NewFlagSet("pipeline", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL, overriding the pipeline file")
+	chain := fs.String("chain", "", "chain name or id, overriding the pipeline file")
+	block := fs.String("block", "", "block to run all calls at, overriding the pipeline file")
+	script := fs.String("script", "", "Starlark script post-processing the results, overriding the pipeline file")
+	scale := fs.String("scale", "", "render integers as fixed-point: a scale for all (ray, wad, e8, decimals or 1eN) or name=scale pairs, e.g. liquidityRate=ray")
+	outFile := addOutputFlags(fs)
+	manifestPath := fs.String("manifest", "", "file every executed request is appended to, with its endpoint, block and response hash")
+	fs.BoolVar(&deterministic, "deterministic", false, "leave timestamps and endpoint names out of the output files")
+	noColor := fs.Bool("no-color", false, "never color the output, even on a terminal")
+	fs.Parse(args)
+	if *noColor {
+		colorEnabled = false
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler pipeline [flags] <file>")
+	}
+	if *outFile.path != "" {
+		if _, err := outFile.outputFormat(); err != nil {
+			return err
+		}
+	}
+	if *manifestPath != "" {
+		enableManifest(*manifestPath)
+	}
+
+	pipeline, err := loadPipeline(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	pipeline.Chain = firstNonEmpty(*chain, pipeline.Chain)
+	if *chain != "" && *rpcURL == "" {
+		// A chain given on the command line also selects its endpoint
+		pipeline.RpcURL = ""
+	}
+	pipeline.RpcURL = firstNonEmpty(*rpcURL, pipeline.RpcURL, chainRPC(pipeline.Chain))
+	pipeline.Block = firstNonEmpty(*block, pipeline.Block, "latest")
+	pipeline.Script = firstNonEmpty(*script, pipeline.Script)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, pipeline.RpcURL)
+	if opts.Scales, err = parseScales(*scale, cfg); err != nil {
+		return err
+	}
+	decoder, err := newCallDecoder(pipeline.ABIs)
+	if err != nil {
+		return err
+	}
+
+	// Pin a moving block tag so every step sees the same state
+	blockParam, err := pinBlock(pipeline.RpcURL, pipeline.Block)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Running %d steps at block %s\n", len(pipeline.Steps), blockParam)
+
+	// Results of the completed steps are available to later steps by step name
+	ctx, err := newArgContext(pipeline.RpcURL, pipeline.Chain, blockParam)
+	if err != nil {
+		return err
+	}
+	scriptResults := starlark.NewDict(len(pipeline.Steps))
+	var records []resultRecord
+	for i, step := range pipeline.Steps {
+		name := firstNonEmpty(step.Name, strconv.Itoa(i+1))
+		if _, ok := ctx.results[name]; ok {
+			return fmt.Errorf("step %d: duplicate step name '%s'", i+1, name)
+		}
+
+		to, err := ctx.expand(step.To)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", name, err)
+		}
+		target, err := ctx.target(to)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", name, err)
+		}
+		callArgs, err := ctx.resolveArgs(step.Sig, step.Args)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", name, err)
+		}
+
+		encoded, err := encodeMethodCall(step.Sig, callArgs)
+		if err != nil {
+			return fmt.Errorf("step %s: failed to encode call: %v", name, err)
+		}
+
+		returns := step.Returns
+		if returns == "" {
+			if entry, ok := decoder.functions[encoded[:10]]; ok {
+				returns = entry.returnTypes()
+			}
+		}
+		if returns == "" {
+			return fmt.Errorf("step %s: no return types given and %s is not in the pipeline ABIs", name, step.Sig)
+		}
+		var output string
+		call := map[string]interface{}{"to": target.Hex(), "data": encoded}
+		if err := callRPC(pipeline.RpcURL, &output, "eth_call", call, blockParam); err != nil {
+			return fmt.Errorf("step %s: eth_call failed: %v", name, err)
+		}
+		values, err := decodeReturnValues(output, returns)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", name, err)
+		}
+
+		fmt.Printf("\n[%s] %s on %s\n", name, step.Sig, formatAddress(target, opts))
+		if len(callArgs) > 0 {
+			fmt.Printf("  with (%s)\n", strings.Join(callArgs, ", "))
+		}
+		returnTypes := splitTypeList(trimTypeList(returns))
+		stepOpts := opts
+		stepOpts.Names = nil
+		results := make(map[string]string)
+		for j, returnType := range returnTypes {
+			_, outputName := splitTypeName(returnType)
+			stepOpts.Names = append(stepOpts.Names, outputName)
+			if j >= len(values) {
+				continue
+			}
+			if raw, ok := templateValue(values[j]); ok {
+				results[strconv.Itoa(j)] = raw
+				if outputName != "" {
+					results[outputName] = raw
+				}
+			}
+		}
+		ctx.results[name] = results
+		scriptResults.SetKey(starlark.String(name), scriptOutputs(values, stepOpts.Names))
+		formatted := formatReturnValues(values, returnTypes, stepOpts)
+		printReturnValues(formatted, returnTypes, "  ")
+
+		result := newPluginResult(target.Hex(), step.Sig, blockParam, values, returnTypes, formatted, stepOpts.Names)
+		records = append(records, outFile.record(name, result, output))
+	}
+	if err := outFile.write(records); err != nil {
+		return err
+	}
+
+	if pipeline.Script != "" {
+		fmt.Println()
+		return runScript(pipeline.Script, scriptResults, opts)
+	}
+	return nil
+}
+
+// Function to read a pipeline or batch file
+func loadPipeline(path string) (*pipelineFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline: %v", err)
+	}
+	var pipeline pipelineFile
+	if err := json.Unmarshal(data, &pipeline); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline: %v", err)
+	}
+	return &pipeline, nil
+}
+
+// Function to replace the {{step.output}} placeholders of a value by earlier results
+func expandTemplate(s string, results map[string]map[string]string) (string, error) {
+	var expandErr error
+	expanded := templatePattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := templatePattern.FindStringSubmatch(match)
+		outputs, ok := results[parts[1]]
+		if !ok {
+			expandErr = fmt.Errorf("unknown step '%s' in %s", parts[1], match)
+			return match
+		}
+		// A bare step name refers to its only output
+		output := parts[2]
+		if output == "" {
+			if _, multiple := outputs["1"]; multiple {
+				expandErr = fmt.Errorf("%s is ambiguous, name the output as {{%s.<name or index>}}", match, parts[1])
+				return match
+			}
+			output = "0"
+		}
+		value, ok := outputs[output]
+		if !ok {
+			expandErr = fmt.Errorf("step '%s' has no scalar output '%s'", parts[1], output)
+			return match
+		}
+		return value
+	})
+	return expanded, expandErr
+}
+
+// Function to render a decoded value in the form accepted as a call argument.
+// Arrays and tuples cannot be passed on and are reported as false.
+func templateValue(val interface{}) (string, bool) {
+	if n, ok := asBigInt(val); ok {
+		return n.String(), true
+	}
+	switch v := val.(type) {
+	case common.Address:
+		return v.Hex(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return v, true
+	}
+	if b, ok := asBytes(val); ok {
+		return hexutil.Encode(b), true
+	}
+	return "", false
+}
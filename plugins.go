@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Plugins are executables named contract-curler-<name>, found in the plugins directory
+// or on the PATH. Running "contract-curler <name> args..." executes the plugin with the
+// same arguments, and "--formatter <name>" pipes decoded results to it as JSON.
+const pluginPrefix = "contract-curler-"
+
+// pluginResult is the JSON document a formatter plugin reads on stdin
+type pluginResult struct {
+	Contract string        `json:"contract"`
+	Function string        `json:"function"`
+	Block    string        `json:"block"`
+	Values   []pluginValue `json:"values"`
+}
+
+// pluginValue is a decoded value with its ABI type and name
+type pluginValue struct {
+	Type  string      `json:"type"`
+	Name  string      `json:"name,omitempty"`
+	Value interface{} `json:"value"`
+	// Value as the built-in formatter would display it
+	Display string `json:"display"`
+}
+
+// Function to get the directory searched for plugins before the PATH
+func pluginDir() string {
+	return filepath.Join(configDir(), "plugins")
+}
+
+// Function to find the executable of a plugin
+func findPlugin(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	local := filepath.Join(pluginDir(), pluginPrefix+name)
+	if info, err := os.Stat(local); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+		return local, true
+	}
+	if path, err := exec.LookPath(pluginPrefix + name); err == nil {
+		return path, true
+	}
+	return "", false
+}
+
+// Function to run a command plugin with the terminal attached
+func runPlugin(path string, args []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "CONTRACT_CURLER_HOME="+configDir())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s failed: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Function to list the available plugins
+func runPlugins(args []string) error {
+	found := make(map[string]string)
+	dirs := append([]string{pluginDir()}, filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Name(), pluginPrefix)
+			if name == entry.Name() || entry.IsDir() || entry.Mode()&0111 == 0 {
+				continue
+			}
+			// The plugins directory and earlier PATH entries take precedence
+			if _, ok := found[name]; !ok {
+				found[name] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		fmt.Printf("No plugins found in %s or on the PATH\n", pluginDir())
+		return nil
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-20s %s\n", name, found[name])
+	}
+	return nil
+}
+
+// Function to format decoded results with a formatter plugin, returning what it prints
+func formatWithPlugin(name string, result pluginResult) (string, error) {
+	path, ok := findPlugin(name)
+	if !ok {
+		return "", fmt.Errorf("formatter plugin '%s' not found (expected %s%s in %s or on the PATH)", name, pluginPrefix, name, pluginDir())
+	}
+	input, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode results for plugin: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(path, "format")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "CONTRACT_CURLER_HOME="+configDir())
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("formatter plugin %s failed: %v", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// Function to collect decoded values with their types, names and display forms, as passed
+// to formatter plugins and written to output files
+func newPluginResult(contract, function, block string, values []interface{}, returnTypes, formatted, names []string) pluginResult {
+	result := pluginResult{Contract: contract, Function: function, Block: block}
+	for i, val := range values {
+		typStr, _ := splitTypeName(returnTypes[i])
+		display := strings.TrimSpace(strings.TrimPrefix(formatted[i], strings.TrimSpace(returnTypes[i])+":"))
+		result.Values = append(result.Values, pluginValue{Type: typStr, Name: names[i], Value: jsonValue(val), Display: display})
+	}
+	return result
+}
+
+// Function to convert a decoded value into plain JSON: integers as decimal strings,
+// addresses and bytes as hex, arrays as lists and tuples as objects
+func jsonValue(val interface{}) interface{} {
+	if n, ok := asBigInt(val); ok {
+		return n.String()
+	}
+	switch v := val.(type) {
+	case common.Address:
+		return v.Hex()
+	case bool, string:
+		return v
+	}
+	if b, ok := asBytes(val); ok {
+		return hexutil.Encode(b)
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Struct:
+		object := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			name := []rune(rv.Type().Field(i).Name)
+			name[0] = unicode.ToLower(name[0])
+			object[string(name)] = jsonValue(rv.Field(i).Interface())
+		}
+		return object
+	}
+	return fmt.Sprint(val)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Set by the global --override-policy flag, given before the command
+var policyOverride bool
+
+// policy limits the transactions that may be signed, e.g. when the tool is handed to
+// operators who should only run a known set of operations. Empty lists allow anything.
+type policy struct {
+	// Largest value a transaction may send, in wei or like 0.5eth
+	MaxValue string `json:"maxValue"`
+	// Chains by name or id, targets by address, alias or label, and functions by selector or
+	// signature. Plain transfers without calldata are only limited by the value and target.
+	AllowedChains    []string `json:"allowedChains"`
+	AllowedTargets   []string `json:"allowedTargets"`
+	AllowedSelectors []string `json:"allowedSelectors"`
+	// Whether --override-policy lets a transaction violating the policy through, rather
+	// than it always being blocked
+	Overridable bool `json:"overridable"`
+}
+
+// Function to read a policy file
+func loadPolicy(path string) (*policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %v", err)
+	}
+	p := &policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy '%s': %v", path, err)
+	}
+	return p, nil
+}
+
+// Function to list what a transaction breaks of a policy
+func (p *policy) violations(cfg *Config, chainID uint64, to *common.Address, value *big.Int, data []byte) ([]string, error) {
+	var violations []string
+	if p.MaxValue != "" {
+		maxValue, err := parseValue(p.MaxValue)
+		if err != nil {
+			return nil, fmt.Errorf("maxValue: %v", err)
+		}
+		if value != nil && value.Cmp(maxValue) > 0 {
+			violations = append(violations, fmt.Sprintf("value %s ETH is above the maximum of %s ETH", formatFixed(value, 18), formatFixed(maxValue, 18)))
+		}
+	}
+
+	if len(p.AllowedChains) > 0 {
+		allowed := false
+		for _, chain := range p.AllowedChains {
+			id, err := parseChain(chain)
+			if err != nil {
+				return nil, fmt.Errorf("allowedChains: %v", err)
+			}
+			allowed = allowed || id == chainID
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("chain %s is not allowed", chainName(chainID)))
+		}
+	}
+
+	if len(p.AllowedTargets) > 0 {
+		allowed := false
+		for _, target := range p.AllowedTargets {
+			address, err := cfg.resolveAddress(target, strconv.FormatUint(chainID, 10), "")
+			if err != nil {
+				return nil, fmt.Errorf("allowedTargets: %v", err)
+			}
+			allowed = allowed || (to != nil && address == *to)
+		}
+		switch {
+		case to == nil:
+			violations = append(violations, "contract deployments are not allowed")
+		case !allowed:
+			violations = append(violations, fmt.Sprintf("target %s is not allowed", to.Hex()))
+		}
+	}
+
+	if len(p.AllowedSelectors) > 0 && len(data) > 0 {
+		selector := "0x" + hex.EncodeToString(data[:min(len(data), 4)])
+		allowed := false
+		for _, entry := range p.AllowedSelectors {
+			allowedSelector := strings.ToLower(entry)
+			if !strings.HasPrefix(allowedSelector, "0x") {
+				signature, err := canonicalSignature(entry)
+				if err != nil {
+					return nil, fmt.Errorf("allowedSelectors: %v", err)
+				}
+				allowedSelector = "0x" + functionSelector(signature)
+			}
+			allowed = allowed || allowedSelector == selector
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("function %s is not allowed", selector))
+		}
+	}
+	return violations, nil
+}
+
+// Function to check a transaction against the configured policies before it is signed.
+// Violations block it, unless every policy broken is overridable and --override-policy
+// was given, in which case they are only reported.
+func enforcePolicies(chainID uint64, to *common.Address, value *big.Int, data []byte) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var violations []string
+	overridable := true
+	for _, path := range cfg.Policies {
+		p, err := loadPolicy(path)
+		if err != nil {
+			return err
+		}
+		broken, err := p.violations(cfg, chainID, to, value, data)
+		if err != nil {
+			return fmt.Errorf("invalid policy '%s': %v", path, err)
+		}
+		for _, violation := range broken {
+			violations = append(violations, fmt.Sprintf("%s (%s)", violation, path))
+		}
+		overridable = overridable && (len(broken) == 0 || p.Overridable)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if overridable && policyOverride {
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "%s: policy overridden: %s\n", colorize(colorYellow, "Warning"), violation)
+		}
+		return nil
+	}
+	message := "the transaction violates the policy: " + strings.Join(violations, "; ")
+	if overridable {
+		message += " (use --override-policy to sign it anyway)"
+	}
+	return fmt.Errorf("%s", message)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// QR codes are encoded in byte mode with error correction level M, enough for the URIs
+// shown to phones, up to version 15 (412 bytes).
+
+// qrBlocks is the error correction layout of a QR version at level M: the error correction
+// codewords of each block, and the number and data codewords of the blocks of each group
+type qrBlocks struct {
+	ecPerBlock        int
+	groups, groupData [2]int
+}
+
+// Layouts of versions 1 to 15 at level M, per ISO/IEC 18004 table 9
+var qrLayoutsM = []qrBlocks{
+	{10, [2]int{1, 0}, [2]int{16, 0}},
+	{16, [2]int{1, 0}, [2]int{28, 0}},
+	{26, [2]int{1, 0}, [2]int{44, 0}},
+	{18, [2]int{2, 0}, [2]int{32, 0}},
+	{24, [2]int{2, 0}, [2]int{43, 0}},
+	{16, [2]int{4, 0}, [2]int{27, 0}},
+	{18, [2]int{4, 0}, [2]int{31, 0}},
+	{22, [2]int{2, 2}, [2]int{38, 39}},
+	{22, [2]int{3, 2}, [2]int{36, 37}},
+	{26, [2]int{4, 1}, [2]int{43, 44}},
+	{30, [2]int{1, 4}, [2]int{50, 51}},
+	{22, [2]int{6, 2}, [2]int{36, 37}},
+	{22, [2]int{8, 1}, [2]int{37, 38}},
+	{24, [2]int{4, 5}, [2]int{40, 41}},
+	{24, [2]int{5, 5}, [2]int{41, 42}},
+}
+
+// qrCode is the module matrix of a QR code, true for dark modules
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Function to encode data as a QR code of the smallest version it fits
+func encodeQR(data []byte) (*qrCode, error) {
+	for version := 1; version <= len(qrLayoutsM); version++ {
+		layout := qrLayoutsM[version-1]
+		capacity := layout.groups[0]*layout.groupData[0] + layout.groups[1]*layout.groupData[1]
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*capacity {
+			continue
+		}
+
+		// Byte mode indicator, character count and data, then the terminator and padding
+		var bits qrBitBuffer
+		bits.append(0x4, 4)
+		bits.append(len(data), countBits)
+		for _, b := range data {
+			bits.append(int(b), 8)
+		}
+		bits.append(0, min(4, 8*capacity-len(bits)))
+		bits.append(0, (8-len(bits)%8)%8)
+		codewords := bits.bytes()
+		for pad := 0; len(codewords) < capacity; pad++ {
+			codewords = append(codewords, []byte{0xec, 0x11}[pad%2])
+		}
+
+		qr := newQRCode(version)
+		qr.drawCodewords(interleaveQR(codewords, layout))
+		qr.applyBestMask()
+		return qr, nil
+	}
+	return nil, fmt.Errorf("%d bytes are too many for a QR code", len(data))
+}
+
+// qrBitBuffer accumulates bits, most significant first
+type qrBitBuffer []bool
+
+func (b *qrBitBuffer) append(value, count int) {
+	for i := count - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+func (b qrBitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// Function to split data codewords into blocks, append their error correction codewords,
+// and interleave the blocks column by column as they are placed in the symbol
+func interleaveQR(data []byte, layout qrBlocks) []byte {
+	var blocks, ecBlocks [][]byte
+	for g := 0; g < 2; g++ {
+		for i := 0; i < layout.groups[g]; i++ {
+			block := data[:layout.groupData[g]]
+			data = data[layout.groupData[g]:]
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, reedSolomon(block, layout.ecPerBlock))
+		}
+	}
+	var out []byte
+	for i := 0; i < max(layout.groupData[0], layout.groupData[1]); i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// Function to multiply in GF(256) with the QR code polynomial x^8+x^4+x^3+x^2+1
+func gfMultiply(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x1d
+		z ^= (y >> i & 1) * x
+	}
+	return z
+}
+
+// Function to compute the Reed-Solomon error correction codewords of a block
+func reedSolomon(data []byte, degree int) []byte {
+	// Generator polynomial (x - α^0)(x - α^1)...(x - α^(degree-1)), leading term omitted
+	generator := make([]byte, degree)
+	generator[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range generator {
+			generator[j] = gfMultiply(generator[j], root)
+			if j+1 < degree {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+
+	remainder := make([]byte, degree)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[degree-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMultiply(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+// Function to create the symbol of a version with its function patterns drawn
+func newQRCode(version int) *qrCode {
+	size := 17 + 4*version
+	qr := &qrCode{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range qr.modules {
+		qr.modules[y] = make([]bool, size)
+		qr.function[y] = make([]bool, size)
+	}
+
+	// Timing patterns
+	for i := 0; i < size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+	// Finder patterns with their separators
+	for _, corner := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x >= 0 && x < size && y >= 0 && y < size {
+					distance := max(abs(dx), abs(dy))
+					qr.setFunction(x, y, distance != 2 && distance != 4)
+				}
+			}
+		}
+	}
+	// Alignment patterns, except where they would overlap the finders
+	positions := qrAlignmentPositions(version)
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if i == 0 && j == 0 || i == 0 && j == len(positions)-1 || i == len(positions)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Format areas are reserved now and written with the mask
+	qr.drawFormat(0)
+	if version >= 7 {
+		remainder := version
+		for i := 0; i < 12; i++ {
+			remainder = remainder<<1 ^ (remainder>>11)*0x1f25
+		}
+		bits := version<<12 | remainder
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			qr.setFunction(a, b, bits>>i&1 == 1)
+			qr.setFunction(b, a, bits>>i&1 == 1)
+		}
+	}
+	return qr
+}
+
+// Function to get the absolute value of an integer
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Function to get the centers of the alignment patterns of a version along either axis
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, 17+4*version-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func (qr *qrCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// Function to draw both copies of the format information of level M and a mask, and the
+// dark module next to the bottom left finder
+func (qr *qrCode) drawFormat(mask int) {
+	data := mask // level M is 00
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = remainder<<1 ^ (remainder>>9)*0x537
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.size-8, true)
+}
+
+// Function to place the codewords in the zigzag order of two-module columns, from the
+// bottom right corner, skipping the function patterns
+func (qr *qrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// The vertical timing pattern shifts the columns left of it
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < qr.size; vert++ {
+			y := vert
+			if upward {
+				y = qr.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if !qr.function[y][x] && i < 8*len(codewords) {
+					qr.modules[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// Function to tell whether a mask pattern inverts the module at x, y
+func qrMaskInverts(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	}
+	return ((x+y)%2+x*y%3)%2 == 0
+}
+
+// Function to invert the data modules selected by a mask; applying it twice undoes it
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if !qr.function[y][x] && qrMaskInverts(mask, x, y) {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// Function to apply the mask whose symbol scores the lowest penalty, as scanners read
+// those best
+func (qr *qrCode) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormat(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(best)
+	qr.drawFormat(best)
+}
+
+// Function to score a symbol by the penalty rules of the standard: runs of a color, 2x2
+// blocks, patterns resembling finders and imbalance between dark and light
+func (qr *qrCode) penalty() int {
+	penalty, dark := 0, 0
+	for _, horizontal := range []bool{true, false} {
+		for a := 0; a < qr.size; a++ {
+			run := 0
+			line := make([]byte, qr.size)
+			for b := 0; b < qr.size; b++ {
+				module := qr.modules[a][b]
+				if !horizontal {
+					module = qr.modules[b][a]
+				}
+				line[b] = '0'
+				if module {
+					line[b] = '1'
+				}
+				if b > 0 && line[b] == line[b-1] {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					penalty += 3
+				} else if run > 5 {
+					penalty++
+				}
+			}
+			// Finder-like patterns with light modules on either side, past the edge included
+			padded := "0000" + string(line) + "0000"
+			penalty += 40 * (strings.Count(padded, "10111010000") + strings.Count(padded, "00001011101"))
+		}
+	}
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := qr.modules[y][x]
+				if qr.modules[y-1][x] == c && qr.modules[y][x-1] == c && qr.modules[y-1][x-1] == c {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := qr.size * qr.size
+	// 10 points for each 5% step away from half dark
+	penalty += 10 * (abs(dark*20-total*10) / total)
+	return penalty
+}
+
+// Function to print a QR code with half block characters, two module rows per line, light
+// modules drawn as blocks for the dark background of most terminals
+func (qr *qrCode) print(w io.Writer) {
+	const quiet = 2
+	light := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		return x < 0 || y < 0 || x >= qr.size || y >= qr.size || !qr.modules[y][x]
+	}
+	total := qr.size + 2*quiet
+	for y := 0; y < total; y += 2 {
+		var line strings.Builder
+		for x := 0; x < total; x++ {
+			top, bottom := light(x, y), y+1 < total && light(x, y+1)
+			switch {
+			case top && bottom:
+				line.WriteString("█")
+			case top:
+				line.WriteString("▀")
+			case bottom:
+				line.WriteString("▄")
+			default:
+				line.WriteString(" ")
+			}
+		}
+		fmt.Fprintln(w, line.String())
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The data codewords of "HELLO WORLD" at 1-M and their error correction codewords, from
+	// the worked example of the QR code tutorial at thonky.com
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomon = %v, want %v", got, want)
+	}
+}
+
+func TestQRFormatAndVersionInformation(t *testing.T) {
+	// Format information of level M with masks 0 to 7, per ISO/IEC 18004 table C.1, most
+	// significant bit first
+	formats := []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	for mask, want := range formats {
+		qr := newQRCode(1)
+		qr.drawFormat(mask)
+		if got := qrFormatBits(qr); got != want {
+			t.Errorf("mask %d: format %s, want %s", mask, got, want)
+		}
+	}
+
+	// Version information of version 7, 0x07c94 per table D.1, in the 6x3 block above the
+	// bottom left finder, least significant bit first
+	qr := newQRCode(7)
+	var bits int
+	for i := 0; i < 18; i++ {
+		if qr.modules[qr.size-11+i%3][i/3] {
+			bits |= 1 << i
+		}
+	}
+	if bits != 0x07c94 {
+		t.Errorf("version information %05x, want 07c94", bits)
+	}
+}
+
+// Function to read the format information around the top left finder, most significant
+// bit first, checking the copy split between the other two finders matches it
+func qrFormatBits(qr *qrCode) string {
+	var first, second []byte
+	bit := func(x, y int) byte {
+		if qr.modules[y][x] {
+			return '1'
+		}
+		return '0'
+	}
+	for x := 0; x <= 8; x++ {
+		if x != 6 {
+			first = append(first, bit(x, 8))
+		}
+	}
+	for y := 7; y >= 0; y-- {
+		if y != 6 {
+			first = append(first, bit(8, y))
+		}
+	}
+	for y := qr.size - 1; y >= qr.size-7; y-- {
+		second = append(second, bit(8, y))
+	}
+	for x := qr.size - 8; x < qr.size; x++ {
+		second = append(second, bit(x, 8))
+	}
+	if !bytes.Equal(first, second) {
+		return "mismatched copies " + string(first) + " and " + string(second)
+	}
+	return string(first)
+}
+
+func TestQRAlignmentPositions(t *testing.T) {
+	// Per ISO/IEC 18004 table E.1
+	tests := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		6:  {6, 34},
+		7:  {6, 22, 38},
+		10: {6, 28, 50},
+		13: {6, 34, 62},
+		14: {6, 26, 46, 66},
+		15: {6, 26, 48, 70},
+	}
+	for version, want := range tests {
+		if got := qrAlignmentPositions(version); !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestEncodeQRVersions(t *testing.T) {
+	// Byte mode capacities at level M, per ISO/IEC 18004 table 7
+	tests := []struct {
+		length, version int
+	}{
+		{1, 1}, {14, 1}, {15, 2}, {26, 2}, {27, 3}, {62, 4}, {63, 5}, {213, 10}, {214, 11}, {412, 15},
+	}
+	for _, test := range tests {
+		qr, err := encodeQR(bytes.Repeat([]byte("a"), test.length))
+		if err != nil {
+			t.Errorf("%d bytes: %v", test.length, err)
+		} else if qr.size != 17+4*test.version {
+			t.Errorf("%d bytes: version %d, want %d", test.length, (qr.size-17)/4, test.version)
+		}
+	}
+	if _, err := encodeQR(bytes.Repeat([]byte("a"), 413)); err == nil {
+		t.Errorf("413 bytes encoded")
+	}
+}
+
+// Function to decode a symbol written by encodeQR: unmask it with the mask of its format
+// information, read the codewords back, check every block against its error correction
+// codewords and return the byte mode data
+func decodeQR(t *testing.T, qr *qrCode) []byte {
+	t.Helper()
+	version := (qr.size - 17) / 4
+	layout := qrLayoutsM[version-1]
+	format := qrFormatBits(qr)
+	mask := -1
+	for m, want := range []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	} {
+		if format == want {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("invalid format information %s", format)
+	}
+
+	// Codewords in the zigzag order of two-module columns, from the bottom right corner
+	var bits []bool
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = qr.size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if !qr.function[y][x] {
+					bits = append(bits, qr.modules[y][x] != qrMaskInverts(mask, x, y))
+				}
+			}
+		}
+	}
+	codewords := qrBitBuffer(bits[:len(bits)/8*8]).bytes()
+
+	// Blocks are interleaved column by column, data first
+	var blocks [][]byte
+	for g := 0; g < 2; g++ {
+		for i := 0; i < layout.groups[g]; i++ {
+			blocks = append(blocks, make([]byte, 0, layout.groupData[g]+layout.ecPerBlock))
+		}
+	}
+	for i := 0; i < max(layout.groupData[0], layout.groupData[1]); i++ {
+		for b := range blocks {
+			if i < cap(blocks[b])-layout.ecPerBlock {
+				blocks[b] = append(blocks[b], codewords[0])
+				codewords = codewords[1:]
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[0])
+			codewords = codewords[1:]
+		}
+	}
+
+	// A block and its error correction codewords form a polynomial with the roots α^0 to
+	// α^(ec-1) of the generator
+	var data []byte
+	for b, block := range blocks {
+		root := byte(1)
+		for i := 0; i < layout.ecPerBlock; i++ {
+			var syndrome byte
+			for _, c := range block {
+				syndrome = gfMultiply(syndrome, root) ^ c
+			}
+			if syndrome != 0 {
+				t.Fatalf("block %d: syndrome %d is %d", b, i, syndrome)
+			}
+			root = gfMultiply(root, 0x02)
+		}
+		data = append(data, block[:len(block)-layout.ecPerBlock]...)
+	}
+
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("mode %x, want byte mode", data[0]>>4)
+	}
+	var length int
+	for i := 4; i < 4+countBits; i++ {
+		length = length<<1 | int(data[i/8]>>(7-i%8)&1)
+	}
+	decoded := make([]byte, length)
+	for j := range decoded {
+		for i := 4 + countBits + 8*j; i < 4+countBits+8*j+8; i++ {
+			decoded[j] = decoded[j]<<1 | data[i/8]>>(7-i%8)&1
+		}
+	}
+	return decoded
+}
+
+func TestEncodeQRRoundTrip(t *testing.T) {
+	messages := []string{
+		"hello",
+		"wc:7f6e504bfad60b485450578e05678ed3e8e8c4751d3c6160be17160d63ec90f9@2?relay-protocol=irn&symKey=587d5484ce2a2a6ee3ba1962fdd7e8588e06200c46823bd18fbd67def96ad303",
+		strings.Repeat("0123456789", 41),
+	}
+	for _, message := range messages {
+		qr, err := encodeQR([]byte(message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Finder patterns in three corners, timing patterns between them
+		for _, corner := range [][2]int{{0, 0}, {qr.size - 7, 0}, {0, qr.size - 7}} {
+			for i := 0; i < 7; i++ {
+				if !qr.modules[corner[1]][corner[0]+i] || !qr.modules[corner[1]+i][corner[0]] || qr.modules[corner[1]+1][corner[0]+1+i%5] {
+					t.Fatalf("%d bytes: no finder pattern at %v", len(message), corner)
+				}
+			}
+		}
+		for i := 8; i < qr.size-8; i++ {
+			if qr.modules[6][i] != (i%2 == 0) || qr.modules[i][6] != (i%2 == 0) {
+				t.Fatalf("%d bytes: broken timing pattern at %d", len(message), i)
+			}
+		}
+		if !qr.modules[qr.size-8][8] {
+			t.Errorf("%d bytes: no dark module", len(message))
+		}
+		if got := decodeQR(t, qr); string(got) != message {
+			t.Errorf("decoded %q, want %q", got, message)
+		}
+	}
+}
+
+func TestPrintQR(t *testing.T) {
+	qr, err := encodeQR([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	qr.print(&out)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	// Two module rows per line, a quiet zone of two modules around the 21x21 symbol
+	if len(lines) != 13 {
+		t.Fatalf("%d lines, want 13", len(lines))
+	}
+	// The quiet zone is light, dark modules are blank: the top two rows of the top left
+	// finder and its light separator
+	if lines[0] != strings.Repeat("█", 25) {
+		t.Errorf("first line %q", lines[0])
+	}
+	if want := "██ ▄▄▄▄▄ █"; !strings.HasPrefix(lines[1], want) {
+		t.Errorf("second line %q, want prefix %q", lines[1], want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket throttling the requests sent to one endpoint
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+var (
+	endpointsOnce sync.Once
+	// Endpoint profiles of the config file keyed by name
+	endpointProfiles map[string]EndpointConfig
+	limitersMu       sync.Mutex
+	// Rate limiters keyed by endpoint URL, nil for unthrottled endpoints
+	limiters = make(map[string]*rateLimiter)
+)
+
+// Function to load the endpoint profiles of the config file once per run
+func loadEndpointProfiles() map[string]EndpointConfig {
+	endpointsOnce.Do(func() {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: endpoint profiles not loaded: %v\n", err)
+			return
+		}
+		endpointProfiles = cfg.Endpoints
+	})
+	return endpointProfiles
+}
+
+// Function to resolve an endpoint profile name to its URL, leaving URLs unchanged
+func resolveEndpoint(rpcURL string) string {
+	if profile, ok := loadEndpointProfiles()[rpcURL]; ok && profile.URL != "" {
+		return profile.URL
+	}
+	return rpcURL
+}
+
+// Function to get the rate limiter of an endpoint URL, or nil if it is not throttled
+func limiterFor(url string) *rateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if limiter, ok := limiters[url]; ok {
+		return limiter
+	}
+
+	var limiter *rateLimiter
+	for _, profile := range loadEndpointProfiles() {
+		if profile.serves(url) && profile.RequestsPerSecond > 0 {
+			burst := float64(profile.Burst)
+			if burst < 1 {
+				burst = 1
+			}
+			limiter = &rateLimiter{rate: profile.RequestsPerSecond, burst: burst, tokens: burst, last: time.Now()}
+			break
+		}
+	}
+	limiters[url] = limiter
+	return limiter
+}
+
+// Function to block until the bucket allows another request. Tokens are reserved before
+// sleeping so concurrent callers queue up instead of all waking at once.
+func (l *rateLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
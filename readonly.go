@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/contract-curler/rpc"
+)
+
+// Set by the global --read-only flag, given before the command
+var readOnlyFlag bool
+
+// Whether the config asks for read-only mode, read once per run
+var (
+	readOnlyConfigOnce sync.Once
+	readOnlyConfig     bool
+)
+
+// JSON-RPC methods signing with an account of the node or broadcasting transactions,
+// refused in read-only mode whatever command sends them
+var writeMethods = map[string]bool{
+	"eth_sendRawTransaction":            true,
+	"eth_sendRawTransactionConditional": true,
+	"eth_sendTransaction":               true,
+	"eth_sendBundle":                    true,
+	"eth_sendPrivateTransaction":        true,
+	"eth_sign":                          true,
+	"eth_signTransaction":               true,
+	"eth_signTypedData":                 true,
+	"eth_signTypedData_v3":              true,
+	"eth_signTypedData_v4":              true,
+	"personal_sign":                     true,
+	"personal_sendTransaction":          true,
+	"personal_signTransaction":          true,
+}
+
+// Function to tell whether signing and broadcasting are disabled, by --read-only or by
+// "readOnly" in the config. A config that cannot be read leaves the decision to the
+// commands, which fail on it anyway.
+func readOnlyMode() bool {
+	readOnlyConfigOnce.Do(func() {
+		if cfg, err := loadConfig(); err == nil {
+			readOnlyConfig = cfg.ReadOnly
+		}
+	})
+	return readOnlyFlag || readOnlyConfig
+}
+
+// Function to refuse an operation that signs or broadcasts in read-only mode
+func requireWritable(operation string) error {
+	if !readOnlyMode() {
+		return nil
+	}
+	return fmt.Errorf("%s is disabled in read-only mode (set by --read-only or \"readOnly\" in the config)", operation)
+}
+
+// Function to refuse the calls signing or broadcasting in read-only mode, so no command
+// can reach a write method of an endpoint, batches included
+func readOnlyMiddleware(next rpc.Transport) rpc.Transport {
+	if !readOnlyMode() {
+		return next
+	}
+	return rpc.TransportFunc(func(call *rpc.Call) (io.ReadCloser, error) {
+		for _, method := range rpc.Methods(call.Payload) {
+			if writeMethods[method] {
+				return nil, requireWritable(method)
+			}
+		}
+		return next.RoundTrip(call)
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Gas of a plain transfer, enough for the self-send cancelling a transaction
+const transferGas = 21000
+
+// pendingTransaction holds the fields of a transaction needed to replace it
+type pendingTransaction struct {
+	BlockNumber          *hexutil.Uint64   `json:"blockNumber"`
+	From                 common.Address    `json:"from"`
+	To                   *common.Address   `json:"to"`
+	Nonce                hexutil.Uint64    `json:"nonce"`
+	Value                *hexutil.Big      `json:"value"`
+	Input                hexutil.Bytes     `json:"input"`
+	Gas                  hexutil.Uint64    `json:"gas"`
+	Type                 hexutil.Uint64    `json:"type"`
+	GasPrice             *hexutil.Big      `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+	AccessList           *types.AccessList `json:"accessList"`
+	ChainID              *hexutil.Big      `json:"chainId"`
+}
+
+// Function to rebroadcast a pending transaction with higher fees
+func runBump(args []string) error {
+	return replaceTransaction("bump", args)
+}
+
+// Function to replace a pending transaction with a zero-value self-send using its nonce
+func runCancel(args []string) error {
+	return replaceTransaction("cancel", args)
+}
+
+// Function to replace a pending transaction, either with the same transaction paying more
+// (bump) or with an empty self-send (cancel). Nodes only accept a replacement raising both
+// fees by 10%, so the old fees are raised by --bump-percent, or to the current suggestion
+// when higher.
+func replaceTransaction(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	keyFile := fs.String("key-file", "", "file holding the hex private key of the sender (default: $"+privateKeyEnv+")")
+	percent := fs.Uint64("bump-percent", 15, "fee increase over the pending transaction, in percent (at least 10)")
+	maxFee := fs.String("max-fee", "", "max fee per gas of the replacement, e.g. 60gwei")
+	priorityFee := fs.String("priority-fee", "", "max priority fee per gas of the replacement, e.g. 3gwei")
+	noWait := fs.Bool("no-wait", false, "return once the replacement is broadcast")
+	yes := fs.Bool("yes", false, "send the replacement without asking for confirmation")
+	wait := addWaitFlags(fs)
+	fs.Parse(args)
+
+	if err := requireWritable(name); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler %s [flags] <tx hash>", name)
+	}
+	if *percent < 10 {
+		return fmt.Errorf("--bump-percent must be at least 10, nodes reject smaller increases")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var pending *pendingTransaction
+	if err := callRPC(endpoint, &pending, "eth_getTransactionByHash", fs.Arg(0)); err != nil {
+		return fmt.Errorf("failed to fetch transaction: %v", err)
+	}
+	if pending == nil {
+		return fmt.Errorf("transaction %s not found", fs.Arg(0))
+	}
+	if pending.BlockNumber != nil {
+		return fmt.Errorf("transaction %s is already mined in block %d", fs.Arg(0), *pending.BlockNumber)
+	}
+	if pending.Type > types.DynamicFeeTxType {
+		return fmt.Errorf("replacing transactions of type %d is not supported", pending.Type)
+	}
+
+	key, err := loadSigningKey(*keyFile, false)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("the key of %s is required (--key-file or $%s)", pending.From.Hex(), privateKeyEnv)
+	}
+	if signer := crypto.PubkeyToAddress(key.PublicKey); signer != pending.From {
+		return fmt.Errorf("the key is for %s but the transaction was sent by %s", signer.Hex(), pending.From.Hex())
+	}
+	chainID, err := cfg.chainID(*chain, endpoint)
+	if err != nil {
+		return err
+	}
+
+	// Fees suggested for a new transaction, kept when above the bumped ones
+	tip, feeCap, err := suggestFees(endpoint, *priorityFee, *maxFee)
+	if err != nil {
+		return err
+	}
+	var oldTip, oldFeeCap *big.Int
+	if pending.Type == types.DynamicFeeTxType {
+		oldTip, oldFeeCap = (*big.Int)(pending.MaxPriorityFeePerGas), (*big.Int)(pending.MaxFeePerGas)
+	} else {
+		oldTip = (*big.Int)(pending.GasPrice)
+	}
+	if feeCap == nil || pending.Type != types.DynamicFeeTxType {
+		// Legacy and EIP-2930 transactions are replaced by ones of their type, so only a gas
+		// price is raised
+		if feeCap != nil {
+			tip = feeCap
+		}
+		if *maxFee == "" {
+			tip = bumpFee(oldTip, tip, *percent)
+		}
+		feeCap = nil
+		if minimum := raise(oldTip, 10); tip.Cmp(minimum) < 0 {
+			return fmt.Errorf("gas price %s gwei is below the %s gwei nodes accept as a replacement", formatFixed(tip, 9), formatFixed(minimum, 9))
+		}
+	} else {
+		if *priorityFee == "" {
+			tip = bumpFee(oldTip, tip, *percent)
+		}
+		if *maxFee == "" {
+			feeCap = bumpFee(oldFeeCap, feeCap, *percent)
+		}
+		if feeCap.Cmp(tip) < 0 {
+			feeCap = tip
+		}
+		if minimum := raise(oldTip, 10); tip.Cmp(minimum) < 0 {
+			return fmt.Errorf("priority fee %s gwei is below the %s gwei nodes accept as a replacement", formatFixed(tip, 9), formatFixed(minimum, 9))
+		}
+		if minimum := raise(oldFeeCap, 10); feeCap.Cmp(minimum) < 0 {
+			return fmt.Errorf("max fee %s gwei is below the %s gwei nodes accept as a replacement", formatFixed(feeCap, 9), formatFixed(minimum, 9))
+		}
+	}
+
+	to, value, data, gas := pending.To, (*big.Int)(pending.Value), []byte(pending.Input), uint64(pending.Gas)
+	if name == "cancel" {
+		to, value, data, gas = &pending.From, new(big.Int), nil, transferGas
+	}
+	if to == nil {
+		return fmt.Errorf("bumping contract deployments is not supported")
+	}
+	var accessList types.AccessList
+	if name == "bump" && pending.AccessList != nil {
+		accessList = *pending.AccessList
+	}
+	tx, err := replacementTransaction(pending, chainID, to, value, gas, tip, feeCap, data, accessList)
+	if err != nil {
+		return err
+	}
+	signed, err := signTransaction(tx, chainID, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replacing %s (nonce %d of %s)\n", fs.Arg(0), pending.Nonce, pending.From.Hex())
+	if name == "cancel" {
+		fmt.Println("With: zero-value self-send")
+	}
+	confirmOptions.yes, confirmOptions.abiPaths = *yes, *wait.abiPaths
+	hash, err := broadcastTransaction(endpoint, signed)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Transaction:", hash)
+	if *noWait {
+		return nil
+	}
+	return waitAndReport(endpoint, hash, wait, cfg)
+}
+
+// Function to build the replacement of a pending transaction with its type: EIP-2930
+// transactions stay EIP-2930 ones, paying a gas price and carrying the access list, which
+// a legacy transaction would drop
+func replacementTransaction(pending *pendingTransaction, chainID uint64, to *common.Address, value *big.Int, gas uint64, tip, feeCap *big.Int, data []byte, accessList types.AccessList) (*types.Transaction, error) {
+	if pending.ChainID != nil && pending.ChainID.ToInt().Sign() != 0 && pending.ChainID.ToInt().Uint64() != chainID {
+		return nil, fmt.Errorf("the transaction is for chain %s, not %d", pending.ChainID.ToInt(), chainID)
+	}
+	if pending.Type == types.AccessListTxType {
+		return types.NewTx(&types.AccessListTx{
+			ChainID: new(big.Int).SetUint64(chainID), Nonce: uint64(pending.Nonce), GasPrice: tip,
+			Gas: gas, To: to, Value: value, Data: data, AccessList: accessList,
+		}), nil
+	}
+	return newTransaction(chainID, uint64(pending.Nonce), to, value, gas, tip, feeCap, data, accessList), nil
+}
+
+// Function to get the fee of a replacement: the old fee raised by a percentage, or the
+// suggested fee when higher
+func bumpFee(old, suggested *big.Int, percent uint64) *big.Int {
+	bumped := raise(old, percent)
+	if suggested != nil && suggested.Cmp(bumped) > 0 {
+		return suggested
+	}
+	return bumped
+}
+
+// Function to raise a fee by a percentage, rounding up
+func raise(fee *big.Int, percent uint64) *big.Int {
+	if fee == nil {
+		return new(big.Int)
+	}
+	raised := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+percent))
+	raised.Add(raised, big.NewInt(99))
+	return raised.Div(raised, big.NewInt(100))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestReplaceAccessListTransaction(t *testing.T) {
+	t.Setenv("CONTRACT_CURLER_HOME", t.TempDir())
+	t.Setenv(privateKeyEnv, "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	accessList := types.AccessList{{
+		Address:     common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+		StorageKeys: []common.Hash{common.HexToHash("0x01")},
+	}}
+	pending, _ := json.Marshal(map[string]interface{}{
+		"blockNumber": nil, "from": "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23", "to": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+		"nonce": "0x5", "value": "0x0", "input": "0x095ea7b3", "gas": "0xc350", "type": "0x1",
+		"gasPrice": "0x3b9aca00", "accessList": accessList, "chainId": "0x1",
+	})
+
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Id     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		result := map[string]string{
+			"eth_getTransactionByHash": string(pending),
+			"eth_chainId":              `"0x1"`,
+			"eth_getBlockByNumber":     `{"number":"0x10","baseFeePerGas":"0x3b9aca00"}`,
+			"eth_maxPriorityFeePerGas": `"0x3b9aca00"`,
+			"eth_gasPrice":             `"0x3b9aca00"`,
+			"eth_sendRawTransaction":   `"0x` + common.Bytes2Hex(make([]byte, 32)) + `"`,
+		}[request.Method]
+		if request.Method == "eth_sendRawTransaction" {
+			var raw string
+			json.Unmarshal(request.Params[0], &raw)
+			sent = append(sent, raw)
+		}
+		if result == "" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(request.Id) + `,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(request.Id) + `,"result":` + result + `}`))
+	}))
+	defer server.Close()
+	defer func(saved bool) { confirmOptions.yes = saved }(confirmOptions.yes)
+
+	hash := "0x" + common.Bytes2Hex(make([]byte, 32))
+	for _, command := range []string{"bump", "cancel"} {
+		sent = nil
+		if err := replaceTransaction(command, []string{"--rpc", server.URL, "--yes", "--no-wait", hash}); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		if len(sent) != 1 {
+			t.Fatalf("%s: sent %d transactions", command, len(sent))
+		}
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(hexutil.MustDecode(sent[0])); err != nil {
+			t.Fatal(err)
+		}
+		// The replacement keeps the type, and for a bump the access list, raising the gas price
+		wantList := accessList
+		if command == "cancel" {
+			wantList = types.AccessList{}
+		}
+		if tx.Type() != types.AccessListTxType || tx.ChainId().Uint64() != 1 || tx.Nonce() != 5 ||
+			tx.GasPrice().Cmp(big.NewInt(1_150_000_000)) < 0 || !reflect.DeepEqual(tx.AccessList(), wantList) {
+			t.Errorf("%s: replacement of type %d on chain %s, nonce %d, gas price %s, access list %v",
+				command, tx.Type(), tx.ChainId(), tx.Nonce(), tx.GasPrice(), tx.AccessList())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Pattern of strings encoded as integers
+var decimalPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// Function to RLP-encode items given as JSON, or decode RLP into JSON
+func runRLP(args []string) error {
+	if len(args) != 2 || (args[0] != "encode" && args[0] != "decode") {
+		return fmt.Errorf("usage: contract-curler rlp encode <json> | rlp decode <hex> (- reads standard input)")
+	}
+	input := args[1]
+	if input == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read standard input: %v", err)
+		}
+		input = string(data)
+	}
+	input = strings.TrimSpace(input)
+
+	if args[0] == "encode" {
+		// Numbers are kept as written, as they may not fit a float64
+		decoder := json.NewDecoder(strings.NewReader(input))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		item, err := rlpItem(value)
+		if err != nil {
+			return err
+		}
+		encoded, err := rlp.EncodeToBytes(item)
+		if err != nil {
+			return fmt.Errorf("failed to encode: %v", err)
+		}
+		fmt.Println(hexutil.Encode(encoded))
+		return nil
+	}
+
+	data, err := hexutil.Decode("0x" + strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid hex: %v", err)
+	}
+	value, rest, err := decodeRLP(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d trailing bytes after the RLP item", len(rest))
+	}
+	out, _ := json.MarshalIndent(value, "", "  ")
+	fmt.Println(string(out))
+	return nil
+}
+
+// Function to convert a JSON value to an RLP item: arrays are lists, 0x strings are bytes,
+// numbers and decimal strings are integers and other strings are their UTF-8 bytes
+func rlpItem(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, element := range v {
+			item, err := rlpItem(element)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case json.Number:
+		return rlpInteger(string(v))
+	case string:
+		if strings.HasPrefix(v, "0x") {
+			data, err := hexutil.Decode(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex '%s': %v", v, err)
+			}
+			return data, nil
+		}
+		if decimalPattern.MatchString(v) {
+			return rlpInteger(v)
+		}
+		return []byte(v), nil
+	case bool:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{}, nil
+	case nil:
+		return []byte{}, nil
+	}
+	return nil, fmt.Errorf("cannot encode %v as RLP", value)
+}
+
+// Function to encode a non-negative integer as its minimal big-endian bytes
+func rlpInteger(s string) (interface{}, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid integer '%s', RLP only encodes non-negative integers", s)
+	}
+	return n.Bytes(), nil
+}
+
+// Function to decode an RLP item into JSON values: lists as arrays and strings as hex,
+// returning the bytes following it
+func decodeRLP(data []byte) (interface{}, []byte, error) {
+	kind, content, rest, err := rlp.Split(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid RLP: %v", err)
+	}
+	if kind != rlp.List {
+		return hexutil.Encode(content), rest, nil
+	}
+	items := []interface{}{}
+	for len(content) > 0 {
+		var item interface{}
+		item, content, err = decodeRLP(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rest, nil
+}
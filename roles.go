@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Events emitted by OpenZeppelin AccessControl when roles change hands
+const (
+	roleGrantedEvent = "RoleGranted(bytes32,address,address)"
+	roleRevokedEvent = "RoleRevoked(bytes32,address,address)"
+)
+
+// Role names recognised when they show up as hashes in events or getRoleAdmin
+var knownRoleNames = []string{
+	"DEFAULT_ADMIN_ROLE", "ADMIN_ROLE", "MINTER_ROLE", "BURNER_ROLE", "PAUSER_ROLE", "UPGRADER_ROLE",
+	"OPERATOR_ROLE", "MANAGER_ROLE", "GOVERNOR_ROLE", "GUARDIAN_ROLE", "KEEPER_ROLE", "SNAPSHOT_ROLE",
+	"URI_SETTER_ROLE", "PROPOSER_ROLE", "EXECUTOR_ROLE", "CANCELLER_ROLE", "TIMELOCK_ADMIN_ROLE",
+}
+
+// accessRole is a role of an AccessControl contract with the name it is known by, if any
+type accessRole struct {
+	name string
+	hash common.Hash
+}
+
+// Function to compute the hash of a role name. DEFAULT_ADMIN_ROLE is the zero hash rather
+// than the hash of its name.
+func roleHash(name string) common.Hash {
+	if name == "DEFAULT_ADMIN_ROLE" {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash([]byte(name))
+}
+
+// Function to parse a role given by name or as a 32-byte hash
+func parseRole(s string) (accessRole, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") {
+		hash, err := hexutil.Decode(s)
+		if err != nil || len(hash) != 32 {
+			return accessRole{}, fmt.Errorf("invalid role hash '%s'", s)
+		}
+		return accessRole{hash: common.BytesToHash(hash)}, nil
+	}
+	return accessRole{name: s, hash: roleHash(s)}, nil
+}
+
+// Function to inspect the roles of an AccessControl contract: their hashes, admin roles,
+// current members and whether given accounts hold them
+func runRoles(args []string) error {
+	fs := flag.NewFlagSet("roles", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	roleList := fs.String("role", "", "comma-separated role names like MINTER_ROLE, or role hashes (default: the roles found in events)")
+	accounts := fs.String("account", "", "comma-separated accounts to check hasRole for")
+	members := fs.Bool("members", true, "scan RoleGranted/RoleRevoked events to list the members of each role")
+	from := fs.String("from", "0", "first block of the event scan")
+	block := fs.String("block", "latest", "block to query, and last block of the event scan")
+	chunk := fs.Uint64("chunk", 50000, "number of blocks requested per eth_getLogs call")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler roles [flags] <contract>")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, endpoint)
+	contract, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[common.Hash]string)
+	for _, name := range knownRoleNames {
+		names[roleHash(name)] = name
+	}
+	var roles []accessRole
+	if *roleList != "" {
+		for _, s := range strings.Split(*roleList, ",") {
+			role, err := parseRole(s)
+			if err != nil {
+				return err
+			}
+			if role.name != "" {
+				names[role.hash] = role.name
+			}
+			roles = append(roles, role)
+		}
+	}
+
+	var holders map[common.Hash]map[common.Address]bool
+	if *members {
+		if holders, err = scanRoleMembers(endpoint, contract, *from, *block, *chunk); err != nil {
+			return err
+		}
+		if *roleList == "" {
+			for hash := range holders {
+				roles = append(roles, accessRole{hash: hash})
+			}
+			sort.Slice(roles, func(i, j int) bool { return bytes.Compare(roles[i].hash[:], roles[j].hash[:]) < 0 })
+		}
+	}
+	if len(roles) == 0 {
+		roles = []accessRole{{name: "DEFAULT_ADMIN_ROLE"}}
+	}
+
+	roleLabel := func(hash common.Hash) string {
+		if name, ok := names[hash]; ok {
+			return name + " " + hash.Hex()
+		}
+		return hash.Hex()
+	}
+	call := func(signature string, args []string, returnTypes string) ([]interface{}, error) {
+		data, err := encodeMethodCall(signature, args)
+		if err != nil {
+			return nil, err
+		}
+		return callForValues(endpoint, contract.Hex(), data, *block, returnTypes)
+	}
+
+	for i, role := range roles {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(roleLabel(role.hash))
+		if values, err := call("getRoleAdmin(bytes32)", []string{role.hash.Hex()}, "(bytes32)"); err == nil {
+			fmt.Println("  Admin: " + roleLabel(common.Hash(values[0].([32]byte))))
+		} else {
+			fmt.Printf("  Admin: unknown (%v)\n", err)
+		}
+
+		current, source := roleMembers(call, role.hash, holders)
+		if current != nil {
+			fmt.Printf("  Members (%s):\n", source)
+			if len(current) == 0 {
+				fmt.Println("    none")
+			}
+			for _, member := range current {
+				line := "    " + formatAddress(member, opts)
+				// Events can be missed by a partial scan, so confirm against the contract
+				if values, err := call("hasRole(bytes32,address)", []string{role.hash.Hex(), member.Hex()}, "(bool)"); err == nil && !values[0].(bool) {
+					line += "  (hasRole is false)"
+				}
+				fmt.Println(line)
+			}
+		}
+
+		if *accounts != "" {
+			for _, s := range strings.Split(*accounts, ",") {
+				account, err := cfg.resolveAddress(strings.TrimSpace(s), *chain, endpoint)
+				if err != nil {
+					return err
+				}
+				values, err := call("hasRole(bytes32,address)", []string{role.hash.Hex(), account.Hex()}, "(bool)")
+				switch {
+				case err != nil:
+					fmt.Printf("  hasRole(%s): error: %v\n", formatAddress(account, opts), err)
+				case values[0].(bool):
+					fmt.Printf("  ✓ %s has the role\n", formatAddress(account, opts))
+				default:
+					fmt.Printf("  ✗ %s does not have the role\n", formatAddress(account, opts))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Function to list the members of a role, from AccessControlEnumerable when the contract
+// implements it and from the scanned events otherwise. Returns nil when neither is available.
+func roleMembers(call func(string, []string, string) ([]interface{}, error), role common.Hash, holders map[common.Hash]map[common.Address]bool) ([]common.Address, string) {
+	if values, err := call("getRoleMemberCount(bytes32)", []string{role.Hex()}, "(uint256)"); err == nil {
+		count, _ := asBigInt(values[0])
+		members := []common.Address{}
+		for i := int64(0); count != nil && i < count.Int64(); i++ {
+			values, err := call("getRoleMember(bytes32,uint256)", []string{role.Hex(), big.NewInt(i).String()}, "(address)")
+			if err != nil {
+				break
+			}
+			members = append(members, values[0].(common.Address))
+		}
+		if count != nil && int64(len(members)) == count.Int64() {
+			return members, "getRoleMember"
+		}
+	}
+	if holders == nil {
+		return nil, ""
+	}
+	members := []common.Address{}
+	for member := range holders[role] {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return bytes.Compare(members[i][:], members[j][:]) < 0 })
+	return members, "from events"
+}
+
+// Function to replay the RoleGranted and RoleRevoked events of a contract, returning the
+// accounts holding each role at the end of the range
+func scanRoleMembers(rpcURL string, contract common.Address, from, to string, chunk uint64) (map[common.Hash]map[common.Address]bool, error) {
+	if chunk == 0 {
+		return nil, fmt.Errorf("chunk size must be greater than zero")
+	}
+	fromBlock, err := parseBlockNumber(from)
+	if err != nil {
+		return nil, err
+	}
+	var toBlock uint64
+	if to == "latest" {
+		if toBlock, err = latestBlockNumber(rpcURL); err != nil {
+			return nil, fmt.Errorf("failed to fetch latest block: %v", err)
+		}
+	} else if toBlock, err = parseBlockNumber(to); err != nil {
+		return nil, err
+	}
+
+	granted, revoked := eventTopic(roleGrantedEvent), eventTopic(roleRevokedEvent)
+	var events []LogEntry
+	for start := fromBlock; start <= toBlock; start += chunk {
+		end := start + chunk - 1
+		if end > toBlock {
+			end = toBlock
+		}
+		found := len(events)
+		for _, topic := range []string{granted, revoked} {
+			logs, err := getLogs(rpcURL, []string{contract.Hex()}, []string{topic}, start, end)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch role events for blocks %d-%d: %v", start, end, err)
+			}
+			events = append(events, logs...)
+		}
+		fmt.Fprintf(os.Stderr, "Scanned blocks %d-%d: %d role events\n", start, end, len(events)-found)
+	}
+
+	// Grants and revocations are fetched separately, so put them back in chain order
+	position := func(entry LogEntry) (uint64, uint64) {
+		block, _ := hexutil.DecodeUint64(entry.BlockNumber)
+		index, _ := hexutil.DecodeUint64(entry.LogIndex)
+		return block, index
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		bi, li := position(events[i])
+		bj, lj := position(events[j])
+		return bi < bj || (bi == bj && li < lj)
+	})
+
+	holders := make(map[common.Hash]map[common.Address]bool)
+	for _, entry := range events {
+		if entry.Removed || len(entry.Topics) < 3 {
+			continue
+		}
+		role := common.HexToHash(entry.Topics[1])
+		account := common.BytesToAddress(common.FromHex(entry.Topics[2]))
+		if holders[role] == nil {
+			holders[role] = make(map[common.Address]bool)
+		}
+		if strings.EqualFold(entry.Topics[0], granted) {
+			holders[role][account] = true
+		} else {
+			delete(holders[role], account)
+		}
+	}
+	return holders, nil
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contract-curler/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Endpoint used when no RPC URL is given or configured
+const defaultRpcURL = "http://localhost:8545"
+
+// Function to get the RPC URL used when none is given: the configured one, the endpoint
+// profile named after the configured chain, or the local node
+func defaultRPC() string {
+	return chainRPC("")
+}
+
+// Function to get the RPC URL used for a chain when none is given: the endpoint profile
+// named after the chain, or the default endpoint
+func chainRPC(chain string) string {
+	cfg, err := loadConfig()
+	if err != nil {
+		return defaultRpcURL
+	}
+	if profile := chainProfile(cfg, chain); profile != "" {
+		return profile
+	}
+	if cfg.Rpc != "" {
+		return cfg.Rpc
+	}
+	if profile := chainProfile(cfg, cfg.Chain); profile != "" {
+		return profile
+	}
+	return defaultRpcURL
+}
+
+// Function to find the endpoint profile of a chain, named after it or after its id
+func chainProfile(cfg *Config, chain string) string {
+	if chain == "" {
+		return ""
+	}
+	if _, ok := cfg.Endpoints[chain]; ok {
+		return chain
+	}
+	if id, err := parseChain(chain); err == nil {
+		for _, name := range []string{chainName(id), strconv.FormatUint(id, 10)} {
+			if _, ok := cfg.Endpoints[name]; ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Client shared by all requests so connections to an endpoint are kept alive and reused
+// across the many calls of batch, watch and log-scan workloads
+var httpClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+// JsonRpcError represents the error object of a JSON-RPC response
+type JsonRpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JsonRpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// JsonRpcResponse represents a JSON-RPC response, whose result is any JSON value left
+// undecoded: hex data for eth_call, but objects, arrays or null for other methods. Its id
+// is nil when the endpoint could not tell which request failed.
+type JsonRpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      *int            `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *JsonRpcError   `json:"error"`
+}
+
+// Function to check that a response is a JSON-RPC 2.0 response carrying either a result
+// or an error
+func (r *JsonRpcResponse) checkFields() error {
+	if r.JsonRpc != "2.0" {
+		return fmt.Errorf("not a JSON-RPC 2.0 response (jsonrpc %q)", r.JsonRpc)
+	}
+	switch {
+	case r.Result != nil && r.Error != nil:
+		return fmt.Errorf("response has both a result and an error (%v)", r.Error)
+	case r.Result == nil && r.Error == nil:
+		return fmt.Errorf("response has neither a result nor an error")
+	}
+	return nil
+}
+
+// Function to check that a response is valid and answers the request with the given id
+func (r *JsonRpcResponse) validate(id int) error {
+	if err := r.checkFields(); err != nil {
+		return err
+	}
+	return checkResponseID(r.Id, id, r.Error != nil)
+}
+
+// Id of the last JSON-RPC request built, so each request gets its own and responses can
+// be matched to them
+var requestIDs struct {
+	sync.Mutex
+	last int
+}
+
+// Function to reserve n consecutive request ids, returning the first
+func reserveRequestIDs(n int) int {
+	requestIDs.Lock()
+	defer requestIDs.Unlock()
+	first := requestIDs.last + 1
+	requestIDs.last += n
+	return first
+}
+
+// Function to build a JSON-RPC request with the next request id
+func newRPCRequest(method string, params []interface{}) JsonRpcRequest {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return JsonRpcRequest{JsonRpc: "2.0", Method: method, Params: params, Id: reserveRequestIDs(1)}
+}
+
+// Function to check that a response answers the request with the given id. Errors about
+// requests the endpoint could not parse carry no id, and are reported as they are.
+func checkResponseID(id *int, want int, failed bool) error {
+	switch {
+	case id == nil && failed:
+		return nil
+	case id == nil:
+		return fmt.Errorf("response has no id, expected %d", want)
+	case *id != want:
+		return fmt.Errorf("response id %d does not match request id %d", *id, want)
+	}
+	return nil
+}
+
+// Function to retry a request whose state was pruned against the configured archive
+// endpoint, or to explain that an archive node is needed when there is none
+func archiveFallback(rpcURL string, out interface{}, method string, params []interface{}, rpcErr error) error {
+	cfg, err := loadConfig()
+	if err == nil && cfg.ArchiveRpc != "" && resolveEndpoint(cfg.ArchiveRpc) != resolveEndpoint(rpcURL) {
+		fmt.Fprintf(os.Stderr, "State not available on %s, retrying %s on archive endpoint %s\n", rpcURL, method, cfg.ArchiveRpc)
+		return callRPC(cfg.ArchiveRpc, out, method, params...)
+	}
+	return fmt.Errorf("%v: the node no longer has the state of the requested block, historical calls need an archive node "+
+		"(set \"archiveRpc\" in %s to retry on one automatically)", rpcErr, filepath.Join(configDir(), "config.json"))
+}
+
+// Retries of a request for a block the node has not caught up with, and the delay before
+// the first one, doubled for each next one
+const (
+	lagRetries = 4
+	lagDelay   = 500 * time.Millisecond
+)
+
+// Function to send a JSON-RPC request and decode its result into out. Calls reverting with
+// an EIP-3668 OffchainLookup are completed through the gateways of the contract.
+func callRPC(rpcURL string, out interface{}, method string, params ...interface{}) error {
+	err := requestRPC(rpcURL, out, method, params...)
+	if method == "eth_call" && err != nil {
+		return followOffchainLookups(rpcURL, out, params, err)
+	}
+	return err
+}
+
+// Function to send a JSON-RPC request and decode its result into out. Requests for a block
+// the node has not seen yet are retried with a growing, jittered delay.
+func requestRPC(rpcURL string, out interface{}, method string, params ...interface{}) error {
+	err := sendRPC(rpcURL, out, method, params...)
+	for attempt := 0; attempt < lagRetries && isBlockLagError(err); attempt++ {
+		delay := lagDelay << attempt
+		delay += time.Duration(rand.Int63n(int64(delay / 2)))
+		fmt.Fprintf(os.Stderr, "%s is behind (%v), retrying %s in %s\n", displayEndpoint(resolveEndpoint(rpcURL)), err, method, delay.Round(time.Millisecond))
+		time.Sleep(delay)
+		err = sendRPC(rpcURL, out, method, params...)
+	}
+	if rpcErr, ok := err.(*JsonRpcError); ok && isMissingStateError(rpcErr) {
+		return archiveFallback(rpcURL, out, method, params, rpcErr)
+	}
+	return err
+}
+
+// Function to send a JSON-RPC request to exactly the given endpoint, without archive fallback
+func sendRPC(rpcURL string, out interface{}, method string, params ...interface{}) error {
+	request := newRPCRequest(method, params)
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON request: %v", err)
+	}
+
+	body, err := openJSON(rpcURL, jsonData, 0)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %v", err)
+	}
+	// Drain what the decoder left so the connection can be reused
+	defer func() {
+		io.Copy(ioutil.Discard, body)
+		body.Close()
+	}()
+
+	var response JsonRpcResponse
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	if err := response.validate(request.Id); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(response.Result, out); err != nil {
+			return fmt.Errorf("failed to parse %s result: %v", method, err)
+		}
+	}
+	return nil
+}
+
+// Function to send a JSON-RPC request whose result is an array, passing each element to
+// each as soon as it is decoded so huge results are never held in memory at once. The
+// response is checked like JsonRpcResponse.validate does once it has streamed past.
+func streamRPC(rpcURL string, method string, params []interface{}, each func(item json.RawMessage) error) error {
+	request := newRPCRequest(method, params)
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON request: %v", err)
+	}
+	body, err := openJSON(rpcURL, jsonData, 0)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %v", err)
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, body)
+		body.Close()
+	}()
+
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("failed to parse response: expected a JSON object")
+	}
+	// The fields checked by JsonRpcResponse.validate, as they stream past
+	var version string
+	var id *int
+	var rpcErr *JsonRpcError
+	hasResult := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		switch token {
+		case "jsonrpc":
+			if err := decoder.Decode(&version); err != nil {
+				return fmt.Errorf("failed to parse response: %v", err)
+			}
+		case "result":
+			hasResult = true
+			token, err := decoder.Token()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s result: %v", method, err)
+			}
+			if token == nil {
+				continue
+			}
+			if token != json.Delim('[') {
+				return fmt.Errorf("failed to parse %s result: expected an array", method)
+			}
+			for decoder.More() {
+				var item json.RawMessage
+				if err := decoder.Decode(&item); err != nil {
+					return fmt.Errorf("failed to parse %s result: %v", method, err)
+				}
+				if err := each(item); err != nil {
+					return err
+				}
+			}
+			if _, err := decoder.Token(); err != nil {
+				return fmt.Errorf("failed to parse %s result: %v", method, err)
+			}
+		case "id":
+			// Usually sent first, but a mismatch found after the result still fails the call
+			if err := decoder.Decode(&id); err != nil {
+				return fmt.Errorf("failed to parse response: %v", err)
+			}
+			if id != nil && *id != request.Id {
+				return fmt.Errorf("%s: response id %d does not match request id %d", method, *id, request.Id)
+			}
+		case "error":
+			if err := decoder.Decode(&rpcErr); err != nil {
+				return fmt.Errorf("failed to parse response: %v", err)
+			}
+		default:
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return fmt.Errorf("failed to parse response: %v", err)
+			}
+		}
+	}
+	if version != "2.0" {
+		return fmt.Errorf("%s: not a JSON-RPC 2.0 response (jsonrpc %q)", method, version)
+	}
+	switch {
+	case hasResult && rpcErr != nil:
+		return fmt.Errorf("%s: response has both a result and an error (%v)", method, rpcErr)
+	case !hasResult && rpcErr == nil:
+		return fmt.Errorf("%s: response has neither a result nor an error", method)
+	}
+	if err := checkResponseID(id, request.Id, rpcErr != nil); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	if rpcErr != nil {
+		return rpcErr
+	}
+	return nil
+}
+
+// Function to POST a JSON-RPC payload, retrying transport failures and overloaded endpoints
+func postJSON(rpcURL string, jsonData []byte, retries int) ([]byte, error) {
+	body, err := openJSON(rpcURL, jsonData, retries)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	return data, nil
+}
+
+// Function to POST a JSON-RPC payload and return the decompressed response body for
+// decoding as it arrives, recording the request in the manifest when one is kept
+func openJSON(rpcURL string, jsonData []byte, retries int) (io.ReadCloser, error) {
+	body, err := openRequest(rpcURL, jsonData, retries)
+	if err != nil {
+		return nil, err
+	}
+	return recordResponse(rpcURL, jsonData, body), nil
+}
+
+// Function to send a JSON-RPC payload through the transport of the endpoint and return
+// the decompressed response body. Only failures before the body starts are retried, and
+// profiles with fallbacks fail over to the next endpoint of their rotation.
+func openRequest(rpcURL string, jsonData []byte, retries int) (io.ReadCloser, error) {
+	rotation := endpointRotation(rpcURL)
+	var lastErr error
+	for i, url := range rotation {
+		t, err := rpc.Open(url)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			fmt.Fprintf(os.Stderr, "Failing over to %s after error: %v\n", displayEndpoint(url), lastErr)
+		}
+		started := time.Now()
+		body, err := t.RoundTrip(&rpc.Call{Endpoint: url, Payload: jsonData, Retries: retries})
+		if len(rotation) > 1 {
+			recordOutcome(rpcURL, url, time.Since(started), err)
+		}
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Function to build a curl command reproducing a JSON-RPC request
+func curlCommand(rpcURL string, jsonData []byte) string {
+	data := strings.ReplaceAll(string(jsonData), "'", `'\''`)
+	return fmt.Sprintf("curl -X POST %s -H \"Content-Type: application/json\" --data '%s'", displayEndpoint(resolveEndpoint(rpcURL)), data)
+}
+
+// Function to call an arbitrary JSON-RPC method, e.g. rpc debug_traceTransaction '["0x...", {}]'
+func runRPC(args []string) error {
+	fs := flag.NewFlagSet("rpc", flag.ExitOnError)
+	rpcURL := fs.String("rpc", defaultRPC(), "Ethereum RPC URL")
+	retries := fs.Int("retries", 2, "number of retries on transport errors")
+	curlOnly := fs.Bool("curl", false, "print the curl command without executing it")
+	manifestPath := fs.String("manifest", "", "file every executed request is appended to, with its endpoint, block and response hash")
+	fs.BoolVar(&deterministic, "deterministic", false, "leave timestamps, latencies and endpoint names out of the output")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: contract-curler rpc [flags] <method> [params-json | param...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing method")
+	}
+
+	params, err := parseRPCParams(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	request := newRPCRequest(fs.Arg(0), params)
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON request: %v", err)
+	}
+
+	if *curlOnly {
+		fmt.Println(curlCommand(*rpcURL, jsonData))
+		return nil
+	}
+	if *manifestPath != "" {
+		enableManifest(*manifestPath)
+	}
+	fmt.Fprintln(os.Stderr, "Generated curl command:")
+	fmt.Fprintln(os.Stderr, curlCommand(*rpcURL, jsonData))
+
+	body, err := postJSON(*rpcURL, jsonData, *retries)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %v", err)
+	}
+	var response JsonRpcResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	if err := response.validate(request.Id); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, response.Result, "", "  "); err != nil {
+		return fmt.Errorf("failed to format result: %v", err)
+	}
+	fmt.Println(pretty.String())
+
+	// Hex quantities are easier to read in decimal too
+	var quantity string
+	if json.Unmarshal(response.Result, &quantity) == nil {
+		if n, err := hexutil.DecodeBig(quantity); err == nil {
+			fmt.Fprintf(os.Stderr, "(decimal %s)\n", n.String())
+		}
+	}
+	return nil
+}
+
+// Function to build params from a JSON array or from individual JSON values or plain strings.
+// Numbers are kept as written, as they may not fit a float64.
+func parseRPCParams(args []string) ([]interface{}, error) {
+	params := []interface{}{}
+	if len(args) == 1 && strings.HasPrefix(strings.TrimSpace(args[0]), "[") {
+		if err := unmarshalNumbers(args[0], &params); err != nil {
+			return nil, fmt.Errorf("failed to parse params: %v", err)
+		}
+		return params, nil
+	}
+	for _, arg := range args {
+		var value interface{}
+		if err := unmarshalNumbers(arg, &value); err != nil {
+			// Not JSON, so pass it as a string such as an address or block tag
+			value = arg
+		}
+		params = append(params, value)
+	}
+	return params, nil
+}
+
+// Function to parse a single JSON value like json.Unmarshal, decoding numbers as json.Number
+func unmarshalNumbers(data string, value interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// Function to fetch the number of the most recent block
+func latestBlockNumber(rpcURL string) (uint64, error) {
+	var result hexutil.Uint64
+	if err := callRPC(rpcURL, &result, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return uint64(result), nil
+}
+
+// Function to resolve a moving block tag like latest to the hex number of the block it
+// points to now, so a series of calls all see the same state
+func pinBlock(rpcURL, block string) (string, error) {
+	switch block {
+	case "latest":
+		n, err := latestBlockNumber(rpcURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to get latest block: %v", err)
+		}
+		return hexutil.EncodeUint64(n), nil
+	case "safe", "finalized":
+		var header blockHeader
+		if err := callRPC(rpcURL, &header, "eth_getBlockByNumber", block, false); err != nil {
+			return "", fmt.Errorf("failed to resolve block '%s': %v", block, err)
+		}
+		return hexutil.EncodeUint64(uint64(header.Number)), nil
+	case "earliest":
+		return "0x0", nil
+	case "pending":
+		fmt.Fprintln(os.Stderr, colorize(colorYellow, "Warning")+": the pending block cannot be pinned, calls made while it changes see different state")
+		return block, nil
+	}
+	n, err := parseBlockNumber(block)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.EncodeUint64(n), nil
+}
+
+// Function to parse a block number given in decimal or 0x-prefixed hex
+func parseBlockNumber(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") {
+		return hexutil.DecodeUint64(s)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number '%s'", s)
+	}
+	return n, nil
+}
+
+// blockHeader holds the fields of a block needed to follow the chain
+type blockHeader struct {
+	Number     hexutil.Uint64 `json:"number"`
+	Hash       string         `json:"hash"`
+	ParentHash string         `json:"parentHash"`
+}
+
+// Function to fetch the header of a block by number
+func getBlockHeader(rpcURL string, number uint64) (*blockHeader, error) {
+	var header *blockHeader
+	if err := callRPC(rpcURL, &header, "eth_getBlockByNumber", hexutil.EncodeUint64(number), false); err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	return header, nil
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// PreSendHook changes a call before it is sent, its payload or headers, e.g. to sign it for
+// an authenticated endpoint. An error fails the call.
+type PreSendHook interface {
+	PreSend(call *Call) error
+}
+
+// PreSendFunc turns a function into a PreSendHook
+type PreSendFunc func(call *Call) error
+
+func (f PreSendFunc) PreSend(call *Call) error {
+	return f(call)
+}
+
+// PostReceiveHook checks the response of a call, returning it or a replacement. An error
+// fails the call.
+type PostReceiveHook interface {
+	PostReceive(call *Call, response []byte) ([]byte, error)
+}
+
+// PostReceiveFunc turns a function into a PostReceiveHook
+type PostReceiveFunc func(call *Call, response []byte) ([]byte, error)
+
+func (f PostReceiveFunc) PostReceive(call *Call, response []byte) ([]byte, error) {
+	return f(call, response)
+}
+
+// Function to build a middleware running hooks around each attempt of a call, in order, to
+// be registered with Use. Pre-send hooks change a copy of the call, so a retry starts again
+// from the original one, and post-receive hooks get the whole response.
+func Hooks(preSend []PreSendHook, postReceive []PostReceiveHook) Middleware {
+	return func(next Transport) Transport {
+		if len(preSend)+len(postReceive) == 0 {
+			return next
+		}
+		return TransportFunc(func(call *Call) (io.ReadCloser, error) {
+			sent := *call
+			sent.Header = call.Header.Clone()
+			for _, hook := range preSend {
+				if err := hook.PreSend(&sent); err != nil {
+					return nil, err
+				}
+			}
+
+			body, err := next.RoundTrip(&sent)
+			if err != nil || len(postReceive) == 0 {
+				return body, err
+			}
+			response, err := ioutil.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read response: %v", err)
+			}
+			for _, hook := range postReceive {
+				if response, err = hook.PostReceive(&sent, response); err != nil {
+					return nil, err
+				}
+			}
+			return ioutil.NopCloser(bytes.NewReader(response)), nil
+		})
+	}
+}
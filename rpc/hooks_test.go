@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var sent []string
+	endpoint := TransportFunc(func(call *Call) (io.ReadCloser, error) {
+		sent = append(sent, string(call.Payload)+" "+call.Header.Get("Authorization"))
+		if len(sent) == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return ioutil.NopCloser(strings.NewReader(`{"result":"0x1"}`)), nil
+	})
+	sign := PreSendFunc(func(call *Call) error {
+		call.Payload = append(call.Payload, '!')
+		if call.Header == nil {
+			call.Header = make(http.Header)
+		}
+		call.Header.Set("Authorization", "signed")
+		return nil
+	})
+	validate := PostReceiveFunc(func(call *Call, response []byte) ([]byte, error) {
+		return []byte(strings.ToUpper(string(response))), nil
+	})
+
+	transport := Retry(Hooks([]PreSendHook{sign}, []PostReceiveHook{validate})(endpoint))
+	call := &Call{Payload: []byte("request"), Retries: 1}
+	body, err := transport.RoundTrip(call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _ := ioutil.ReadAll(body)
+	if string(response) != `{"RESULT":"0X1"}` {
+		t.Errorf("response %s was not replaced", response)
+	}
+	// Each attempt starts again from the original call, which is left unchanged
+	if len(sent) != 2 || sent[0] != "request! signed" || sent[1] != "request! signed" {
+		t.Errorf("sent %q", sent)
+	}
+	if string(call.Payload) != "request" || call.Header != nil {
+		t.Errorf("original call changed to %s %v", call.Payload, call.Header)
+	}
+
+	reject := PreSendFunc(func(call *Call) error {
+		return errors.New("rejected")
+	})
+	if _, err := Hooks([]PreSendHook{reject}, nil)(endpoint).RoundTrip(&Call{}); err == nil || err.Error() != "rejected" {
+		t.Errorf("pre-send error not returned: %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+// Package rpc sends JSON-RPC payloads to Ethereum endpoints over HTTP, WebSocket or IPC.
+// Every transport is wrapped in the middlewares registered with Use, so programs embedding
+// it can observe or change the calls, and transports for other schemes can be added with
+// Register.
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends the HTTP requests, and its timeout bounds each WebSocket and IPC exchange
+var Client = &http.Client{Timeout: 5 * time.Minute}
+
+// DialTimeout bounds opening WebSocket and IPC connections
+var DialTimeout = 10 * time.Second
+
+// UserAgent is sent in WebSocket handshakes
+var UserAgent = "contract-curler"
+
+// Call is a JSON-RPC payload on its way to an endpoint
+type Call struct {
+	Endpoint string
+	Payload  []byte
+	// Number of times the call may be retried after a failure
+	Retries int
+	// Headers added to HTTP requests, e.g. by pre-send hooks
+	Header http.Header
+}
+
+// Transport sends JSON-RPC payloads to an endpoint, returning the body of the response
+type Transport interface {
+	RoundTrip(call *Call) (io.ReadCloser, error)
+}
+
+// TransportFunc turns a function into a Transport
+type TransportFunc func(call *Call) (io.ReadCloser, error)
+
+func (f TransportFunc) RoundTrip(call *Call) (io.ReadCloser, error) {
+	return f(call)
+}
+
+// Middleware wraps a transport to observe or change the calls going through it
+type Middleware func(next Transport) Transport
+
+// NonIdempotent lists the methods whose calls are never retried, as a retry after a
+// failure that reached the node could send a transaction twice
+var NonIdempotent = map[string]bool{
+	"eth_sendRawTransaction":            true,
+	"eth_sendRawTransactionConditional": true,
+	"eth_sendTransaction":               true,
+	"eth_sendBundle":                    true,
+	"eth_sendPrivateTransaction":        true,
+	"personal_sendTransaction":          true,
+}
+
+var (
+	mu sync.Mutex
+	// Middlewares wrapping every transport, the first one outermost
+	middlewares []Middleware
+	// Functions opening the transports of the registered URL schemes
+	schemes = make(map[string]func(endpoint string) (Transport, error))
+	// Transports keyed by endpoint URL, wrapped in the middlewares
+	transports = make(map[string]Transport)
+)
+
+// Function to add middlewares wrapping the transports opened from now on, inside those
+// added before
+func Use(m ...Middleware) {
+	mu.Lock()
+	defer mu.Unlock()
+	middlewares = append(middlewares, m...)
+}
+
+// Function to open the endpoints of a URL scheme with a transport of its own, or to replace
+// the built-in transport of http, https, ws, wss or ipc
+func Register(scheme string, open func(endpoint string) (Transport, error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	schemes[strings.ToLower(scheme)] = open
+}
+
+// Function to get the transport of an endpoint URL: HTTP, WebSocket for ws:// and wss://
+// URLs, IPC for ipc:// URLs and paths to a node's socket, or the transport registered for
+// its scheme. Transports are opened once and reused.
+func Open(endpoint string) (Transport, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if t, ok := transports[endpoint]; ok {
+		return t, nil
+	}
+	var t Transport
+	scheme := ""
+	if u, err := url.Parse(endpoint); err == nil {
+		scheme = strings.ToLower(u.Scheme)
+	}
+	if open, ok := schemes[scheme]; ok {
+		var err error
+		if t, err = open(endpoint); err != nil {
+			return nil, err
+		}
+	} else {
+		switch {
+		case scheme == "ws" || scheme == "wss":
+			t = &WebSocket{URL: endpoint}
+		case scheme == "ipc":
+			t = &IPC{Path: strings.TrimPrefix(endpoint, "ipc://")}
+		case strings.HasPrefix(endpoint, "/") || strings.HasSuffix(endpoint, ".ipc"):
+			t = &IPC{Path: endpoint}
+		case scheme == "http" || scheme == "https":
+			t = HTTP{}
+		default:
+			return nil, fmt.Errorf("invalid endpoint '%s' (expected an http, https, ws, wss or ipc URL, or a socket path)", endpoint)
+		}
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		t = middlewares[i](t)
+	}
+	transports[endpoint] = t
+	return t, nil
+}
+
+// HTTP POSTs payloads with Client, accepting compressed responses
+type HTTP struct{}
+
+func (HTTP) RoundTrip(call *Call) (io.ReadCloser, error) {
+	request, err := http.NewRequest("POST", call.Endpoint, bytes.NewReader(call.Payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	// Asking explicitly turns off the transport's own gzip handling, so deflate is accepted too
+	request.Header.Set("Accept-Encoding", "gzip, deflate")
+	for name, values := range call.Header {
+		request.Header[name] = values
+	}
+	resp, err := Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := decompressedBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		message, _ := ioutil.ReadAll(io.LimitReader(body, 4096))
+		body.Close()
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return body, nil
+}
+
+// multiCloser closes a decompressing reader together with the response body below it
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var firstErr error
+	for _, closer := range m.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Function to wrap a response body in the decompressor matching its Content-Encoding
+func decompressedBody(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip response: %v", err)
+		}
+		return &multiCloser{reader, []io.Closer{reader, resp.Body}}, nil
+	case "deflate":
+		// Servers send deflate both zlib-wrapped, as the spec says, and raw
+		buffered := bufio.NewReader(resp.Body)
+		header, err := buffered.Peek(2)
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress deflate response: %v", err)
+			}
+			return &multiCloser{reader, []io.Closer{reader, resp.Body}}, nil
+		}
+		reader := flate.NewReader(buffered)
+		return &multiCloser{reader, []io.Closer{reader, resp.Body}}, nil
+	}
+	return resp.Body, nil
+}
+
+// WebSocket sends payloads over a connection kept open across calls, one call at a time so
+// each response is the next message
+type WebSocket struct {
+	URL  string
+	mu   sync.Mutex
+	conn *Conn
+}
+
+func (t *WebSocket) RoundTrip(call *Call) (io.ReadCloser, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		conn, err := DialWebSocket(t.URL)
+		if err != nil {
+			return nil, err
+		}
+		t.conn = conn
+	}
+	message, err := t.exchange(call.Payload)
+	if err != nil {
+		// The connection is reopened by the next call
+		t.conn.Close()
+		t.conn = nil
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(message)), nil
+}
+
+// Function to send a payload and read the message answering it
+func (t *WebSocket) exchange(payload []byte) ([]byte, error) {
+	if err := t.conn.WriteMessage(payload); err != nil {
+		return nil, err
+	}
+	t.conn.conn.SetReadDeadline(time.Now().Add(Client.Timeout))
+	defer t.conn.conn.SetReadDeadline(time.Time{})
+	return t.conn.ReadMessage()
+}
+
+// IPC sends payloads over the Unix socket of a node, like geth.ipc, kept open across calls.
+// Responses are not delimited, so each is read as the next JSON value.
+type IPC struct {
+	Path    string
+	mu      sync.Mutex
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+func (t *IPC) RoundTrip(call *Call) (io.ReadCloser, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		conn, err := net.DialTimeout("unix", t.Path, DialTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", t.Path, err)
+		}
+		t.conn, t.decoder = conn, json.NewDecoder(bufio.NewReader(conn))
+	}
+	t.conn.SetDeadline(time.Now().Add(Client.Timeout))
+	var message json.RawMessage
+	_, err := t.conn.Write(call.Payload)
+	if err == nil {
+		err = t.decoder.Decode(&message)
+	}
+	if err != nil {
+		t.conn.Close()
+		t.conn, t.decoder = nil, nil
+		return nil, err
+	}
+	t.conn.SetDeadline(time.Time{})
+	return ioutil.NopCloser(bytes.NewReader(message)), nil
+}
+
+// Function to retry failed calls with a growing delay, up to the retries of the call. Only
+// failures before the response body starts are retried, and calls of NonIdempotent
+// methods never are.
+func Retry(next Transport) Transport {
+	return TransportFunc(func(call *Call) (io.ReadCloser, error) {
+		retries := call.Retries
+		for _, method := range Methods(call.Payload) {
+			if NonIdempotent[method] {
+				retries = 0
+			}
+		}
+		var lastErr error
+		for attempt := 0; attempt <= retries; attempt++ {
+			if attempt > 0 {
+				fmt.Fprintf(os.Stderr, "Retrying request (%d/%d) after error: %v\n", attempt, retries, lastErr)
+				time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+			}
+			body, err := next.RoundTrip(call)
+			if err == nil {
+				return body, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	})
+}
+
+// Function to get the methods of a JSON-RPC payload, one per request of a batch
+func Methods(payload []byte) []string {
+	type request struct {
+		Method string `json:"method"`
+	}
+	var batch []request
+	if json.Unmarshal(payload, &batch) != nil {
+		var single request
+		if json.Unmarshal(payload, &single) != nil {
+			return nil
+		}
+		batch = []request{single}
+	}
+	methods := make([]string, len(batch))
+	for i, r := range batch {
+		methods[i] = r.Method
+	}
+	return methods
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`, 3},
+		{`{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x00"]}`, 1},
+		{`[{"method":"eth_chainId"},{"method":"eth_sendTransaction"}]`, 1},
+	}
+	for _, test := range tests {
+		attempts := 0
+		failing := TransportFunc(func(call *Call) (io.ReadCloser, error) {
+			attempts++
+			return nil, errors.New("connection reset")
+		})
+		if _, err := Retry(failing).RoundTrip(&Call{Payload: []byte(test.payload), Retries: 2}); err == nil {
+			t.Errorf("%s: no error", test.payload)
+		}
+		if attempts != test.want {
+			t.Errorf("%s: %d attempts, want %d", test.payload, attempts, test.want)
+		}
+	}
+}
+
+func TestRegisterAndUse(t *testing.T) {
+	var seen []string
+	Use(func(next Transport) Transport {
+		return TransportFunc(func(call *Call) (io.ReadCloser, error) {
+			seen = append(seen, call.Endpoint)
+			return next.RoundTrip(call)
+		})
+	})
+	Register("mock", func(endpoint string) (Transport, error) {
+		return TransportFunc(func(call *Call) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`)), nil
+		}), nil
+	})
+
+	transport, err := Open("mock://node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := transport.RoundTrip(&Call{Endpoint: "mock://node", Payload: []byte(`{"method":"eth_chainId"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, _ := ioutil.ReadAll(body)
+	if !strings.Contains(string(response), `"result":"0x1"`) {
+		t.Errorf("unexpected response %s", response)
+	}
+	if len(seen) != 1 || seen[0] != "mock://node" {
+		t.Errorf("middleware saw %v", seen)
+	}
+	if again, _ := Open("mock://node"); again == nil {
+		t.Errorf("transport not reused")
+	}
+
+	if _, err := Open("ftp://node"); err == nil {
+		t.Errorf("unsupported scheme accepted")
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"method":"eth_call"}`, "eth_call"},
+		{`[{"method":"eth_call"},{"method":"eth_chainId"}]`, "eth_call,eth_chainId"},
+		{`not json`, ""},
+	}
+	for _, test := range tests {
+		if got := strings.Join(Methods([]byte(test.payload)), ","); got != test.want {
+			t.Errorf("Methods(%s) = %s, want %s", test.payload, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package rpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key of the WebSocket handshake, appended to the client's key to compute the accept value
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageSize is the largest message read from a WebSocket
+const MaxMessageSize = 16 << 20
+
+// WebSocket frame opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// Conn is a WebSocket connection speaking just enough of RFC 6455 for JSON-RPC: text
+// messages, fragmentation, pings and closing
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// Clients mask the frames they send, servers must not
+	client bool
+	// Writes come from the reader answering pings as well as from senders
+	mu sync.Mutex
+}
+
+// Function to open a WebSocket connection to a ws:// or wss:// URL
+func DialWebSocket(rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebSocket URL: %v", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host += map[string]string{"ws": ":80", "wss": ":443"}[u.Scheme]
+	}
+	dialer := &net.Dialer{Timeout: DialTimeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = dialer.Dial("tcp", host)
+	case "wss":
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported WebSocket scheme '%s' (expected ws or wss)", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", u.Host, err)
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req, _ := http.NewRequest("GET", rawURL, nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("User-Agent", UserAgent)
+	conn.SetDeadline(time.Now().Add(DialTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %s", resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+// Function to accept a WebSocket upgrade request, taking over its connection
+func AcceptWebSocket(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a WebSocket upgrade request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("the connection cannot be taken over")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over the connection: %v", err)
+	}
+	buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buffered.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n\r\n")
+	if err := buffered.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %v", err)
+	}
+	return &Conn{conn: conn, reader: buffered.Reader}, nil
+}
+
+// Function to compute the Sec-WebSocket-Accept value answering a key
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Function to read the next text or binary message, answering pings on the way
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, nil)
+			return nil, io.EOF
+		}
+		if len(message)+len(payload) > MaxMessageSize {
+			return nil, fmt.Errorf("WebSocket message larger than %d bytes", MaxMessageSize)
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// Function to read a frame, unmasking its payload
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0f
+	masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, fmt.Errorf("WebSocket frame larger than %d bytes", MaxMessageSize)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// Function to send a text message
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// Function to send a single frame, masked when sent by a client
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.conn.Write(frame); err != nil {
+		return fmt.Errorf("failed to write to WebSocket: %v", err)
+	}
+	return nil
+}
+
+// Function to close the connection, telling the peer first
+func (c *Conn) Close() error {
+	c.writeFrame(wsClose, []byte{0x03, 0xe8})
+	return c.conn.Close()
+}
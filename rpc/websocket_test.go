@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Function to connect a Conn to the other end of a pipe, read raw by the test
+func pipeConn(client bool) (*Conn, net.Conn) {
+	local, remote := net.Pipe()
+	return &Conn{conn: local, reader: bufio.NewReader(local), client: client}, remote
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// The example handshake of RFC 6455 section 1.3
+	if got := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("webSocketAccept = %s", got)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 256)
+	longer := bytes.Repeat([]byte("y"), 65536)
+	tests := []struct {
+		name  string
+		frame []byte
+		want  []byte
+	}{
+		// The examples of RFC 6455 section 5.7
+		{"unmasked", []byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f}, []byte("Hello")},
+		{"masked", []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}, []byte("Hello")},
+		{"fragmented", []byte{0x01, 0x03, 0x48, 0x65, 0x6c, 0x80, 0x02, 0x6c, 0x6f}, []byte("Hello")},
+		{"16-bit length", append([]byte{0x82, 0x7e, 0x01, 0x00}, long...), long},
+		{"64-bit length", append([]byte{0x82, 0x7f, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00}, longer...), longer},
+	}
+	for _, test := range tests {
+		conn, remote := pipeConn(false)
+		go remote.Write(test.frame)
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: read %d bytes %.20q, want %d bytes %.20q", test.name, len(got), got, len(test.want), test.want)
+		}
+		remote.Close()
+	}
+}
+
+func TestReadMessageAnswersPings(t *testing.T) {
+	conn, remote := pipeConn(false)
+	defer remote.Close()
+	go func() {
+		// A ping in the middle of a fragmented message
+		remote.Write([]byte{0x01, 0x03, 0x48, 0x65, 0x6c, 0x89, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f})
+		pong := make([]byte, 7)
+		io.ReadFull(remote, pong)
+		if !bytes.Equal(pong, []byte{0x8a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f}) {
+			t.Errorf("pong % x", pong)
+		}
+		remote.Write([]byte{0x80, 0x02, 0x6c, 0x6f})
+	}()
+	got, err := conn.ReadMessage()
+	if err != nil || string(got) != "Hello" {
+		t.Errorf("read %q, %v", got, err)
+	}
+}
+
+func TestReadMessageClose(t *testing.T) {
+	conn, remote := pipeConn(false)
+	defer remote.Close()
+	go func() {
+		remote.Write([]byte{0x88, 0x02, 0x03, 0xe8})
+		ioutil.ReadAll(remote)
+	}()
+	if _, err := conn.ReadMessage(); err != io.EOF {
+		t.Errorf("close frame read as %v", err)
+	}
+}
+
+func TestReadMessageTooLarge(t *testing.T) {
+	conn, remote := pipeConn(false)
+	defer remote.Close()
+	go remote.Write([]byte{0x82, 0x7f, 0, 0, 0, 0, 0x10, 0, 0, 0})
+	if _, err := conn.ReadMessage(); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("oversized frame read as %v", err)
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		header  []byte
+	}{
+		{[]byte("Hello"), []byte{0x81, 0x05}},
+		{bytes.Repeat([]byte("x"), 256), []byte{0x81, 0x7e, 0x01, 0x00}},
+		{bytes.Repeat([]byte("y"), 65536), []byte{0x81, 0x7f, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00}},
+	}
+	for _, test := range tests {
+		for _, client := range []bool{false, true} {
+			conn, remote := pipeConn(client)
+			go conn.WriteMessage(test.payload)
+
+			header := make([]byte, len(test.header))
+			io.ReadFull(remote, header)
+			want := append([]byte{}, test.header...)
+			if client {
+				// Clients set the mask bit and send the masking key
+				want[1] |= 0x80
+			}
+			if !bytes.Equal(header, want) {
+				t.Errorf("client %v, %d bytes: header % x, want % x", client, len(test.payload), header, want)
+			}
+			var mask [4]byte
+			if client {
+				io.ReadFull(remote, mask[:])
+			}
+			payload := make([]byte, len(test.payload))
+			io.ReadFull(remote, payload)
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+			if !bytes.Equal(payload, test.payload) {
+				t.Errorf("client %v, %d bytes: payload differs", client, len(test.payload))
+			}
+			remote.Close()
+		}
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := AcceptWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		message, err := conn.ReadMessage()
+		if err == nil {
+			conn.WriteMessage(append([]byte("echo "), message...))
+		}
+	}))
+	defer server.Close()
+
+	conn, err := DialWebSocket("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage([]byte(`{"method":"eth_chainId"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := conn.ReadMessage(); err != nil || string(got) != `echo {"method":"eth_chainId"}` {
+		t.Errorf("read %q, %v", got, err)
+	}
+}
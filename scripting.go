@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"go.starlark.net/starlark"
+)
+
+// Scripts are Starlark files defining process(results), where results maps each call
+// name to a dict of its outputs by name and by index. Whatever process returns is
+// printed, strings as they are and other values in Starlark syntax.
+//
+//	def process(results):
+//	    decimals = results["decimals"][0]
+//	    return "balance: " + fixed(results["balance"]["amount"], decimals)
+
+// Function to run the process function of a script over the results of one or more calls
+func runScript(path string, results *starlark.Dict, opts formatOptions) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read script: %v", err)
+	}
+
+	thread := &starlark.Thread{
+		Name:  path,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(os.Stdout, msg) },
+	}
+	predeclared := starlark.StringDict{
+		"fixed": starlark.NewBuiltin("fixed", scriptFixed),
+		"label": starlark.NewBuiltin("label", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var address string
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &address); err != nil {
+				return nil, err
+			}
+			return starlark.String(labelAddress(address, opts)), nil
+		}),
+	}
+	globals, err := starlark.ExecFile(thread, path, src, predeclared)
+	if err != nil {
+		return fmt.Errorf("script error: %v", err)
+	}
+
+	process, ok := globals["process"].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("script %s does not define process(results)", path)
+	}
+	output, err := starlark.Call(thread, process, starlark.Tuple{results}, nil)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return fmt.Errorf("script error: %s", evalErr.Backtrace())
+		}
+		return fmt.Errorf("script error: %v", err)
+	}
+	switch v := output.(type) {
+	case starlark.NoneType:
+	case starlark.String:
+		fmt.Println(string(v))
+	default:
+		fmt.Println(v.String())
+	}
+	return nil
+}
+
+// Function implementing fixed(n, decimals), which renders an integer scaled down by 10^decimals
+func scriptFixed(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var n starlark.Int
+	var decimals int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &n, &decimals); err != nil {
+		return nil, err
+	}
+	if decimals < 0 || decimals > 77 {
+		return nil, fmt.Errorf("%s: decimals must be between 0 and 77", b.Name())
+	}
+	return starlark.String(formatFixed(n.BigInt(), decimals)), nil
+}
+
+// Function to build the script view of a call's results: outputs by name and by index
+func scriptOutputs(values []interface{}, names []string) *starlark.Dict {
+	outputs := starlark.NewDict(len(values) * 2)
+	for i, val := range values {
+		value := toStarlark(val)
+		outputs.SetKey(starlark.MakeInt(i), value)
+		if i < len(names) && names[i] != "" {
+			outputs.SetKey(starlark.String(names[i]), value)
+		}
+	}
+	return outputs
+}
+
+// Function to convert a decoded value for scripts: integers stay numbers, addresses and
+// bytes become hex strings, arrays become lists and tuples dicts
+func toStarlark(val interface{}) starlark.Value {
+	if n, ok := asBigInt(val); ok {
+		return starlark.MakeBigInt(new(big.Int).Set(n))
+	}
+	switch v := val.(type) {
+	case common.Address:
+		return starlark.String(v.Hex())
+	case bool:
+		return starlark.Bool(v)
+	case string:
+		return starlark.String(v)
+	}
+	if b, ok := asBytes(val); ok {
+		return starlark.String(hexutil.Encode(b))
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make([]starlark.Value, rv.Len())
+		for i := range list {
+			list[i] = toStarlark(rv.Index(i).Interface())
+		}
+		return starlark.NewList(list)
+	case reflect.Struct:
+		dict := starlark.NewDict(rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			name := []rune(rv.Type().Field(i).Name)
+			name[0] = unicode.ToLower(name[0])
+			dict.SetKey(starlark.String(string(name)), toStarlark(rv.Field(i).Interface()))
+		}
+		return dict
+	}
+	return starlark.String(fmt.Sprint(val))
+}
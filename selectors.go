@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Function to report selector collisions among function signatures, given by ABIs or on
+// the command line, and the signatures missing from the dispatcher of a contract
+func runSelectors(args []string) error {
+	fs := flag.NewFlagSet("selectors", flag.ExitOnError)
+	abiPaths := fs.String("abi", "", "comma-separated ABI or artifact files whose functions are checked")
+	check := fs.String("check", "", "contract address, alias or hex file whose dispatcher must contain the signatures")
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint and deployment")
+	block := fs.String("block", "latest", "block to read the code at")
+	fs.Parse(args)
+
+	var signatures []string
+	if *abiPaths != "" {
+		for _, path := range strings.Split(*abiPaths, ",") {
+			contract, err := loadABI(strings.TrimSpace(path))
+			if err != nil {
+				return err
+			}
+			for _, entry := range contract.Entries {
+				if entry.Type == "function" {
+					signatures = append(signatures, entry.signature())
+				}
+			}
+		}
+	}
+	for _, signature := range fs.Args() {
+		canonical, err := canonicalSignature(signature)
+		if err != nil {
+			return err
+		}
+		signatures = append(signatures, canonical)
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("usage: contract-curler selectors [--abi <files>] [--check <address | hex file>] [signature...]")
+	}
+
+	bySelector := make(map[string][]string)
+	var selectors []string
+	for _, signature := range signatures {
+		selector := "0x" + functionSelector(signature)
+		if _, ok := bySelector[selector]; !ok {
+			selectors = append(selectors, selector)
+		}
+		known := false
+		for _, other := range bySelector[selector] {
+			known = known || other == signature
+		}
+		if !known {
+			bySelector[selector] = append(bySelector[selector], signature)
+		}
+	}
+	sort.Strings(selectors)
+
+	problems := 0
+	for _, selector := range selectors {
+		fmt.Printf("%s  %s\n", selector, strings.Join(bySelector[selector], ", "))
+	}
+	for _, selector := range selectors {
+		if len(bySelector[selector]) > 1 {
+			fmt.Printf("%s: %s share selector %s\n", colorize(colorRed, "Collision"), strings.Join(bySelector[selector], " and "), selector)
+			problems++
+		}
+	}
+
+	if *check != "" {
+		code, err := loadCode(*check, firstNonEmpty(*rpcURL, chainRPC(*chain)), *chain, *block)
+		if err != nil {
+			return err
+		}
+		dispatcher, err := newDispatcherCheck(code)
+		if err != nil {
+			return err
+		}
+		if dispatcher == nil {
+			fmt.Fprintf(os.Stderr, "No selector dispatcher found in the code of %s, or it delegates calls, so its functions cannot be checked\n", *check)
+		} else {
+			for _, selector := range selectors {
+				for _, signature := range bySelector[selector] {
+					if message := dispatcher.missing(signature, signatures); message != "" {
+						fmt.Printf("%s: %s\n", colorize(colorRed, "Missing"), message)
+						problems++
+					}
+				}
+			}
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %s", pluralize(problems, "selector problem"))
+	}
+	return nil
+}
+
+// dispatcherCheck tells whether the dispatcher of a contract handles a signature
+type dispatcherCheck struct {
+	selectors map[string]bool
+	db        *signatureDB
+}
+
+// Function to read the dispatcher of contract code. Code without one, or delegating calls
+// as proxies do, handles selectors it does not list, so no check is returned for it.
+func newDispatcherCheck(code []byte) (*dispatcherCheck, error) {
+	code, _ = splitMetadata(code)
+	instructions := disassemble(code)
+	check := &dispatcherCheck{selectors: make(map[string]bool)}
+	for _, in := range instructions {
+		if in.op == 0xf4 {
+			return nil, nil
+		}
+	}
+	for _, entry := range dispatchTable(instructions) {
+		check.selectors[entry.selector] = true
+	}
+	if len(check.selectors) == 0 {
+		return nil, nil
+	}
+	db, err := loadSignatureDB()
+	if err != nil {
+		return nil, err
+	}
+	check.db = db
+	return check, nil
+}
+
+// Function to explain why a signature is not handled by the dispatcher, suggesting the
+// signatures differing only by case among the known ones and those of its selectors.
+// It returns "" if the dispatcher handles the signature.
+func (d *dispatcherCheck) missing(signature string, known []string) string {
+	selector := "0x" + functionSelector(signature)
+	if d.selectors[selector] {
+		return ""
+	}
+	candidates := append([]string{}, known...)
+	for selector := range d.selectors {
+		candidates = append(candidates, d.db.lookup(selector)...)
+	}
+	var suggestions []string
+	seen := map[string]bool{signature: true}
+	for _, candidate := range candidates {
+		if !seen[candidate] && strings.EqualFold(candidate, signature) && d.selectors["0x"+functionSelector(candidate)] {
+			suggestions = append(suggestions, candidate)
+		}
+		seen[candidate] = true
+	}
+	sort.Strings(suggestions)
+	message := fmt.Sprintf("the dispatcher has no selector %s for %s", selector, signature)
+	if len(suggestions) > 0 {
+		message += ", did you mean " + strings.Join(suggestions, " or ") + "?"
+	}
+	return message
+}
+
+// Function to warn before calling a function the dispatcher of the contract does not
+// handle, which usually means a typo in the signature. Failures to get the code are not
+// reported, the call reporting them.
+func warnUnknownSelector(rpcURL, contract, signature string, known []string) {
+	var code hexutil.Bytes
+	if err := callRPC(rpcURL, &code, "eth_getCode", contract, "latest"); err != nil {
+		return
+	}
+	dispatcher, err := newDispatcherCheck(code)
+	if err != nil || dispatcher == nil {
+		return
+	}
+	if message := dispatcher.missing(signature, known); message != "" {
+		fmt.Println(colorize(colorYellow, "Warning") + ": " + message)
+	}
+}
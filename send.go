@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tip used when the node does not implement eth_maxPriorityFeePerGas
+const defaultPriorityFee = 1500000000
+
+// txReceipt holds the fields of a transaction receipt needed to report its outcome
+type txReceipt struct {
+	BlockNumber       hexutil.Uint64 `json:"blockNumber"`
+	BlockHash         string         `json:"blockHash"`
+	Status            hexutil.Uint64 `json:"status"`
+	GasUsed           hexutil.Uint64 `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big   `json:"effectiveGasPrice"`
+	ContractAddress   *string        `json:"contractAddress"`
+	Logs              []LogEntry     `json:"logs"`
+}
+
+// waitOptions control how long and how deep a transaction is followed once broadcast
+type waitOptions struct {
+	confirmations *uint64
+	timeout       *time.Duration
+	interval      *time.Duration
+	abiPaths      *string
+}
+
+// Function to register the flags controlling the wait for a transaction
+func addWaitFlags(fs *flag.FlagSet) *waitOptions {
+	return &waitOptions{
+		confirmations: fs.Uint64("confirmations", 1, "number of blocks, including the one mining the transaction, to wait for"),
+		timeout:       fs.Duration("timeout", 5*time.Minute, "time to wait for the confirmations before giving up"),
+		interval:      fs.Duration("interval", 3*time.Second, "polling interval of the receipt"),
+		abiPaths:      fs.String("abi", "", "comma-separated ABI files used to decode the logs, in addition to the configured contracts"),
+	}
+}
+
+// Function to sign a call as a transaction, broadcast it and wait for its confirmations
+func runSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	call := addCallFlags(fs)
+	keyFile := fs.String("key-file", "", "file holding the hex private key to sign with (default: $"+privateKeyEnv+")")
+	nonce := fs.Int64("nonce", -1, "nonce of the transaction (default: the next pending nonce of the sender)")
+	gasLimit := fs.Uint64("gas", 0, "gas limit (default: estimated)")
+	maxFee := fs.String("max-fee", "", "max fee per gas, e.g. 40gwei (default: twice the base fee plus the tip)")
+	priorityFee := fs.String("priority-fee", "", "max priority fee per gas, e.g. 2gwei (default: eth_maxPriorityFeePerGas)")
+	noWait := fs.Bool("no-wait", false, "return once the transaction is broadcast")
+	yes := fs.Bool("yes", false, "send without asking for confirmation")
+	wait := addWaitFlags(fs)
+	walletConnect := addWalletConnectFlags(fs)
+	fs.Parse(args)
+
+	if err := requireWritable("send"); err != nil {
+		return err
+	}
+	var key *ecdsa.PrivateKey
+	if !*walletConnect.enabled {
+		var err error
+		if key, err = loadSigningKey(*keyFile, false); err != nil {
+			return err
+		}
+		if key == nil {
+			return fmt.Errorf("a signing key is required (--key-file, $%s or --walletconnect)", privateKeyEnv)
+		}
+	}
+	rpcURL := call.endpoint()
+	callObject, err := call.callObject(fs.Args())
+	if err != nil {
+		return err
+	}
+	if _, ok := callObject["eip712Meta"]; ok {
+		return fmt.Errorf("sending zkSync paymaster transactions is not supported")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	chainID, err := cfg.chainID(*call.chain, rpcURL)
+	if err != nil {
+		return err
+	}
+	wallet, err := walletConnect.connect(chainID)
+	if err != nil {
+		return err
+	}
+	var from common.Address
+	if wallet != nil {
+		defer wallet.Close()
+		from = wallet.account
+	} else {
+		from = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	callObject["from"] = from.Hex()
+
+	if *nonce < 0 {
+		var pending hexutil.Uint64
+		if err := callRPC(rpcURL, &pending, "eth_getTransactionCount", from.Hex(), "pending"); err != nil {
+			return fmt.Errorf("failed to get nonce of %s: %v", from.Hex(), err)
+		}
+		*nonce = int64(pending)
+	}
+	if *gasLimit == 0 {
+		var estimate hexutil.Uint64
+		if err := callRPC(rpcURL, &estimate, "eth_estimateGas", callObject); err != nil {
+			return fmt.Errorf("eth_estimateGas failed, the transaction would likely revert: %v", err)
+		}
+		*gasLimit = uint64(estimate)
+	}
+	tip, feeCap, err := suggestFees(rpcURL, *priorityFee, *maxFee)
+	if err != nil {
+		return err
+	}
+
+	to := common.HexToAddress(callObject["to"].(string))
+	value := new(big.Int)
+	if v, ok := callObject["value"].(string); ok {
+		if value, err = hexutil.DecodeBig(v); err != nil {
+			return fmt.Errorf("invalid value '%s': %v", v, err)
+		}
+	}
+	var data []byte
+	if d, ok := callObject["data"].(string); ok {
+		if data, err = hexutil.Decode(d); err != nil {
+			return fmt.Errorf("invalid calldata: %v", err)
+		}
+	}
+	tx := newTransaction(chainID, uint64(*nonce), &to, value, *gasLimit, tip, feeCap, data, nil)
+
+	confirmOptions.yes, confirmOptions.signature, confirmOptions.abiPaths = *yes, *call.sig, *wait.abiPaths
+	var hash string
+	if wallet != nil {
+		// The wallet signs and broadcasts the transaction itself
+		if err := enforcePolicies(chainID, &to, value, data); err != nil {
+			return err
+		}
+		if err := confirmTransaction(rpcURL, chainID, from, tx); err != nil {
+			return err
+		}
+		if err := wallet.request("eth_sendTransaction", []interface{}{walletTransaction(from, tx)}, &hash); err != nil {
+			return err
+		}
+		sentNonce := tx.Nonce()
+		auditWalletTransaction(rpcURL, chainID, from, &to, &sentNonce, hash)
+	} else {
+		signed, err := signTransaction(tx, chainID, key)
+		if err != nil {
+			return err
+		}
+		if hash, err = broadcastTransaction(rpcURL, signed); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Transaction:", hash)
+	if *noWait {
+		return nil
+	}
+	return waitAndReport(rpcURL, hash, wait, cfg)
+}
+
+// Function to wait for a transaction broadcast elsewhere and report its outcome
+func runWait(args []string) error {
+	fs := flag.NewFlagSet("wait", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	wait := addWaitFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler wait [flags] <tx hash>")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	return waitAndReport(firstNonEmpty(*rpcURL, chainRPC(*chain)), fs.Arg(0), wait, cfg)
+}
+
+// Function to build an EIP-1559 transaction, or a legacy one when no fee cap is set because
+// the chain has no base fee
+func newTransaction(chainID, nonce uint64, to *common.Address, value *big.Int, gas uint64, tip, feeCap *big.Int, data []byte, accessList types.AccessList) *types.Transaction {
+	if feeCap == nil {
+		return types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: tip, Gas: gas, To: to, Value: value, Data: data})
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID: new(big.Int).SetUint64(chainID), Nonce: nonce, GasTipCap: tip, GasFeeCap: feeCap,
+		Gas: gas, To: to, Value: value, Data: data, AccessList: accessList,
+	})
+}
+
+// Function to describe a transaction as the eth_sendTransaction object a wallet signs
+func walletTransaction(from common.Address, tx *types.Transaction) map[string]interface{} {
+	object := map[string]interface{}{
+		"from":  from.Hex(),
+		"value": hexutil.EncodeBig(tx.Value()),
+		"data":  hexutil.Encode(tx.Data()),
+		"gas":   hexutil.EncodeUint64(tx.Gas()),
+		"nonce": hexutil.EncodeUint64(tx.Nonce()),
+	}
+	if tx.To() != nil {
+		object["to"] = tx.To().Hex()
+	}
+	if tx.Type() == types.LegacyTxType {
+		object["gasPrice"] = hexutil.EncodeBig(tx.GasPrice())
+	} else {
+		object["maxFeePerGas"] = hexutil.EncodeBig(tx.GasFeeCap())
+		object["maxPriorityFeePerGas"] = hexutil.EncodeBig(tx.GasTipCap())
+	}
+	return object
+}
+
+// Function to sign a transaction for a chain, once the configured policies allow it
+func signTransaction(tx *types.Transaction, chainID uint64, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	if err := enforcePolicies(chainID, tx.To(), tx.Value(), tx.Data()); err != nil {
+		return nil, err
+	}
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(new(big.Int).SetUint64(chainID)), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	return signed, nil
+}
+
+// Function to broadcast a signed transaction once it is confirmed, returning its hash
+func broadcastTransaction(rpcURL string, tx *types.Transaction) (string, error) {
+	if err := requireWritable("broadcasting transactions"); err != nil {
+		return "", err
+	}
+	chainID := uint64(0)
+	if tx.Protected() {
+		chainID = tx.ChainId().Uint64()
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return "", fmt.Errorf("invalid transaction signature: %v", err)
+	}
+	if err := confirmTransaction(rpcURL, chainID, from, tx); err != nil {
+		return "", err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transaction: %v", err)
+	}
+	// The transport records the transaction in the audit log before sending it
+	var hash string
+	if err := callRPC(rpcURL, &hash, "eth_sendRawTransaction", hexutil.Encode(raw)); err != nil {
+		return "", fmt.Errorf("failed to broadcast transaction: %v", err)
+	}
+	return hash, nil
+}
+
+// Function to pick the tip and fee cap of a transaction. The fee cap is nil on chains
+// without a base fee, where the tip is the legacy gas price.
+func suggestFees(rpcURL, priorityFee, maxFee string) (*big.Int, *big.Int, error) {
+	var header struct {
+		BaseFeePerGas *hexutil.Big `json:"baseFeePerGas"`
+	}
+	if err := callRPC(rpcURL, &header, "eth_getBlockByNumber", "latest", false); err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch latest block: %v", err)
+	}
+	if header.BaseFeePerGas == nil {
+		if maxFee != "" {
+			gasPrice, err := parseValue(maxFee)
+			return gasPrice, nil, err
+		}
+		var gasPrice hexutil.Big
+		if err := callRPC(rpcURL, &gasPrice, "eth_gasPrice"); err != nil {
+			return nil, nil, fmt.Errorf("eth_gasPrice failed: %v", err)
+		}
+		return (*big.Int)(&gasPrice), nil, nil
+	}
+
+	tip := big.NewInt(defaultPriorityFee)
+	if priorityFee != "" {
+		parsed, err := parseValue(priorityFee)
+		if err != nil {
+			return nil, nil, err
+		}
+		tip = parsed
+	} else {
+		var suggested hexutil.Big
+		if err := callRPC(rpcURL, &suggested, "eth_maxPriorityFeePerGas"); err == nil {
+			tip = (*big.Int)(&suggested)
+		}
+	}
+	// Twice the base fee survives six full blocks of base fee increases
+	feeCap := new(big.Int).Add(new(big.Int).Mul((*big.Int)(header.BaseFeePerGas), big.NewInt(2)), tip)
+	if maxFee != "" {
+		parsed, err := parseValue(maxFee)
+		if err != nil {
+			return nil, nil, err
+		}
+		feeCap = parsed
+	}
+	if feeCap.Cmp(tip) < 0 {
+		return nil, nil, fmt.Errorf("max fee %s gwei is below the priority fee %s gwei", formatFixed(feeCap, 9), formatFixed(tip, 9))
+	}
+	return tip, feeCap, nil
+}
+
+// Function to wait for a transaction and print its outcome and decoded logs, failing when
+// it reverted
+func waitAndReport(rpcURL, hash string, wait *waitOptions, cfg *Config) error {
+	receipt, err := waitForReceipt(rpcURL, hash, *wait.confirmations, *wait.timeout, *wait.interval)
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, rpcURL)
+	fee := new(big.Int).SetUint64(uint64(receipt.GasUsed))
+	if receipt.EffectiveGasPrice != nil {
+		fee.Mul(fee, (*big.Int)(receipt.EffectiveGasPrice))
+	}
+	fmt.Printf("Gas used: %d (fee %s ETH)\n", receipt.GasUsed, formatFixed(fee, 18))
+	if receipt.ContractAddress != nil && *receipt.ContractAddress != "" {
+		fmt.Println("Contract created:", *receipt.ContractAddress)
+	}
+	if receipt.Status != 1 {
+		return fmt.Errorf("transaction %s reverted", hash)
+	}
+	fmt.Println("✓ success")
+
+	if len(receipt.Logs) > 0 {
+		decoder, err := configDecoder(cfg, *wait.abiPaths)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nLogs (%d):\n", len(receipt.Logs))
+		for _, entry := range receipt.Logs {
+			fmt.Printf("  %s %s\n", formatAddress(common.HexToAddress(entry.Address), opts), decoder.describeLog(entry, opts))
+		}
+	}
+	return nil
+}
+
+// Function to poll for the receipt of a transaction until it has enough confirmations,
+// printing each transition: pending, mined, confirmations, and reorgs moving it
+func waitForReceipt(rpcURL, hash string, confirmations uint64, timeout, interval time.Duration) (*txReceipt, error) {
+	deadline := time.Now().Add(timeout)
+	state, minedIn := "", ""
+	var confirmed uint64
+	for {
+		var receipt *txReceipt
+		if err := callRPC(rpcURL, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+			return nil, fmt.Errorf("failed to fetch receipt: %v", err)
+		}
+		switch {
+		case receipt == nil:
+			next := "pending"
+			var tx map[string]interface{}
+			if err := callRPC(rpcURL, &tx, "eth_getTransactionByHash", hash); err == nil && tx == nil {
+				next = "not found by the node (not propagated yet, or dropped)"
+			}
+			if minedIn != "" {
+				fmt.Fprintf(os.Stderr, "Block %s was reorged out, the transaction is pending again\n", minedIn)
+				minedIn, confirmed = "", 0
+			}
+			if next != state {
+				fmt.Fprintln(os.Stderr, "Status: "+next)
+				state = next
+			}
+		default:
+			if receipt.BlockHash != minedIn {
+				verb := "mined"
+				if minedIn != "" {
+					verb = "re-mined after a reorg"
+				}
+				fmt.Fprintf(os.Stderr, "Status: %s in block %d\n", verb, receipt.BlockNumber)
+				minedIn, state, confirmed = receipt.BlockHash, "mined", 0
+			}
+			head, err := latestBlockNumber(rpcURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch latest block: %v", err)
+			}
+			if head >= uint64(receipt.BlockNumber) {
+				if depth := head - uint64(receipt.BlockNumber) + 1; depth != confirmed {
+					confirmed = depth
+					// A receipt first seen deeper than required is fully confirmed
+					fmt.Fprintf(os.Stderr, "Confirmations: %d/%d\n", min(confirmed, confirmations), confirmations)
+				}
+			}
+			if confirmed >= confirmations {
+				return receipt, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for %s (%s)", timeout, hash, state)
+		}
+		time.Sleep(interval)
+	}
+}
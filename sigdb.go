@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// First page of the 4byte.directory function signature listing
+const fourByteSignaturesURL = "https://www.4byte.directory/api/v1/signatures/"
+
+// signatureDB maps 4-byte selectors to the text signatures known for them
+type signatureDB struct {
+	// Highest 4byte.directory id synced so far
+	LastID int
+	// Page of the listing, in ascending id order, the last sync stopped on and the next
+	// one resumes from
+	Page       string
+	Signatures map[string][]string
+}
+
+// Function to get the path of the local signature database
+func sigdbPath() string {
+	return filepath.Join(configDir(), "signatures.tsv")
+}
+
+// Function to load the local signature database, returning an empty one if none exists
+func loadSignatureDB() (*signatureDB, error) {
+	db := &signatureDB{Signatures: make(map[string][]string)}
+	file, err := os.Open(sigdbPath())
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signature database: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# last-id ") {
+			db.LastID, _ = strconv.Atoi(strings.TrimPrefix(line, "# last-id "))
+			continue
+		}
+		if strings.HasPrefix(line, "# page ") {
+			db.Page = strings.TrimPrefix(line, "# page ")
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) == 2 {
+			db.Signatures[fields[0]] = append(db.Signatures[fields[0]], fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read signature database: %v", err)
+	}
+	return db, nil
+}
+
+// Function to write the signature database sorted by selector, replacing the old file atomically
+func (db *signatureDB) save() error {
+	selectors := make([]string, 0, len(db.Signatures))
+	for selector := range db.Signatures {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# last-id %d\n", db.LastID)
+	if db.Page != "" {
+		fmt.Fprintf(&b, "# page %s\n", db.Page)
+	}
+	for _, selector := range selectors {
+		for _, signature := range db.Signatures[selector] {
+			fmt.Fprintf(&b, "%s\t%s\n", selector, signature)
+		}
+	}
+
+	if err := os.MkdirAll(configDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	tmpPath := sigdbPath() + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write signature database: %v", err)
+	}
+	if err := os.Rename(tmpPath, sigdbPath()); err != nil {
+		return fmt.Errorf("failed to write signature database: %v", err)
+	}
+	return nil
+}
+
+// Function to add a signature, reporting whether it was new
+func (db *signatureDB) add(signature string) bool {
+	signature = strings.ReplaceAll(strings.TrimSpace(signature), " ", "")
+	selector := "0x" + functionSelector(signature)
+	for _, known := range db.Signatures[selector] {
+		if known == signature {
+			return false
+		}
+	}
+	db.Signatures[selector] = append(db.Signatures[selector], signature)
+	return true
+}
+
+// Function to find the signatures known for a selector
+func (db *signatureDB) lookup(selector string) []string {
+	selector = strings.ToLower(selector)
+	if !strings.HasPrefix(selector, "0x") {
+		selector = "0x" + selector
+	}
+	return db.Signatures[selector]
+}
+
+// Function to maintain and query the local signature database
+func runSigdb(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler sigdb sync|import|lookup|stats")
+	}
+
+	db, err := loadSignatureDB()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "sync":
+		fs := flag.NewFlagSet("sigdb sync", flag.ExitOnError)
+		pages := fs.Int("pages", 100, "maximum number of pages to fetch, 0 for no limit")
+		fs.Parse(args[1:])
+		return syncFourByte(db, fourByteSignaturesURL, *pages)
+	case "import":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: contract-curler sigdb import <file>")
+		}
+		return importSignatures(db, args[1])
+	case "lookup":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: contract-curler sigdb lookup <selector>")
+		}
+		signatures := db.lookup(args[1])
+		if len(signatures) == 0 {
+			return fmt.Errorf("no signature known for %s", args[1])
+		}
+		for _, signature := range signatures {
+			fmt.Println(signature)
+		}
+		return nil
+	case "stats":
+		count := 0
+		for _, signatures := range db.Signatures {
+			count += len(signatures)
+		}
+		fmt.Printf("Database: %s\n", sigdbPath())
+		fmt.Printf("Selectors: %d\nSignatures: %d\nLast synced 4byte id: %d\n", len(db.Signatures), count, db.LastID)
+		return nil
+	}
+	return fmt.Errorf("usage: contract-curler sigdb sync|import|lookup|stats")
+}
+
+// Function to fetch the signatures of 4byte.directory in ascending id order, resuming from
+// the page the last sync stopped on. Signatures are only ever added after the last page,
+// so a sync stopped by the page limit leaves no gap: the next one picks up where it was.
+func syncFourByte(db *signatureDB, listURL string, maxPages int) error {
+	var page struct {
+		Next    string `json:"next"`
+		Results []struct {
+			ID            int    `json:"id"`
+			TextSignature string `json:"text_signature"`
+		} `json:"results"`
+	}
+
+	url := firstNonEmpty(db.Page, listURL+"?ordering=id")
+	added := 0
+	for fetched := 0; ; fetched++ {
+		if maxPages > 0 && fetched == maxPages {
+			fmt.Fprintf(os.Stderr, "Stopped after %d pages, sync again to continue\n", fetched)
+			break
+		}
+		resp, err := httpClient.Get(url)
+		if err != nil {
+			return fmt.Errorf("failed to fetch signatures: %v", err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read signatures: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to fetch signatures: HTTP %d", resp.StatusCode)
+		}
+		page.Next = ""
+		page.Results = nil
+		if err := json.Unmarshal(body, &page); err != nil {
+			return fmt.Errorf("failed to parse signatures: %v", err)
+		}
+
+		for _, result := range page.Results {
+			if result.ID > db.LastID {
+				db.LastID = result.ID
+			}
+			if db.add(result.TextSignature) {
+				added++
+			}
+		}
+		db.Page = url
+		fmt.Fprintf(os.Stderr, "Fetched page %d (%d new signatures)\n", fetched+1, added)
+		// The last page is fetched again by the next sync, for what was added to it since
+		if page.Next == "" {
+			break
+		}
+		url = page.Next
+	}
+
+	if err := db.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Added %d signatures to %s\n", added, sigdbPath())
+	return nil
+}
+
+// Function to import signatures from a file with one signature per line,
+// optionally preceded by its selector as in "0xa9059cbb,transfer(address,uint256)"
+func importSignatures(db *signatureDB, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %v", path, err)
+	}
+	defer file.Close()
+
+	added := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		open := strings.Index(line, "(")
+		if open < 0 {
+			continue
+		}
+		// The selector is recomputed, so anything before the signature is dropped
+		if i := strings.IndexAny(line[:open], ",\t"); i >= 0 {
+			line = line[i+1:]
+		}
+		if db.add(line) {
+			added++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read '%s': %v", path, err)
+	}
+
+	if err := db.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d signatures into %s\n", added, sigdbPath())
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// Function to serve a signature listing of two per page in ascending id order, as
+// 4byte.directory does with ordering=id
+func fourByteServer(t *testing.T, signatures *[]string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ordering") != "id" {
+			t.Errorf("listing fetched in %q order", r.URL.Query().Get("ordering"))
+		}
+		number, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		number = max(number, 1)
+		type result struct {
+			ID            int    `json:"id"`
+			TextSignature string `json:"text_signature"`
+		}
+		var page struct {
+			Next    string   `json:"next"`
+			Results []result `json:"results"`
+		}
+		page.Results = []result{}
+		for i := (number - 1) * 2; i < len(*signatures) && i < number*2; i++ {
+			page.Results = append(page.Results, result{ID: i + 1, TextSignature: (*signatures)[i]})
+		}
+		if number*2 < len(*signatures) {
+			page.Next = server.URL + "/?ordering=id&page=" + strconv.Itoa(number+1)
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	return server
+}
+
+func TestSyncFourByteResumes(t *testing.T) {
+	t.Setenv("CONTRACT_CURLER_HOME", t.TempDir())
+	signatures := []string{"a()", "b()", "c()", "d()", "e()"}
+	server := fourByteServer(t, &signatures)
+	defer server.Close()
+
+	db := &signatureDB{Signatures: make(map[string][]string)}
+	if err := syncFourByte(db, server.URL+"/", 2); err != nil {
+		t.Fatal(err)
+	}
+	if db.LastID != 4 || db.lookup(functionSelector("e()")) != nil {
+		t.Fatalf("sync stopped at the page limit with last id %d", db.LastID)
+	}
+
+	// Signatures added meanwhile land after the last page, and the sync resumes from disk
+	signatures = append(signatures, "f()")
+	db, err := loadSignatureDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncFourByte(db, server.URL+"/", 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, signature := range signatures {
+		if len(db.lookup(functionSelector(signature))) != 1 {
+			t.Errorf("%s was not synced", signature)
+		}
+	}
+	if db.LastID != len(signatures) {
+		t.Errorf("last id %d, want %d", db.LastID, len(signatures))
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Values returned by isValidSignature(bytes32,bytes), and by the isValidSignature(bytes,bytes)
+// of the draft standard, when a contract accepts a signature
+const (
+	eip1271MagicValue       = "0x1626ba7e"
+	eip1271LegacyMagicValue = "0x20c13b0b"
+)
+
+// signedPayload is what a signature was made over, given as a message or a digest
+type signedPayload struct {
+	message     *string
+	messageFile *string
+	hash        *string
+}
+
+// Function to register the flags describing the signed payload
+func addPayloadFlags(fs *flag.FlagSet) *signedPayload {
+	return &signedPayload{
+		message:     fs.String("message", "", "message signed with personal_sign (EIP-191), as text or 0x-prefixed bytes"),
+		messageFile: fs.String("message-file", "", "file holding the message signed with personal_sign, e.g. a multi-line Sign-In with Ethereum message"),
+		hash:        fs.String("hash", "", "32-byte digest that was signed directly, e.g. an EIP-712 hash"),
+	}
+}
+
+// Function to compute the digest that was signed
+func (p *signedPayload) digest() (common.Hash, error) {
+	given := 0
+	for _, value := range []string{*p.message, *p.messageFile, *p.hash} {
+		if value != "" {
+			given++
+		}
+	}
+	switch {
+	case given > 1:
+		return common.Hash{}, fmt.Errorf("--message, --message-file and --hash are mutually exclusive")
+	case *p.message != "":
+		return personalMessageHash(*p.message), nil
+	case *p.messageFile != "":
+		// Signed as the bytes of the file, which are never taken for hex
+		data, err := ioutil.ReadFile(*p.messageFile)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("failed to read message file: %v", err)
+		}
+		return personalMessageHash(hexutil.Encode(data)), nil
+	case *p.hash != "":
+		hash, err := hexutil.Decode(*p.hash)
+		if err != nil || len(hash) != 32 {
+			return common.Hash{}, fmt.Errorf("--hash must be 32 bytes of hex")
+		}
+		return common.BytesToHash(hash), nil
+	}
+	return common.Hash{}, fmt.Errorf("--message, --message-file or --hash is required")
+}
+
+// Function to hash a message the way personal_sign does. Messages given as 0x-prefixed hex
+// are signed as the bytes they encode, like wallets do.
+func personalMessageHash(message string) common.Hash {
+	data := []byte(message)
+	if decoded, err := hexutil.Decode(message); err == nil {
+		data = decoded
+	}
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(data))
+	return crypto.Keccak256Hash([]byte(prefix), data)
+}
+
+// Function to decode a 65-byte r||s||v signature, or a 64-byte EIP-2098 compact one,
+// returning it with v normalised to 0 or 1
+func decodeSignature(s string) ([]byte, error) {
+	sig, err := hexutil.Decode(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	switch len(sig) {
+	case 65:
+		sig = append([]byte(nil), sig...)
+		if sig[64] >= 27 {
+			sig[64] -= 27
+		}
+	case 64:
+		// The top bit of s carries the parity of y
+		v := sig[32] >> 7
+		sig = append(append([]byte(nil), sig...), v)
+		sig[32] &= 0x7f
+	default:
+		return nil, fmt.Errorf("signature must be 64 or 65 bytes, got %d", len(sig))
+	}
+	if sig[64] > 1 {
+		return nil, fmt.Errorf("invalid recovery id %d", sig[64])
+	}
+	return sig, nil
+}
+
+// Function to recover the address that produced a signature over a digest
+func recoverSigner(hash common.Hash, sig []byte) (common.Address, error) {
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover signer: %v", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Function to print the address that signed a message or digest
+func runRecover(args []string) error {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	payload := addPayloadFlags(fs)
+	signature := fs.String("signature", "", "signature to recover the signer of")
+	fs.Parse(args)
+
+	hash, err := payload.digest()
+	if err != nil {
+		return err
+	}
+	sig, err := decodeSignature(*signature)
+	if err != nil {
+		return err
+	}
+	signer, err := recoverSigner(hash, sig)
+	if err != nil {
+		return err
+	}
+	fmt.Println(signer.Hex())
+	return nil
+}
+
+// Function to sign a message with personal_sign (EIP-191 version 0x45), as wallets and
+// the eth_sign of current nodes do, or a digest directly, as the eth_sign of old nodes did
+func runSignMessage(args []string) error {
+	fs := flag.NewFlagSet("sign-message", flag.ExitOnError)
+	payload := addPayloadFlags(fs)
+	keyFile := fs.String("key-file", "", "file holding the hex private key to sign with (default: $"+privateKeyEnv+")")
+	compact := fs.Bool("compact", false, "print the 64-byte EIP-2098 compact signature instead of the 65-byte one")
+	fs.Parse(args)
+
+	if err := requireWritable("sign-message"); err != nil {
+		return err
+	}
+
+	key, err := loadSigningKey(*keyFile, false)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("a signing key is required (--key-file or $%s)", privateKeyEnv)
+	}
+	hash, err := payload.digest()
+	if err != nil {
+		return err
+	}
+	sig, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %v", err)
+	}
+
+	fmt.Println("Signer:   ", crypto.PubkeyToAddress(key.PublicKey).Hex())
+	fmt.Println("Digest:   ", hash.Hex())
+	if *compact {
+		// The parity of y goes in the top bit of s
+		compacted := append([]byte(nil), sig[:64]...)
+		compacted[32] |= sig[64] << 7
+		fmt.Println("Signature:", hexutil.Encode(compacted))
+		return nil
+	}
+	// Wallets report v as 27 or 28
+	sig[64] += 27
+	fmt.Println("Signature:", hexutil.Encode(sig))
+	return nil
+}
+
+// Function to check that a signature was made by an address. Signatures of accounts
+// are checked by recovery, those of contract wallets with EIP-1271 isValidSignature.
+func runVerifySig(args []string) error {
+	fs := flag.NewFlagSet("verify-sig", flag.ExitOnError)
+	payload := addPayloadFlags(fs)
+	signature := fs.String("signature", "", "signature to verify")
+	address := fs.String("address", "", "expected signer, an account or a contract wallet")
+	rpcURL := fs.String("rpc", defaultRPC(), "Ethereum RPC URL, used for contract wallets")
+	block := fs.String("block", "latest", "block to check contract wallet signatures at")
+	wallet := fs.Bool("wallet", false, "check with EIP-1271 only, treating the address as a contract wallet")
+	fs.Parse(args)
+
+	if *address == "" {
+		return fmt.Errorf("--address is required")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	expected, err := cfg.resolveAddress(*address, "", *rpcURL)
+	if err != nil {
+		return err
+	}
+	hash, err := payload.digest()
+	if err != nil {
+		return err
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(*signature))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	// Contract wallets may use signatures of any length, so only recover standard ones
+	if sig, err := decodeSignature(*signature); err == nil && !*wallet {
+		if signer, err := recoverSigner(hash, sig); err == nil && signer == expected {
+			fmt.Printf("✓ valid signature by %s (recovered)\n", expected.Hex())
+			return nil
+		}
+	}
+
+	var code string
+	if err := callRPC(*rpcURL, &code, "eth_getCode", expected.Hex(), *block); err != nil {
+		return fmt.Errorf("failed to get code of %s: %v", expected.Hex(), err)
+	}
+	if len(code) <= 2 {
+		if *wallet {
+			return fmt.Errorf("%s has no code, it is not a contract wallet", expected.Hex())
+		}
+		if sig, err := decodeSignature(*signature); err == nil {
+			if signer, err := recoverSigner(hash, sig); err == nil {
+				return fmt.Errorf("signature was made by %s, not %s", signer.Hex(), expected.Hex())
+			}
+		}
+		return fmt.Errorf("signature is not valid for %s", expected.Hex())
+	}
+
+	valid, detail := checkEIP1271(*rpcURL, expected, hash, raw, *block)
+	if !valid {
+		return fmt.Errorf("contract wallet %s rejected the signature: %s", expected.Hex(), detail)
+	}
+	fmt.Printf("✓ valid signature by contract wallet %s (%s)\n", expected.Hex(), detail)
+	return nil
+}
+
+// Function to ask a contract wallet whether it accepts a signature over a digest, returning
+// the verdict and how the wallet's answer was interpreted. Wallets following the draft of
+// the standard, like early Gnosis Safes, are asked through isValidSignature(bytes,bytes).
+func checkEIP1271(rpcURL string, wallet common.Address, hash common.Hash, sig []byte, block string) (bool, string) {
+	valid, detail := callIsValidSignature(rpcURL, wallet, "isValidSignature(bytes32,bytes)", hash.Hex(), sig, eip1271MagicValue, block)
+	if valid {
+		return true, "EIP-1271, " + detail
+	}
+	if legacyValid, legacyDetail := callIsValidSignature(rpcURL, wallet, "isValidSignature(bytes,bytes)", hash.Hex(), sig, eip1271LegacyMagicValue, block); legacyValid {
+		return true, "legacy EIP-1271, " + legacyDetail
+	}
+	return false, detail
+}
+
+// Function to call one variant of isValidSignature and interpret what it returns
+func callIsValidSignature(rpcURL string, wallet common.Address, signature, hash string, sig []byte, magic, block string) (bool, string) {
+	data, err := encodeMethodCall(signature, []string{hash, hexutil.Encode(sig)})
+	if err != nil {
+		return false, err.Error()
+	}
+	var output string
+	call := map[string]interface{}{"to": wallet.Hex(), "data": data}
+	if err := callRPC(rpcURL, &output, "eth_call", call, block); err != nil {
+		return false, fmt.Sprintf("%s reverted: %v", signature, err)
+	}
+	result, err := hexutil.Decode(output)
+	switch {
+	case err != nil || len(result) == 0:
+		return false, fmt.Sprintf("the contract does not implement %s", signature)
+	case len(result) >= 4 && bytes.Equal(result[:4], hexutil.MustDecode(magic)):
+		return true, "returned the magic value " + magic
+	case len(result) >= 4 && bytes.Equal(result[:4], []byte{0xff, 0xff, 0xff, 0xff}):
+		return false, fmt.Sprintf("%s returned 0xffffffff, an explicit rejection", signature)
+	}
+	returned := result
+	if len(returned) > 4 {
+		returned = returned[:4]
+	}
+	return false, fmt.Sprintf("%s returned %s instead of the magic value %s", signature, hexutil.Encode(returned), magic)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// callFlags describe a simulated call and are shared by the simulation commands
+type callFlags struct {
+	rpcURL *string
+	chain  *string
+	from   *string
+	to     *string
+	data   *string
+	sig    *string
+	value  *string
+	block  *string
+	// zkSync paymaster sponsoring the call and its input
+	paymaster      *string
+	paymasterInput *string
+}
+
+// Function to register the call description flags on a flag set
+func addCallFlags(fs *flag.FlagSet) *callFlags {
+	return &callFlags{
+		rpcURL: fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")"),
+		chain:  fs.String("chain", "", "chain name or id, selecting its endpoint profile and contract deployments"),
+		from:   fs.String("from", "", "sender of the call"),
+		to:     fs.String("to", "", "address, contract alias or label of the contract to call"),
+		data:   fs.String("data", "", "raw calldata, instead of --sig and arguments"),
+		sig:    fs.String("sig", "", "function signature, with its arguments given after the flags"),
+		value:  fs.String("value", "", "wei sent with the call, or an amount like 1.5eth"),
+		block:  fs.String("block", "latest", "block to simulate the call at"),
+
+		paymaster:      fs.String("paymaster", "", "zkSync paymaster paying for the call"),
+		paymasterInput: fs.String("paymaster-input", "0x", "input passed to the zkSync paymaster"),
+	}
+}
+
+// Function to build the eth_call style call object described by the flags
+func (c *callFlags) callObject(args []string) (map[string]interface{}, error) {
+	if *c.to == "" {
+		return nil, fmt.Errorf("--to is required")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	to, err := cfg.resolveAddress(*c.to, *c.chain, c.endpoint())
+	if err != nil {
+		return nil, err
+	}
+	warnDenylisted(cfg, to, "target")
+	call := map[string]interface{}{"to": to.Hex()}
+	if *c.from != "" {
+		call["from"] = *c.from
+	}
+
+	switch {
+	case *c.data != "":
+		call["data"] = *c.data
+	case *c.sig != "":
+		block, err := c.blockParam()
+		if err != nil {
+			return nil, err
+		}
+		ctx, err := newArgContext(c.endpoint(), *c.chain, block)
+		if err != nil {
+			return nil, err
+		}
+		args, err = ctx.resolveArgs(*c.sig, args)
+		if err != nil {
+			return nil, err
+		}
+		data, err := encodeMethodCall(*c.sig, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode call: %v", err)
+		}
+		call["data"] = data
+	}
+
+	if *c.value != "" {
+		value, err := parseValue(*c.value)
+		if err != nil {
+			return nil, err
+		}
+		call["value"] = hexutil.EncodeBig(value)
+	}
+	if *c.paymaster != "" {
+		meta, err := zkSyncMeta(*c.paymaster, *c.paymasterInput)
+		if err != nil {
+			return nil, err
+		}
+		call["type"] = hexutil.EncodeUint64(zkSyncTxType)
+		call["eip712Meta"] = meta
+	}
+	return call, nil
+}
+
+// Function to get the endpoint of the call: the given one or the one of the chain
+func (c *callFlags) endpoint() string {
+	return firstNonEmpty(*c.rpcURL, chainRPC(*c.chain))
+}
+
+// Function to get the block parameter, converting decimal numbers to hex quantities
+func (c *callFlags) blockParam() (string, error) {
+	switch *c.block {
+	case "latest", "pending", "earliest", "safe", "finalized":
+		return *c.block, nil
+	}
+	n, err := parseBlockNumber(*c.block)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.EncodeUint64(n), nil
+}
+
+// Function to parse an amount of wei, accepting an eth or gwei suffix for decimal amounts
+func parseValue(s string) (*big.Int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	decimals := 0
+	switch {
+	case strings.HasSuffix(s, "gwei"):
+		s, decimals = strings.TrimSuffix(s, "gwei"), 9
+	case strings.HasSuffix(s, "eth"):
+		s, decimals = strings.TrimSuffix(s, "eth"), 18
+	case strings.HasSuffix(s, "wei"):
+		s = strings.TrimSuffix(s, "wei")
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("invalid value: no amount given")
+	}
+	value, err := parseFixed(s, decimals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value '%s': %v", s, err)
+	}
+	if value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid value '%s': must not be negative", s)
+	}
+	return value, nil
+}
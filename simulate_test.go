@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"1", "1"},
+		{"2wei", "2"},
+		{"1.5gwei", "1500000000"},
+		{"0.5eth", "500000000000000000"},
+		{" 1 ETH ", "1000000000000000000"},
+	}
+	for _, test := range tests {
+		got, err := parseValue(test.value)
+		if err != nil {
+			t.Errorf("parseValue(%q): %v", test.value, err)
+		} else if got.String() != test.want {
+			t.Errorf("parseValue(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+
+	for _, value := range []string{"", " ", "eth", "-1", "-0.5eth", "1.5", "0.0000000001gwei", "abc"} {
+		if got, err := parseValue(value); err == nil {
+			t.Errorf("parseValue(%q) = %s, want an error", value, got)
+		}
+	}
+}
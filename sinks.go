@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logSink receives the JSON lines of a log scan: logs and reorg notices
+type logSink interface {
+	write(line []byte) error
+	Close() error
+}
+
+// Sink the log scan writes to, standard output unless --sink is given
+var logOutput logSink = stdoutSink{}
+
+// Time allowed to connect to a sink
+const sinkDialTimeout = 10 * time.Second
+
+// Function to open the sink of a URL:
+//
+//	tcp://host:port             JSON lines over a plain TCP connection
+//	nats://[user:pass@]host:port/subject   NATS messages published on a subject
+//	redis://[:pass@]host:port/stream       entries added to a Redis stream, in field "data"
+//	kafka-rest://host:port/topic           records produced through a Kafka REST Proxy (v2 API)
+//	sqlite:events.db                       rows of a table per event of the ABIs
+//	csv:dir, parquet:dir                   a file per event of the ABIs in a directory
+func openSink(rawURL string, abiPaths []string) (logSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink '%s': %v", rawURL, err)
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+	if kind, ok := map[string]string{"nats": "subject", "redis": "stream", "kafka-rest": "topic"}[u.Scheme]; ok && name == "" {
+		return nil, fmt.Errorf("sink '%s' names no %s", rawURL, kind)
+	}
+	switch u.Scheme {
+	case "sqlite":
+		path := firstNonEmpty(u.Opaque, u.Path)
+		if path == "" {
+			return nil, fmt.Errorf("sink '%s' names no database file", rawURL)
+		}
+		return openSQLiteSink(path, abiPaths)
+	case "csv", "parquet":
+		dir := firstNonEmpty(u.Opaque, u.Path)
+		if dir == "" {
+			return nil, fmt.Errorf("sink '%s' names no directory", rawURL)
+		}
+		return openExportSink(dir, u.Scheme, abiPaths)
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", u.Host, sinkDialTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to sink: %v", err)
+		}
+		return &tcpSink{conn: conn}, nil
+	case "nats":
+		return openNatsSink(u, name)
+	case "redis":
+		return openRedisSink(u, name)
+	case "kafka-rest":
+		return &kafkaRestSink{url: "http://" + u.Host + "/topics/" + url.PathEscape(name)}, nil
+	}
+	return nil, fmt.Errorf("unsupported sink '%s' (expected tcp, nats, redis, kafka-rest, sqlite, csv or parquet)", u.Scheme)
+}
+
+// stdoutSink prints each line
+type stdoutSink struct{}
+
+func (stdoutSink) write(line []byte) error {
+	fmt.Println(string(line))
+	return nil
+}
+
+func (stdoutSink) Close() error {
+	return nil
+}
+
+// tcpSink writes newline-terminated lines to a TCP connection
+type tcpSink struct {
+	conn net.Conn
+}
+
+func (s *tcpSink) write(line []byte) error {
+	if _, err := s.conn.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write to sink: %v", err)
+	}
+	return nil
+}
+
+func (s *tcpSink) Close() error {
+	return s.conn.Close()
+}
+
+// natsSink publishes each line as a message, speaking the NATS text protocol
+type natsSink struct {
+	conn    net.Conn
+	subject string
+	// Writes come from the scan and from answers to the server's pings
+	mu sync.Mutex
+	// First error the server reported, failing the next write
+	err error
+	// Signaled by the server's answer to our ping
+	pong chan struct{}
+}
+
+// Function to connect to a NATS server and start answering its pings
+func openNatsSink(u *url.URL, subject string) (logSink, error) {
+	conn, err := net.DialTimeout("tcp", u.Host, sinkDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	// The server greets with INFO {...}
+	if info, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to NATS: unexpected greeting %q (%v)", info, err)
+	}
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "contract-curler", "lang": "go"}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		options["pass"], _ = u.User.Password()
+	}
+	encoded, _ := json.Marshal(options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", encoded); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+
+	sink := &natsSink{conn: conn, subject: subject, pong: make(chan struct{}, 1)}
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			sink.mu.Lock()
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				fmt.Fprint(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "PONG"):
+				select {
+				case sink.pong <- struct{}{}:
+				default:
+				}
+			case strings.HasPrefix(line, "-ERR"):
+				if sink.err == nil {
+					sink.err = fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+				}
+			}
+			sink.mu.Unlock()
+		}
+	}()
+	return sink, nil
+}
+
+func (s *natsSink) write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\n", s.subject, len(line), line); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %v", err)
+	}
+	return nil
+}
+
+func (s *natsSink) Close() error {
+	defer s.conn.Close()
+	// The server answers a ping once it has processed everything sent before it
+	s.mu.Lock()
+	_, err := fmt.Fprint(s.conn, "PING\r\n")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-s.pong:
+	case <-time.After(sinkDialTimeout):
+		return fmt.Errorf("NATS did not confirm the published messages")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// redisSink adds each line to a Redis stream with XADD, speaking RESP
+type redisSink struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	stream string
+}
+
+// Function to connect to a Redis server, authenticating with the password of the URL
+func openRedisSink(u *url.URL, stream string) (logSink, error) {
+	conn, err := net.DialTimeout("tcp", u.Host, sinkDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	sink := &redisSink{conn: conn, reader: bufio.NewReader(conn), stream: stream}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			if _, err := sink.command("AUTH", password); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to authenticate to Redis: %v", err)
+			}
+		}
+	}
+	return sink, nil
+}
+
+// Function to send a command and read its reply, which must be a simple or bulk string
+func (s *redisSink) command(args ...string) (string, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := s.conn.Write(b.Bytes()); err != nil {
+		return "", err
+	}
+	reply, err := s.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimRight(reply, "\r\n")
+	switch {
+	case strings.HasPrefix(reply, "-"):
+		return "", fmt.Errorf("%s", reply[1:])
+	case strings.HasPrefix(reply, "$"):
+		value, err := s.reader.ReadString('\n')
+		return strings.TrimRight(value, "\r\n"), err
+	}
+	return strings.TrimPrefix(reply, "+"), nil
+}
+
+func (s *redisSink) write(line []byte) error {
+	if _, err := s.command("XADD", s.stream, "*", "data", string(line)); err != nil {
+		return fmt.Errorf("failed to add to Redis stream: %v", err)
+	}
+	return nil
+}
+
+func (s *redisSink) Close() error {
+	return s.conn.Close()
+}
+
+// kafkaRestSink produces each line as a JSON record through a Kafka REST Proxy, Kafka's
+// own protocol needing a client library
+type kafkaRestSink struct {
+	url string
+}
+
+func (s *kafkaRestSink) write(line []byte) error {
+	body := []byte(`{"records":[{"value":` + string(line) + `}]}`)
+	resp, err := httpClient.Post(s.url, "application/vnd.kafka.json.v2+json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to produce to Kafka: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to produce to Kafka: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func (s *kafkaRestSink) Close() error {
+	return nil
+}
+
+// Function to close the sink of the scan, reporting failures to flush it
+func closeLogOutput() {
+	if err := logOutput.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to close sink: %v\n", err)
+	}
+}
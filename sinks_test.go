@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to accept a single connection on a local port, served by a fake server
+func fakeServer(t *testing.T, serve func(conn net.Conn, reader *bufio.Reader)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		serve(conn, bufio.NewReader(conn))
+	}()
+	return listener.Addr().String()
+}
+
+func TestTCPSink(t *testing.T) {
+	received := make(chan string, 1)
+	address := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		data, _ := ioutil.ReadAll(reader)
+		received <- string(data)
+	})
+	sink, err := openSink("tcp://"+address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`{"block":1}`, `{"block":2}`} {
+		if err := sink.write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "{\"block\":1}\n{\"block\":2}\n" {
+		t.Errorf("received %q", got)
+	}
+}
+
+// Function to serve the NATS text protocol: greet, take the CONNECT options, answer pings
+// and collect the messages published, then report them once the client hangs up
+func fakeNats(t *testing.T, reply string, connected chan<- map[string]interface{}, published chan<- []string) string {
+	return fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		line, _ := reader.ReadString('\n')
+		var options map[string]interface{}
+		json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &options)
+		connected <- options
+		if reply != "" {
+			fmt.Fprint(conn, reply)
+		}
+		// The server pings too, and expects an answer
+		fmt.Fprint(conn, "PING\r\n")
+		var messages []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				published <- messages
+				return
+			}
+			switch {
+			case line == "PONG\r\n":
+				messages = append(messages, "PONG")
+			case strings.HasPrefix(line, "PUB "):
+				fields := strings.Fields(line)
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(reader, payload)
+				messages = append(messages, fields[1]+" "+string(payload[:size]))
+			case line == "PING\r\n":
+				messages = append(messages, "PING")
+				fmt.Fprint(conn, "PONG\r\n")
+			}
+		}
+	})
+}
+
+func TestNatsSink(t *testing.T) {
+	connected, published := make(chan map[string]interface{}, 1), make(chan []string, 1)
+	address := fakeNats(t, "", connected, published)
+	sink, err := openSink("nats://scanner:secret@"+address+"/chain.logs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options := <-connected; options["user"] != "scanner" || options["pass"] != "secret" || options["verbose"] != false {
+		t.Errorf("CONNECT options %v", options)
+	}
+	for _, line := range []string{`{"block":1}`, `{"block":2}`} {
+		if err := sink.write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Closing waits for the server to answer a ping, confirming the messages
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The answer to the server's ping is sent whenever the sink reads it
+	var got []string
+	var answered bool
+	for _, message := range <-published {
+		if message == "PONG" {
+			answered = true
+		} else {
+			got = append(got, message)
+		}
+	}
+	want := []string{`chain.logs {"block":1}`, `chain.logs {"block":2}`, "PING"}
+	if !answered || strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("server got %q, ping answered %v", got, answered)
+	}
+}
+
+func TestNatsSinkError(t *testing.T) {
+	connected, published := make(chan map[string]interface{}, 1), make(chan []string, 1)
+	address := fakeNats(t, "-ERR 'Authorization Violation'\r\n", connected, published)
+	sink, err := openSink("nats://"+address+"/chain.logs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-connected
+	// The error arrives asynchronously and fails the writes after it
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.write([]byte(`{}`)) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sink.Close(); err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("Close returned %v", err)
+	}
+}
+
+// Function to read a RESP array of bulk strings, as clients send commands
+func readRESP(reader *bufio.Reader) ([]string, error) {
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "*")))
+	args := make([]string, count)
+	for i := range args {
+		sizeLine, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(sizeLine, "$")))
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+		args[i] = string(value[:size])
+	}
+	return args, nil
+}
+
+func TestRedisSink(t *testing.T) {
+	commands := make(chan []string, 3)
+	address := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		for id := 1; ; id++ {
+			args, err := readRESP(reader)
+			if err != nil {
+				close(commands)
+				return
+			}
+			commands <- args
+			switch {
+			case args[0] == "AUTH":
+				fmt.Fprint(conn, "+OK\r\n")
+			case args[0] == "XADD" && id == 3:
+				fmt.Fprint(conn, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+			default:
+				reply := fmt.Sprintf("1700000000000-%d", id)
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(reply), reply)
+			}
+		}
+	})
+	sink, err := openSink("redis://:secret@"+address+"/logs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.write([]byte(`{"block":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.write([]byte(`{"block":2}`)); err == nil || !strings.Contains(err.Error(), "WRONGTYPE") {
+		t.Errorf("error reply returned %v", err)
+	}
+	sink.Close()
+
+	var got []string
+	for args := range commands {
+		got = append(got, strings.Join(args, " "))
+	}
+	want := []string{"AUTH secret", `XADD logs * data {"block":1}`, `XADD logs * data {"block":2}`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+}
+
+func TestRedisSinkAuthFailure(t *testing.T) {
+	address := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		readRESP(reader)
+		fmt.Fprint(conn, "-WRONGPASS invalid username-password pair\r\n")
+	})
+	if _, err := openSink("redis://:wrong@"+address+"/logs", nil); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("failed authentication returned %v", err)
+	}
+}
+
+func TestKafkaRestSink(t *testing.T) {
+	var bodies []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/topics/chain logs" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			t.Errorf("request %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"error_code":50301,"message":"Connection to broker failed"}`)
+	}))
+	defer server.Close()
+
+	sink, err := openSink("kafka-rest://"+strings.TrimPrefix(server.URL, "http://")+"/chain%20logs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.write([]byte(`{"block":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := sink.write([]byte(`{"block":2}`)); err == nil || !strings.Contains(err.Error(), "Connection to broker failed") {
+		t.Errorf("failed produce returned %v", err)
+	}
+	if len(bodies) != 2 || bodies[0] != `{"records":[{"value":{"block":1}}]}` {
+		t.Errorf("proxy got %q", bodies)
+	}
+}
+
+func TestOpenSinkErrors(t *testing.T) {
+	for _, rawURL := range []string{"nats://127.0.0.1:4222", "redis://127.0.0.1:6379/", "kafka-rest://127.0.0.1:8082", "ftp://host/file", "sqlite:", "csv:"} {
+		if _, err := openSink(rawURL, nil); err == nil {
+			t.Errorf("openSink(%s) succeeded", rawURL)
+		}
+	}
+}
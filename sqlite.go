@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// sqliteShell writes SQL statements to a database through the sqlite3 command line shell,
+// which avoids building a SQLite driver into the binary
+type sqliteShell struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	writer *bufio.Writer
+	stderr bytes.Buffer
+	// Set once the shell has exited and its stderr is complete
+	exited bool
+}
+
+// Function to start the sqlite3 shell on a database file, creating it if needed
+func openSQLite(path string) (*sqliteShell, error) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("writing to SQLite needs the sqlite3 command line shell in PATH")
+	}
+	shell := &sqliteShell{cmd: exec.Command("sqlite3", "-bail", path)}
+	shell.cmd.Stderr = &shell.stderr
+	stdin, err := shell.cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sqlite3: %v", err)
+	}
+	if err := shell.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start sqlite3: %v", err)
+	}
+	shell.stdin, shell.writer = stdin, bufio.NewWriter(stdin)
+	// Readers may query the database while a scan writes to it
+	if err := shell.exec("PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL;"); err != nil {
+		return nil, err
+	}
+	return shell, nil
+}
+
+// Function to send statements to the shell. Statements are buffered, so errors in them
+// show up in later calls or when closing.
+func (s *sqliteShell) exec(sql string) error {
+	if _, err := s.writer.WriteString(sql + "\n"); err != nil {
+		return s.failure(err)
+	}
+	return nil
+}
+
+// Function to make the statements sent so far reach the shell
+func (s *sqliteShell) flush() error {
+	if err := s.writer.Flush(); err != nil {
+		return s.failure(err)
+	}
+	return nil
+}
+
+// Function to wait for the shell to run all statements and exit
+func (s *sqliteShell) Close() error {
+	if err := s.writer.Flush(); err != nil {
+		return s.failure(err)
+	}
+	if err := s.wait(); err != nil {
+		return s.failure(err)
+	}
+	return nil
+}
+
+// Function to close the input of the shell and wait for it to exit
+func (s *sqliteShell) wait() error {
+	if s.exited {
+		return nil
+	}
+	s.exited = true
+	s.stdin.Close()
+	return s.cmd.Wait()
+}
+
+// Function to report a failure of the shell, which explains itself on stderr. The shell
+// stops at the first failed statement (-bail), so a write failing on its closed input is
+// reported with the error of that statement.
+func (s *sqliteShell) failure(err error) error {
+	if waitErr := s.wait(); waitErr != nil {
+		err = waitErr
+	}
+	if message := strings.TrimSpace(s.stderr.String()); message != "" {
+		return fmt.Errorf("sqlite3: %s", message)
+	}
+	return fmt.Errorf("sqlite3: %v", err)
+}
+
+// Function to quote an SQL identifier
+func sqlIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Function to quote an SQL string literal
+func sqlText(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Function to render a decoded value as an SQL literal: integers that fit SQLite's 64 bits
+// as numbers, other scalars as text and arrays and tuples as JSON text
+func sqlValue(value interface{}) string {
+	if n, ok := asBigInt(value); ok && n.IsInt64() {
+		return n.String()
+	}
+	if b, ok := value.(bool); ok {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	if text, ok := templateValue(value); ok {
+		return sqlText(text)
+	}
+	return sqlText(csvValue(value))
+}
+
+// Function to get the SQLite column type of an ABI type. Integers beyond SQLite's signed
+// 64 bits, uint64 included, are text, since SQLite would round them to floating point.
+func sqlColumnType(abiType string) string {
+	if abiType == "bool" {
+		return "INTEGER"
+	}
+	if matches := fuzzTypePattern.FindStringSubmatch(abiType); matches != nil && matches[1] != "bytes" {
+		if size, _ := strconv.Atoi(matches[2]); size > 0 && (size < 64 || size == 64 && matches[1] == "int") {
+			return "INTEGER"
+		}
+	}
+	return "TEXT"
+}
+
+// sqliteSink inserts decoded logs into the event tables
+type sqliteSink struct {
+	shell  *sqliteShell
+	tables *eventTables
+}
+
+// Function to open a database and create the tables of the events of the ABIs
+func openSQLiteSink(path string, abiPaths []string) (logSink, error) {
+	tables, err := newEventTables(abiPaths)
+	if err != nil {
+		return nil, err
+	}
+	shell, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables.names {
+		var definitions []string
+		for _, column := range tables.columns[table] {
+			definitions = append(definitions, sqlIdent(column.name)+" "+sqlColumnType(column.abiType))
+		}
+		definitions = append(definitions, "PRIMARY KEY (block_number, log_index)")
+		if err := shell.exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", sqlIdent(table), strings.Join(definitions, ", "))); err != nil {
+			return nil, err
+		}
+	}
+	return &sqliteSink{shell: shell, tables: tables}, shell.flush()
+}
+
+// Function to insert a log, or apply a reorg notice by deleting the logs it invalidates.
+// Logs emitted again after resuming a scan replace their earlier rows.
+func (s *sqliteSink) write(line []byte) error {
+	notice, entry, err := parseScanLine(line)
+	if err != nil {
+		return err
+	}
+	if notice != nil {
+		condition := fmt.Sprintf("block_number >= %d", notice.Block)
+		if notice.Reason == "removed log" {
+			condition = "block_hash = " + sqlText(notice.OldHash)
+		}
+		for _, table := range s.tables.names {
+			if err := s.shell.exec(fmt.Sprintf("DELETE FROM %s WHERE %s;", sqlIdent(table), condition)); err != nil {
+				return err
+			}
+		}
+		return s.shell.flush()
+	}
+	if entry.Removed {
+		return nil
+	}
+
+	row, err := s.tables.row(*entry)
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(row.values))
+	values := make([]string, len(row.values))
+	for i, value := range row.values {
+		columns[i] = sqlIdent(s.tables.columns[row.table][i].name)
+		values[i] = sqlValue(value)
+	}
+	if err := s.shell.exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s);",
+		sqlIdent(row.table), strings.Join(columns, ", "), strings.Join(values, ", "))); err != nil {
+		return err
+	}
+	return s.shell.flush()
+}
+
+func (s *sqliteSink) Close() error {
+	return s.shell.Close()
+}
+
+// Function to write call results to the results table of a database, one row per decoded
+// value like the CSV output, replacing the table unless appending
+func writeSQLiteResults(path string, records []resultRecord, appendRows bool) error {
+	shell, err := openSQLite(path)
+	if err != nil {
+		return err
+	}
+	statements := []string{"BEGIN;"}
+	if !appendRows {
+		statements = append(statements, "DROP TABLE IF EXISTS results;")
+	}
+	definitions := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		definitions[i] = sqlIdent(column) + " TEXT"
+		if column == "gas" {
+			definitions[i] = sqlIdent(column) + " INTEGER"
+		}
+	}
+	statements = append(statements, fmt.Sprintf("CREATE TABLE IF NOT EXISTS results (%s);", strings.Join(definitions, ", ")))
+	for _, record := range records {
+		gas := "NULL"
+		if record.Gas > 0 {
+			gas = strconv.FormatUint(record.Gas, 10)
+		}
+		for _, value := range record.Values {
+			row := []string{record.Time, record.Step, record.Contract, record.Function, record.Block,
+				value.Name, value.Type, csvValue(value.Value), value.Display, record.Raw}
+			for i := range row {
+				row[i] = sqlText(row[i])
+			}
+			statements = append(statements, fmt.Sprintf("INSERT INTO results VALUES (%s, %s);", strings.Join(row, ", "), gas))
+		}
+	}
+	statements = append(statements, "COMMIT;")
+	for _, statement := range statements {
+		if err := shell.exec(statement); err != nil {
+			return err
+		}
+	}
+	// A failed statement makes the shell exit with its error, leaving the table unchanged
+	if err := shell.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Function to run a query with the sqlite3 shell, skipping the test without one
+func sqliteQuery(t *testing.T, path, query string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	out, err := exec.Command("sqlite3", "-bail", path, query).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %v: %s", query, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestWriteSQLiteResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	sqliteQuery(t, path, "SELECT 1;")
+	records := []resultRecord{
+		{Gas: 21000, pluginResult: pluginResult{Contract: "0x00000000219ab540356cBB839Cbe05303d7705Fa", Function: "name", Block: "latest",
+			Values: []pluginValue{{Type: "string", Value: "it's", Display: "it's"}}}},
+		{pluginResult: pluginResult{Contract: "0x00000000219ab540356cBB839Cbe05303d7705Fa", Function: "balances", Block: "latest",
+			Values: []pluginValue{{Type: "uint256", Name: "a", Value: "1"}, {Type: "uint256", Name: "b", Value: "2"}}}},
+	}
+	if err := writeSQLiteResults(path, records, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSQLiteResults(path, records[:1], true); err != nil {
+		t.Fatal(err)
+	}
+	if got := sqliteQuery(t, path, "SELECT function, name, value, gas FROM results;"); got != "name||it's|21000\nbalances|a|1|\nbalances|b|2|\nname||it's|21000" {
+		t.Errorf("results table holds %q", got)
+	}
+	if err := writeSQLiteResults(path, records[1:], false); err != nil {
+		t.Fatal(err)
+	}
+	if got := sqliteQuery(t, path, "SELECT count(*) FROM results;"); got != "2" {
+		t.Errorf("replaced table holds %s rows", got)
+	}
+}
+
+func TestWriteSQLiteResultsFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	sqliteQuery(t, path, "CREATE TABLE results (value TEXT); INSERT INTO results VALUES ('kept');")
+	records := []resultRecord{{pluginResult: pluginResult{Function: "name", Values: []pluginValue{{Type: "string", Value: "x"}}}}}
+	// The table has other columns, so inserting into it fails and nothing is written
+	err := writeSQLiteResults(path, records, true)
+	if err == nil || !strings.Contains(err.Error(), "has 1 columns but 11 values were supplied") {
+		t.Errorf("failed insert returned %v", err)
+	}
+	if got := sqliteQuery(t, path, "SELECT * FROM results;"); got != "kept" {
+		t.Errorf("results table holds %q", got)
+	}
+	if err := writeSQLiteResults(filepath.Join(path, "results.db"), records, false); err == nil || !strings.Contains(err.Error(), "sqlite3: ") {
+		t.Errorf("unwritable database returned %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// prestateAccount is an account state reported by the prestateTracer
+type prestateAccount struct {
+	Balance string            `json:"balance"`
+	Nonce   *uint64           `json:"nonce"`
+	Code    string            `json:"code"`
+	Storage map[string]string `json:"storage"`
+}
+
+// prestateDiff is the result of the prestateTracer in diff mode. Post only holds the
+// fields that changed, and storage slots that are zero before or after are omitted.
+type prestateDiff struct {
+	Pre  map[string]prestateAccount `json:"pre"`
+	Post map[string]prestateAccount `json:"post"`
+}
+
+// Function to summarise the state changes of a simulated call or a mined transaction
+func runStateDiff(args []string) error {
+	fs := flag.NewFlagSet("state-diff", flag.ExitOnError)
+	call := addCallFlags(fs)
+	txHash := fs.String("tx", "", "diff an existing transaction instead of simulating a call")
+	fs.Parse(args)
+	rpcURL := call.endpoint()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg).withChain(cfg, rpcURL)
+
+	tracer := map[string]interface{}{
+		"tracer":       "prestateTracer",
+		"tracerConfig": map[string]interface{}{"diffMode": true},
+	}
+	var diff prestateDiff
+	block := "latest"
+	if *txHash != "" {
+		if err := callRPC(rpcURL, &diff, "debug_traceTransaction", *txHash, tracer); err != nil {
+			return fmt.Errorf("debug_traceTransaction failed: %v", err)
+		}
+	} else {
+		callObject, err := call.callObject(fs.Args())
+		if err != nil {
+			return err
+		}
+		block, err = call.blockParam()
+		if err != nil {
+			return err
+		}
+		if err := callRPC(rpcURL, &diff, "debug_traceCall", callObject, block, tracer); err != nil {
+			return fmt.Errorf("debug_traceCall failed: %v", err)
+		}
+	}
+
+	addresses := make(map[string]bool)
+	for address := range diff.Pre {
+		addresses[strings.ToLower(address)] = true
+	}
+	for address := range diff.Post {
+		addresses[strings.ToLower(address)] = true
+	}
+	sorted := make([]string, 0, len(addresses))
+	for address := range addresses {
+		sorted = append(sorted, address)
+	}
+	sort.Strings(sorted)
+
+	if len(sorted) == 0 {
+		fmt.Println("No state changes")
+		return nil
+	}
+	for _, address := range sorted {
+		pre, inPre := findAccount(diff.Pre, address)
+		post, inPost := findAccount(diff.Post, address)
+		fmt.Println(labelAddress(address, opts))
+		printAccountDiff(rpcURL, address, block, pre, post, inPre, inPost)
+	}
+	return nil
+}
+
+// Function to find an account regardless of the address case used by the node
+func findAccount(accounts map[string]prestateAccount, address string) (prestateAccount, bool) {
+	for key, account := range accounts {
+		if strings.EqualFold(key, address) {
+			return account, true
+		}
+	}
+	return prestateAccount{}, false
+}
+
+// Function to print the before and after values of a changed account
+func printAccountDiff(rpcURL, address, block string, pre, post prestateAccount, inPre, inPost bool) {
+	switch {
+	case !inPre:
+		fmt.Println("  account created")
+	case !inPost:
+		fmt.Println("  account removed")
+	}
+
+	if inPost && post.Balance != "" {
+		before, after := traceValue(pre.Balance), traceValue(post.Balance)
+		delta := new(big.Int).Sub(after, before)
+		sign := "+"
+		if delta.Sign() < 0 {
+			sign = ""
+		}
+		fmt.Printf("  balance: %s ETH -> %s ETH (%s%s ETH)\n", formatFixed(before, 18), formatFixed(after, 18), sign, formatFixed(delta, 18))
+	}
+	if inPost && post.Nonce != nil {
+		var before uint64
+		if pre.Nonce != nil {
+			before = *pre.Nonce
+		}
+		fmt.Printf("  nonce: %d -> %d\n", before, *post.Nonce)
+	}
+	if inPost && post.Code != "" {
+		fmt.Printf("  code: %d bytes -> %d bytes\n", codeSize(pre.Code), codeSize(post.Code))
+	}
+
+	slots := make(map[string]bool)
+	for slot := range pre.Storage {
+		slots[slot] = true
+	}
+	for slot := range post.Storage {
+		slots[slot] = true
+	}
+	if len(slots) == 0 {
+		return
+	}
+	sorted := make([]string, 0, len(slots))
+	for slot := range slots {
+		sorted = append(sorted, slot)
+	}
+	sort.Strings(sorted)
+
+	// Token contracts mostly change balance slots, so show amounts scaled by their decimals
+	decimals, isToken := tokenDecimals(rpcURL, address, block)
+	for _, slot := range sorted {
+		before, after := storageValue(pre.Storage[slot]), storageValue(post.Storage[slot])
+		line := fmt.Sprintf("  storage %s: %s -> %s", slot, hexutil.EncodeBig(before), hexutil.EncodeBig(after))
+		if isToken && before.BitLen() <= 128 && after.BitLen() <= 128 {
+			line += fmt.Sprintf(" (as token amount: %s -> %s)", formatFixed(before, decimals), formatFixed(after, decimals))
+		}
+		fmt.Println(line)
+	}
+}
+
+// Function to parse a storage word, treating an omitted slot as zero
+func storageValue(word string) *big.Int {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(word, "0x"), 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return n
+}
+
+// Function to get the size of hex-encoded code
+func codeSize(code string) int {
+	b, err := hexutil.Decode(code)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// Function to get the decimals of a token through the token cache, reporting false for
+// non-tokens
+func tokenDecimals(rpcURL, address, block string) (int, bool) {
+	token, ok := lookupToken(rpcURL, common.HexToAddress(address), block)
+	if !ok {
+		return 0, false
+	}
+	return *token.Decimals, true
+}
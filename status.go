@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Chains shorter than this are too young for the archive probe to tell anything, since
+// pruning nodes keep at least the recent state
+const archiveProbeMinHead = 10000
+
+// nodeStatus summarises the state of an RPC endpoint
+type nodeStatus struct {
+	ClientVersion string
+	ChainID       uint64
+	Head          uint64
+	HeadTime      time.Time
+	// Nil when the node is in sync
+	Syncing *syncProgress
+	// "yes", "no" or an explanation of why it is unknown
+	Archive string
+}
+
+// syncProgress is the object eth_syncing returns while a node catches up
+type syncProgress struct {
+	StartingBlock hexutil.Uint64 `json:"startingBlock"`
+	CurrentBlock  hexutil.Uint64 `json:"currentBlock"`
+	HighestBlock  hexutil.Uint64 `json:"highestBlock"`
+}
+
+// Function to report the latest block, sync status, client and archive support of an endpoint
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	rpcURL := fs.String("rpc", defaultRPC(), "Ethereum RPC URL")
+	fs.BoolVar(&deterministic, "deterministic", false, "leave timestamps, latencies and endpoint names out of the output")
+	fs.Parse(args)
+
+	status, err := probeNode(*rpcURL)
+	if err != nil {
+		return err
+	}
+	printNodeStatus(*rpcURL, status)
+	return nil
+}
+
+// Function to query the status of an endpoint. Only a failing eth_blockNumber is an
+// error, the other fields are reported as unknown when their method is unsupported.
+func probeNode(rpcURL string) (*nodeStatus, error) {
+	head, err := latestBlockNumber(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %s is not reachable: %v", rpcURL, err)
+	}
+	status := &nodeStatus{Head: head}
+
+	if err := callRPC(rpcURL, &status.ClientVersion, "web3_clientVersion"); err != nil {
+		status.ClientVersion = "unknown (" + err.Error() + ")"
+	}
+	var chainID hexutil.Uint64
+	if err := callRPC(rpcURL, &chainID, "eth_chainId"); err == nil {
+		status.ChainID = uint64(chainID)
+	}
+	var header struct {
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	if err := callRPC(rpcURL, &header, "eth_getBlockByNumber", hexutil.EncodeUint64(head), false); err == nil && header.Timestamp > 0 {
+		status.HeadTime = time.Unix(int64(header.Timestamp), 0).UTC()
+	}
+
+	// eth_syncing returns false when in sync and a progress object otherwise
+	var syncing json.RawMessage
+	if err := callRPC(rpcURL, &syncing, "eth_syncing"); err == nil && strings.HasPrefix(strings.TrimSpace(string(syncing)), "{") {
+		var progress syncProgress
+		if json.Unmarshal(syncing, &progress) == nil {
+			status.Syncing = &progress
+		}
+	}
+
+	status.Archive = probeArchive(rpcURL, head)
+	return status, nil
+}
+
+// Function to tell whether an endpoint serves old state by reading a balance at block 1
+func probeArchive(rpcURL string, head uint64) string {
+	if head < archiveProbeMinHead {
+		return fmt.Sprintf("unknown (chain has only %d blocks)", head)
+	}
+	var balance string
+	err := sendRPC(rpcURL, &balance, "eth_getBalance", "0x0000000000000000000000000000000000000000", "0x1")
+	switch {
+	case err == nil:
+		return "yes"
+	case isMissingStateError(err):
+		return "no"
+	}
+	return "unknown (" + err.Error() + ")"
+}
+
+// Function to check whether an error means the node has pruned the requested state
+func isMissingStateError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, pattern := range []string{"missing trie node", "state not available", "state is not available", "historical state", "state histories haven't been fully indexed"} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to tell whether an error means the node has not caught up with the requested
+// block yet, common right after pinning the newest block or on a lagging fallback
+func isBlockLagError(err error) bool {
+	if _, ok := err.(*JsonRpcError); !ok {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range []string{"header not found", "block not found", "unknown block", "requested block number is greater", "block is out of range", "after last accepted block"} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to print the status of an endpoint
+func printNodeStatus(rpcURL string, status *nodeStatus) {
+	fmt.Printf("Endpoint:       %s\n", displayEndpoint(rpcURL))
+	fmt.Printf("Client:         %s\n", firstNonEmpty(status.ClientVersion, "unknown"))
+	if status.ChainID != 0 {
+		fmt.Printf("Chain ID:       %d\n", status.ChainID)
+	}
+	fmt.Printf("Latest block:   %d", status.Head)
+	if !status.HeadTime.IsZero() && !deterministic {
+		fmt.Printf(" (%s, %s ago)", status.HeadTime.Format(time.RFC3339), time.Since(status.HeadTime).Round(time.Second))
+	}
+	fmt.Println()
+	if status.Syncing != nil {
+		remaining := uint64(0)
+		if status.Syncing.HighestBlock > status.Syncing.CurrentBlock {
+			remaining = uint64(status.Syncing.HighestBlock - status.Syncing.CurrentBlock)
+		}
+		fmt.Printf("Sync status:    syncing, at %d of %d (%d behind)\n", status.Syncing.CurrentBlock, status.Syncing.HighestBlock, remaining)
+	} else {
+		fmt.Println("Sync status:    in sync")
+	}
+	fmt.Printf("Archive node:   %s\n", status.Archive)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// tokenMetadata is what is cached about a token: metadata never changes once a token is
+// deployed, so entries never expire
+type tokenMetadata struct {
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	Decimals *int   `json:"decimals,omitempty"`
+}
+
+// tokenCache is the token metadata of one chain keyed by lowercase address, as kept on disk
+type tokenCache struct {
+	chainID uint64
+	tokens  map[string]tokenMetadata
+}
+
+// Token caches loaded so far by chain id, and the chain id of each endpoint, shared by the
+// concurrent calls of batches
+var (
+	tokenCachesMu  sync.Mutex
+	tokenCaches    = make(map[uint64]*tokenCache)
+	endpointChains = make(map[string]uint64)
+)
+
+// Function to get the directory holding the token cache of every chain
+func tokenCacheDir() string {
+	return filepath.Join(configDir(), "token-cache")
+}
+
+// Function to get the token cache of the chain an endpoint serves, loading it from disk the
+// first time, nil when the chain cannot be told
+func tokenCacheFor(rpcURL string) *tokenCache {
+	chainID, ok := endpointChainID(rpcURL)
+	if !ok {
+		return nil
+	}
+	tokenCachesMu.Lock()
+	defer tokenCachesMu.Unlock()
+	if cache, ok := tokenCaches[chainID]; ok {
+		return cache
+	}
+	cache := &tokenCache{chainID: chainID, tokens: make(map[string]tokenMetadata)}
+	// A missing or unreadable cache starts empty, it only saves lookups
+	if data, err := ioutil.ReadFile(cache.path()); err == nil {
+		json.Unmarshal(data, &cache.tokens)
+		for address, token := range cache.tokens {
+			if token.Decimals == nil {
+				delete(cache.tokens, address)
+			}
+		}
+	}
+	tokenCaches[chainID] = cache
+	return cache
+}
+
+// Function to get the chain id an endpoint serves, asking it once per run
+func endpointChainID(rpcURL string) (uint64, bool) {
+	tokenCachesMu.Lock()
+	chainID, known := endpointChains[rpcURL]
+	tokenCachesMu.Unlock()
+	if known {
+		return chainID, true
+	}
+	var id hexutil.Uint64
+	if err := callRPC(rpcURL, &id, "eth_chainId"); err != nil {
+		return 0, false
+	}
+	tokenCachesMu.Lock()
+	endpointChains[rpcURL] = uint64(id)
+	tokenCachesMu.Unlock()
+	return uint64(id), true
+}
+
+// Function to get the path of the cache file of a chain
+func (c *tokenCache) path() string {
+	return filepath.Join(tokenCacheDir(), strconv.FormatUint(c.chainID, 10)+".json")
+}
+
+// Function to get the cached metadata of a token
+func (c *tokenCache) get(address common.Address) (tokenMetadata, bool) {
+	tokenCachesMu.Lock()
+	defer tokenCachesMu.Unlock()
+	token, ok := c.tokens[strings.ToLower(address.Hex())]
+	return token, ok
+}
+
+// Function to get a snapshot of the cached tokens, safe to read while lookups go on
+func (c *tokenCache) snapshot() map[string]tokenMetadata {
+	tokenCachesMu.Lock()
+	defer tokenCachesMu.Unlock()
+	tokens := make(map[string]tokenMetadata, len(c.tokens))
+	for address, token := range c.tokens {
+		tokens[address] = token
+	}
+	return tokens
+}
+
+// Function to cache the metadata of a token and write the cache of its chain
+func (c *tokenCache) put(address common.Address, token tokenMetadata) error {
+	tokenCachesMu.Lock()
+	c.tokens[strings.ToLower(address.Hex())] = token
+	data, _ := json.MarshalIndent(c.tokens, "", "  ")
+	tokenCachesMu.Unlock()
+	return c.write(data)
+}
+
+// Function to drop the cached tokens of a chain, with its cache file
+func (c *tokenCache) clear() error {
+	tokenCachesMu.Lock()
+	c.tokens = make(map[string]tokenMetadata)
+	tokenCachesMu.Unlock()
+	if err := os.Remove(c.path()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove token cache: %v", err)
+	}
+	return nil
+}
+
+// Function to write the cache file, replacing the old one atomically
+func (c *tokenCache) write(data []byte) error {
+	if err := os.MkdirAll(tokenCacheDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create token cache directory: %v", err)
+	}
+	tmp, err := ioutil.TempFile(tokenCacheDir(), ".tokens-")
+	if err != nil {
+		return fmt.Errorf("failed to write token cache: %v", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path())
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write token cache: %v", err)
+	}
+	return nil
+}
+
+// Function to get the metadata of a token from the cache of its chain, reading it from the
+// token at a block and caching it when it is missing. Only contracts answering decimals()
+// are cached, so accounts that are not tokens are looked up again.
+func lookupToken(rpcURL string, address common.Address, block string) (tokenMetadata, bool) {
+	cache := tokenCacheFor(rpcURL)
+	if cache != nil {
+		if token, ok := cache.get(address); ok {
+			return token, true
+		}
+	}
+	token, ok := fetchToken(rpcURL, address, block)
+	if !ok {
+		return tokenMetadata{}, false
+	}
+	if cache != nil {
+		if err := cache.put(address, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return token, true
+}
+
+// Function to read the metadata of a token, reporting false when it has no decimals()
+func fetchToken(rpcURL string, address common.Address, block string) (tokenMetadata, bool) {
+	// Read as uint256 so out of range values are caught rather than truncated
+	decimals, ok := callUintGetter(rpcURL, address.Hex(), "decimals()", nil, block)
+	if !ok || decimals.Cmp(big.NewInt(255)) > 0 {
+		return tokenMetadata{}, false
+	}
+	token := tokenMetadata{Decimals: new(int)}
+	*token.Decimals = int(decimals.Int64())
+	token.Name, _ = callStringGetter(rpcURL, address.Hex(), "name()", block)
+	token.Symbol, _ = callStringGetter(rpcURL, address.Hex(), "symbol()", block)
+	return token, true
+}
+
+// Function to manage the token metadata cache of a chain: list, add and clear
+func runTokens(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler tokens list|add|clear [flags] [addresses...]")
+	}
+	fs := flag.NewFlagSet("tokens "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting the endpoint")
+	refresh := fs.Bool("refresh", false, "read the tokens again even when they are cached")
+	fs.Parse(args[1:])
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cache := tokenCacheFor(endpoint)
+	if cache == nil {
+		return fmt.Errorf("failed to get the chain id of %s", displayEndpoint(endpoint))
+	}
+	switch args[0] {
+	case "list":
+		tokens := cache.snapshot()
+		if len(tokens) == 0 {
+			fmt.Printf("No tokens cached for %s\n", chainName(cache.chainID))
+			return nil
+		}
+		addresses := make([]string, 0, len(tokens))
+		for address := range tokens {
+			addresses = append(addresses, address)
+		}
+		sort.Slice(addresses, func(i, j int) bool {
+			return strings.ToLower(tokens[addresses[i]].Symbol) < strings.ToLower(tokens[addresses[j]].Symbol)
+		})
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "token\tsymbol\tdecimals\tname\t")
+		for _, address := range addresses {
+			token := tokens[address]
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t\n", common.HexToAddress(address).Hex(), token.Symbol, *token.Decimals, token.Name)
+		}
+		w.Flush()
+		fmt.Printf("\n%s cached for %s in %s\n", pluralize(len(tokens), "token"), chainName(cache.chainID), cache.path())
+		return nil
+
+	case "add":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: contract-curler tokens add [flags] <address>...")
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		for _, arg := range fs.Args() {
+			address, err := cfg.resolveAddress(arg, *chain, endpoint)
+			if err != nil {
+				return err
+			}
+			var token tokenMetadata
+			var ok bool
+			if *refresh {
+				if token, ok = fetchToken(endpoint, address, "latest"); ok {
+					err = cache.put(address, token)
+				}
+			} else {
+				token, ok = lookupToken(endpoint, address, "latest")
+			}
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s has no decimals(), it is not a token", address.Hex())
+			}
+			fmt.Printf("%s: %s, %d decimals\n", address.Hex(), firstNonEmpty(token.Symbol, "?"), *token.Decimals)
+		}
+		return nil
+
+	case "clear":
+		if err := cache.clear(); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared the token cache of %s\n", chainName(cache.chainID))
+		return nil
+	}
+	return fmt.Errorf("unknown tokens subcommand '%s' (expected list, add or clear)", args[0])
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// traceFrame is a call frame returned by the OpenEthereum-style trace_* methods
+type traceFrame struct {
+	Type   string `json:"type"`
+	Action struct {
+		CallType      string `json:"callType"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Value         string `json:"value"`
+		Input         string `json:"input"`
+		Init          string `json:"init"`
+		Address       string `json:"address"`
+		RefundAddress string `json:"refundAddress"`
+		Balance       string `json:"balance"`
+		Author        string `json:"author"`
+		RewardType    string `json:"rewardType"`
+	} `json:"action"`
+	Result *struct {
+		GasUsed string `json:"gasUsed"`
+		Output  string `json:"output"`
+		Address string `json:"address"`
+	} `json:"result"`
+	Error           string `json:"error"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+	BlockNumber     uint64 `json:"blockNumber"`
+}
+
+// Function to fetch and decode internal call traces
+func runTrace(args []string) error {
+	if len(args) == 0 || (args[0] != "tx" && args[0] != "filter") {
+		return fmt.Errorf("usage: contract-curler trace tx <hash> | trace filter [flags]")
+	}
+
+	fs := flag.NewFlagSet("trace "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", defaultRPC(), "Ethereum RPC URL exposing the trace_* methods")
+	abiPaths := fs.String("abi", "", "comma-separated ABI files used to label call frames")
+	from := fs.String("from", "", "first block (trace filter)")
+	to := fs.String("to", "latest", "last block (trace filter)")
+	fromAddress := fs.String("from-address", "", "comma-separated senders to filter on (trace filter)")
+	toAddress := fs.String("to-address", "", "comma-separated recipients to filter on (trace filter)")
+	count := fs.Int("count", 100, "maximum number of traces to return (trace filter)")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	var paths []string
+	if *abiPaths != "" {
+		paths = strings.Split(*abiPaths, ",")
+	}
+	decoder, err := newCallDecoder(paths)
+	if err != nil {
+		return err
+	}
+	opts := displayOptions(cfg)
+
+	var method string
+	var params []interface{}
+	if args[0] == "tx" {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: contract-curler trace tx <hash>")
+		}
+		method, params = "trace_transaction", []interface{}{fs.Arg(0)}
+	} else {
+		if *from == "" {
+			return fmt.Errorf("trace filter needs --from")
+		}
+		filter := map[string]interface{}{"fromBlock": *from, "toBlock": *to, "count": *count}
+		for key, value := range map[string]string{"fromAddress": *fromAddress, "toAddress": *toAddress} {
+			if value != "" {
+				filter[key] = strings.Split(value, ",")
+			}
+		}
+		for _, key := range []string{"fromBlock", "toBlock"} {
+			if s := filter[key].(string); s != "latest" && s != "earliest" && s != "pending" {
+				n, err := parseBlockNumber(s)
+				if err != nil {
+					return err
+				}
+				filter[key] = hexutil.EncodeUint64(n)
+			}
+		}
+		method, params = "trace_filter", []interface{}{filter}
+	}
+
+	// Frames are printed while the response is still arriving
+	printer := &tracePrinter{decoder: decoder, opts: opts}
+	err = streamRPC(*rpcURL, method, params, func(item json.RawMessage) error {
+		var frame traceFrame
+		if err := json.Unmarshal(item, &frame); err != nil {
+			return fmt.Errorf("failed to parse trace: %v", err)
+		}
+		printer.print(frame)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", method, err)
+	}
+	printer.finish()
+	return nil
+}
+
+// tracePrinter prints call frames as an indented tree per transaction while they are
+// decoded, and the value transfers seen once all frames are printed
+type tracePrinter struct {
+	decoder   *callDecoder
+	opts      formatOptions
+	currentTx string
+	transfers []string
+}
+
+// Function to print a call frame, starting a new transaction heading when needed
+func (p *tracePrinter) print(frame traceFrame) {
+	if frame.TransactionHash != p.currentTx {
+		p.currentTx = frame.TransactionHash
+		fmt.Printf("\nTransaction %s (block %d)\n", p.currentTx, frame.BlockNumber)
+	}
+
+	indent := strings.Repeat("  ", len(frame.TraceAddress)+1)
+	fmt.Println(indent + describeFrame(frame, p.decoder, p.opts))
+
+	if value := traceValue(frame.Action.Value); value.Sign() > 0 {
+		recipient := frame.Action.To
+		if frame.Type == "create" && frame.Result != nil {
+			recipient = frame.Result.Address
+		}
+		p.transfers = append(p.transfers, fmt.Sprintf("%s -> %s: %s ETH",
+			labelAddress(frame.Action.From, p.opts), labelAddress(recipient, p.opts), formatFixed(value, 18)))
+	}
+}
+
+// Function to print the value transfers of all printed frames
+func (p *tracePrinter) finish() {
+	if len(p.transfers) > 0 {
+		fmt.Println("\nValue transfers:")
+		for _, transfer := range p.transfers {
+			fmt.Println("  " + transfer)
+		}
+	}
+}
+
+// Function to describe a single call frame on one line
+func describeFrame(frame traceFrame, decoder *callDecoder, opts formatOptions) string {
+	action := frame.Action
+	var line string
+	switch frame.Type {
+	case "call":
+		callType := strings.ToUpper(firstNonEmpty(action.CallType, "call"))
+		line = fmt.Sprintf("%s %s -> %s", callType, labelAddress(action.From, opts), labelAddress(action.To, opts))
+		if input, err := hexutil.Decode(action.Input); err == nil && len(input) > 0 {
+			line += " " + decoder.describe(input, opts)
+		}
+	case "create":
+		line = fmt.Sprintf("CREATE %s", labelAddress(action.From, opts))
+		if frame.Result != nil && frame.Result.Address != "" {
+			line += " -> created " + labelAddress(frame.Result.Address, opts)
+		}
+	case "suicide":
+		line = fmt.Sprintf("SELFDESTRUCT %s -> refund %s", labelAddress(action.Address, opts), labelAddress(action.RefundAddress, opts))
+		if balance := traceValue(action.Balance); balance.Sign() > 0 {
+			line += fmt.Sprintf(" (%s ETH)", formatFixed(balance, 18))
+		}
+	case "reward":
+		line = fmt.Sprintf("REWARD (%s) %s", action.RewardType, labelAddress(action.Author, opts))
+	default:
+		line = strings.ToUpper(frame.Type)
+	}
+
+	if value := traceValue(action.Value); value.Sign() > 0 {
+		line += fmt.Sprintf(" [value %s ETH]", formatFixed(value, 18))
+	}
+	if frame.Result != nil && frame.Result.GasUsed != "" {
+		if gasUsed, err := hexutil.DecodeUint64(frame.Result.GasUsed); err == nil {
+			line += fmt.Sprintf(" [gas %d]", gasUsed)
+		}
+	}
+	if frame.Error != "" {
+		line += " ERROR: " + frame.Error
+	}
+	return line
+}
+
+// Function to parse a hex wei amount from a trace, treating missing values as zero
+func traceValue(value string) *big.Int {
+	n, err := hexutil.DecodeBig(value)
+	if err != nil {
+		return new(big.Int)
+	}
+	return n
+}
+
+// Function to render a hex address string with its label when one is known
+func labelAddress(address string, opts formatOptions) string {
+	if !common.IsHexAddress(address) {
+		return address
+	}
+	return formatAddress(common.HexToAddress(address), opts)
+}
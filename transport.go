@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/contract-curler/rpc"
+)
+
+// Environment variables turning on the logging of every JSON-RPC request, and a summary
+// of the requests sent to each endpoint printed on exit
+const (
+	rpcLogEnv     = "CONTRACT_CURLER_RPC_LOG"
+	rpcMetricsEnv = "CONTRACT_CURLER_RPC_METRICS"
+)
+
+// Function to set up the transports of the rpc package with the shared HTTP client and the
+// middlewares of the tool
+func init() {
+	rpc.Client = httpClient
+	rpc.DialTimeout = sinkDialTimeout
+	rpc.UserAgent = "contract-curler/" + toolVersion()
+	// The first one is outermost
+	rpc.Use(readOnlyMiddleware, auditMiddleware, rpc.Retry, hookMiddleware, rateLimitMiddleware, logMiddleware, metricsMiddleware)
+}
+
+// Function to throttle the calls of endpoints with a rate limit
+func rateLimitMiddleware(next rpc.Transport) rpc.Transport {
+	return rpc.TransportFunc(func(call *rpc.Call) (io.ReadCloser, error) {
+		limiterFor(call.Endpoint).wait()
+		return next.RoundTrip(call)
+	})
+}
+
+// Function to log each attempt of a call with its latency when $CONTRACT_CURLER_RPC_LOG
+// is set
+func logMiddleware(next rpc.Transport) rpc.Transport {
+	if os.Getenv(rpcLogEnv) == "" {
+		return next
+	}
+	return rpc.TransportFunc(func(call *rpc.Call) (io.ReadCloser, error) {
+		started := time.Now()
+		body, err := next.RoundTrip(call)
+		elapsed := time.Since(started).Round(time.Millisecond)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rpc %s %s (%s): %v\n", payloadMethod(call.Payload), displayEndpoint(call.Endpoint), elapsed, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "rpc %s %s (%s)\n", payloadMethod(call.Payload), displayEndpoint(call.Endpoint), elapsed)
+		}
+		return body, err
+	})
+}
+
+// Function to describe a payload by its method, or its size for batches
+func payloadMethod(payload []byte) string {
+	var batch []JsonRpcRequest
+	if json.Unmarshal(payload, &batch) == nil {
+		return fmt.Sprintf("batch of %d", len(batch))
+	}
+	var request JsonRpcRequest
+	if json.Unmarshal(payload, &request) == nil && request.Method != "" {
+		return request.Method
+	}
+	return "request"
+}
+
+// endpointMetrics counts the calls made to an endpoint
+type endpointMetrics struct {
+	calls, failures int
+	total, slowest  time.Duration
+}
+
+var rpcMetrics struct {
+	sync.Mutex
+	endpoints map[string]*endpointMetrics
+}
+
+// Function to count the calls of each endpoint and their latency when
+// $CONTRACT_CURLER_RPC_METRICS is set, for printRPCMetrics
+func metricsMiddleware(next rpc.Transport) rpc.Transport {
+	if os.Getenv(rpcMetricsEnv) == "" {
+		return next
+	}
+	return rpc.TransportFunc(func(call *rpc.Call) (io.ReadCloser, error) {
+		started := time.Now()
+		body, err := next.RoundTrip(call)
+		elapsed := time.Since(started)
+		rpcMetrics.Lock()
+		defer rpcMetrics.Unlock()
+		if rpcMetrics.endpoints == nil {
+			rpcMetrics.endpoints = make(map[string]*endpointMetrics)
+		}
+		m, ok := rpcMetrics.endpoints[call.Endpoint]
+		if !ok {
+			m = &endpointMetrics{}
+			rpcMetrics.endpoints[call.Endpoint] = m
+		}
+		m.calls++
+		if err != nil {
+			m.failures++
+		}
+		m.total += elapsed
+		m.slowest = max(m.slowest, elapsed)
+		return body, err
+	})
+}
+
+// Function to print the calls made to each endpoint, when metrics were collected
+func printRPCMetrics() {
+	rpcMetrics.Lock()
+	defer rpcMetrics.Unlock()
+	if len(rpcMetrics.endpoints) == 0 {
+		return
+	}
+	var endpoints []string
+	for endpoint := range rpcMetrics.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	fmt.Fprintln(os.Stderr, "\nRPC requests:")
+	for _, endpoint := range endpoints {
+		m := rpcMetrics.endpoints[endpoint]
+		average := (m.total / time.Duration(m.calls)).Round(time.Millisecond)
+		fmt.Fprintf(os.Stderr, "  %s: %s, %d failed, %s average, %s slowest\n", displayEndpoint(endpoint),
+			pluralize(m.calls, "request"), m.failures, average, m.slowest.Round(time.Millisecond))
+	}
+}
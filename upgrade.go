@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Storage slots of the implementation and the beacon defined by EIP-1967:
+// keccak256("eip1967.proxy.implementation") - 1 and keccak256("eip1967.proxy.beacon") - 1
+const (
+	eip1967ImplementationSlot = "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc"
+	eip1967BeaconSlot         = "0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50"
+)
+
+// Function to encode the upgrade of an EIP-1967 proxy to a new implementation, sent to the
+// proxy itself for UUPS proxies and transparent proxies administered by an account, and to
+// the ProxyAdmin otherwise. The upgrade is checked before it is printed: the implementation
+// must have code, UUPS implementations must return the implementation slot from
+// proxiableUUID() and keep an upgrade function, and the call is simulated from the account
+// allowed to make it.
+func runUpgrade(args []string) error {
+	fs := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "chain name or id, selecting its endpoint profile and contract deployments")
+	initSig := fs.String("init-sig", "", "function called on the new implementation through the proxy after the upgrade, with its arguments given after the addresses")
+	from := fs.String("from", "", "account sending the upgrade, for the simulation (default: the proxy admin or owner)")
+	force := fs.Bool("force", false, "print the upgrade even when a safety check fails")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: contract-curler upgrade [flags] <proxy> <new-implementation> [init args...]")
+	}
+	if *initSig == "" && fs.NArg() > 2 {
+		return fmt.Errorf("initialization arguments need --init-sig")
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	proxy, err := cfg.resolveAddress(fs.Arg(0), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	implementation, err := cfg.resolveAddress(fs.Arg(1), *chain, endpoint)
+	if err != nil {
+		return err
+	}
+	initData := "0x"
+	if *initSig != "" {
+		ctx, err := newArgContext(endpoint, *chain, "latest")
+		if err != nil {
+			return err
+		}
+		initArgs, err := ctx.resolveArgs(*initSig, fs.Args()[2:])
+		if err != nil {
+			return err
+		}
+		if initData, err = encodeMethodCall(*initSig, initArgs); err != nil {
+			return fmt.Errorf("failed to encode initialization call: %v", err)
+		}
+	}
+
+	current, err := readAddressSlot(endpoint, proxy, eip1967ImplementationSlot)
+	if err != nil {
+		return err
+	}
+	if current == (common.Address{}) {
+		if beacon, err := readAddressSlot(endpoint, proxy, eip1967BeaconSlot); err == nil && beacon != (common.Address{}) {
+			return fmt.Errorf("%s is a beacon proxy, upgrade its beacon %s instead", proxy.Hex(), beacon.Hex())
+		}
+		return fmt.Errorf("%s has no EIP-1967 implementation, it is not a proxy this command can upgrade", proxy.Hex())
+	}
+	admin, err := readAddressSlot(endpoint, proxy, eip1967AdminSlot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Proxy:                  %s\n", proxy.Hex())
+	fmt.Printf("Current implementation: %s\n", current.Hex())
+	fmt.Printf("New implementation:     %s\n", implementation.Hex())
+
+	// Safety checks, each failing the upgrade unless --force is given
+	var problems []string
+	var code hexutil.Bytes
+	if err := callRPC(endpoint, &code, "eth_getCode", implementation.Hex(), "latest"); err != nil {
+		return fmt.Errorf("failed to get code of %s: %v", implementation.Hex(), err)
+	}
+	if len(code) == 0 {
+		problems = append(problems, fmt.Sprintf("%s has no code, the proxy would stop working", implementation.Hex()))
+	}
+	if implementation == current {
+		problems = append(problems, "the proxy already uses this implementation")
+	}
+
+	// OpenZeppelin 5 removed upgradeTo and upgrade, leaving only the AndCall variants
+	var target common.Address
+	var data, kind string
+	switch {
+	case admin == (common.Address{}):
+		kind = "UUPS"
+		target = proxy
+		if uuid, ok := callBytes32Getter(endpoint, implementation, "proxiableUUID()"); !ok {
+			problems = append(problems, "the new implementation has no proxiableUUID(), it is not UUPS upgradeable")
+		} else if uuid != common.HexToHash(eip1967ImplementationSlot) {
+			problems = append(problems, fmt.Sprintf("proxiableUUID() of the new implementation is %s, not the EIP-1967 implementation slot", uuid.Hex()))
+		}
+		if len(code) > 0 && !bytes.Contains(code, hexutil.MustDecode("0x"+functionSelector("upgradeToAndCall(address,bytes)"))) {
+			problems = append(problems, "the new implementation has no upgradeToAndCall(address,bytes), the proxy could never be upgraded again")
+		}
+		if initData == "0x" && upgradeInterfaceVersion(endpoint, proxy) == "" {
+			data, err = encodeMethodCall("upgradeTo(address)", []string{implementation.Hex()})
+		} else {
+			data, err = encodeMethodCall("upgradeToAndCall(address,bytes)", []string{implementation.Hex(), initData})
+		}
+	case !hasCode(endpoint, admin):
+		kind = "transparent, administered by an account"
+		target = proxy
+		if initData == "0x" {
+			data, err = encodeMethodCall("upgradeTo(address)", []string{implementation.Hex()})
+		} else {
+			data, err = encodeMethodCall("upgradeToAndCall(address,bytes)", []string{implementation.Hex(), initData})
+		}
+	default:
+		kind = "transparent, administered by ProxyAdmin " + admin.Hex()
+		target = admin
+		if initData == "0x" && upgradeInterfaceVersion(endpoint, admin) == "" {
+			data, err = encodeMethodCall("upgrade(address,address)", []string{proxy.Hex(), implementation.Hex()})
+		} else {
+			data, err = encodeMethodCall("upgradeAndCall(address,address,bytes)", []string{proxy.Hex(), implementation.Hex(), initData})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode upgrade: %v", err)
+	}
+	fmt.Printf("Kind:                   %s\n", kind)
+
+	// The upgrade is simulated from the account allowed to make it
+	sender := *from
+	if sender == "" {
+		switch {
+		case target == proxy && admin != (common.Address{}):
+			sender = admin.Hex()
+		default:
+			if owner, ok := callAddressGetter(endpoint, target, "owner()", "latest"); ok {
+				sender = owner.Hex()
+			}
+		}
+	}
+	if sender == "" {
+		fmt.Println(colorize(colorYellow, "Not simulated") + ": no admin or owner found, give the sender with --from")
+	} else {
+		var output hexutil.Bytes
+		call := map[string]interface{}{"from": sender, "to": target.Hex(), "data": data}
+		if err := callRPC(endpoint, &output, "eth_call", call, "latest"); err != nil {
+			problems = append(problems, fmt.Sprintf("the upgrade fails when sent from %s: %v", sender, err))
+		} else {
+			fmt.Printf("Simulated from:         %s\n", sender)
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Println(colorize(colorRed, "Check failed") + ": " + problem)
+	}
+	if len(problems) > 0 && !*force {
+		return fmt.Errorf("%s failed, not printing the upgrade (use --force to print it anyway)", pluralize(len(problems), "safety check"))
+	}
+	fmt.Printf("\nSend to:  %s\n", target.Hex())
+	fmt.Printf("Calldata: %s\n", data)
+	fmt.Printf("\ncontract-curler send --to %s --data %s\n", target.Hex(), data)
+	return nil
+}
+
+// Function to read an address stored right aligned in a storage slot
+func readAddressSlot(rpcURL string, contract common.Address, slot string) (common.Address, error) {
+	var word common.Hash
+	if err := callRPC(rpcURL, &word, "eth_getStorageAt", contract.Hex(), slot, "latest"); err != nil {
+		return common.Address{}, fmt.Errorf("failed to read storage of %s: %v", contract.Hex(), err)
+	}
+	return common.BytesToAddress(word.Bytes()), nil
+}
+
+// Function to tell whether an account has code, assuming it does when it cannot be read
+func hasCode(rpcURL string, account common.Address) bool {
+	var code hexutil.Bytes
+	if err := callRPC(rpcURL, &code, "eth_getCode", account.Hex(), "latest"); err != nil {
+		return true
+	}
+	return len(code) > 0
+}
+
+// Function to call a getter returning a bytes32, reporting false when it is missing or
+// reverts
+func callBytes32Getter(rpcURL string, contract common.Address, getter string) (common.Hash, bool) {
+	values, err := callForValues(rpcURL, contract.Hex(), "0x"+functionSelector(getter), "latest", "(bytes32)")
+	if err != nil {
+		return common.Hash{}, false
+	}
+	hash, ok := values[0].([32]byte)
+	return hash, ok
+}
+
+// Function to get the UPGRADE_INTERFACE_VERSION of an OpenZeppelin 5 proxy or ProxyAdmin,
+// empty for earlier versions
+func upgradeInterfaceVersion(rpcURL string, contract common.Address) string {
+	values, err := callForValues(rpcURL, contract.Hex(), "0x"+functionSelector("UPGRADE_INTERFACE_VERSION()"), "latest", "(string)")
+	if err != nil {
+		return ""
+	}
+	version, _ := values[0].(string)
+	return strings.TrimSpace(version)
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/contract-curler/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Environment variable holding the WalletConnect Cloud project id
+const walletConnectProjectEnv = "WALLETCONNECT_PROJECT_ID"
+
+// Relay server of WalletConnect v2
+const walletConnectRelay = "wss://relay.walletconnect.com"
+
+// Time the wallet has to answer the pairing and each request, as the user confirms them
+// on their phone
+const walletConnectTimeout = 5 * time.Minute
+
+// Lifetime of the messages published on the relay, in seconds
+const walletConnectTTL = 300
+
+// Relay tags of the requests sent to a wallet, telling the relay how to handle them
+const (
+	wcTagSessionPropose = 1100
+	wcTagSessionRequest = 1108
+	wcTagSessionDelete  = 1112
+)
+
+// Relay tags of the responses to the requests of a wallet, by method
+var wcResponseTags = map[string]int{
+	"wc_pairingDelete": 1001,
+	"wc_pairingPing":   1003,
+	"wc_sessionSettle": 1103,
+	"wc_sessionUpdate": 1105,
+	"wc_sessionExtend": 1107,
+	"wc_sessionEvent":  1111,
+	"wc_sessionDelete": 1113,
+	"wc_sessionPing":   1115,
+}
+
+// walletConnectOptions are the flags selecting a WalletConnect wallet to sign with
+type walletConnectOptions struct {
+	enabled   *bool
+	projectID *string
+}
+
+// Function to register the flags signing with a wallet over WalletConnect
+func addWalletConnectFlags(fs *flag.FlagSet) *walletConnectOptions {
+	return &walletConnectOptions{
+		enabled:   fs.Bool("walletconnect", false, "sign with a mobile wallet paired over WalletConnect instead of a local key"),
+		projectID: fs.String("project-id", "", "WalletConnect Cloud project id (default: $"+walletConnectProjectEnv+")"),
+	}
+}
+
+// Function to pair with a wallet when --walletconnect is given, returning nil otherwise
+func (o *walletConnectOptions) connect(chainID uint64) (*wcSession, error) {
+	if !*o.enabled {
+		return nil, nil
+	}
+	if err := requireWritable("signing with WalletConnect"); err != nil {
+		return nil, err
+	}
+	projectID := firstNonEmpty(*o.projectID, os.Getenv(walletConnectProjectEnv))
+	if projectID == "" {
+		return nil, fmt.Errorf("a WalletConnect project id is required (--project-id or $%s)", walletConnectProjectEnv)
+	}
+	return connectWallet(projectID, chainID)
+}
+
+// wcSession is a WalletConnect v2 session with a wallet, approved for one chain
+type wcSession struct {
+	relay   *rpc.Conn
+	chainID uint64
+	// Account of the wallet on the chain
+	account common.Address
+	// Topic and key of the session, once the wallet approved the proposal
+	topic string
+	key   []byte
+
+	mu sync.Mutex
+	// Symmetric keys of the topics subscribed to
+	keys map[string][]byte
+	// Responses of the relay awaited by id
+	pending map[uint64]chan wcRelayMessage
+	// Decrypted messages of the wallet
+	inbox chan wcMessage
+	// Set when the relay connection fails, closing done
+	err  error
+	done chan struct{}
+}
+
+// wcRelayMessage is a JSON-RPC message of the relay
+type wcRelayMessage struct {
+	ID     uint64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *JsonRpcError   `json:"error"`
+}
+
+// wcMessage is a JSON-RPC message exchanged with the wallet, a request or a response
+type wcMessage struct {
+	ID      uint64          `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
+	topic   string
+}
+
+// Function to connect to the relay, show the pairing URI and QR code, and wait for the
+// wallet to approve a session on the chain
+func connectWallet(projectID string, chainID uint64) (*wcSession, error) {
+	token, err := walletConnectAuth()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"auth": {token}, "projectId": {projectID}, "ua": {"wc-2/go-contract-curler-" + toolVersion()}}
+	relay, err := rpc.DialWebSocket(walletConnectRelay + "/?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the WalletConnect relay: %v", err)
+	}
+	s := &wcSession{
+		relay: relay, chainID: chainID,
+		keys:    make(map[string][]byte),
+		pending: make(map[uint64]chan wcRelayMessage),
+		inbox:   make(chan wcMessage, 16),
+		done:    make(chan struct{}),
+	}
+	go s.readRelay()
+
+	pairingTopic, pairingKey := randomHex(32), make([]byte, 32)
+	rand.Read(pairingKey)
+	if err := s.subscribe(pairingTopic, pairingKey); err != nil {
+		relay.Close()
+		return nil, err
+	}
+	private := make([]byte, curve25519.ScalarSize)
+	rand.Read(private)
+	public, _ := curve25519.X25519(private, curve25519.Basepoint)
+
+	chain := fmt.Sprintf("eip155:%d", chainID)
+	proposal := map[string]interface{}{
+		"relays": []interface{}{map[string]string{"protocol": "irn"}},
+		"requiredNamespaces": map[string]interface{}{
+			"eip155": map[string]interface{}{
+				"chains":  []string{chain},
+				"methods": []string{"eth_sendTransaction", "eth_signTypedData_v4", "personal_sign"},
+				"events":  []string{"chainChanged", "accountsChanged"},
+			},
+		},
+		"proposer": map[string]interface{}{
+			"publicKey": hex.EncodeToString(public),
+			"metadata": map[string]interface{}{
+				"name":        "contract-curler",
+				"description": "Command line client for Ethereum contracts",
+				"url":         "https://github.com/jaethewiederholen/contract-curler",
+				"icons":       []string{},
+			},
+		},
+	}
+	id, err := s.sendRequest(pairingTopic, "wc_sessionPropose", proposal, wcTagSessionPropose)
+	if err != nil {
+		s.relay.Close()
+		return nil, err
+	}
+
+	expiry := time.Now().Add(walletConnectTTL * time.Second).Unix()
+	uri := fmt.Sprintf("wc:%s@2?relay-protocol=irn&symKey=%s&expiryTimestamp=%d", pairingTopic, hex.EncodeToString(pairingKey), expiry)
+	if qr, err := encodeQR([]byte(uri)); err == nil {
+		qr.print(os.Stderr)
+	}
+	fmt.Fprintln(os.Stderr, "Scan the QR code with your wallet, or paste the pairing URI:")
+	fmt.Fprintln(os.Stderr, uri)
+
+	var approval struct {
+		ResponderPublicKey string `json:"responderPublicKey"`
+	}
+	if err := s.awaitResponse(id, &approval); err != nil {
+		s.relay.Close()
+		return nil, fmt.Errorf("the wallet rejected the session: %v", err)
+	}
+	peer, err := hex.DecodeString(approval.ResponderPublicKey)
+	if err != nil || len(peer) != curve25519.PointSize {
+		s.relay.Close()
+		return nil, fmt.Errorf("the wallet sent an invalid public key '%s'", approval.ResponderPublicKey)
+	}
+	shared, err := curve25519.X25519(private, peer)
+	if err != nil {
+		s.relay.Close()
+		return nil, fmt.Errorf("failed to derive the session key: %v", err)
+	}
+	s.key = make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, shared, nil, nil), s.key)
+	topic := sha256.Sum256(s.key)
+	s.topic = hex.EncodeToString(topic[:])
+	if err := s.subscribe(s.topic, s.key); err != nil {
+		s.relay.Close()
+		return nil, err
+	}
+
+	// The wallet settles the session on its topic, giving its accounts
+	deadline := time.After(walletConnectTimeout)
+	for s.account == (common.Address{}) {
+		select {
+		case message := <-s.inbox:
+			if err := s.handleRequest(message); err != nil {
+				s.relay.Close()
+				return nil, err
+			}
+		case <-s.done:
+			return nil, s.err
+		case <-deadline:
+			s.Close()
+			return nil, fmt.Errorf("the wallet did not settle the session within %s", walletConnectTimeout)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Connected to wallet account %s on %s\n", s.account.Hex(), chainName(chainID))
+	return s, nil
+}
+
+// Function to create the JWT authenticating the client to the relay, signed by a fresh
+// Ed25519 key identified as a did:key
+func walletConnectAuth() (string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the relay key: %v", err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT"})
+	now := time.Now().Unix()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"iss": "did:key:z" + base58Encode(append([]byte{0xed, 0x01}, public...)),
+		"sub": randomHex(32),
+		"aud": walletConnectRelay,
+		"iat": now,
+		"exp": now + 86400,
+	})
+	signed := encode(header) + "." + encode(payload)
+	return signed + "." + encode(ed25519.Sign(private, []byte(signed))), nil
+}
+
+// Function to get random bytes as hex
+func randomHex(n int) string {
+	data := make([]byte, n)
+	rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
+// Function to create a JSON-RPC id from the time, as WalletConnect clients do
+func wcMessageID() uint64 {
+	var r [2]byte
+	rand.Read(r[:])
+	return uint64(time.Now().UnixMilli())*1000 + uint64(binary.BigEndian.Uint16(r[:]))%1000
+}
+
+// Function to read the relay until the connection fails, acknowledging the messages
+// published on the topics subscribed to and passing them on decrypted
+func (s *wcSession) readRelay() {
+	defer close(s.done)
+	for {
+		data, err := s.relay.ReadMessage()
+		if err != nil {
+			s.err = fmt.Errorf("lost the connection to the WalletConnect relay: %v", err)
+			return
+		}
+		var message wcRelayMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			continue
+		}
+		if message.Method == "" {
+			s.mu.Lock()
+			waiting := s.pending[message.ID]
+			delete(s.pending, message.ID)
+			s.mu.Unlock()
+			if waiting != nil {
+				waiting <- message
+			}
+			continue
+		}
+		if message.Method != "irn_subscription" {
+			continue
+		}
+		ack, _ := json.Marshal(map[string]interface{}{"id": message.ID, "jsonrpc": "2.0", "result": true})
+		s.relay.WriteMessage(ack)
+		var params struct {
+			Data struct {
+				Topic   string `json:"topic"`
+				Message string `json:"message"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(message.Params, &params); err != nil {
+			continue
+		}
+		s.mu.Lock()
+		key := s.keys[params.Data.Topic]
+		s.mu.Unlock()
+		plain, err := wcDecrypt(key, params.Data.Message)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring undecryptable WalletConnect message: %v\n", err)
+			continue
+		}
+		var payload wcMessage
+		if err := json.Unmarshal(plain, &payload); err != nil {
+			continue
+		}
+		payload.topic = params.Data.Topic
+		s.inbox <- payload
+	}
+}
+
+// Function to call a method of the relay and wait for its result
+func (s *wcSession) relayCall(method string, params interface{}) error {
+	id := wcMessageID()
+	waiting := make(chan wcRelayMessage, 1)
+	s.mu.Lock()
+	s.pending[id] = waiting
+	s.mu.Unlock()
+	request, _ := json.Marshal(map[string]interface{}{"id": id, "jsonrpc": "2.0", "method": method, "params": params})
+	if err := s.relay.WriteMessage(request); err != nil {
+		return err
+	}
+	select {
+	case response := <-waiting:
+		if response.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, response.Error.Message)
+		}
+		return nil
+	case <-s.done:
+		return s.err
+	case <-time.After(sinkDialTimeout):
+		return fmt.Errorf("%s timed out", method)
+	}
+}
+
+// Function to subscribe to a topic whose messages are encrypted with a key
+func (s *wcSession) subscribe(topic string, key []byte) error {
+	s.mu.Lock()
+	s.keys[topic] = key
+	s.mu.Unlock()
+	return s.relayCall("irn_subscribe", map[string]string{"topic": topic})
+}
+
+// Function to encrypt a message and publish it on a topic
+func (s *wcSession) publish(topic string, message wcMessage, tag int) error {
+	message.JSONRPC = "2.0"
+	plain, _ := json.Marshal(message)
+	s.mu.Lock()
+	key := s.keys[topic]
+	s.mu.Unlock()
+	sealed, err := wcEncrypt(key, plain)
+	if err != nil {
+		return err
+	}
+	return s.relayCall("irn_publish", map[string]interface{}{
+		"topic": topic, "message": sealed, "ttl": walletConnectTTL, "tag": tag, "prompt": true,
+	})
+}
+
+// Function to send a request to the wallet on a topic, returning its id
+func (s *wcSession) sendRequest(topic, method string, params interface{}, tag int) (uint64, error) {
+	encoded, _ := json.Marshal(params)
+	id := wcMessageID()
+	return id, s.publish(topic, wcMessage{ID: id, Method: method, Params: encoded}, tag)
+}
+
+// Function to wait for the wallet's response to a request, answering its own requests in
+// the meantime
+func (s *wcSession) awaitResponse(id uint64, result interface{}) error {
+	deadline := time.After(walletConnectTimeout)
+	for {
+		select {
+		case message := <-s.inbox:
+			if message.Method != "" {
+				if err := s.handleRequest(message); err != nil {
+					return err
+				}
+				continue
+			}
+			if message.ID != id {
+				continue
+			}
+			if message.Error != nil {
+				return fmt.Errorf("%s", message.Error.Message)
+			}
+			if err := json.Unmarshal(message.Result, result); err != nil {
+				return fmt.Errorf("invalid response from the wallet: %v", err)
+			}
+			return nil
+		case <-s.done:
+			return s.err
+		case <-deadline:
+			return fmt.Errorf("no answer from the wallet within %s", walletConnectTimeout)
+		}
+	}
+}
+
+// Function to answer a request of the wallet: the settlement of the session gives its
+// account, and deleting the session ends the command
+func (s *wcSession) handleRequest(message wcMessage) error {
+	tag, ok := wcResponseTags[message.Method]
+	if !ok {
+		return nil
+	}
+	if err := s.publish(message.topic, wcMessage{ID: message.ID, Result: json.RawMessage("true")}, tag); err != nil {
+		return err
+	}
+	switch message.Method {
+	case "wc_sessionSettle":
+		if message.topic != s.topic {
+			return nil
+		}
+		var settle struct {
+			Namespaces map[string]struct {
+				Accounts []string `json:"accounts"`
+			} `json:"namespaces"`
+		}
+		if err := json.Unmarshal(message.Params, &settle); err != nil {
+			return fmt.Errorf("invalid session settlement from the wallet: %v", err)
+		}
+		prefix := fmt.Sprintf("eip155:%d:", s.chainID)
+		for _, account := range settle.Namespaces["eip155"].Accounts {
+			if strings.HasPrefix(account, prefix) && common.IsHexAddress(strings.TrimPrefix(account, prefix)) {
+				s.account = common.HexToAddress(strings.TrimPrefix(account, prefix))
+				return nil
+			}
+		}
+		return fmt.Errorf("the wallet approved no account on %s", chainName(s.chainID))
+	case "wc_sessionDelete", "wc_pairingDelete":
+		return fmt.Errorf("the wallet ended the session")
+	}
+	return nil
+}
+
+// Function to have the wallet handle a JSON-RPC request, like signing, on the chain of the
+// session
+func (s *wcSession) request(method string, params []interface{}, result interface{}) error {
+	id, err := s.sendRequest(s.topic, "wc_sessionRequest", map[string]interface{}{
+		"request": map[string]interface{}{"method": method, "params": params},
+		"chainId": fmt.Sprintf("eip155:%d", s.chainID),
+	}, wcTagSessionRequest)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Confirm the request in your wallet")
+	if err := s.awaitResponse(id, result); err != nil {
+		return fmt.Errorf("%s failed in the wallet: %v", method, err)
+	}
+	return nil
+}
+
+// Function to end the session and disconnect from the relay
+func (s *wcSession) Close() error {
+	if s.topic != "" {
+		s.sendRequest(s.topic, "wc_sessionDelete", map[string]interface{}{"code": 6000, "message": "User disconnected."}, wcTagSessionDelete)
+	}
+	return s.relay.Close()
+}
+
+// Function to encrypt a message as a type 0 envelope: the type byte, the nonce and the
+// ChaCha20-Poly1305 ciphertext, in base64
+func wcEncrypt(key, plain []byte) (string, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid WalletConnect key: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	envelope := aead.Seal(append([]byte{0}, nonce...), nonce, plain, nil)
+	return base64.StdEncoding.EncodeToString(envelope), nil
+}
+
+// Function to decrypt a type 0 envelope
+func wcDecrypt(key []byte, message string) ([]byte, error) {
+	envelope, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope: %v", err)
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("no key for the topic")
+	}
+	if len(envelope) < 1+aead.NonceSize() || envelope[0] != 0 {
+		return nil, fmt.Errorf("unsupported envelope")
+	}
+	nonce := envelope[1 : 1+aead.NonceSize()]
+	return aead.Open(nil, nonce, envelope[1+aead.NonceSize():], nil)
+}
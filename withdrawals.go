@@ -0,0 +1,472 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Arbitrum precompile building the Merkle proofs of L2 to L1 messages
+var nodeInterface = common.HexToAddress("0x00000000000000000000000000000000000000C8")
+
+// Dispute games searched, newest first, for one covering a withdrawal
+const maxDisputeGames = 50
+
+// Status of a dispute game its challenger won, whose output root cannot prove anything
+const challengerWins = 1
+
+// withdrawalTransaction is an OP Stack withdrawal as the OptimismPortal takes it
+type withdrawalTransaction struct {
+	Nonce    *big.Int
+	Sender   common.Address
+	Target   common.Address
+	Value    *big.Int
+	GasLimit *big.Int
+	Data     []byte
+}
+
+// outputRootProof is the preimage of an L2 output root, proving the storage root of the
+// L2ToL1MessagePasser at an L2 block
+type outputRootProof struct {
+	Version                  [32]byte
+	StateRoot                [32]byte
+	MessagePasserStorageRoot [32]byte
+	LatestBlockhash          [32]byte
+}
+
+// Members of the OptimismPortal structs, in the order of their Solidity declaration
+var (
+	withdrawalTransactionComponents = []abi.ArgumentMarshaling{
+		{Name: "nonce", Type: "uint256"},
+		{Name: "sender", Type: "address"},
+		{Name: "target", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "gasLimit", Type: "uint256"},
+		{Name: "data", Type: "bytes"},
+	}
+	outputRootProofComponents = []abi.ArgumentMarshaling{
+		{Name: "version", Type: "bytes32"},
+		{Name: "stateRoot", Type: "bytes32"},
+		{Name: "messagePasserStorageRoot", Type: "bytes32"},
+		{Name: "latestBlockhash", Type: "bytes32"},
+	}
+)
+
+// Function to run the withdrawal subcommands, building the L1 transactions completing an
+// L2 to L1 withdrawal sent by an L2 transaction: prove and finalize for OP Stack chains,
+// execute for Arbitrum chains. Each checks the withdrawal can take the step, simulates
+// the call and prints it ready to send.
+func runWithdrawal(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract-curler withdrawal prove|finalize|execute [flags] <L2 tx hash>")
+	}
+	fs := flag.NewFlagSet("withdrawal "+args[0], flag.ExitOnError)
+	rpcURL := fs.String("rpc", "", "Ethereum RPC URL of the L2 (default: the endpoint of --chain, or "+defaultRPC()+")")
+	chain := fs.String("chain", "", "L2 chain name or id, selecting its endpoint")
+	destRPC := fs.String("dest-rpc", "", "RPC URL of the L1 (default: the endpoint profile of the chain)")
+	destContract := fs.String("dest-contract", "", "OptimismPortal or Outbox on the L1 (default: the known one of the L2)")
+	index := fs.Int("index", 0, "withdrawal to use when the transaction sent several, counting from 0")
+	chunk := fs.Uint64("chunk", 10000, "number of L1 blocks requested per eth_getLogs call looking for the latest confirmed Arbitrum outbox root")
+	lookback := fs.Uint64("lookback", 100000, "number of L1 blocks searched for the latest confirmed Arbitrum outbox root")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: contract-curler withdrawal %s [flags] <L2 tx hash>", args[0])
+	}
+	endpoint := firstNonEmpty(*rpcURL, chainRPC(*chain))
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	l2Chain, err := cfg.chainID(*chain, endpoint)
+	if err != nil {
+		return err
+	}
+	receipt, err := sentMessageReceipt(endpoint, fs.Arg(0), l2Chain)
+	if err != nil {
+		return err
+	}
+
+	// Function to pick the withdrawal event of the transaction and the L1 contract settling it
+	settle := func(topic common.Hash, emitter common.Address, settlements map[uint64]settlement, kind string) (LogEntry, string, common.Address, error) {
+		var events []LogEntry
+		for _, entry := range receipt.Logs {
+			if len(entry.Topics) > 0 && common.HexToHash(entry.Topics[0]) == topic && common.HexToAddress(entry.Address) == emitter {
+				events = append(events, entry)
+			}
+		}
+		if len(events) == 0 {
+			return LogEntry{}, "", common.Address{}, fmt.Errorf("transaction %s sent no %s withdrawal", fs.Arg(0), kind)
+		}
+		if *index < 0 || *index >= len(events) {
+			return LogEntry{}, "", common.Address{}, fmt.Errorf("transaction %s sent %s, --index %d is out of range", fs.Arg(0), pluralize(len(events), kind+" withdrawal"), *index)
+		}
+		known, ok := settlements[l2Chain]
+		if !ok && *destContract == "" {
+			return LogEntry{}, "", common.Address{}, fmt.Errorf("no %s L1 contract known for %s, give it with --dest-rpc and --dest-contract", kind, chainName(l2Chain))
+		}
+		l1, err := destinationRPC(cfg, *destRPC, known.chain)
+		if err != nil {
+			return LogEntry{}, "", common.Address{}, err
+		}
+		return events[*index], l1, common.HexToAddress(firstNonEmpty(*destContract, known.contract)), nil
+	}
+
+	var l1, data string
+	var target common.Address
+	switch args[0] {
+	case "prove", "finalize":
+		var entry LogEntry
+		if entry, l1, target, err = settle(messagePassedTopic, l2ToL1MessagePasser, optimismPortals, "OP Stack"); err != nil {
+			return err
+		}
+		tx, withdrawalHash, err := decodeMessagePassed(entry)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Withdrawal: %s\n", withdrawalHash.Hex())
+		fmt.Printf("Portal:     %s\n", target.Hex())
+		finalized, err := callForValues(l1, target.Hex(), "0x"+functionSelector("finalizedWithdrawals(bytes32)")+strings.TrimPrefix(withdrawalHash.Hex(), "0x"), "latest", "(bool)")
+		if err != nil {
+			return fmt.Errorf("finalizedWithdrawals failed: %v", err)
+		}
+		if finalized[0].(bool) {
+			return fmt.Errorf("withdrawal %s is already finalized", withdrawalHash.Hex())
+		}
+		provenAt, submitter, err := optimismProof(l1, target, withdrawalHash)
+		if err != nil {
+			return err
+		}
+		if args[0] == "prove" {
+			if provenAt != nil {
+				fmt.Fprintf(os.Stderr, "Withdrawal was already proven at %s, proving it again\n", time.Unix(provenAt.Int64(), 0).UTC().Format(time.RFC3339))
+			}
+			data, err = optimismProveCalldata(endpoint, l1, target, tx, withdrawalHash, receipt.BlockNumber)
+		} else {
+			if provenAt == nil {
+				return fmt.Errorf("withdrawal %s is not proven yet, prove it first with: contract-curler withdrawal prove", withdrawalHash.Hex())
+			}
+			if finalizable := optimismFinalizable(l1, target, provenAt); time.Now().Before(finalizable) {
+				return fmt.Errorf("withdrawal %s can only be finalized after %s", withdrawalHash.Hex(), finalizable.UTC().Format(time.RFC3339))
+			}
+			data, err = optimismFinalizeCalldata(tx, submitter)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "execute":
+		var entry LogEntry
+		if entry, l1, target, err = settle(l2ToL1TxTopic, arbSys, arbitrumOutboxes, "Arbitrum"); err != nil {
+			return err
+		}
+		fmt.Printf("Outbox:     %s\n", target.Hex())
+		if data, err = arbitrumExecuteCalldata(endpoint, l1, target, entry, *chunk, *lookback); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown withdrawal subcommand '%s' (expected prove, finalize or execute)", args[0])
+	}
+
+	// Anyone may send these calls, so the simulation needs no sender
+	var output hexutil.Bytes
+	call := map[string]interface{}{"to": target.Hex(), "data": data}
+	if err := callRPC(l1, &output, "eth_call", call, "latest"); err != nil {
+		fmt.Println(colorize(colorYellow, "Simulation failed") + ": " + err.Error())
+	} else {
+		fmt.Println("Simulated:  the call succeeds")
+	}
+	fmt.Printf("\nSend to:  %s\n", target.Hex())
+	fmt.Printf("Calldata: %s\n", data)
+	fmt.Printf("\ncontract-curler send --to %s --data %s\n", target.Hex(), data)
+	return nil
+}
+
+// Function to decode a MessagePassed event into the withdrawal it initiated, checking it
+// hashes to the withdrawal hash of the event
+func decodeMessagePassed(entry LogEntry) (withdrawalTransaction, common.Hash, error) {
+	values, err := decodeReturnValues(entry.Data, "(uint256,uint256,bytes,bytes32)")
+	if err != nil || len(entry.Topics) != 4 {
+		return withdrawalTransaction{}, common.Hash{}, fmt.Errorf("invalid MessagePassed event")
+	}
+	tx := withdrawalTransaction{
+		Nonce:    new(big.Int).SetBytes(common.HexToHash(entry.Topics[1]).Bytes()),
+		Sender:   common.HexToAddress(entry.Topics[2]),
+		Target:   common.HexToAddress(entry.Topics[3]),
+		Value:    values[0].(*big.Int),
+		GasLimit: values[1].(*big.Int),
+		Data:     values[2].([]byte),
+	}
+	withdrawalHash := common.Hash(values[3].([32]byte))
+	encoded, err := encodeArguments(
+		[]string{"uint256", "address", "address", "uint256", "uint256", "bytes"},
+		[]string{tx.Nonce.String(), tx.Sender.Hex(), tx.Target.Hex(), tx.Value.String(), tx.GasLimit.String(), hexutil.Encode(tx.Data)},
+	)
+	if err != nil {
+		return withdrawalTransaction{}, common.Hash{}, fmt.Errorf("failed to encode withdrawal: %v", err)
+	}
+	if crypto.Keccak256Hash(encoded) != withdrawalHash {
+		return withdrawalTransaction{}, common.Hash{}, fmt.Errorf("MessagePassed event does not hash to its withdrawal hash %s", withdrawalHash.Hex())
+	}
+	return tx, withdrawalHash, nil
+}
+
+// Function to build proveWithdrawalTransaction for an OP Stack withdrawal: find the
+// newest output root published on L1 that covers the block of the withdrawal, then prove
+// the withdrawal is stored in the L2ToL1MessagePasser at that block. Portals with fault
+// proofs take the index of a dispute game, earlier ones the index of an L2OutputOracle
+// output.
+func optimismProveCalldata(l2, l1 string, portal common.Address, tx withdrawalTransaction, withdrawalHash common.Hash, withdrawalBlock hexutil.Uint64) (string, error) {
+	var outputIndex, l2Block *big.Int
+	var outputRoot common.Hash
+	if factory, ok := callAddressGetter(l1, portal, "disputeGameFactory()", "latest"); ok {
+		gameType, ok := callUintGetter(l1, portal.Hex(), "respectedGameType()", nil, "latest")
+		if !ok {
+			return "", fmt.Errorf("respectedGameType() failed on %s", portal.Hex())
+		}
+		count, ok := callUintGetter(l1, factory.Hex(), "gameCount()", nil, "latest")
+		if !ok {
+			return "", fmt.Errorf("gameCount() failed on dispute game factory %s", factory.Hex())
+		}
+		for i := count.Int64() - 1; i >= 0 && i >= count.Int64()-maxDisputeGames; i-- {
+			data, err := encodeMethodCall("gameAtIndex(uint256)", []string{fmt.Sprint(i)})
+			if err != nil {
+				return "", err
+			}
+			game, err := callForValues(l1, factory.Hex(), data, "latest", "(uint32,uint64,address)")
+			if err != nil {
+				return "", fmt.Errorf("gameAtIndex(%d) failed: %v", i, err)
+			}
+			if uint64(game[0].(uint32)) != gameType.Uint64() {
+				continue
+			}
+			proxy := game[2].(common.Address)
+			if status, ok := callUintGetter(l1, proxy.Hex(), "status()", nil, "latest"); ok && status.Int64() == challengerWins {
+				continue
+			}
+			block, ok := callUintGetter(l1, proxy.Hex(), "l2BlockNumber()", nil, "latest")
+			if !ok {
+				return "", fmt.Errorf("l2BlockNumber() failed on dispute game %s", proxy.Hex())
+			}
+			root, ok := callBytes32Getter(l1, proxy, "rootClaim()")
+			if !ok {
+				return "", fmt.Errorf("rootClaim() failed on dispute game %s", proxy.Hex())
+			}
+			outputIndex, l2Block, outputRoot = big.NewInt(i), block, root
+			break
+		}
+		if l2Block == nil {
+			return "", fmt.Errorf("no dispute game of type %s among the latest %d on %s", gameType, maxDisputeGames, factory.Hex())
+		}
+		if l2Block.Uint64() < uint64(withdrawalBlock) {
+			return "", fmt.Errorf("the newest dispute game covers L2 block %s, the withdrawal in block %d cannot be proven yet", l2Block, withdrawalBlock)
+		}
+		fmt.Printf("Proven against dispute game %s at L2 block %s\n", outputIndex, l2Block)
+	} else {
+		oracle, ok := callAddressGetter(l1, portal, "l2Oracle()", "latest")
+		if !ok {
+			return "", fmt.Errorf("%s has neither disputeGameFactory() nor l2Oracle(), it is not an OptimismPortal", portal.Hex())
+		}
+		latest, ok := callUintGetter(l1, oracle.Hex(), "latestBlockNumber()", nil, "latest")
+		if !ok {
+			return "", fmt.Errorf("latestBlockNumber() failed on L2OutputOracle %s", oracle.Hex())
+		}
+		if latest.Uint64() < uint64(withdrawalBlock) {
+			return "", fmt.Errorf("the latest output covers L2 block %s, the withdrawal in block %d cannot be proven yet", latest, withdrawalBlock)
+		}
+		if outputIndex, ok = callUintGetter(l1, oracle.Hex(), "getL2OutputIndexAfter(uint256)", []string{fmt.Sprint(uint64(withdrawalBlock))}, "latest"); !ok {
+			return "", fmt.Errorf("getL2OutputIndexAfter failed on L2OutputOracle %s", oracle.Hex())
+		}
+		data, err := encodeMethodCall("getL2Output(uint256)", []string{outputIndex.String()})
+		if err != nil {
+			return "", err
+		}
+		output, err := callForValues(l1, oracle.Hex(), data, "latest", "(bytes32,uint128,uint128)")
+		if err != nil {
+			return "", fmt.Errorf("getL2Output failed: %v", err)
+		}
+		outputRoot, l2Block = common.Hash(output[0].([32]byte)), output[2].(*big.Int)
+		fmt.Printf("Proven against output %s at L2 block %s\n", outputIndex, l2Block)
+	}
+
+	// The withdrawal is stored as sentMessages[withdrawalHash] = true, sentMessages being the
+	// first slot of the message passer
+	slot := crypto.Keccak256Hash(withdrawalHash.Bytes(), make([]byte, 32))
+	blockParam := hexutil.EncodeBig(l2Block)
+	var proof struct {
+		StorageHash  common.Hash `json:"storageHash"`
+		StorageProof []struct {
+			Value hexutil.Big     `json:"value"`
+			Proof []hexutil.Bytes `json:"proof"`
+		} `json:"storageProof"`
+	}
+	if err := callRPC(l2, &proof, "eth_getProof", l2ToL1MessagePasser.Hex(), []string{slot.Hex()}, blockParam); err != nil {
+		return "", fmt.Errorf("failed to get storage proof at L2 block %s: %v", l2Block, err)
+	}
+	if len(proof.StorageProof) != 1 || proof.StorageProof[0].Value.ToInt().Sign() == 0 {
+		return "", fmt.Errorf("withdrawal %s is not stored in the message passer at L2 block %s", withdrawalHash.Hex(), l2Block)
+	}
+	var block struct {
+		Hash      common.Hash `json:"hash"`
+		StateRoot common.Hash `json:"stateRoot"`
+	}
+	if err := callRPC(l2, &block, "eth_getBlockByNumber", blockParam, false); err != nil {
+		return "", fmt.Errorf("failed to get L2 block %s: %v", l2Block, err)
+	}
+	rootProof := outputRootProof{StateRoot: block.StateRoot, MessagePasserStorageRoot: proof.StorageHash, LatestBlockhash: block.Hash}
+	// Output roots of version 0 hash the four words of the proof
+	if crypto.Keccak256Hash(rootProof.Version[:], rootProof.StateRoot[:], rootProof.MessagePasserStorageRoot[:], rootProof.LatestBlockhash[:]) != outputRoot {
+		return "", fmt.Errorf("L2 block %s does not hash to output root %s, the L2 endpoint is not on the chain of the portal", l2Block, outputRoot.Hex())
+	}
+
+	withdrawalType, err := abi.NewType("tuple", "", withdrawalTransactionComponents)
+	if err != nil {
+		return "", err
+	}
+	rootProofType, err := abi.NewType("tuple", "", outputRootProofComponents)
+	if err != nil {
+		return "", err
+	}
+	uint256Type, _ := abi.NewType("uint256", "", nil)
+	bytesArrayType, _ := abi.NewType("bytes[]", "", nil)
+	nodes := make([][]byte, len(proof.StorageProof[0].Proof))
+	for i, node := range proof.StorageProof[0].Proof {
+		nodes[i] = node
+	}
+	arguments := abi.Arguments{{Type: withdrawalType}, {Type: uint256Type}, {Type: rootProofType}, {Type: bytesArrayType}}
+	packed, err := arguments.Pack(tx, outputIndex, rootProof, nodes)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode proof: %v", err)
+	}
+	return "0x" + functionSelector("proveWithdrawalTransaction((uint256,address,address,uint256,uint256,bytes),uint256,(bytes32,bytes32,bytes32,bytes32),bytes[])") + hexutil.Encode(packed)[2:], nil
+}
+
+// Function to build the finalization of a proven OP Stack withdrawal. Portals with fault
+// proofs finalize the proof of the sender unless told whose proof to use, so the proof
+// submitter is named, letting any account finalize.
+func optimismFinalizeCalldata(tx withdrawalTransaction, submitter common.Address) (string, error) {
+	withdrawalType, err := abi.NewType("tuple", "", withdrawalTransactionComponents)
+	if err != nil {
+		return "", err
+	}
+	if submitter == (common.Address{}) {
+		packed, err := abi.Arguments{{Type: withdrawalType}}.Pack(tx)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode withdrawal: %v", err)
+		}
+		return "0x" + functionSelector("finalizeWithdrawalTransaction((uint256,address,address,uint256,uint256,bytes))") + hexutil.Encode(packed)[2:], nil
+	}
+	addressType, _ := abi.NewType("address", "", nil)
+	packed, err := abi.Arguments{{Type: withdrawalType}, {Type: addressType}}.Pack(tx, submitter)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode withdrawal: %v", err)
+	}
+	fmt.Printf("Proven by:  %s\n", submitter.Hex())
+	return "0x" + functionSelector("finalizeWithdrawalTransactionExternalProof((uint256,address,address,uint256,uint256,bytes),address)") + hexutil.Encode(packed)[2:], nil
+}
+
+// Function to build executeTransaction for an Arbitrum L2 to L1 message: find the latest
+// outbox root confirmed on L1, check it includes the message, then have the L2 build the
+// Merkle proof of the message against it
+func arbitrumExecuteCalldata(l2, l1 string, outbox common.Address, entry LogEntry, chunk, lookback uint64) (string, error) {
+	values, err := decodeReturnValues(entry.Data, "(address,uint256,uint256,uint256,uint256,bytes)")
+	if err != nil || len(entry.Topics) != 4 {
+		return "", fmt.Errorf("invalid L2ToL1Tx event")
+	}
+	position := new(big.Int).SetBytes(common.HexToHash(entry.Topics[3]).Bytes())
+	fmt.Printf("Message:    %s\n", position)
+	spent, err := callForValues(l1, outbox.Hex(), "0x"+functionSelector("isSpent(uint256)")+strings.TrimPrefix(common.BigToHash(position).Hex(), "0x"), "latest", "(bool)")
+	if err != nil {
+		return "", fmt.Errorf("isSpent failed: %v", err)
+	}
+	if spent[0].(bool) {
+		return "", fmt.Errorf("message %s is already executed", position)
+	}
+
+	// The Outbox logs every confirmed root with the L2 block it was taken at, and Arbitrum
+	// blocks carry the number of messages sent up to them
+	if chunk == 0 {
+		return "", fmt.Errorf("chunk size must be greater than zero")
+	}
+	head, err := latestBlockNumber(l1)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch latest L1 block: %v", err)
+	}
+	topic := eventTopic("SendRootUpdated(bytes32,bytes32)")
+	floor := uint64(0)
+	if head >= lookback {
+		floor = head - lookback + 1
+	}
+	var latest *LogEntry
+	for end := head; latest == nil; {
+		start := floor
+		if end >= floor+chunk {
+			start = end - chunk + 1
+		}
+		logs, err := getLogs(l1, []string{outbox.Hex()}, []string{topic}, start, end)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch outbox roots for blocks %d-%d: %v", start, end, err)
+		}
+		fmt.Fprintf(os.Stderr, "Scanned L1 blocks %d-%d: %s\n", start, end, pluralize(len(logs), "outbox root"))
+		if len(logs) > 0 {
+			latest = &logs[len(logs)-1]
+		}
+		if start == floor {
+			break
+		}
+		end = start - 1
+	}
+	if latest == nil || len(latest.Topics) != 3 {
+		return "", fmt.Errorf("no outbox root confirmed in the last %d L1 blocks, raise --lookback", lookback)
+	}
+	root, l2BlockHash := common.HexToHash(latest.Topics[1]), latest.Topics[2]
+	var block struct {
+		SendCount *hexutil.Big `json:"sendCount"`
+		MixHash   common.Hash  `json:"mixHash"`
+	}
+	if err := callRPC(l2, &block, "eth_getBlockByHash", l2BlockHash, false); err != nil {
+		return "", fmt.Errorf("failed to get L2 block %s: %v", l2BlockHash, err)
+	}
+	size := new(big.Int).SetUint64(binary.BigEndian.Uint64(block.MixHash[:8]))
+	if block.SendCount != nil {
+		size = block.SendCount.ToInt()
+	}
+	if position.Cmp(size) >= 0 {
+		confirmable := time.Unix(values[3].(*big.Int).Int64(), 0).Add(withdrawalDelay)
+		return "", fmt.Errorf("the latest confirmed outbox root holds %s messages, message %s is not confirmed yet (expected around %s)", size, position, confirmable.UTC().Format(time.RFC3339))
+	}
+
+	data, err := encodeMethodCall("constructOutboxProof(uint64,uint64)", []string{size.String(), position.String()})
+	if err != nil {
+		return "", err
+	}
+	proof, err := callForValues(l2, nodeInterface.Hex(), data, "latest", "(bytes32,bytes32,bytes32[])")
+	if err != nil {
+		return "", fmt.Errorf("constructOutboxProof failed: %v", err)
+	}
+	if common.Hash(proof[1].([32]byte)) != root {
+		return "", fmt.Errorf("proof root %s is not the confirmed outbox root %s, the L2 endpoint is not on the chain of the outbox", common.Hash(proof[1].([32]byte)).Hex(), root.Hex())
+	}
+
+	var arguments abi.Arguments
+	for _, typ := range []string{"bytes32[]", "uint256", "address", "address", "uint256", "uint256", "uint256", "uint256", "bytes"} {
+		abiType, _ := abi.NewType(typ, "", nil)
+		arguments = append(arguments, abi.Argument{Type: abiType})
+	}
+	packed, err := arguments.Pack(proof[2].([][32]byte), position, values[0].(common.Address), common.HexToAddress(entry.Topics[1]),
+		values[1].(*big.Int), values[2].(*big.Int), values[3].(*big.Int), values[4].(*big.Int), values[5].([]byte))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode proof: %v", err)
+	}
+	return "0x" + functionSelector("executeTransaction(bytes32[],uint256,address,address,uint256,uint256,uint256,uint256,bytes)") + hexutil.Encode(packed)[2:], nil
+}
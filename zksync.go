@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// EIP-712 transaction type of zkSync Era, which carries paymaster and pubdata fields
+const zkSyncTxType = 0x71
+
+// Gas per byte of pubdata a zkSync transaction accepts unless told otherwise
+const defaultGasPerPubdata = 50000
+
+// Chain ids of the zkSync Era chains
+var zkSyncChains = map[uint64]bool{324: true, 300: true}
+
+// zkSyncFee is the result of zks_estimateFee
+type zkSyncFee struct {
+	GasLimit             hexutil.Big `json:"gas_limit"`
+	GasPerPubdataLimit   hexutil.Big `json:"gas_per_pubdata_limit"`
+	MaxFeePerGas         hexutil.Big `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas hexutil.Big `json:"max_priority_fee_per_gas"`
+}
+
+// Function to build the eip712Meta field of a zkSync call paid for by a paymaster
+func zkSyncMeta(paymaster, input string) (map[string]interface{}, error) {
+	if !common.IsHexAddress(paymaster) {
+		return nil, fmt.Errorf("invalid paymaster address '%s'", paymaster)
+	}
+	data, err := hexutil.Decode(firstNonEmpty(input, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid paymaster input: %v", err)
+	}
+	// zkSync nodes expect the paymaster input as an array of bytes rather than hex
+	bytes := make([]int, len(data))
+	for i, b := range data {
+		bytes[i] = int(b)
+	}
+	return map[string]interface{}{
+		"gasPerPubdata": hexutil.EncodeUint64(defaultGasPerPubdata),
+		"paymasterParams": map[string]interface{}{
+			"paymaster":      common.HexToAddress(paymaster).Hex(),
+			"paymasterInput": bytes,
+		},
+	}, nil
+}
+
+// Function to estimate the fee of a call on zkSync, where gas covers both execution and pubdata
+func estimateZkSyncFee(rpcURL string, callObject map[string]interface{}) error {
+	var fee zkSyncFee
+	if err := callRPC(rpcURL, &fee, "zks_estimateFee", callObject); err != nil {
+		return fmt.Errorf("zks_estimateFee failed: %v", err)
+	}
+	gasLimit := (*big.Int)(&fee.GasLimit)
+	maxFee := (*big.Int)(&fee.MaxFeePerGas)
+	fmt.Printf("Gas limit: %s\n", gasLimit)
+	fmt.Printf("Gas per pubdata limit: %s\n", (*big.Int)(&fee.GasPerPubdataLimit))
+	fmt.Printf("Max fee per gas: %s gwei\n", formatFixed(maxFee, 9))
+	fmt.Printf("Max priority fee per gas: %s gwei\n", formatFixed((*big.Int)(&fee.MaxPriorityFeePerGas), 9))
+	if meta, ok := callObject["eip712Meta"].(map[string]interface{}); ok {
+		params := meta["paymasterParams"].(map[string]interface{})
+		fmt.Printf("Paid by paymaster: %s\n", params["paymaster"])
+	}
+	fmt.Printf("Max total cost: %s ETH\n", formatFixed(new(big.Int).Mul(gasLimit, maxFee), 18))
+	return nil
+}